@@ -0,0 +1,290 @@
+package autofunc
+
+import (
+	"math"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// DefaultLeak is the leak used by LeakyReLU when its
+// Leak field is 0.
+const DefaultLeak = 0.01
+
+// ReLU is a Func, RFunc, and RBatcher which applies the
+// rectified linear function max(0, x) component-wise.
+//
+// At x=0, where the function is not differentiable, the
+// derivative is taken to be 0.5, the average of the
+// left and right derivatives.
+type ReLU struct{}
+
+func (_ ReLU) Apply(in Result) Result {
+	return applyElemwise(in, reluEval)
+}
+
+func (_ ReLU) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, reluEval)
+}
+
+func (r ReLU) Batch(in Result, n int) Result {
+	return r.Apply(in)
+}
+
+func (r ReLU) BatchR(v RVector, in RResult, n int) RResult {
+	return r.ApplyR(v, in)
+}
+
+func reluEval(x float64) (y, d, d2 float64) {
+	if x > 0 {
+		return x, 1, 0
+	} else if x < 0 {
+		return 0, 0, 0
+	}
+	return 0, 0.5, 0
+}
+
+// LeakyReLU is a Func, RFunc, and RBatcher which applies
+// the leaky rectified linear function component-wise.
+// Positive inputs are left alone, while negative inputs
+// are scaled by Leak.
+//
+// At x=0, the derivative is taken to be the average of
+// the left and right derivatives, (1+Leak)/2.
+type LeakyReLU struct {
+	// Leak is the slope for negative inputs.
+	// If it is 0, DefaultLeak is used.
+	Leak float64
+}
+
+func (l LeakyReLU) Apply(in Result) Result {
+	return applyElemwise(in, l.eval)
+}
+
+func (l LeakyReLU) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, l.eval)
+}
+
+func (l LeakyReLU) Batch(in Result, n int) Result {
+	return l.Apply(in)
+}
+
+func (l LeakyReLU) BatchR(v RVector, in RResult, n int) RResult {
+	return l.ApplyR(v, in)
+}
+
+func (l LeakyReLU) eval(x float64) (y, d, d2 float64) {
+	leak := l.Leak
+	if leak == 0 {
+		leak = DefaultLeak
+	}
+	if x > 0 {
+		return x, 1, 0
+	} else if x < 0 {
+		return leak * x, leak, 0
+	}
+	return 0, (1 + leak) / 2, 0
+}
+
+// ELU is a Func, RFunc, and RBatcher which applies the
+// exponential linear unit component-wise.
+// Positive inputs are left alone, while a negative input
+// x is mapped to Alpha*(exp(x)-1).
+//
+// At x=0, the first and second derivatives are taken to
+// be the averages of their left and right limits.
+type ELU struct {
+	// Alpha scales the negative part of the function.
+	// If it is 0, an alpha of 1 is used.
+	Alpha float64
+}
+
+func (e ELU) Apply(in Result) Result {
+	return applyElemwise(in, e.eval)
+}
+
+func (e ELU) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, e.eval)
+}
+
+func (e ELU) Batch(in Result, n int) Result {
+	return e.Apply(in)
+}
+
+func (e ELU) BatchR(v RVector, in RResult, n int) RResult {
+	return e.ApplyR(v, in)
+}
+
+func (e ELU) eval(x float64) (y, d, d2 float64) {
+	alpha := e.Alpha
+	if alpha == 0 {
+		alpha = 1
+	}
+	if x > 0 {
+		return x, 1, 0
+	} else if x < 0 {
+		exp := math.Exp(x)
+		return alpha * (exp - 1), alpha * exp, alpha * exp
+	}
+	return 0, (1 + alpha) / 2, alpha / 2
+}
+
+// Softplus is a Func, RFunc, and RBatcher which computes
+// ln(1+exp(x)) for each component x of its input.
+type Softplus struct{}
+
+func (_ Softplus) Apply(in Result) Result {
+	return applyElemwise(in, softplusEval)
+}
+
+func (_ Softplus) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, softplusEval)
+}
+
+func (s Softplus) Batch(in Result, n int) Result {
+	return s.Apply(in)
+}
+
+func (s Softplus) BatchR(v RVector, in RResult, n int) RResult {
+	return s.ApplyR(v, in)
+}
+
+func softplusEval(x float64) (y, d, d2 float64) {
+	// Avoid taking the log of a big number.
+	if x > 0 {
+		y = x + math.Log1p(math.Exp(-x))
+	} else {
+		y = math.Log1p(math.Exp(x))
+	}
+	d = 1 / (1 + math.Exp(-x))
+	d2 = d * (1 - d)
+	return
+}
+
+// Tanh is a Func, RFunc, and RBatcher which applies the
+// hyperbolic tangent component-wise.
+type Tanh struct{}
+
+func (_ Tanh) Apply(in Result) Result {
+	return applyElemwise(in, tanhEval)
+}
+
+func (_ Tanh) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, tanhEval)
+}
+
+func (t Tanh) Batch(in Result, n int) Result {
+	return t.Apply(in)
+}
+
+func (t Tanh) BatchR(v RVector, in RResult, n int) RResult {
+	return t.ApplyR(v, in)
+}
+
+func tanhEval(x float64) (y, d, d2 float64) {
+	y = math.Tanh(x)
+	d = 1 - y*y
+	d2 = -2 * y * d
+	return
+}
+
+// An elemwiseEval computes a scalar function along with
+// its first and second derivatives.
+type elemwiseEval func(x float64) (y, d, d2 float64)
+
+func applyElemwise(in Result, f elemwiseEval) Result {
+	scope := scopeOf(in)
+	inVec := in.Output()
+	out := scope.Alloc(len(inVec))
+	deriv := scope.Alloc(len(inVec))
+	for i, x := range inVec {
+		out[i], deriv[i], _ = f(x)
+	}
+	return scope.result(&elemwiseResult{
+		OutputVec: out,
+		Deriv:     deriv,
+		Input:     in,
+	})
+}
+
+func applyElemwiseR(in RResult, f elemwiseEval) RResult {
+	scope := scopeOfR(in)
+	inVec := in.Output()
+	inVecR := in.ROutput()
+	out := scope.Alloc(len(inVec))
+	outR := scope.Alloc(len(inVec))
+	deriv := scope.Alloc(len(inVec))
+	derivR := scope.Alloc(len(inVec))
+	for i, x := range inVec {
+		y, d, d2 := f(x)
+		out[i] = y
+		outR[i] = d * inVecR[i]
+		deriv[i] = d
+		derivR[i] = d2 * inVecR[i]
+	}
+	return scope.rresult(&elemwiseRResult{
+		OutputVec:  out,
+		ROutputVec: outR,
+		Deriv:      deriv,
+		DerivR:     derivR,
+		Input:      in,
+	})
+}
+
+type elemwiseResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Deriv     linalg.Vector
+	Input     Result
+}
+
+func (e *elemwiseResult) Output() linalg.Vector {
+	return e.OutputVec
+}
+
+func (e *elemwiseResult) Constant(g Gradient) bool {
+	return e.Input.Constant(g)
+}
+
+func (e *elemwiseResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
+	if !e.Input.Constant(grad) {
+		for i, d := range e.Deriv {
+			upstream[i] *= d
+		}
+		e.Input.PropagateGradient(upstream, grad)
+	}
+}
+
+type elemwiseRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Deriv      linalg.Vector
+	DerivR     linalg.Vector
+	Input      RResult
+}
+
+func (e *elemwiseRResult) Output() linalg.Vector {
+	return e.OutputVec
+}
+
+func (e *elemwiseRResult) ROutput() linalg.Vector {
+	return e.ROutputVec
+}
+
+func (e *elemwiseRResult) Constant(rg RGradient, g Gradient) bool {
+	return e.Input.Constant(rg, g)
+}
+
+func (e *elemwiseRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rgrad RGradient, grad Gradient) {
+	if !e.Input.Constant(rgrad, grad) {
+		for i, d := range e.Deriv {
+			u := upstream[i]
+			upstream[i] = u * d
+			upstreamR[i] = upstreamR[i]*d + u*e.DerivR[i]
+		}
+		e.Input.PropagateRGradient(upstream, upstreamR, rgrad, grad)
+	}
+}
@@ -0,0 +1,85 @@
+package autofunc
+
+import (
+	"sync"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// An Allocator provides the vectors which built-in
+// Results use for their outputs and for intermediate
+// values during back propagation.
+//
+// Allocators are set per computation using a Scope.
+type Allocator interface {
+	// Alloc returns a zero vector of the given size.
+	Alloc(size int) linalg.Vector
+}
+
+// Alloc allocates a zero vector using the Scope's
+// Allocator, so that a Scope is an Allocator itself.
+// It may be called on a nil Scope.
+func (s *Scope) Alloc(size int) linalg.Vector {
+	if s == nil || s.Allocator == nil {
+		return make(linalg.Vector, size)
+	}
+	return s.Allocator.Alloc(size)
+}
+
+// copyVector copies a vector into a vector from the
+// Scope's Allocator.
+func (s *Scope) copyVector(v linalg.Vector) linalg.Vector {
+	res := s.Alloc(len(v))
+	copy(res, v)
+	return res
+}
+
+// An Arena is an Allocator which recycles vectors.
+//
+// Vectors are handed out until Reset is called, at which
+// point all of them may be handed out again.
+// Thus, Reset invalidates every Result computed since the
+// previous Reset, along with their outputs.
+// It is typically called once per training step, after
+// the gradient has been applied.
+//
+// An Arena is safe for concurrent use.
+// The zero value is an empty Arena.
+type Arena struct {
+	lock sync.Mutex
+	free map[int][]linalg.Vector
+	used map[int][]linalg.Vector
+}
+
+// Alloc returns a zero vector of the given size.
+func (a *Arena) Alloc(size int) linalg.Vector {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.free == nil {
+		a.free = map[int][]linalg.Vector{}
+		a.used = map[int][]linalg.Vector{}
+	}
+	var res linalg.Vector
+	if free := a.free[size]; len(free) > 0 {
+		res = free[len(free)-1]
+		a.free[size] = free[:len(free)-1]
+		for i := range res {
+			res[i] = 0
+		}
+	} else {
+		res = make(linalg.Vector, size)
+	}
+	a.used[size] = append(a.used[size], res)
+	return res
+}
+
+// Reset makes every vector returned by Alloc available
+// for reuse.
+func (a *Arena) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for size, used := range a.used {
+		a.free[size] = append(a.free[size], used...)
+		a.used[size] = used[:0]
+	}
+}
@@ -7,6 +7,8 @@ import (
 )
 
 type resultSum struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 
 	R1 Result
@@ -15,11 +17,12 @@ type resultSum struct {
 
 // Add adds two Results.
 func Add(r1, r2 Result) Result {
-	return &resultSum{
-		OutputVec: r1.Output().Copy().Add(r2.Output()),
+	scope := scopeOf(r1, r2)
+	return scope.result(&resultSum{
+		OutputVec: scope.copyVector(r1.Output()).Add(r2.Output()),
 		R1:        r1,
 		R2:        r2,
-	}
+	})
 }
 
 func (r *resultSum) Output() linalg.Vector {
@@ -36,7 +39,7 @@ func (r *resultSum) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 		if r2Const {
 			r.R1.PropagateGradient(upstream, grad)
 		} else {
-			backup := make(linalg.Vector, len(upstream))
+			backup := r.Scope.Alloc(len(upstream))
 			copy(backup, upstream)
 			r.R1.PropagateGradient(backup, grad)
 		}
@@ -47,6 +50,8 @@ func (r *resultSum) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 }
 
 type rresultSum struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 
@@ -56,12 +61,13 @@ type rresultSum struct {
 
 // AddR adds two RResults.
 func AddR(r1, r2 RResult) RResult {
-	return &rresultSum{
-		OutputVec:  r1.Output().Copy().Add(r2.Output()),
-		ROutputVec: r1.ROutput().Copy().Add(r2.ROutput()),
+	scope := scopeOfR(r1, r2)
+	return scope.rresult(&rresultSum{
+		OutputVec:  scope.copyVector(r1.Output()).Add(r2.Output()),
+		ROutputVec: scope.copyVector(r1.ROutput()).Add(r2.ROutput()),
 		R1:         r1,
 		R2:         r2,
-	}
+	})
 }
 
 func (r *rresultSum) Output() linalg.Vector {
@@ -83,8 +89,8 @@ func (r *rresultSum) PropagateRGradient(upstream, upstreamR linalg.Vector,
 		if r2Const {
 			r.R1.PropagateRGradient(upstream, upstreamR, rgrad, grad)
 		} else {
-			backup := make(linalg.Vector, len(upstream))
-			backupR := make(linalg.Vector, len(upstreamR))
+			backup := r.Scope.Alloc(len(upstream))
+			backupR := r.Scope.Alloc(len(upstreamR))
 			copy(backup, upstream)
 			copy(backupR, upstreamR)
 			r.R1.PropagateRGradient(backup, backupR, rgrad, grad)
@@ -96,6 +102,8 @@ func (r *rresultSum) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type resultDiff struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	R1        Result
 	R2        Result
@@ -103,17 +111,18 @@ type resultDiff struct {
 
 // Sub subtracts b from a (componentwise).
 func Sub(a, b Result) Result {
+	scope := scopeOf(a, b)
 	aOut := a.Output()
 	bOut := b.Output()
-	res := make(linalg.Vector, len(aOut))
+	res := scope.Alloc(len(aOut))
 	for i, x := range aOut {
 		res[i] = x - bOut[i]
 	}
-	return &resultDiff{
+	return scope.result(&resultDiff{
 		OutputVec: res,
 		R1:        a,
 		R2:        b,
-	}
+	})
 }
 
 func (r *resultDiff) Output() linalg.Vector {
@@ -125,15 +134,22 @@ func (r *resultDiff) Constant(g Gradient) bool {
 }
 
 func (r *resultDiff) PropagateGradient(u linalg.Vector, g Gradient) {
+	r2Const := r.R2.Constant(g)
 	if !r.R1.Constant(g) {
-		r.R1.PropagateGradient(u.Copy(), g)
+		if r2Const {
+			r.R1.PropagateGradient(u, g)
+			return
+		}
+		r.R1.PropagateGradient(r.Scope.copyVector(u), g)
 	}
-	if !r.R2.Constant(g) {
+	if !r2Const {
 		r.R2.PropagateGradient(u.Scale(-1), g)
 	}
 }
 
 type rresultDiff struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	R1         RResult
@@ -142,22 +158,23 @@ type rresultDiff struct {
 
 // SubR subtracts b from a (componentwise).
 func SubR(a, b RResult) RResult {
+	scope := scopeOfR(a, b)
 	aOut := a.Output()
 	bOut := b.Output()
 	aOutR := a.ROutput()
 	bOutR := b.ROutput()
-	res := make(linalg.Vector, len(aOut))
-	resR := make(linalg.Vector, len(aOut))
+	res := scope.Alloc(len(aOut))
+	resR := scope.Alloc(len(aOut))
 	for i, x := range aOut {
 		res[i] = x - bOut[i]
 		resR[i] = aOutR[i] - bOutR[i]
 	}
-	return &rresultDiff{
+	return scope.rresult(&rresultDiff{
 		OutputVec:  res,
 		ROutputVec: resR,
 		R1:         a,
 		R2:         b,
-	}
+	})
 }
 
 func (r *rresultDiff) Output() linalg.Vector {
@@ -173,15 +190,22 @@ func (r *rresultDiff) Constant(rg RGradient, g Gradient) bool {
 }
 
 func (r *rresultDiff) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	r2Const := r.R2.Constant(rg, g)
 	if !r.R1.Constant(rg, g) {
-		r.R1.PropagateRGradient(u.Copy(), uR.Copy(), rg, g)
+		if r2Const {
+			r.R1.PropagateRGradient(u, uR, rg, g)
+			return
+		}
+		r.R1.PropagateRGradient(r.Scope.copyVector(u), r.Scope.copyVector(uR), rg, g)
 	}
-	if !r.R2.Constant(rg, g) {
+	if !r2Const {
 		r.R2.PropagateRGradient(u.Scale(-1), uR.Scale(-1), rg, g)
 	}
 }
 
 type addScalerResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Scaler    float64
 	Input     Result
@@ -189,16 +213,17 @@ type addScalerResult struct {
 
 // AddScaler adds a scaler to every component of a vector.
 func AddScaler(r Result, f float64) Result {
+	scope := scopeOf(r)
 	inVec := r.Output()
-	res := make(linalg.Vector, len(inVec))
+	res := scope.Alloc(len(inVec))
 	for i, x := range inVec {
 		res[i] = x + f
 	}
-	return &addScalerResult{
+	return scope.result(&addScalerResult{
 		OutputVec: res,
 		Scaler:    f,
 		Input:     r,
-	}
+	})
 }
 
 func (a *addScalerResult) Output() linalg.Vector {
@@ -214,6 +239,8 @@ func (a *addScalerResult) PropagateGradient(upstream linalg.Vector, grad Gradien
 }
 
 type addScalerRResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Scaler    float64
 	Input     RResult
@@ -221,16 +248,17 @@ type addScalerRResult struct {
 
 // AddScalerR is like AddScaler, but with RResults.
 func AddScalerR(r RResult, f float64) RResult {
+	scope := scopeOfR(r)
 	inVec := r.Output()
-	res := make(linalg.Vector, len(inVec))
+	res := scope.Alloc(len(inVec))
 	for i, x := range inVec {
 		res[i] = x + f
 	}
-	return &addScalerRResult{
+	return scope.rresult(&addScalerRResult{
 		OutputVec: res,
 		Scaler:    f,
 		Input:     r,
-	}
+	})
 }
 
 func (a *addScalerRResult) Output() linalg.Vector {
@@ -251,6 +279,8 @@ func (a *addScalerRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type resultProduct struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 
 	R1 Result
@@ -259,20 +289,21 @@ type resultProduct struct {
 
 // Mul multiplies two Results component-wise.
 func Mul(r1, r2 Result) Result {
+	scope := scopeOf(r1, r2)
 	r1Output := r1.Output()
 	r2Output := r2.Output()
 	if len(r1Output) != len(r2Output) {
 		panic("vector sizes do not match")
 	}
-	product := make(linalg.Vector, len(r1Output))
+	product := scope.Alloc(len(r1Output))
 	for i, x := range r1Output {
 		product[i] = x * r2Output[i]
 	}
-	return &resultProduct{
+	return scope.result(&resultProduct{
 		OutputVec: product,
 		R1:        r1,
 		R2:        r2,
-	}
+	})
 }
 
 func (r *resultProduct) Output() linalg.Vector {
@@ -284,9 +315,15 @@ func (r *resultProduct) Constant(g Gradient) bool {
 }
 
 func (r *resultProduct) PropagateGradient(upstream linalg.Vector, grad Gradient) {
-	downstream := make(linalg.Vector, len(upstream))
+	r2Const := r.R2.Constant(grad)
 
 	if !r.R1.Constant(grad) {
+		// The upstream vector can be reused for R1 when it is
+		// not needed for R2.
+		downstream := upstream
+		if !r2Const {
+			downstream = r.Scope.Alloc(len(upstream))
+		}
 		r2Out := r.R2.Output()
 		for i, x := range upstream {
 			downstream[i] = x * r2Out[i]
@@ -294,16 +331,18 @@ func (r *resultProduct) PropagateGradient(upstream linalg.Vector, grad Gradient)
 		r.R1.PropagateGradient(downstream, grad)
 	}
 
-	if !r.R2.Constant(grad) {
+	if !r2Const {
 		r1Out := r.R1.Output()
-		for i, x := range upstream {
-			downstream[i] = x * r1Out[i]
+		for i, x := range r1Out {
+			upstream[i] *= x
 		}
-		r.R2.PropagateGradient(downstream, grad)
+		r.R2.PropagateGradient(upstream, grad)
 	}
 }
 
 type rresultProduct struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 
@@ -313,6 +352,7 @@ type rresultProduct struct {
 
 // MulR multiplies two RResults component-wise.
 func MulR(r1, r2 RResult) RResult {
+	scope := scopeOfR(r1, r2)
 	r1Output := r1.Output()
 	r1OutputR := r1.ROutput()
 	r2Output := r2.Output()
@@ -320,19 +360,19 @@ func MulR(r1, r2 RResult) RResult {
 	if len(r1Output) != len(r2Output) {
 		panic("vector sizes do not match")
 	}
-	product := make(linalg.Vector, len(r1Output))
-	productR := make(linalg.Vector, len(r1Output))
+	product := scope.Alloc(len(r1Output))
+	productR := scope.Alloc(len(r1Output))
 	for i, x := range r1Output {
 		y := r2Output[i]
 		product[i] = x * y
 		productR[i] = x*r2OutputR[i] + r1OutputR[i]*y
 	}
-	return &rresultProduct{
+	return scope.rresult(&rresultProduct{
 		OutputVec:  product,
 		ROutputVec: productR,
 		R1:         r1,
 		R2:         r2,
-	}
+	})
 }
 
 func (r *rresultProduct) Output() linalg.Vector {
@@ -349,10 +389,14 @@ func (r *rresultProduct) Constant(rg RGradient, g Gradient) bool {
 
 func (r *rresultProduct) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	rgrad RGradient, grad Gradient) {
-	downstream := make(linalg.Vector, len(upstream))
-	downstreamR := make(linalg.Vector, len(upstream))
+	r2Const := r.R2.Constant(rgrad, grad)
 
 	if !r.R1.Constant(rgrad, grad) {
+		downstream, downstreamR := upstream, upstreamR
+		if !r2Const {
+			downstream = r.Scope.Alloc(len(upstream))
+			downstreamR = r.Scope.Alloc(len(upstream))
+		}
 		r2Out := r.R2.Output()
 		r2OutR := r.R2.ROutput()
 		for i, x := range upstream {
@@ -363,19 +407,21 @@ func (r *rresultProduct) PropagateRGradient(upstream, upstreamR linalg.Vector,
 		r.R1.PropagateRGradient(downstream, downstreamR, rgrad, grad)
 	}
 
-	if !r.R2.Constant(rgrad, grad) {
+	if !r2Const {
 		r1Out := r.R1.Output()
 		r1OutR := r.R1.ROutput()
 		for i, x := range upstream {
 			otherOut := r1Out[i]
-			downstream[i] = x * otherOut
-			downstreamR[i] = x*r1OutR[i] + upstreamR[i]*otherOut
+			upstream[i] = x * otherOut
+			upstreamR[i] = x*r1OutR[i] + upstreamR[i]*otherOut
 		}
-		r.R2.PropagateRGradient(downstream, downstreamR, rgrad, grad)
+		r.R2.PropagateRGradient(upstream, upstreamR, rgrad, grad)
 	}
 }
 
 type resultQuotient struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Num       Result
 	Denom     Result
@@ -383,17 +429,18 @@ type resultQuotient struct {
 
 // Div computes a/b (elementwise).
 func Div(a, b Result) Result {
+	scope := scopeOf(a, b)
 	aOut := a.Output()
 	bOut := b.Output()
-	out := make(linalg.Vector, len(aOut))
+	out := scope.Alloc(len(aOut))
 	for i, x := range aOut {
 		out[i] = x / bOut[i]
 	}
-	return &resultQuotient{
+	return scope.result(&resultQuotient{
 		OutputVec: out,
 		Num:       a,
 		Denom:     b,
-	}
+	})
 }
 
 func (r *resultQuotient) Output() linalg.Vector {
@@ -407,7 +454,7 @@ func (r *resultQuotient) Constant(g Gradient) bool {
 func (r *resultQuotient) PropagateGradient(u linalg.Vector, g Gradient) {
 	denomOut := r.Denom.Output()
 	if !r.Num.Constant(g) {
-		uScaled := u.Copy()
+		uScaled := r.Scope.copyVector(u)
 		for i, x := range denomOut {
 			uScaled[i] /= x
 		}
@@ -427,6 +474,8 @@ func DivR(a, b RResult) RResult {
 }
 
 type scaledResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Scaler    float64
 	Input     Result
@@ -434,11 +483,12 @@ type scaledResult struct {
 
 // Scale scales a Result component-wise.
 func Scale(r Result, f float64) Result {
-	return &scaledResult{
-		OutputVec: r.Output().Copy().Scale(f),
+	scope := scopeOf(r)
+	return scope.result(&scaledResult{
+		OutputVec: scope.copyVector(r.Output()).Scale(f),
 		Scaler:    f,
 		Input:     r,
-	}
+	})
 }
 
 func (s *scaledResult) Output() linalg.Vector {
@@ -456,6 +506,8 @@ func (s *scaledResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type scaledRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Scaler     float64
@@ -464,12 +516,13 @@ type scaledRResult struct {
 
 // ScaleR scales an RResult component-wise.
 func ScaleR(r RResult, f float64) RResult {
-	return &scaledRResult{
-		OutputVec:  r.Output().Copy().Scale(f),
-		ROutputVec: r.ROutput().Copy().Scale(f),
+	scope := scopeOfR(r)
+	return scope.rresult(&scaledRResult{
+		OutputVec:  scope.copyVector(r.Output()).Scale(f),
+		ROutputVec: scope.copyVector(r.ROutput()).Scale(f),
 		Scaler:     f,
 		Input:      r,
-	}
+	})
 }
 
 func (s *scaledRResult) Output() linalg.Vector {
@@ -493,6 +546,8 @@ func (s *scaledRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type scaleFirstResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Scaler    Result
 	Input     Result
@@ -501,12 +556,13 @@ type scaleFirstResult struct {
 // ScaleFirst scales all the elements of a
 // vector by the first element of a vector.
 func ScaleFirst(in Result, scaler Result) Result {
+	scope := scopeOf(in, scaler)
 	f := scaler.Output()[0]
-	return &scaleFirstResult{
-		OutputVec: in.Output().Copy().Scale(f),
+	return scope.result(&scaleFirstResult{
+		OutputVec: scope.copyVector(in.Output()).Scale(f),
 		Scaler:    scaler,
 		Input:     in,
-	}
+	})
 }
 
 func (s *scaleFirstResult) Output() linalg.Vector {
@@ -519,7 +575,7 @@ func (s *scaleFirstResult) Constant(g Gradient) bool {
 
 func (s *scaleFirstResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 	if !s.Scaler.Constant(grad) {
-		downstream := make(linalg.Vector, len(s.Scaler.Output()))
+		downstream := s.Scope.Alloc(len(s.Scaler.Output()))
 		downstream[0] = upstream.DotFast(s.Input.Output())
 		s.Scaler.PropagateGradient(downstream, grad)
 	}
@@ -533,6 +589,8 @@ func (s *scaleFirstResult) PropagateGradient(upstream linalg.Vector, grad Gradie
 }
 
 type scaleFirstRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Scaler     RResult
@@ -541,14 +599,17 @@ type scaleFirstRResult struct {
 
 // ScaleFirstR is like ScaleFirst, but for RResults.
 func ScaleFirstR(in RResult, scaler RResult) RResult {
+	scope := scopeOfR(in, scaler)
 	f := scaler.Output()[0]
 	fR := scaler.ROutput()[0]
-	return &scaleFirstRResult{
-		OutputVec:  in.Output().Copy().Scale(f),
-		ROutputVec: in.Output().Copy().Scale(fR).Add(in.ROutput().Copy().Scale(f)),
+	rOut := scope.copyVector(in.Output()).Scale(fR)
+	rOut.Add(scope.copyVector(in.ROutput()).Scale(f))
+	return scope.rresult(&scaleFirstRResult{
+		OutputVec:  scope.copyVector(in.Output()).Scale(f),
+		ROutputVec: rOut,
 		Scaler:     scaler,
 		Input:      in,
-	}
+	})
 }
 
 func (s *scaleFirstRResult) Output() linalg.Vector {
@@ -566,8 +627,8 @@ func (s *scaleFirstRResult) Constant(rg RGradient, g Gradient) bool {
 func (s *scaleFirstRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	rgrad RGradient, grad Gradient) {
 	if !s.Scaler.Constant(rgrad, grad) {
-		downstream := make(linalg.Vector, len(s.Scaler.Output()))
-		downstreamR := make(linalg.Vector, len(s.Scaler.ROutput()))
+		downstream := s.Scope.Alloc(len(s.Scaler.Output()))
+		downstreamR := s.Scope.Alloc(len(s.Scaler.ROutput()))
 		downstream[0] = upstream.DotFast(s.Input.Output())
 		downstreamR[0] = upstreamR.DotFast(s.Input.Output()) +
 			upstream.DotFast(s.Input.ROutput())
@@ -579,13 +640,15 @@ func (s *scaleFirstRResult) PropagateRGradient(upstream, upstreamR linalg.Vector
 	if !s.Input.Constant(rgrad, grad) {
 		scaler := s.Scaler.Output()[0]
 		scalerR := s.Scaler.ROutput()[0]
-		upstreamR.Scale(scaler).Add(upstream.Copy().Scale(scalerR))
+		upstreamR.Scale(scaler).Add(s.Scope.copyVector(upstream).Scale(scalerR))
 		upstream.Scale(scaler)
 		s.Input.PropagateRGradient(upstream, upstreamR, rgrad, grad)
 	}
 }
 
 type addFirstResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 	Scaler    Result
@@ -596,17 +659,18 @@ type addFirstResult struct {
 // This is basically like AddScaler, but instead
 // of a constant, the first element of v2 is used.
 func AddFirst(v1 Result, v2 Result) Result {
+	scope := scopeOf(v1, v2)
 	inVec := v1.Output()
-	outVec := make(linalg.Vector, len(inVec))
+	outVec := scope.Alloc(len(inVec))
 	scaler := v2.Output()[0]
 	for i, x := range inVec {
 		outVec[i] = scaler + x
 	}
-	return &addFirstResult{
+	return scope.result(&addFirstResult{
 		OutputVec: outVec,
 		Input:     v1,
 		Scaler:    v2,
-	}
+	})
 }
 
 func (a *addFirstResult) Output() linalg.Vector {
@@ -619,7 +683,7 @@ func (a *addFirstResult) Constant(g Gradient) bool {
 
 func (a *addFirstResult) PropagateGradient(upstream linalg.Vector, g Gradient) {
 	if !a.Scaler.Constant(g) {
-		scalerUpstream := make(linalg.Vector, len(a.Scaler.Output()))
+		scalerUpstream := a.Scope.Alloc(len(a.Scaler.Output()))
 		for _, x := range upstream {
 			scalerUpstream[0] += x
 		}
@@ -631,6 +695,8 @@ func (a *addFirstResult) PropagateGradient(upstream linalg.Vector, g Gradient) {
 }
 
 type addFirstRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -639,10 +705,11 @@ type addFirstRResult struct {
 
 // AddFirstR is like AddFirst, but for RResults.
 func AddFirstR(v1 RResult, v2 RResult) RResult {
+	scope := scopeOfR(v1, v2)
 	inVec := v1.Output()
 	inVecR := v1.ROutput()
-	outVec := make(linalg.Vector, len(inVec))
-	outVecR := make(linalg.Vector, len(inVec))
+	outVec := scope.Alloc(len(inVec))
+	outVecR := scope.Alloc(len(inVec))
 	scaler := v2.Output()[0]
 	scalerR := v2.ROutput()[0]
 	for i, x := range inVec {
@@ -651,12 +718,12 @@ func AddFirstR(v1 RResult, v2 RResult) RResult {
 	for i, x := range inVecR {
 		outVecR[i] = scalerR + x
 	}
-	return &addFirstRResult{
+	return scope.rresult(&addFirstRResult{
 		OutputVec:  outVec,
 		ROutputVec: outVecR,
 		Input:      v1,
 		Scaler:     v2,
-	}
+	})
 }
 
 func (a *addFirstRResult) Output() linalg.Vector {
@@ -674,8 +741,8 @@ func (a *addFirstRResult) Constant(rg RGradient, g Gradient) bool {
 func (a *addFirstRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	rg RGradient, g Gradient) {
 	if !a.Scaler.Constant(rg, g) {
-		scalerUpstream := make(linalg.Vector, len(a.Scaler.Output()))
-		scalerUpstreamR := make(linalg.Vector, len(a.Scaler.Output()))
+		scalerUpstream := a.Scope.Alloc(len(a.Scaler.Output()))
+		scalerUpstreamR := a.Scope.Alloc(len(a.Scaler.Output()))
 		for i, x := range upstream {
 			scalerUpstream[0] += x
 			scalerUpstreamR[0] += upstreamR[i]
@@ -688,21 +755,24 @@ func (a *addFirstRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type resultSquare struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
 
 // Square squares every component of a Result.
 func Square(r Result) Result {
+	scope := scopeOf(r)
 	rVec := r.Output()
-	out := make(linalg.Vector, len(rVec))
+	out := scope.Alloc(len(rVec))
 	for i, x := range rVec {
 		out[i] = x * x
 	}
-	return &resultSquare{
+	return scope.result(&resultSquare{
 		OutputVec: out,
 		Input:     r,
-	}
+	})
 }
 
 func (r *resultSquare) Output() linalg.Vector {
@@ -723,6 +793,8 @@ func (r *resultSquare) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type rresultSquare struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -730,19 +802,20 @@ type rresultSquare struct {
 
 // SquareR squares every component of an RResult.
 func SquareR(r RResult) RResult {
+	scope := scopeOfR(r)
 	vec := r.Output()
 	vecR := r.ROutput()
-	out := make(linalg.Vector, len(vec))
-	outR := make(linalg.Vector, len(vec))
+	out := scope.Alloc(len(vec))
+	outR := scope.Alloc(len(vec))
 	for i, x := range vec {
 		out[i] = x * x
 		outR[i] = 2 * x * vecR[i]
 	}
-	return &rresultSquare{
+	return scope.rresult(&rresultSquare{
 		OutputVec:  out,
 		ROutputVec: outR,
 		Input:      r,
-	}
+	})
 }
 
 func (r *rresultSquare) Output() linalg.Vector {
@@ -770,6 +843,8 @@ func (r *rresultSquare) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type resultInverse struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -777,15 +852,16 @@ type resultInverse struct {
 // Inverse computes component-wise reciprocals.
 // NaNs or Infs will result from 0-divisions.
 func Inverse(r Result) Result {
+	scope := scopeOf(r)
 	inVec := r.Output()
-	outVec := make(linalg.Vector, len(inVec))
+	outVec := scope.Alloc(len(inVec))
 	for i, x := range inVec {
 		outVec[i] = 1 / x
 	}
-	return &resultInverse{
+	return scope.result(&resultInverse{
 		OutputVec: outVec,
 		Input:     r,
-	}
+	})
 }
 
 func (r *resultInverse) Output() linalg.Vector {
@@ -806,6 +882,8 @@ func (r *resultInverse) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type rresultInverse struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	SquaredOut linalg.Vector
@@ -814,11 +892,12 @@ type rresultInverse struct {
 
 // InverseR is like Inverse, but for RResults.
 func InverseR(r RResult) RResult {
+	scope := scopeOfR(r)
 	inVec := r.Output()
 	inVecR := r.ROutput()
-	outVec := make(linalg.Vector, len(inVec))
-	outVecR := make(linalg.Vector, len(inVec))
-	squaredOut := make(linalg.Vector, len(inVec))
+	outVec := scope.Alloc(len(inVec))
+	outVecR := scope.Alloc(len(inVec))
+	squaredOut := scope.Alloc(len(inVec))
 	for i, x := range inVec {
 		recip := 1 / x
 		squared := recip * recip
@@ -826,12 +905,12 @@ func InverseR(r RResult) RResult {
 		squaredOut[i] = squared
 		outVecR[i] = -squared * inVecR[i]
 	}
-	return &rresultInverse{
+	return scope.rresult(&rresultInverse{
 		OutputVec:  outVec,
 		ROutputVec: outVecR,
 		SquaredOut: squaredOut,
 		Input:      r,
-	}
+	})
 }
 
 func (r *rresultInverse) Output() linalg.Vector {
@@ -863,6 +942,8 @@ func (r *rresultInverse) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type resultPow struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Power     float64
 	Input     Result
@@ -870,16 +951,17 @@ type resultPow struct {
 
 // Pow raises each component of r to a given power.
 func Pow(r Result, pow float64) Result {
+	scope := scopeOf(r)
 	input := r.Output()
-	output := make(linalg.Vector, len(input))
+	output := scope.Alloc(len(input))
 	for i, x := range input {
 		output[i] = math.Pow(x, pow)
 	}
-	return &resultPow{
+	return scope.result(&resultPow{
 		OutputVec: output,
 		Power:     pow,
 		Input:     r,
-	}
+	})
 }
 
 func (r *resultPow) Output() linalg.Vector {
@@ -902,6 +984,8 @@ func (r *resultPow) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 }
 
 type rresultPow struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Power      float64
@@ -910,10 +994,11 @@ type rresultPow struct {
 
 // PowR is like Pow, but for RResults.
 func PowR(r RResult, pow float64) RResult {
+	scope := scopeOfR(r)
 	input := r.Output()
 	inputR := r.ROutput()
-	output := make(linalg.Vector, len(input))
-	outputR := make(linalg.Vector, len(input))
+	output := scope.Alloc(len(input))
+	outputR := scope.Alloc(len(input))
 	for i, x := range input {
 		output[i] = math.Pow(x, pow)
 	}
@@ -923,12 +1008,12 @@ func PowR(r RResult, pow float64) RResult {
 			outputR[i] = pow * math.Pow(x, pow-1) * xR
 		}
 	}
-	return &rresultPow{
+	return scope.rresult(&rresultPow{
 		OutputVec:  output,
 		ROutputVec: outputR,
 		Power:      pow,
 		Input:      r,
-	}
+	})
 }
 
 func (r *rresultPow) Output() linalg.Vector {
@@ -962,6 +1047,8 @@ func (r *rresultPow) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type sumAllResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -970,14 +1057,15 @@ type sumAllResult struct {
 // returns a vector with that sum as its one
 // and only element.
 func SumAll(r Result) Result {
+	scope := scopeOf(r)
 	var sum float64
 	for _, x := range r.Output() {
 		sum += x
 	}
-	return &sumAllResult{
-		OutputVec: linalg.Vector{sum},
+	return scope.result(&sumAllResult{
+		OutputVec: scope.copyVector(linalg.Vector{sum}),
 		Input:     r,
-	}
+	})
 }
 
 func (s *sumAllResult) Output() linalg.Vector {
@@ -991,7 +1079,7 @@ func (s *sumAllResult) Constant(g Gradient) bool {
 func (s *sumAllResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 	if !s.Input.Constant(grad) {
 		inLen := len(s.Input.Output())
-		downstream := make(linalg.Vector, inLen)
+		downstream := s.Scope.Alloc(inLen)
 		for i := range downstream {
 			downstream[i] = upstream[0]
 		}
@@ -1000,6 +1088,8 @@ func (s *sumAllResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type sumAllRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -1007,17 +1097,18 @@ type sumAllRResult struct {
 
 // SumAllR is like SumAll, but for an RResult.
 func SumAllR(r RResult) RResult {
+	scope := scopeOfR(r)
 	var sum, rsum float64
 	routput := r.ROutput()
 	for i, x := range r.Output() {
 		sum += x
 		rsum += routput[i]
 	}
-	return &sumAllRResult{
-		OutputVec:  linalg.Vector{sum},
-		ROutputVec: linalg.Vector{rsum},
+	return scope.rresult(&sumAllRResult{
+		OutputVec:  scope.copyVector(linalg.Vector{sum}),
+		ROutputVec: scope.copyVector(linalg.Vector{rsum}),
 		Input:      r,
-	}
+	})
 }
 
 func (s *sumAllRResult) Output() linalg.Vector {
@@ -1036,8 +1127,8 @@ func (s *sumAllRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	rgrad RGradient, grad Gradient) {
 	if !s.Input.Constant(rgrad, grad) {
 		inLen := len(s.Input.Output())
-		downstream := make(linalg.Vector, inLen)
-		downstreamR := make(linalg.Vector, inLen)
+		downstream := s.Scope.Alloc(inLen)
+		downstreamR := s.Scope.Alloc(inLen)
 		for i := range downstream {
 			downstream[i] = upstream[0]
 			downstreamR[i] = upstreamR[0]
@@ -1075,7 +1166,7 @@ func AddLogDomainR(v1, v2 RResult) RResult {
 // the components of a vector, add the results, then take the
 // natural log.
 func SumAllLogDomain(v Result) Result {
-	maxVal := v.Output().MaxAbs()
+	maxVal := maxValue(v.Output())
 	exp := Exp{}.Apply(AddScaler(v, -maxVal))
 	sum := SumAll(exp)
 	return AddScaler(Log{}.Apply(sum), maxVal)
@@ -1083,8 +1174,21 @@ func SumAllLogDomain(v Result) Result {
 
 // SumAllLogDomainR is like SumAllLogDomain but for RResults.
 func SumAllLogDomainR(v RResult) RResult {
-	maxVal := v.Output().MaxAbs()
+	maxVal := maxValue(v.Output())
 	exp := Exp{}.ApplyR(nil, AddScalerR(v, -maxVal))
 	sum := SumAllR(exp)
 	return AddScalerR(Log{}.ApplyR(nil, sum), maxVal)
 }
+
+// maxValue returns the largest component of v, or 0 if
+// v is empty.
+func maxValue(v linalg.Vector) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	max := v[0]
+	for _, x := range v[1:] {
+		max = math.Max(max, x)
+	}
+	return max
+}
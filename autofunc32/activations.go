@@ -0,0 +1,181 @@
+package autofunc32
+
+import "math"
+
+// Sigmoid is a Func and RFunc which applies the logistic
+// sigmoid function component-wise.
+type Sigmoid struct{}
+
+func (_ Sigmoid) Apply(in Result) Result {
+	return applyElemwise(in, sigmoidEval)
+}
+
+func (_ Sigmoid) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, sigmoidEval)
+}
+
+func sigmoidEval(x float64) (y, d, d2 float64) {
+	y = 1 / (1 + math.Exp(-x))
+	d = y * (1 - y)
+	d2 = d * (1 - 2*y)
+	return
+}
+
+// Tanh is a Func and RFunc which applies the hyperbolic
+// tangent component-wise.
+type Tanh struct{}
+
+func (_ Tanh) Apply(in Result) Result {
+	return applyElemwise(in, tanhEval)
+}
+
+func (_ Tanh) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, tanhEval)
+}
+
+func tanhEval(x float64) (y, d, d2 float64) {
+	y = math.Tanh(x)
+	d = 1 - y*y
+	d2 = -2 * y * d
+	return
+}
+
+// ReLU is a Func and RFunc which applies the rectified
+// linear function max(0, x) component-wise.
+//
+// Like autofunc.ReLU, the derivative at x=0 is taken to
+// be 0.5.
+type ReLU struct{}
+
+func (_ ReLU) Apply(in Result) Result {
+	return applyElemwise(in, reluEval)
+}
+
+func (_ ReLU) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, reluEval)
+}
+
+func reluEval(x float64) (y, d, d2 float64) {
+	if x > 0 {
+		return x, 1, 0
+	} else if x < 0 {
+		return 0, 0, 0
+	}
+	return 0, 0.5, 0
+}
+
+// Exp is a Func and RFunc which exponentiates every
+// component of its input.
+type Exp struct{}
+
+func (_ Exp) Apply(in Result) Result {
+	return applyElemwise(in, expEval)
+}
+
+func (_ Exp) ApplyR(v RVector, in RResult) RResult {
+	return applyElemwiseR(in, expEval)
+}
+
+func expEval(x float64) (y, d, d2 float64) {
+	y = math.Exp(x)
+	return y, y, y
+}
+
+// An elemwiseEval computes a scalar function along with
+// its first and second derivatives.
+// It is evaluated in double precision, and the results
+// are rounded to single precision.
+type elemwiseEval func(x float64) (y, d, d2 float64)
+
+func applyElemwise(in Result, f elemwiseEval) Result {
+	inVec := in.Output()
+	out := make(Vector, len(inVec))
+	deriv := make(Vector, len(inVec))
+	for i, x := range inVec {
+		y, d, _ := f(float64(x))
+		out[i], deriv[i] = float32(y), float32(d)
+	}
+	return &elemwiseResult{
+		OutputVec: out,
+		Deriv:     deriv,
+		Input:     in,
+	}
+}
+
+func applyElemwiseR(in RResult, f elemwiseEval) RResult {
+	inVec := in.Output()
+	inVecR := in.ROutput()
+	out := make(Vector, len(inVec))
+	outR := make(Vector, len(inVec))
+	deriv := make(Vector, len(inVec))
+	derivR := make(Vector, len(inVec))
+	for i, x := range inVec {
+		y, d, d2 := f(float64(x))
+		out[i] = float32(y)
+		outR[i] = float32(d) * inVecR[i]
+		deriv[i] = float32(d)
+		derivR[i] = float32(d2) * inVecR[i]
+	}
+	return &elemwiseRResult{
+		OutputVec:  out,
+		ROutputVec: outR,
+		Deriv:      deriv,
+		DerivR:     derivR,
+		Input:      in,
+	}
+}
+
+type elemwiseResult struct {
+	OutputVec Vector
+	Deriv     Vector
+	Input     Result
+}
+
+func (e *elemwiseResult) Output() Vector {
+	return e.OutputVec
+}
+
+func (e *elemwiseResult) Constant(g Gradient) bool {
+	return e.Input.Constant(g)
+}
+
+func (e *elemwiseResult) PropagateGradient(upstream Vector, grad Gradient) {
+	if !e.Input.Constant(grad) {
+		for i, d := range e.Deriv {
+			upstream[i] *= d
+		}
+		e.Input.PropagateGradient(upstream, grad)
+	}
+}
+
+type elemwiseRResult struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	Deriv      Vector
+	DerivR     Vector
+	Input      RResult
+}
+
+func (e *elemwiseRResult) Output() Vector {
+	return e.OutputVec
+}
+
+func (e *elemwiseRResult) ROutput() Vector {
+	return e.ROutputVec
+}
+
+func (e *elemwiseRResult) Constant(rg RGradient, g Gradient) bool {
+	return e.Input.Constant(rg, g)
+}
+
+func (e *elemwiseRResult) PropagateRGradient(upstream, upstreamR Vector,
+	rgrad RGradient, grad Gradient) {
+	if !e.Input.Constant(rgrad, grad) {
+		for i, d := range e.Deriv {
+			u := upstream[i]
+			upstream[i] = u * d
+			upstreamR[i] = upstreamR[i]*d + u*e.DerivR[i]
+		}
+		e.Input.PropagateRGradient(upstream, upstreamR, rgrad, grad)
+	}
+}
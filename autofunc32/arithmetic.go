@@ -0,0 +1,353 @@
+package autofunc32
+
+type resultSum struct {
+	OutputVec Vector
+	R1        Result
+	R2        Result
+}
+
+// Add adds two Results.
+func Add(r1, r2 Result) Result {
+	return &resultSum{
+		OutputVec: r1.Output().Copy().Add(r2.Output()),
+		R1:        r1,
+		R2:        r2,
+	}
+}
+
+func (r *resultSum) Output() Vector {
+	return r.OutputVec
+}
+
+func (r *resultSum) Constant(g Gradient) bool {
+	return r.R1.Constant(g) && r.R2.Constant(g)
+}
+
+func (r *resultSum) PropagateGradient(u Vector, g Gradient) {
+	if !r.R1.Constant(g) {
+		r.R1.PropagateGradient(u.Copy(), g)
+	}
+	if !r.R2.Constant(g) {
+		r.R2.PropagateGradient(u, g)
+	}
+}
+
+type rresultSum struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	R1         RResult
+	R2         RResult
+}
+
+// AddR adds two RResults.
+func AddR(r1, r2 RResult) RResult {
+	return &rresultSum{
+		OutputVec:  r1.Output().Copy().Add(r2.Output()),
+		ROutputVec: r1.ROutput().Copy().Add(r2.ROutput()),
+		R1:         r1,
+		R2:         r2,
+	}
+}
+
+func (r *rresultSum) Output() Vector {
+	return r.OutputVec
+}
+
+func (r *rresultSum) ROutput() Vector {
+	return r.ROutputVec
+}
+
+func (r *rresultSum) Constant(rg RGradient, g Gradient) bool {
+	return r.R1.Constant(rg, g) && r.R2.Constant(rg, g)
+}
+
+func (r *rresultSum) PropagateRGradient(u, uR Vector, rg RGradient, g Gradient) {
+	if !r.R1.Constant(rg, g) {
+		r.R1.PropagateRGradient(u.Copy(), uR.Copy(), rg, g)
+	}
+	if !r.R2.Constant(rg, g) {
+		r.R2.PropagateRGradient(u, uR, rg, g)
+	}
+}
+
+type resultDiff struct {
+	OutputVec Vector
+	R1        Result
+	R2        Result
+}
+
+// Sub subtracts b from a (componentwise).
+func Sub(a, b Result) Result {
+	return &resultDiff{
+		OutputVec: b.Output().Copy().Scale(-1).Add(a.Output()),
+		R1:        a,
+		R2:        b,
+	}
+}
+
+func (r *resultDiff) Output() Vector {
+	return r.OutputVec
+}
+
+func (r *resultDiff) Constant(g Gradient) bool {
+	return r.R1.Constant(g) && r.R2.Constant(g)
+}
+
+func (r *resultDiff) PropagateGradient(u Vector, g Gradient) {
+	if !r.R1.Constant(g) {
+		r.R1.PropagateGradient(u.Copy(), g)
+	}
+	if !r.R2.Constant(g) {
+		r.R2.PropagateGradient(u.Scale(-1), g)
+	}
+}
+
+type rresultDiff struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	R1         RResult
+	R2         RResult
+}
+
+// SubR is like Sub, but for RResults.
+func SubR(a, b RResult) RResult {
+	return &rresultDiff{
+		OutputVec:  b.Output().Copy().Scale(-1).Add(a.Output()),
+		ROutputVec: b.ROutput().Copy().Scale(-1).Add(a.ROutput()),
+		R1:         a,
+		R2:         b,
+	}
+}
+
+func (r *rresultDiff) Output() Vector {
+	return r.OutputVec
+}
+
+func (r *rresultDiff) ROutput() Vector {
+	return r.ROutputVec
+}
+
+func (r *rresultDiff) Constant(rg RGradient, g Gradient) bool {
+	return r.R1.Constant(rg, g) && r.R2.Constant(rg, g)
+}
+
+func (r *rresultDiff) PropagateRGradient(u, uR Vector, rg RGradient, g Gradient) {
+	if !r.R1.Constant(rg, g) {
+		r.R1.PropagateRGradient(u.Copy(), uR.Copy(), rg, g)
+	}
+	if !r.R2.Constant(rg, g) {
+		r.R2.PropagateRGradient(u.Scale(-1), uR.Scale(-1), rg, g)
+	}
+}
+
+type resultProduct struct {
+	OutputVec Vector
+	R1        Result
+	R2        Result
+}
+
+// Mul multiplies two Results component-wise.
+func Mul(r1, r2 Result) Result {
+	return &resultProduct{
+		OutputVec: mulVecs(r1.Output(), r2.Output()),
+		R1:        r1,
+		R2:        r2,
+	}
+}
+
+func (r *resultProduct) Output() Vector {
+	return r.OutputVec
+}
+
+func (r *resultProduct) Constant(g Gradient) bool {
+	return r.R1.Constant(g) && r.R2.Constant(g)
+}
+
+func (r *resultProduct) PropagateGradient(u Vector, g Gradient) {
+	if !r.R1.Constant(g) {
+		r.R1.PropagateGradient(mulVecs(u, r.R2.Output()), g)
+	}
+	if !r.R2.Constant(g) {
+		r.R2.PropagateGradient(mulVecs(u, r.R1.Output()), g)
+	}
+}
+
+type rresultProduct struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	R1         RResult
+	R2         RResult
+}
+
+// MulR is like Mul, but for RResults.
+func MulR(r1, r2 RResult) RResult {
+	rOut := mulVecs(r1.ROutput(), r2.Output())
+	return &rresultProduct{
+		OutputVec:  mulVecs(r1.Output(), r2.Output()),
+		ROutputVec: rOut.Add(mulVecs(r1.Output(), r2.ROutput())),
+		R1:         r1,
+		R2:         r2,
+	}
+}
+
+func (r *rresultProduct) Output() Vector {
+	return r.OutputVec
+}
+
+func (r *rresultProduct) ROutput() Vector {
+	return r.ROutputVec
+}
+
+func (r *rresultProduct) Constant(rg RGradient, g Gradient) bool {
+	return r.R1.Constant(rg, g) && r.R2.Constant(rg, g)
+}
+
+func (r *rresultProduct) PropagateRGradient(u, uR Vector, rg RGradient, g Gradient) {
+	for _, pair := range [][2]RResult{{r.R1, r.R2}, {r.R2, r.R1}} {
+		if pair[0].Constant(rg, g) {
+			continue
+		}
+		other, otherR := pair[1].Output(), pair[1].ROutput()
+		downR := mulVecs(uR, other).Add(mulVecs(u, otherR))
+		pair[0].PropagateRGradient(mulVecs(u, other), downR, rg, g)
+	}
+}
+
+type scaledResult struct {
+	OutputVec Vector
+	Scaler    float32
+	Input     Result
+}
+
+// Scale scales a Result by a constant.
+func Scale(r Result, f float32) Result {
+	return &scaledResult{
+		OutputVec: r.Output().Copy().Scale(f),
+		Scaler:    f,
+		Input:     r,
+	}
+}
+
+func (s *scaledResult) Output() Vector {
+	return s.OutputVec
+}
+
+func (s *scaledResult) Constant(g Gradient) bool {
+	return s.Input.Constant(g)
+}
+
+func (s *scaledResult) PropagateGradient(u Vector, g Gradient) {
+	if !s.Input.Constant(g) {
+		s.Input.PropagateGradient(u.Scale(s.Scaler), g)
+	}
+}
+
+type scaledRResult struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	Scaler     float32
+	Input      RResult
+}
+
+// ScaleR is like Scale, but for RResults.
+func ScaleR(r RResult, f float32) RResult {
+	return &scaledRResult{
+		OutputVec:  r.Output().Copy().Scale(f),
+		ROutputVec: r.ROutput().Copy().Scale(f),
+		Scaler:     f,
+		Input:      r,
+	}
+}
+
+func (s *scaledRResult) Output() Vector {
+	return s.OutputVec
+}
+
+func (s *scaledRResult) ROutput() Vector {
+	return s.ROutputVec
+}
+
+func (s *scaledRResult) Constant(rg RGradient, g Gradient) bool {
+	return s.Input.Constant(rg, g)
+}
+
+func (s *scaledRResult) PropagateRGradient(u, uR Vector, rg RGradient, g Gradient) {
+	if !s.Input.Constant(rg, g) {
+		s.Input.PropagateRGradient(u.Scale(s.Scaler), uR.Scale(s.Scaler), rg, g)
+	}
+}
+
+// SquaredNorm is a Func and RFunc which computes the
+// squared Euclidean norm of its input.
+type SquaredNorm struct{}
+
+func (_ SquaredNorm) Apply(r Result) Result {
+	in := r.Output()
+	return &squaredNormResult{
+		OutputVec: Vector{in.Dot(in)},
+		Input:     r,
+	}
+}
+
+func (_ SquaredNorm) ApplyR(v RVector, r RResult) RResult {
+	in := r.Output()
+	return &squaredNormRResult{
+		OutputVec:  Vector{in.Dot(in)},
+		ROutputVec: Vector{2 * in.Dot(r.ROutput())},
+		Input:      r,
+	}
+}
+
+type squaredNormResult struct {
+	OutputVec Vector
+	Input     Result
+}
+
+func (s *squaredNormResult) Output() Vector {
+	return s.OutputVec
+}
+
+func (s *squaredNormResult) Constant(g Gradient) bool {
+	return s.Input.Constant(g)
+}
+
+func (s *squaredNormResult) PropagateGradient(u Vector, g Gradient) {
+	if !s.Input.Constant(g) {
+		s.Input.PropagateGradient(s.Input.Output().Copy().Scale(2*u[0]), g)
+	}
+}
+
+type squaredNormRResult struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	Input      RResult
+}
+
+func (s *squaredNormRResult) Output() Vector {
+	return s.OutputVec
+}
+
+func (s *squaredNormRResult) ROutput() Vector {
+	return s.ROutputVec
+}
+
+func (s *squaredNormRResult) Constant(rg RGradient, g Gradient) bool {
+	return s.Input.Constant(rg, g)
+}
+
+func (s *squaredNormRResult) PropagateRGradient(u, uR Vector, rg RGradient, g Gradient) {
+	if !s.Input.Constant(rg, g) {
+		in, inR := s.Input.Output(), s.Input.ROutput()
+		down := in.Copy().Scale(2 * u[0])
+		downR := in.Copy().Scale(2 * uR[0]).Add(inR.Copy().Scale(2 * u[0]))
+		s.Input.PropagateRGradient(down, downR, rg, g)
+	}
+}
+
+func mulVecs(v1, v2 Vector) Vector {
+	res := make(Vector, len(v1))
+	for i, x := range v1 {
+		res[i] = x * v2[i]
+	}
+	return res
+}
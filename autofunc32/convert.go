@@ -0,0 +1,105 @@
+package autofunc32
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// FromVariable creates a single-precision copy of a
+// Variable.
+func FromVariable(v *autofunc.Variable) *Variable {
+	return &Variable{Vector: FromFloat64(v.Vector)}
+}
+
+// Float64Variable creates a double-precision copy of v.
+func (v *Variable) Float64Variable() *autofunc.Variable {
+	return &autofunc.Variable{Vector: v.Vector.Float64()}
+}
+
+// A Mirror pairs double-precision Variables with
+// single-precision copies, so that values, gradients, and
+// RVectors can be converted between the two precisions.
+//
+// For example, a model can be trained with float64 master
+// weights while its gradients are computed in float32.
+type Mirror struct {
+	Vars64 []*autofunc.Variable
+	Vars32 []*Variable
+}
+
+// NewMirror creates single-precision copies of the
+// Variables.
+func NewMirror(vars []*autofunc.Variable) *Mirror {
+	res := &Mirror{Vars64: vars, Vars32: make([]*Variable, len(vars))}
+	for i, v := range vars {
+		res.Vars32[i] = FromVariable(v)
+	}
+	return res
+}
+
+// Sync32 copies the values of the double-precision
+// Variables into the single-precision ones.
+func (m *Mirror) Sync32() {
+	for i, v := range m.Vars64 {
+		dest := m.Vars32[i].Vector
+		for j, x := range v.Vector {
+			dest[j] = float32(x)
+		}
+	}
+}
+
+// Sync64 copies the values of the single-precision
+// Variables into the double-precision ones.
+func (m *Mirror) Sync64() {
+	for i, v := range m.Vars32 {
+		dest := m.Vars64[i].Vector
+		for j, x := range v.Vector {
+			dest[j] = float64(x)
+		}
+	}
+}
+
+// Gradient64 converts a single-precision Gradient into a
+// Gradient for the double-precision Variables.
+// Variables which are not in the Mirror are ignored.
+func (m *Mirror) Gradient64(g Gradient) autofunc.Gradient {
+	return autofunc.Gradient(m.to64(g))
+}
+
+// RGradient64 is like Gradient64, but for RGradients.
+func (m *Mirror) RGradient64(g RGradient) autofunc.RGradient {
+	return autofunc.RGradient(m.to64(g))
+}
+
+// Gradient32 converts a double-precision Gradient into a
+// Gradient for the single-precision Variables.
+// Variables which are not in the Mirror are ignored.
+func (m *Mirror) Gradient32(g autofunc.Gradient) Gradient {
+	return Gradient(m.to32(g))
+}
+
+// RVector32 converts a double-precision RVector into an
+// RVector for the single-precision Variables.
+func (m *Mirror) RVector32(rv autofunc.RVector) RVector {
+	return RVector(m.to32(rv))
+}
+
+func (m *Mirror) to64(g map[*Variable]Vector) map[*autofunc.Variable]linalg.Vector {
+	res := map[*autofunc.Variable]linalg.Vector{}
+	for i, v := range m.Vars32 {
+		if vec, ok := g[v]; ok {
+			res[m.Vars64[i]] = vec.Float64()
+		}
+	}
+	return res
+}
+
+func (m *Mirror) to32(g map[*autofunc.Variable]linalg.Vector) map[*Variable]Vector {
+	res := map[*Variable]Vector{}
+	for i, v := range m.Vars64 {
+		if vec, ok := g[v]; ok {
+			res[m.Vars32[i]] = FromFloat64(vec)
+		}
+	}
+	return res
+}
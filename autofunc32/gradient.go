@@ -0,0 +1,112 @@
+package autofunc32
+
+import "github.com/gonum/blas/blas32"
+
+// A Gradient is like an autofunc.Gradient, but for
+// single-precision Variables.
+type Gradient map[*Variable]Vector
+
+func NewGradient(vars []*Variable) Gradient {
+	res := Gradient{}
+	for _, v := range vars {
+		res[v] = make(Vector, len(v.Vector))
+	}
+	return res
+}
+
+// Zero resets all the values of the gradient to 0.
+func (g Gradient) Zero() {
+	zeroVariableMap(g)
+}
+
+// Add adds all the values from g1 to g.
+// The gradients should have the exact same keys.
+func (g Gradient) Add(g1 Gradient) {
+	addVariableMaps(g, g1)
+}
+
+// Scale scales all the partials in g by f.
+func (g Gradient) Scale(f float32) {
+	scaleVariableMap(g, f)
+}
+
+// AddToVars performs gradient ascent, adding the
+// values from the gradient to their corresponding
+// variables.
+func (g Gradient) AddToVars(scale float32) {
+	for variable, grad := range g {
+		blas32.Axpy(len(grad), scale, blas32.Vector{Data: grad, Inc: 1},
+			blas32.Vector{Data: variable.Vector, Inc: 1})
+	}
+}
+
+// Copy creates a copy of a Gradient.
+func (g Gradient) Copy() Gradient {
+	return copyVariableMap(g)
+}
+
+// An RGradient is like an autofunc.RGradient, but for
+// single-precision Variables.
+type RGradient map[*Variable]Vector
+
+func NewRGradient(vars []*Variable) RGradient {
+	return RGradient(NewGradient(vars))
+}
+
+// Zero resets all the values of the RGradient to 0.
+func (g RGradient) Zero() {
+	zeroVariableMap(g)
+}
+
+// Add adds all the values from g1 to g.
+// The RGradients should have the exact same keys.
+func (g RGradient) Add(g1 RGradient) {
+	addVariableMaps(g, g1)
+}
+
+// Scale scales all the partials in g by f.
+func (g RGradient) Scale(f float32) {
+	scaleVariableMap(g, f)
+}
+
+// Copy creates a copy of an RGradient.
+func (g RGradient) Copy() RGradient {
+	return copyVariableMap(g)
+}
+
+// An RVector is like an autofunc.RVector, but for
+// single-precision Variables.
+type RVector map[*Variable]Vector
+
+// Zero resets all the values of the RVector to 0.
+func (r RVector) Zero() {
+	zeroVariableMap(r)
+}
+
+func zeroVariableMap(m map[*Variable]Vector) {
+	for _, v := range m {
+		for i := range v {
+			v[i] = 0
+		}
+	}
+}
+
+func addVariableMaps(m, m1 map[*Variable]Vector) {
+	for k, v := range m {
+		v.Add(m1[k])
+	}
+}
+
+func scaleVariableMap(m map[*Variable]Vector, f float32) {
+	for _, v := range m {
+		v.Scale(f)
+	}
+}
+
+func copyVariableMap(m map[*Variable]Vector) map[*Variable]Vector {
+	res := map[*Variable]Vector{}
+	for k, v := range m {
+		res[k] = v.Copy()
+	}
+	return res
+}
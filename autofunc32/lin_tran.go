@@ -0,0 +1,158 @@
+package autofunc32
+
+import (
+	"fmt"
+
+	"github.com/gonum/blas"
+	"github.com/gonum/blas/blas32"
+)
+
+// A LinTran is a Func and RFunc that represents a linear
+// transformation, like autofunc.LinTran.
+// The matrix entries are stored in Data, going left to
+// right, then top to bottom.
+type LinTran struct {
+	Data *Variable
+	Rows int
+	Cols int
+}
+
+// Apply performs matrix multiplication (i.e. m*in).
+func (l *LinTran) Apply(in Result) Result {
+	return l.Batch(in, 1)
+}
+
+// ApplyR is like Apply but for RResults.
+func (l *LinTran) ApplyR(v RVector, in RResult) RResult {
+	return l.BatchR(v, in, 1)
+}
+
+// Batch performs matrix multiplication on all
+// of the input vectors.
+func (l *LinTran) Batch(in Result, n int) Result {
+	l.checkInput(len(in.Output()), n)
+	return &linTranResult{
+		Matrix:    l,
+		Input:     in,
+		OutputVec: l.multiply(l.Data.Vector, in.Output(), nil),
+	}
+}
+
+// BatchR is like Batch, but for RResults.
+func (l *LinTran) BatchR(v RVector, in RResult, n int) RResult {
+	l.checkInput(len(in.Output()), n)
+	rData := NewRVariable(l.Data, v)
+	rOut := l.multiply(rData.ROutput(), in.Output(), nil)
+	return &linTranRResult{
+		Matrix:     l,
+		Input:      in,
+		RData:      rData,
+		OutputVec:  l.multiply(l.Data.Vector, in.Output(), nil),
+		ROutputVec: l.multiply(l.Data.Vector, in.ROutput(), rOut),
+	}
+}
+
+func (l *LinTran) checkInput(size, n int) {
+	if l.Cols*n != size {
+		panic(fmt.Sprintf("input length should be %d but got %d", l.Cols*n, size))
+	}
+}
+
+// multiply computes in*data' for a batch of inputs, adding
+// the result to out (or to a new vector if out is nil).
+func (l *LinTran) multiply(data, in, out Vector) Vector {
+	n := len(in) / l.Cols
+	if out == nil {
+		out = make(Vector, n*l.Rows)
+	}
+	blas32.Gemm(blas.NoTrans, blas.Trans, 1, general(in, n, l.Cols),
+		general(data, l.Rows, l.Cols), 1, general(out, n, l.Rows))
+	return out
+}
+
+// dataGradient adds upstream'*in to grad.
+func (l *LinTran) dataGradient(upstream, in, grad Vector) {
+	n := len(in) / l.Cols
+	blas32.Gemm(blas.Trans, blas.NoTrans, 1, general(upstream, n, l.Rows),
+		general(in, n, l.Cols), 1, general(grad, l.Rows, l.Cols))
+}
+
+// inputGradient computes upstream*data, adding the result
+// to out (or to a new vector if out is nil).
+func (l *LinTran) inputGradient(data, upstream, out Vector) Vector {
+	n := len(upstream) / l.Rows
+	if out == nil {
+		out = make(Vector, n*l.Cols)
+	}
+	blas32.Gemm(blas.NoTrans, blas.NoTrans, 1, general(upstream, n, l.Rows),
+		general(data, l.Rows, l.Cols), 1, general(out, n, l.Cols))
+	return out
+}
+
+type linTranResult struct {
+	OutputVec Vector
+	Input     Result
+	Matrix    *LinTran
+}
+
+func (l *linTranResult) Output() Vector {
+	return l.OutputVec
+}
+
+func (l *linTranResult) Constant(g Gradient) bool {
+	return l.Matrix.Data.Constant(g) && l.Input.Constant(g)
+}
+
+func (l *linTranResult) PropagateGradient(upstream Vector, grad Gradient) {
+	if !l.Matrix.Data.Constant(grad) {
+		l.Matrix.dataGradient(upstream, l.Input.Output(), grad[l.Matrix.Data])
+	}
+	if !l.Input.Constant(grad) {
+		l.Input.PropagateGradient(l.Matrix.inputGradient(l.Matrix.Data.Vector, upstream, nil),
+			grad)
+	}
+}
+
+type linTranRResult struct {
+	OutputVec  Vector
+	ROutputVec Vector
+	Input      RResult
+	RData      *RVariable
+	Matrix     *LinTran
+}
+
+func (l *linTranRResult) Output() Vector {
+	return l.OutputVec
+}
+
+func (l *linTranRResult) ROutput() Vector {
+	return l.ROutputVec
+}
+
+func (l *linTranRResult) Constant(rg RGradient, g Gradient) bool {
+	return l.RData.Constant(rg, g) && l.Input.Constant(rg, g)
+}
+
+func (l *linTranRResult) PropagateRGradient(upstream, upstreamR Vector, rgrad RGradient,
+	grad Gradient) {
+	m := l.Matrix
+	if grad != nil {
+		if dataGrad, ok := grad[m.Data]; ok {
+			m.dataGradient(upstream, l.Input.Output(), dataGrad)
+		}
+	}
+	if dataGrad, ok := rgrad[m.Data]; ok {
+		m.dataGradient(upstream, l.Input.ROutput(), dataGrad)
+		m.dataGradient(upstreamR, l.Input.Output(), dataGrad)
+	}
+	if !l.Input.Constant(rgrad, grad) {
+		inGrad := m.inputGradient(m.Data.Vector, upstream, nil)
+		inGradR := m.inputGradient(l.RData.ROutput(), upstream, nil)
+		m.inputGradient(m.Data.Vector, upstreamR, inGradR)
+		l.Input.PropagateRGradient(inGrad, inGradR, rgrad, grad)
+	}
+}
+
+func general(data Vector, rows, cols int) blas32.General {
+	return blas32.General{Rows: rows, Cols: cols, Stride: cols, Data: data}
+}
@@ -0,0 +1,55 @@
+package autofunc32
+
+// Result is like an autofunc.Result, but its output is a
+// single-precision vector.
+//
+// See autofunc.Result for the contract which
+// implementations must follow.
+type Result interface {
+	Output() Vector
+	Constant(g Gradient) bool
+	PropagateGradient(upstream Vector, grad Gradient)
+}
+
+// RResult is like an autofunc.RResult, but its outputs
+// are single-precision vectors.
+//
+// See autofunc.RResult for the contract which
+// implementations must follow.
+type RResult interface {
+	Output() Vector
+	ROutput() Vector
+	Constant(rg RGradient, g Gradient) bool
+	PropagateRGradient(upstream, upstreamR Vector, rgrad RGradient, grad Gradient)
+}
+
+// A Func is an operation which can be applied to
+// the result of any other operation.
+type Func interface {
+	Apply(input Result) Result
+}
+
+// An RFunc is like a Func, but it must be able
+// to propagate RResults as well as Results.
+type RFunc interface {
+	Func
+	ApplyR(v RVector, input RResult) RResult
+}
+
+// A ComposedRFunc feeds input from each RFunc to the next
+// RFunc in a list.
+type ComposedRFunc []RFunc
+
+func (c ComposedRFunc) Apply(input Result) Result {
+	for _, f := range c {
+		input = f.Apply(input)
+	}
+	return input
+}
+
+func (c ComposedRFunc) ApplyR(v RVector, input RResult) RResult {
+	for _, f := range c {
+		input = f.ApplyR(v, input)
+	}
+	return input
+}
@@ -0,0 +1,151 @@
+package autofunc32test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/autofunc32"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+const float32Tolerance = 1e-4
+
+type testNet struct {
+	Mat    *autofunc.LinTran
+	Bias   *autofunc.Variable
+	Input  *autofunc.Variable
+	Mirror *autofunc32.Mirror
+	RV     autofunc.RVector
+}
+
+func newTestNet() *testNet {
+	rand.Seed(1337)
+	res := &testNet{
+		Mat:   &autofunc.LinTran{Data: randomVariable(12), Rows: 3, Cols: 4},
+		Bias:  randomVariable(6),
+		Input: randomVariable(8),
+		RV:    autofunc.RVector{},
+	}
+	vars := []*autofunc.Variable{res.Mat.Data, res.Bias, res.Input}
+	for _, v := range vars {
+		res.RV[v] = randomVariable(len(v.Vector)).Vector
+	}
+	res.Mirror = autofunc32.NewMirror(vars)
+	return res
+}
+
+func (t *testNet) Apply64() autofunc.RResult {
+	in := autofunc.NewRVariable(t.Input, t.RV)
+	bias := autofunc.NewRVariable(t.Bias, t.RV)
+	out := autofunc.AddR(t.Mat.BatchR(t.RV, in, 2), bias)
+	a := autofunc.Sigmoid{}.ApplyR(t.RV, out)
+	b := autofunc.Tanh{}.ApplyR(t.RV, autofunc.ScaleR(out, 0.5))
+	c := autofunc.Exp{}.ApplyR(t.RV, autofunc.ReLU{}.ApplyR(t.RV, autofunc.SubR(out, bias)))
+	return autofunc.SquaredNorm{}.ApplyR(t.RV, autofunc.AddR(autofunc.MulR(a, b), c))
+}
+
+func (t *testNet) Apply32() autofunc32.RResult {
+	rv := t.Mirror.RVector32(t.RV)
+	vars := t.Mirror.Vars32
+	mat := &autofunc32.LinTran{Data: vars[0], Rows: 3, Cols: 4}
+	in := autofunc32.NewRVariable(vars[2], rv)
+	bias := autofunc32.NewRVariable(vars[1], rv)
+	out := autofunc32.AddR(mat.BatchR(rv, in, 2), bias)
+	a := autofunc32.Sigmoid{}.ApplyR(rv, out)
+	b := autofunc32.Tanh{}.ApplyR(rv, autofunc32.ScaleR(out, 0.5))
+	c := autofunc32.Exp{}.ApplyR(rv, autofunc32.ReLU{}.ApplyR(rv,
+		autofunc32.SubR(out, bias)))
+	return autofunc32.SquaredNorm{}.ApplyR(rv, autofunc32.AddR(autofunc32.MulR(a, b), c))
+}
+
+func TestMatchesFloat64(t *testing.T) {
+	net := newTestNet()
+	res64 := net.Apply64()
+	res32 := net.Apply32()
+	checkClose(t, "output", res64.Output(), res32.Output().Float64())
+	checkClose(t, "r-output", res64.ROutput(), res32.ROutput().Float64())
+
+	grad64 := autofunc.NewGradient(net.Mirror.Vars64)
+	rgrad64 := autofunc.NewRGradient(net.Mirror.Vars64)
+	res64.PropagateRGradient(linalg.Vector{1.5}, linalg.Vector{-0.5}, rgrad64, grad64)
+
+	grad32 := autofunc32.NewGradient(net.Mirror.Vars32)
+	rgrad32 := autofunc32.NewRGradient(net.Mirror.Vars32)
+	res32.PropagateRGradient(autofunc32.Vector{1.5}, autofunc32.Vector{-0.5}, rgrad32, grad32)
+
+	converted := net.Mirror.Gradient64(grad32)
+	convertedR := net.Mirror.RGradient64(rgrad32)
+	for i, v := range net.Mirror.Vars64 {
+		checkClose(t, "gradient", grad64[v], converted[v])
+		checkClose(t, "r-gradient", rgrad64[v], convertedR[v])
+
+		// Plain back-propagation should agree with the
+		// gradient from r-propagation.
+		plain := autofunc32.NewGradient(net.Mirror.Vars32[i : i+1])
+		net.Apply32().PropagateRGradient(autofunc32.Vector{1.5}, autofunc32.Vector{0},
+			autofunc32.RGradient{}, plain)
+		checkClose(t, "plain gradient", grad64[v],
+			plain[net.Mirror.Vars32[i]].Float64())
+	}
+}
+
+func TestNonRMatchesFloat64(t *testing.T) {
+	net := newTestNet()
+	vars := net.Mirror.Vars32
+	mat := &autofunc32.LinTran{Data: vars[0], Rows: 3, Cols: 4}
+	out := autofunc32.Add(mat.Batch(vars[2], 2), vars[1])
+	a := autofunc32.Sigmoid{}.Apply(out)
+	b := autofunc32.Tanh{}.Apply(autofunc32.Scale(out, 0.5))
+	c := autofunc32.Exp{}.Apply(autofunc32.ReLU{}.Apply(autofunc32.Sub(out, vars[1])))
+	res32 := autofunc32.SquaredNorm{}.Apply(autofunc32.Add(autofunc32.Mul(a, b), c))
+	grad32 := autofunc32.NewGradient(vars)
+	res32.PropagateGradient(autofunc32.Vector{1.5}, grad32)
+
+	res64 := net.Apply64()
+	grad64 := autofunc.NewGradient(net.Mirror.Vars64)
+	res64.PropagateRGradient(linalg.Vector{1.5}, linalg.Vector{0}, autofunc.RGradient{}, grad64)
+
+	checkClose(t, "output", res64.Output(), res32.Output().Float64())
+	converted := net.Mirror.Gradient64(grad32)
+	for _, v := range net.Mirror.Vars64 {
+		checkClose(t, "gradient", grad64[v], converted[v])
+	}
+}
+
+func TestMirrorSync(t *testing.T) {
+	v := &autofunc.Variable{Vector: []float64{1.5, -2, 1e-3}}
+	m := autofunc32.NewMirror([]*autofunc.Variable{v})
+	grad := m.Gradient32(autofunc.Gradient{v: []float64{1, 2, 3}})
+	grad.AddToVars(-1)
+	m.Sync64()
+	checkClose(t, "variable", linalg.Vector{0.5, -4, 1e-3 - 3}, v.Vector)
+
+	v.Vector[0] = 7
+	m.Sync32()
+	if m.Vars32[0].Vector[0] != 7 {
+		t.Errorf("expected 7 but got %f", m.Vars32[0].Vector[0])
+	}
+}
+
+func checkClose(t *testing.T, name string, expected, actual linalg.Vector) {
+	if len(expected) != len(actual) {
+		t.Errorf("%s: expected length %d but got %d", name, len(expected), len(actual))
+		return
+	}
+	for i, x := range expected {
+		if math.Abs(x-actual[i]) > float32Tolerance*math.Max(1, math.Abs(x)) {
+			t.Errorf("%s: expected %v but got %v", name, expected, actual)
+			return
+		}
+	}
+}
+
+func randomVariable(size int) *autofunc.Variable {
+	res := &autofunc.Variable{Vector: make(linalg.Vector, size)}
+	for i := range res.Vector {
+		res.Vector[i] = rand.NormFloat64()
+	}
+	return res
+}
@@ -0,0 +1,76 @@
+package autofunc32
+
+// A Variable is a single-precision vector, wrapped in a
+// struct so pointers to it can be used as a map key in
+// things like Gradient.
+type Variable struct {
+	Vector Vector
+}
+
+func (v *Variable) Output() Vector {
+	return v.Vector
+}
+
+func (v *Variable) PropagateGradient(upstream Vector, grad Gradient) {
+	if gradVec, ok := grad[v]; ok {
+		gradVec.Add(upstream)
+	}
+}
+
+func (v *Variable) Constant(g Gradient) bool {
+	_, variable := g[v]
+	return !variable
+}
+
+// An RVariable is a variable that knows about
+// a particular RVector and can thus behave
+// like an RResult.
+type RVariable struct {
+	Variable *Variable
+
+	ROutputVec Vector
+}
+
+func NewRVariable(v *Variable, rv RVector) *RVariable {
+	if vec, ok := rv[v]; ok {
+		return &RVariable{
+			Variable:   v,
+			ROutputVec: vec,
+		}
+	}
+	return &RVariable{
+		Variable:   v,
+		ROutputVec: make(Vector, len(v.Vector)),
+	}
+}
+
+func (r *RVariable) Output() Vector {
+	return r.Variable.Output()
+}
+
+func (r *RVariable) ROutput() Vector {
+	return r.ROutputVec
+}
+
+func (r *RVariable) Constant(rg RGradient, g Gradient) bool {
+	if _, ok := rg[r.Variable]; ok {
+		return false
+	}
+	if g == nil {
+		return true
+	}
+	_, variable := g[r.Variable]
+	return !variable
+}
+
+func (r *RVariable) PropagateRGradient(upstream, upstreamR Vector,
+	rgrad RGradient, grad Gradient) {
+	if grad != nil {
+		if gradVec, ok := grad[r.Variable]; ok {
+			gradVec.Add(upstream)
+		}
+	}
+	if gradVec, ok := rgrad[r.Variable]; ok {
+		gradVec.Add(upstreamR)
+	}
+}
@@ -0,0 +1,80 @@
+// Package autofunc32 is a single-precision version of the
+// core of autofunc.
+//
+// It provides float32 counterparts of Variable, Result,
+// RResult, Gradient, LinTran, and the element-wise
+// functions, using blas32 for linear algebra.
+// This halves the memory used by large models and lets
+// BLAS process twice as many components per instruction.
+//
+// Use a Mirror to convert Variables, gradients, and
+// RVectors between autofunc and autofunc32, for example
+// to keep float64 master copies of float32 parameters.
+package autofunc32
+
+import (
+	"math"
+
+	"github.com/gonum/blas/blas32"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A Vector is a single-precision vector.
+type Vector []float32
+
+// FromFloat64 converts a double-precision vector to a
+// Vector, rounding each component.
+func FromFloat64(v linalg.Vector) Vector {
+	res := make(Vector, len(v))
+	for i, x := range v {
+		res[i] = float32(x)
+	}
+	return res
+}
+
+// Float64 converts v to a double-precision vector.
+func (v Vector) Float64() linalg.Vector {
+	res := make(linalg.Vector, len(v))
+	for i, x := range v {
+		res[i] = float64(x)
+	}
+	return res
+}
+
+// Copy creates a copy of v.
+func (v Vector) Copy() Vector {
+	return append(Vector{}, v...)
+}
+
+// Add adds v1 to v in place and returns v.
+func (v Vector) Add(v1 Vector) Vector {
+	if len(v) != len(v1) {
+		panic("vector lengths do not match")
+	}
+	blas32.Axpy(len(v), 1, blas32.Vector{Data: v1, Inc: 1}, blas32.Vector{Data: v, Inc: 1})
+	return v
+}
+
+// Scale scales v in place and returns v.
+func (v Vector) Scale(f float32) Vector {
+	blas32.Scal(len(v), f, blas32.Vector{Data: v, Inc: 1})
+	return v
+}
+
+// Dot computes the dot product of v and v1.
+func (v Vector) Dot(v1 Vector) float32 {
+	if len(v) != len(v1) {
+		panic("vector lengths do not match")
+	}
+	return blas32.Dot(len(v), blas32.Vector{Data: v, Inc: 1}, blas32.Vector{Data: v1, Inc: 1})
+}
+
+// MaxAbs returns the largest absolute value of the
+// components of v.
+func (v Vector) MaxAbs() float32 {
+	var res float64
+	for _, x := range v {
+		res = math.Max(res, math.Abs(float64(x)))
+	}
+	return float32(res)
+}
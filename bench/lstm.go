@@ -17,6 +17,29 @@ var DefaultLSTMBenchmark = &LSTMBenchmark{
 	TimeSteps:  50,
 }
 
+var SmallLSTMBenchmark = &LSTMBenchmark{
+	InputSize:  4,
+	HiddenSize: 8,
+	OutputSize: 2,
+	TimeSteps:  50,
+}
+
+var MatMulLSTMBenchmark = &LSTMBenchmark{
+	InputSize:  SmallLSTMBenchmark.InputSize,
+	HiddenSize: SmallLSTMBenchmark.HiddenSize,
+	OutputSize: SmallLSTMBenchmark.OutputSize,
+	TimeSteps:  SmallLSTMBenchmark.TimeSteps,
+	MatMul:     true,
+}
+
+var ParamVectorLSTMBenchmark = &LSTMBenchmark{
+	InputSize:   SmallLSTMBenchmark.InputSize,
+	HiddenSize:  SmallLSTMBenchmark.HiddenSize,
+	OutputSize:  SmallLSTMBenchmark.OutputSize,
+	TimeSteps:   SmallLSTMBenchmark.TimeSteps,
+	ParamVector: true,
+}
+
 // LSTMBenchmark tests how quickly autofunc can perform
 // operations on a single-layer LSTM RNN.
 //
@@ -27,6 +50,17 @@ type LSTMBenchmark struct {
 	HiddenSize int
 	OutputSize int
 	TimeSteps  int
+
+	// MatMul, if true, makes the benchmark apply its
+	// weights with autofunc.MatMul and autofunc.Add rather
+	// than with autofunc.LinTran and autofunc.LinAdd.
+	MatMul bool
+
+	// ParamVector, if true, makes the benchmark store the
+	// gradient in an autofunc.ParamVector and use
+	// autofunc.ParamVars for its parameters.
+	// It implies MatMul.
+	ParamVector bool
 }
 
 func (l *LSTMBenchmark) Run(b *testing.B, backProp bool) {
@@ -35,8 +69,25 @@ func (l *LSTMBenchmark) Run(b *testing.B, backProp bool) {
 	inputs := l.generateInputs()
 	outputGrads := l.generateOutputs()
 
+	var gradVal autofunc.Gradient
+	if l.ParamVector {
+		vec := autofunc.NewParamSet(net.Parameters()).NewVector()
+		for _, layer := range net.Layers() {
+			layer.WeightsRes = vec.Var(layer.Weights.Data)
+			layer.BiasesRes = vec.Var(layer.Biases.Var)
+		}
+		gradVal = vec.Gradient()
+	} else {
+		if l.MatMul {
+			for _, layer := range net.Layers() {
+				layer.WeightsRes = layer.Weights.Data
+				layer.BiasesRes = layer.Biases.Var
+			}
+		}
+		gradVal = autofunc.NewGradient(net.Parameters())
+	}
+
 	b.ResetTimer()
-	gradVal := net.AllocGradient()
 	for i := 0; i < b.N; i++ {
 		net.Reset()
 		for _, in := range inputs {
@@ -71,38 +122,19 @@ func (l *LSTMBenchmark) generateOutputs() []linalg.Vector {
 }
 
 func (l *LSTMBenchmark) generateNet() *lstmNet {
-	inputWeights, inputBiases := l.generateGate()
-	inGate, inGateBiases := l.generateGate()
-	forgetGate, forgetGateBiases := l.generateGate()
-	outputGate, outputGateBiases := l.generateGate()
-
-	outputWeights, outputBiases := l.generateLayer(l.OutputSize)
-
 	return &lstmNet{
 		LSTM: &lstmBlock{
-			InWeights:         inputWeights,
-			InGateWeights:     inGate,
-			ForgetGateWeights: forgetGate,
-			InBiases:          inputBiases,
-			InGateBiases:      inGateBiases,
-			ForgetGateBiases:  forgetGateBiases,
+			In:         l.generateLayer(l.HiddenSize),
+			InGate:     l.generateLayer(l.HiddenSize),
+			ForgetGate: l.generateLayer(l.HiddenSize),
 		},
-
-		OutputGate:    outputGate,
-		OutputWeights: outputWeights,
-
-		OutputGateBiases: outputGateBiases,
-		OutputBiases:     outputBiases,
-
-		StateSize: l.HiddenSize,
+		OutputGate: l.generateLayer(l.HiddenSize),
+		Output:     l.generateLayer(l.OutputSize),
+		StateSize:  l.HiddenSize,
 	}
 }
 
-func (l *LSTMBenchmark) generateGate() (*autofunc.LinTran, *autofunc.LinAdd) {
-	return l.generateLayer(l.HiddenSize)
-}
-
-func (l *LSTMBenchmark) generateLayer(outSize int) (*autofunc.LinTran, *autofunc.LinAdd) {
+func (l *LSTMBenchmark) generateLayer(outSize int) *lstmLayer {
 	weightMat := make(linalg.Vector, (l.InputSize+l.HiddenSize)*outSize)
 	for i := range weightMat {
 		weightMat[i] = rand.Float64()*2 - 1
@@ -116,33 +148,54 @@ func (l *LSTMBenchmark) generateLayer(outSize int) (*autofunc.LinTran, *autofunc
 	weightVar := &autofunc.Variable{Vector: weightMat}
 	biasVar := &autofunc.Variable{Vector: biasMat}
 
-	linTran := &autofunc.LinTran{
-		Data: weightVar,
-		Rows: outSize,
-		Cols: l.InputSize + l.HiddenSize,
+	return &lstmLayer{
+		Weights: &autofunc.LinTran{
+			Data: weightVar,
+			Rows: outSize,
+			Cols: l.InputSize + l.HiddenSize,
+		},
+		Biases: &autofunc.LinAdd{Var: biasVar},
 	}
-	linAdd := &autofunc.LinAdd{Var: biasVar}
+}
+
+// lstmLayer is an affine transformation.
+type lstmLayer struct {
+	Weights *autofunc.LinTran
+	Biases  *autofunc.LinAdd
 
-	return linTran, linAdd
+	// WeightsRes and BiasesRes, if set, are the Results
+	// for the parameters, which are used with MatMul and
+	// Add instead of Weights and Biases.
+	WeightsRes autofunc.Result
+	BiasesRes  autofunc.Result
 }
 
-type lstmBlock struct {
-	InWeights         *autofunc.LinTran
-	InGateWeights     *autofunc.LinTran
-	ForgetGateWeights *autofunc.LinTran
+func (l *lstmLayer) Apply(in autofunc.Result) autofunc.Result {
+	if l.WeightsRes == nil {
+		return l.Biases.Apply(l.Weights.Apply(in))
+	}
+	prod := autofunc.MatMul(l.WeightsRes, in, autofunc.MatMulShape{
+		ARows: l.Weights.Rows,
+		ACols: l.Weights.Cols,
+		BRows: l.Weights.Cols,
+		BCols: 1,
+	})
+	return autofunc.Add(prod, l.BiasesRes)
+}
 
-	InBiases         *autofunc.LinAdd
-	InGateBiases     *autofunc.LinAdd
-	ForgetGateBiases *autofunc.LinAdd
+type lstmBlock struct {
+	In         *lstmLayer
+	InGate     *lstmLayer
+	ForgetGate *lstmLayer
 }
 
 func (l *lstmBlock) Apply(state, input autofunc.Result) autofunc.Result {
 	in := autofunc.Concat(state, input)
 
 	s := autofunc.Sigmoid{}
-	inState := s.Apply(l.InBiases.Apply(l.InWeights.Apply(in)))
-	inMask := s.Apply(l.InGateBiases.Apply(l.InGateWeights.Apply(in)))
-	forgetMask := s.Apply(l.ForgetGateBiases.Apply(l.ForgetGateWeights.Apply(in)))
+	inState := s.Apply(l.In.Apply(in))
+	inMask := s.Apply(l.InGate.Apply(in))
+	forgetMask := s.Apply(l.ForgetGate.Apply(in))
 
 	maskedNew := autofunc.Mul(inMask, inState)
 	maskedOld := autofunc.Mul(forgetMask, state)
@@ -154,11 +207,8 @@ type lstmNet struct {
 	LSTM      *lstmBlock
 	StateSize int
 
-	OutputGate    *autofunc.LinTran
-	OutputWeights *autofunc.LinTran
-
-	OutputGateBiases *autofunc.LinAdd
-	OutputBiases     *autofunc.LinAdd
+	OutputGate *lstmLayer
+	Output     *lstmLayer
 
 	inputStates     []*autofunc.Variable
 	outputStates    []autofunc.Result
@@ -186,30 +236,28 @@ func (l *lstmNet) StepTime(sample linalg.Vector) linalg.Vector {
 
 	s := autofunc.Sigmoid{}
 	joinedInput := autofunc.Concat(inState, sampleVar)
-	outputGate := s.Apply(l.OutputGateBiases.Apply(l.OutputGate.Apply(joinedInput)))
+	outputGate := s.Apply(l.OutputGate.Apply(joinedInput))
 
 	maskedState := autofunc.Mul(outStateVar, outputGate)
 
 	augOut := autofunc.Concat(maskedState, sampleVar)
-	result := s.Apply(l.OutputBiases.Apply(l.OutputWeights.Apply(augOut)))
+	result := s.Apply(l.Output.Apply(augOut))
 	l.outputs = append(l.outputs, result)
 
 	return result.Output()
 }
 
-func (l *lstmNet) AllocGradient() autofunc.Gradient {
-	return autofunc.NewGradient([]*autofunc.Variable{
-		l.OutputBiases.Var,
-		l.OutputGateBiases.Var,
-		l.OutputWeights.Data,
-		l.OutputGate.Data,
-		l.LSTM.ForgetGateBiases.Var,
-		l.LSTM.ForgetGateWeights.Data,
-		l.LSTM.InGateBiases.Var,
-		l.LSTM.InGateWeights.Data,
-		l.LSTM.InBiases.Var,
-		l.LSTM.InWeights.Data,
-	})
+func (l *lstmNet) Layers() []*lstmLayer {
+	return []*lstmLayer{l.Output, l.OutputGate, l.LSTM.ForgetGate, l.LSTM.InGate,
+		l.LSTM.In}
+}
+
+func (l *lstmNet) Parameters() []*autofunc.Variable {
+	var res []*autofunc.Variable
+	for _, layer := range l.Layers() {
+		res = append(res, layer.Biases.Var, layer.Weights.Data)
+	}
+	return res
 }
 
 func (l *lstmNet) PropagateGradient(upstreams []linalg.Vector, grad autofunc.Gradient) {
@@ -9,3 +9,15 @@ func BenchmarkLSTMForward(b *testing.B) {
 func BenchmarkLSTMBothWays(b *testing.B) {
 	DefaultLSTMBenchmark.Run(b, true)
 }
+
+func BenchmarkLSTMBothWaysSmall(b *testing.B) {
+	SmallLSTMBenchmark.Run(b, true)
+}
+
+func BenchmarkLSTMBothWaysMatMul(b *testing.B) {
+	MatMulLSTMBenchmark.Run(b, true)
+}
+
+func BenchmarkLSTMBothWaysParamVector(b *testing.B) {
+	ParamVectorLSTMBenchmark.Run(b, true)
+}
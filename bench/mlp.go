@@ -19,11 +19,22 @@ var DefaultMLPBenchmark = &MLPBenchmark{
 	Activation: autofunc.Sigmoid{},
 }
 
+var ArenaMLPBenchmark = &MLPBenchmark{
+	LayerSizes: DefaultMLPBenchmark.LayerSizes,
+	Activation: DefaultMLPBenchmark.Activation,
+	Arena:      true,
+}
+
 // MLPBenchmark tests how quickly autofunc can perform
 // operations on a multilayer perception network.
 type MLPBenchmark struct {
 	LayerSizes []int
 	Activation autofunc.RFunc
+
+	// Arena, if true, makes the benchmark allocate vectors
+	// from an autofunc.Arena which is reset after every
+	// iteration.
+	Arena bool
 }
 
 func (m *MLPBenchmark) Run(b *testing.B, backProp bool) {
@@ -31,12 +42,19 @@ func (m *MLPBenchmark) Run(b *testing.B, backProp bool) {
 	input := m.makeInput()
 	outGrad, _ := m.outputGrads()
 	grad := autofunc.NewGradient(vars)
+	arena := &autofunc.Arena{}
+	var scope *autofunc.Scope
+	if m.Arena {
+		scope = &autofunc.Scope{Allocator: arena}
+	}
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		res := netFunc.Apply(input)
+		res := netFunc.Apply(scope.Result(input))
 		if backProp {
 			res.PropagateGradient(outGrad, grad)
 		}
+		arena.Reset()
 	}
 }
 
@@ -47,12 +65,20 @@ func (m *MLPBenchmark) RunR(b *testing.B, backProp bool) {
 	outGrad, outRGrad := m.outputGrads()
 	rgrad := autofunc.NewRGradient(vars)
 	grad := autofunc.NewGradient(vars)
+	arena := &autofunc.Arena{}
+	var scope *autofunc.Scope
+	if m.Arena {
+		scope = &autofunc.Scope{Allocator: arena}
+	}
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		res := netFunc.ApplyR(rVec, autofunc.NewRVariable(input, rVec))
+		in := scope.RResult(autofunc.NewRVariable(input, rVec))
+		res := netFunc.ApplyR(rVec, in)
 		if backProp {
 			res.PropagateRGradient(outGrad, outRGrad, rgrad, grad)
 		}
+		arena.Reset()
 	}
 }
 
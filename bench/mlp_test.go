@@ -13,3 +13,11 @@ func BenchmarkMLPBothWays(b *testing.B) {
 func BenchmarkMLPHessian(b *testing.B) {
 	DefaultMLPBenchmark.RunR(b, true)
 }
+
+func BenchmarkMLPBothWaysArena(b *testing.B) {
+	ArenaMLPBenchmark.Run(b, true)
+}
+
+func BenchmarkMLPHessianArena(b *testing.B) {
+	ArenaMLPBenchmark.RunR(b, true)
+}
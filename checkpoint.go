@@ -0,0 +1,567 @@
+package autofunc
+
+import (
+	"math"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type checkpointFoldResult struct {
+	scopeRef
+
+	OutputVec   linalg.Vector
+	Initial     Result
+	Ins         []Result
+	F           func(state, in Result) Result
+	Interval    int
+	Checkpoints []linalg.Vector
+
+	// LastPools and LastSteps store the last segment from
+	// the forward pass.
+	LastPools []*Variable
+	LastSteps []Result
+}
+
+// CheckpointFold is like Fold, but it only stores every
+// interval-th state, rather than every intermediate
+// Result.
+// The steps between stored states are recomputed during
+// back propagation, one segment at a time, trading
+// computation for memory.
+//
+// If interval is 0, the square root of the number of
+// inputs is used.
+//
+// Since f must be recomputed, it should be deterministic.
+// The resulting gradients are bit-for-bit equal to those
+// computed by Fold.
+//
+// The last segment is kept from the forward pass.
+// It is not recomputed during back propagation, and it
+// is used to decide if the result is constant.
+// Earlier segments are only recomputed for the latter if
+// the last segment turns out to be constant.
+func CheckpointFold(state Result, ins []Result, interval int,
+	f func(state, in Result) Result) Result {
+	scope := scopeOf(state)
+	if scope == nil {
+		scope = scopeOf(ins...)
+	}
+	res := &checkpointFoldResult{
+		Initial:  state,
+		Ins:      ins,
+		F:        f,
+		Interval: checkpointInterval(interval, len(ins)),
+	}
+	lastStart := lastSegmentStart(res.Interval, len(ins))
+	vec := state.Output()
+	scope.Untaped(func() {
+		for i, in := range ins {
+			if i%res.Interval == 0 {
+				res.Checkpoints = append(res.Checkpoints, vec)
+			}
+			pool := &Variable{Vector: vec}
+			step := f(scope.Result(pool), in)
+			if i >= lastStart {
+				res.LastPools = append(res.LastPools, pool)
+				res.LastSteps = append(res.LastSteps, step)
+			}
+			vec = step.Output()
+		}
+	})
+	res.OutputVec = vec
+	return scope.result(res)
+}
+
+func (c *checkpointFoldResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *checkpointFoldResult) Constant(g Gradient) bool {
+	if !c.Initial.Constant(g) {
+		return false
+	}
+	for _, in := range c.Ins {
+		if !in.Constant(g) {
+			return false
+		}
+	}
+
+	// The state pools are not in g, so this only checks
+	// what the steps use besides their states.
+	for seg := len(c.Checkpoints) - 1; seg >= 0; seg-- {
+		_, steps := c.segment(seg)
+		for _, step := range steps {
+			if !step.Constant(g) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *checkpointFoldResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if len(c.Ins) == 0 {
+		c.Initial.PropagateGradient(u, g)
+		return
+	}
+	stateUp := u
+	for seg := len(c.Checkpoints) - 1; seg >= 0; seg-- {
+		pools, results := c.segment(seg)
+		for i := len(results) - 1; i >= 0; i-- {
+			p := pools[i]
+			g[p] = c.Scope.Alloc(len(p.Vector))
+			if results[i].Constant(g) {
+				delete(g, p)
+				return
+			}
+			results[i].PropagateGradient(stateUp, g)
+			stateUp = g[p]
+			delete(g, p)
+		}
+	}
+	c.Initial.PropagateGradient(stateUp, g)
+}
+
+// segment recomputes the steps in a segment, starting at
+// its checkpoint.
+// The last segment is not recomputed.
+func (c *checkpointFoldResult) segment(seg int) ([]*Variable, []Result) {
+	if seg == len(c.Checkpoints)-1 {
+		return c.LastPools, c.LastSteps
+	}
+	start, end := segmentBounds(seg, c.Interval, len(c.Ins))
+	var pools []*Variable
+	var results []Result
+	vec := c.Checkpoints[seg]
+	c.Scope.Untaped(func() {
+		for _, in := range c.Ins[start:end] {
+			pool := &Variable{Vector: vec}
+			res := c.F(c.Scope.Result(pool), in)
+			pools = append(pools, pool)
+			results = append(results, res)
+			vec = res.Output()
+		}
+	})
+	return pools, results
+}
+
+type checkpointFoldRResult struct {
+	scopeRef
+
+	OutputVec    linalg.Vector
+	ROutputVec   linalg.Vector
+	Initial      RResult
+	Ins          []RResult
+	F            func(state, in RResult) RResult
+	Interval     int
+	Checkpoints  []linalg.Vector
+	RCheckpoints []linalg.Vector
+	LastPools    []*Variable
+	LastSteps    []RResult
+}
+
+// CheckpointFoldR is like CheckpointFold, but for
+// RResults.
+// The resulting gradients are bit-for-bit equal to those
+// computed by FoldR.
+func CheckpointFoldR(state RResult, ins []RResult, interval int,
+	f func(state, in RResult) RResult) RResult {
+	scope := scopeOfR(state)
+	if scope == nil {
+		scope = scopeOfR(ins...)
+	}
+	res := &checkpointFoldRResult{
+		Initial:  state,
+		Ins:      ins,
+		F:        f,
+		Interval: checkpointInterval(interval, len(ins)),
+	}
+	lastStart := lastSegmentStart(res.Interval, len(ins))
+	vec, vecR := state.Output(), state.ROutput()
+	scope.Untaped(func() {
+		for i, in := range ins {
+			if i%res.Interval == 0 {
+				res.Checkpoints = append(res.Checkpoints, vec)
+				res.RCheckpoints = append(res.RCheckpoints, vecR)
+			}
+			pool := &Variable{Vector: vec}
+			step := f(scope.RResult(&RVariable{Variable: pool, ROutputVec: vecR}), in)
+			if i >= lastStart {
+				res.LastPools = append(res.LastPools, pool)
+				res.LastSteps = append(res.LastSteps, step)
+			}
+			vec, vecR = step.Output(), step.ROutput()
+		}
+	})
+	res.OutputVec = vec
+	res.ROutputVec = vecR
+	return scope.rresult(res)
+}
+
+func (c *checkpointFoldRResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *checkpointFoldRResult) ROutput() linalg.Vector {
+	return c.ROutputVec
+}
+
+func (c *checkpointFoldRResult) Constant(rg RGradient, g Gradient) bool {
+	if !c.Initial.Constant(rg, g) {
+		return false
+	}
+	for _, in := range c.Ins {
+		if !in.Constant(rg, g) {
+			return false
+		}
+	}
+	for seg := len(c.Checkpoints) - 1; seg >= 0; seg-- {
+		_, steps := c.segment(seg)
+		for _, step := range steps {
+			if !step.Constant(rg, g) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *checkpointFoldRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
+	g Gradient) {
+	if len(c.Ins) == 0 {
+		c.Initial.PropagateRGradient(u, uR, rg, g)
+		return
+	}
+	if g == nil {
+		g = Gradient{}
+	}
+	stateUp, stateUpR := u, uR
+	for seg := len(c.Checkpoints) - 1; seg >= 0; seg-- {
+		pools, results := c.segment(seg)
+		for i := len(results) - 1; i >= 0; i-- {
+			p := pools[i]
+			g[p] = c.Scope.Alloc(len(p.Vector))
+			rg[p] = c.Scope.Alloc(len(p.Vector))
+			if results[i].Constant(rg, g) {
+				delete(g, p)
+				delete(rg, p)
+				return
+			}
+			results[i].PropagateRGradient(stateUp, stateUpR, rg, g)
+			stateUp, stateUpR = g[p], rg[p]
+			delete(g, p)
+			delete(rg, p)
+		}
+	}
+	c.Initial.PropagateRGradient(stateUp, stateUpR, rg, g)
+}
+
+func (c *checkpointFoldRResult) segment(seg int) ([]*Variable, []RResult) {
+	if seg == len(c.Checkpoints)-1 {
+		return c.LastPools, c.LastSteps
+	}
+	start, end := segmentBounds(seg, c.Interval, len(c.Ins))
+	var pools []*Variable
+	var results []RResult
+	vec, vecR := c.Checkpoints[seg], c.RCheckpoints[seg]
+	c.Scope.Untaped(func() {
+		for _, in := range c.Ins[start:end] {
+			pool := &Variable{Vector: vec}
+			res := c.F(c.Scope.RResult(&RVariable{Variable: pool, ROutputVec: vecR}), in)
+			pools = append(pools, pool)
+			results = append(results, res)
+			vec, vecR = res.Output(), res.ROutput()
+		}
+	})
+	return pools, results
+}
+
+// A CheckpointFunc is like a ComposedFunc, but it only
+// stores the output of every Interval-th Func, rather
+// than every intermediate Result.
+// The Funcs between stored outputs are recomputed during
+// back propagation, one segment at a time, trading
+// computation for memory.
+//
+// The Funcs should be deterministic.
+// The resulting gradients are bit-for-bit equal to those
+// computed by a ComposedFunc, provided that each Func
+// back propagates through its input at most once.
+//
+// Like with CheckpointFold, the last segment is kept from
+// the forward pass and used to decide if the result is
+// constant.
+type CheckpointFunc struct {
+	Funcs []Func
+
+	// Interval is the number of Funcs per segment.
+	// If it is 0, the square root of the number of Funcs is
+	// used.
+	Interval int
+}
+
+// Apply applies the Funcs in order.
+func (c *CheckpointFunc) Apply(in Result) Result {
+	scope := scopeOf(in)
+	interval := checkpointInterval(c.Interval, len(c.Funcs))
+	res := &checkpointFuncResult{
+		Input:       in,
+		Funcs:       c.Funcs,
+		Interval:    interval,
+		Checkpoints: []linalg.Vector{in.Output()},
+	}
+	out := in
+	scope.Untaped(func() {
+		for i, f := range c.Funcs {
+			if i > 0 && i%interval == 0 {
+				res.Checkpoints = append(res.Checkpoints, out.Output())
+				res.LastPool = &Variable{Vector: out.Output()}
+				out = scope.Result(res.LastPool)
+			}
+			out = f.Apply(out)
+		}
+	})
+	res.OutputVec = out.Output()
+	res.LastOut = out
+	return scope.result(res)
+}
+
+type checkpointFuncResult struct {
+	scopeRef
+
+	OutputVec   linalg.Vector
+	Input       Result
+	Funcs       []Func
+	Interval    int
+	Checkpoints []linalg.Vector
+
+	// LastPool and LastOut store the last segment from the
+	// forward pass.
+	// LastPool is nil if there is only one segment.
+	LastPool *Variable
+	LastOut  Result
+}
+
+func (c *checkpointFuncResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *checkpointFuncResult) Constant(g Gradient) bool {
+	if !c.Input.Constant(g) {
+		return false
+	}
+	for seg := len(c.Checkpoints) - 1; seg >= 0; seg-- {
+		if _, out := c.segment(seg); !out.Constant(g) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *checkpointFuncResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if len(c.Funcs) == 0 {
+		c.Input.PropagateGradient(u, g)
+		return
+	}
+	for seg := len(c.Checkpoints) - 1; seg > 0; seg-- {
+		pool, out := c.segment(seg)
+		g[pool] = c.Scope.Alloc(len(pool.Vector))
+		if out.Constant(g) {
+			delete(g, pool)
+			return
+		}
+		out.PropagateGradient(u, g)
+		u = g[pool]
+		delete(g, pool)
+	}
+	if _, out := c.segment(0); !out.Constant(g) {
+		out.PropagateGradient(u, g)
+	}
+}
+
+// segment recomputes a segment of the Funcs.
+// The first segment is applied directly to the input,
+// while the others are applied to a pooled checkpoint.
+// The last segment is not recomputed.
+func (c *checkpointFuncResult) segment(seg int) (*Variable, Result) {
+	if seg == len(c.Checkpoints)-1 {
+		return c.LastPool, c.LastOut
+	}
+	start, end := segmentBounds(seg, c.Interval, len(c.Funcs))
+	var pool *Variable
+	var out Result
+	c.Scope.Untaped(func() {
+		if seg == 0 {
+			out = c.Input
+		} else {
+			pool = &Variable{Vector: c.Checkpoints[seg]}
+			out = c.Scope.Result(pool)
+		}
+		for _, f := range c.Funcs[start:end] {
+			out = f.Apply(out)
+		}
+	})
+	return pool, out
+}
+
+// A CheckpointRFunc is like a CheckpointFunc, but for
+// RFuncs.
+type CheckpointRFunc struct {
+	Funcs    []RFunc
+	Interval int
+}
+
+// Apply applies the Funcs in order.
+func (c *CheckpointRFunc) Apply(in Result) Result {
+	funcs := make([]Func, len(c.Funcs))
+	for i, f := range c.Funcs {
+		funcs[i] = f
+	}
+	return (&CheckpointFunc{Funcs: funcs, Interval: c.Interval}).Apply(in)
+}
+
+// ApplyR applies the RFuncs in order.
+func (c *CheckpointRFunc) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
+	interval := checkpointInterval(c.Interval, len(c.Funcs))
+	res := &checkpointFuncRResult{
+		Input:        in,
+		RV:           v,
+		Funcs:        c.Funcs,
+		Interval:     interval,
+		Checkpoints:  []linalg.Vector{in.Output()},
+		RCheckpoints: []linalg.Vector{in.ROutput()},
+	}
+	out := in
+	scope.Untaped(func() {
+		for i, f := range c.Funcs {
+			if i > 0 && i%interval == 0 {
+				res.Checkpoints = append(res.Checkpoints, out.Output())
+				res.RCheckpoints = append(res.RCheckpoints, out.ROutput())
+				res.LastPool = &Variable{Vector: out.Output()}
+				out = scope.RResult(&RVariable{
+					Variable:   res.LastPool,
+					ROutputVec: out.ROutput(),
+				})
+			}
+			out = f.ApplyR(v, out)
+		}
+	})
+	res.OutputVec = out.Output()
+	res.ROutputVec = out.ROutput()
+	res.LastOut = out
+	return scope.rresult(res)
+}
+
+type checkpointFuncRResult struct {
+	scopeRef
+
+	OutputVec    linalg.Vector
+	ROutputVec   linalg.Vector
+	Input        RResult
+	RV           RVector
+	Funcs        []RFunc
+	Interval     int
+	Checkpoints  []linalg.Vector
+	RCheckpoints []linalg.Vector
+	LastPool     *Variable
+	LastOut      RResult
+}
+
+func (c *checkpointFuncRResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *checkpointFuncRResult) ROutput() linalg.Vector {
+	return c.ROutputVec
+}
+
+func (c *checkpointFuncRResult) Constant(rg RGradient, g Gradient) bool {
+	if !c.Input.Constant(rg, g) {
+		return false
+	}
+	for seg := len(c.Checkpoints) - 1; seg >= 0; seg-- {
+		if _, out := c.segment(seg); !out.Constant(rg, g) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *checkpointFuncRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
+	g Gradient) {
+	if len(c.Funcs) == 0 {
+		c.Input.PropagateRGradient(u, uR, rg, g)
+		return
+	}
+	if g == nil && len(c.Checkpoints) > 1 {
+		g = Gradient{}
+	}
+	for seg := len(c.Checkpoints) - 1; seg > 0; seg-- {
+		pool, out := c.segment(seg)
+		g[pool] = c.Scope.Alloc(len(pool.Vector))
+		rg[pool] = c.Scope.Alloc(len(pool.Vector))
+		if out.Constant(rg, g) {
+			delete(g, pool)
+			delete(rg, pool)
+			return
+		}
+		out.PropagateRGradient(u, uR, rg, g)
+		u, uR = g[pool], rg[pool]
+		delete(g, pool)
+		delete(rg, pool)
+	}
+	if _, out := c.segment(0); !out.Constant(rg, g) {
+		out.PropagateRGradient(u, uR, rg, g)
+	}
+}
+
+func (c *checkpointFuncRResult) segment(seg int) (*Variable, RResult) {
+	if seg == len(c.Checkpoints)-1 {
+		return c.LastPool, c.LastOut
+	}
+	start, end := segmentBounds(seg, c.Interval, len(c.Funcs))
+	var pool *Variable
+	var out RResult
+	c.Scope.Untaped(func() {
+		if seg == 0 {
+			out = c.Input
+		} else {
+			pool = &Variable{Vector: c.Checkpoints[seg]}
+			out = c.Scope.RResult(&RVariable{Variable: pool, ROutputVec: c.RCheckpoints[seg]})
+		}
+		for _, f := range c.Funcs[start:end] {
+			out = f.ApplyR(c.RV, out)
+		}
+	})
+	return pool, out
+}
+
+func checkpointInterval(interval, n int) int {
+	if interval == 0 {
+		interval = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	if interval < 1 {
+		interval = 1
+	}
+	return interval
+}
+
+// lastSegmentStart returns the index of the first step
+// in the last segment.
+func lastSegmentStart(interval, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return (n - 1) / interval * interval
+}
+
+func segmentBounds(seg, interval, n int) (start, end int) {
+	start = seg * interval
+	end = start + interval
+	if end > n {
+		end = n
+	}
+	return
+}
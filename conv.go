@@ -0,0 +1,378 @@
+package autofunc
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A Conv2D is a Func, RFunc, and RBatcher which applies a
+// layer of two-dimensional convolutional filters to an
+// image.
+//
+// Images are packed row by row, with the channels of each
+// pixel stored contiguously.
+// Thus, an image with width w and depth d stores channel
+// z of pixel (x, y) at index (y*w+x)*d+z.
+// Outputs are packed the same way, with one channel per
+// filter.
+type Conv2D struct {
+	InputWidth  int
+	InputHeight int
+	InputDepth  int
+
+	FilterWidth  int
+	FilterHeight int
+	FilterCount  int
+
+	// StrideX and StrideY are the distances between
+	// neighboring filter positions.
+	// A value of 0 is treated as 1.
+	StrideX int
+	StrideY int
+
+	// PaddingX and PaddingY are the number of zero pixels
+	// added to each side of the input.
+	PaddingX int
+	PaddingY int
+
+	// Filters stores the filters one after another, each
+	// packed like an image with width FilterWidth, height
+	// FilterHeight, and depth InputDepth.
+	Filters *Variable
+
+	// Biases stores one bias per filter.
+	// It may be nil, in which case no biases are added.
+	Biases *Variable
+}
+
+// OutputWidth returns the width of output images.
+func (c *Conv2D) OutputWidth() int {
+	return c.windows().outWidth()
+}
+
+// OutputHeight returns the height of output images.
+func (c *Conv2D) OutputHeight() int {
+	return c.windows().outHeight()
+}
+
+// Apply applies the layer to a single image.
+func (c *Conv2D) Apply(in Result) Result {
+	return c.Batch(in, 1)
+}
+
+// ApplyR is like Apply but for RResults.
+func (c *Conv2D) ApplyR(v RVector, in RResult) RResult {
+	return c.BatchR(v, in, 1)
+}
+
+// Batch applies the layer to n packed images.
+func (c *Conv2D) Batch(in Result, n int) Result {
+	scope := scopeOf(in)
+	w := c.windows()
+	w.checkInput(in.Output(), n)
+	patches := w.im2col(scope, in.Output(), n)
+	out := c.emptyOutput(scope, n)
+	c.productShape(n).product(patches, c.Filters.Vector, out)
+	if c.Biases != nil {
+		addBiases(out, c.Biases.Vector)
+	}
+	return scope.result(&conv2DResult{
+		OutputVec: out,
+		Input:     in,
+		Patches:   patches,
+		Layer:     c,
+		N:         n,
+	})
+}
+
+// BatchR is like Batch but for RResults.
+func (c *Conv2D) BatchR(v RVector, in RResult, n int) RResult {
+	scope := scopeOfR(in)
+	w := c.windows()
+	w.checkInput(in.Output(), n)
+	filters := NewRVariable(c.Filters, v)
+	patches := w.im2col(scope, in.Output(), n)
+	patchesR := w.im2col(scope, in.ROutput(), n)
+
+	shape := c.productShape(n)
+	out := c.emptyOutput(scope, n)
+	outR := c.emptyOutput(scope, n)
+	shape.product(patches, filters.Output(), out)
+	shape.product(patchesR, filters.Output(), outR)
+	shape.product(patches, filters.ROutput(), outR)
+
+	var biases *RVariable
+	if c.Biases != nil {
+		biases = NewRVariable(c.Biases, v)
+		addBiases(out, biases.Output())
+		addBiases(outR, biases.ROutput())
+	}
+
+	return scope.rresult(&conv2DRResult{
+		OutputVec:  out,
+		ROutputVec: outR,
+		Input:      in,
+		Patches:    patches,
+		PatchesR:   patchesR,
+		Filters:    filters,
+		Biases:     biases,
+		Layer:      c,
+		N:          n,
+	})
+}
+
+func (c *Conv2D) windows() imageWindows {
+	w := imageWindows{
+		Width:    c.InputWidth,
+		Height:   c.InputHeight,
+		Depth:    c.InputDepth,
+		SpanX:    c.FilterWidth,
+		SpanY:    c.FilterHeight,
+		StrideX:  c.StrideX,
+		StrideY:  c.StrideY,
+		PaddingX: c.PaddingX,
+		PaddingY: c.PaddingY,
+	}
+	if w.StrideX == 0 {
+		w.StrideX = 1
+	}
+	if w.StrideY == 0 {
+		w.StrideY = 1
+	}
+	return w
+}
+
+// productShape returns the shape of the product between
+// the patch matrix and the transposed filter matrix.
+func (c *Conv2D) productShape(n int) MatMulShape {
+	w := c.windows()
+	return MatMulShape{
+		ARows:  n * w.outWidth() * w.outHeight(),
+		ACols:  w.patchSize(),
+		BRows:  c.FilterCount,
+		BCols:  w.patchSize(),
+		TransB: true,
+	}
+}
+
+func (c *Conv2D) emptyOutput(s *Scope, n int) linalg.Vector {
+	w := c.windows()
+	return s.Alloc(n * w.outWidth() * w.outHeight() * c.FilterCount)
+}
+
+type conv2DResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Input     Result
+	Patches   linalg.Vector
+	Layer     *Conv2D
+	N         int
+}
+
+func (c *conv2DResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *conv2DResult) Constant(g Gradient) bool {
+	if c.Layer.Biases != nil && !c.Layer.Biases.Constant(g) {
+		return false
+	}
+	return c.Layer.Filters.Constant(g) && c.Input.Constant(g)
+}
+
+func (c *conv2DResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
+	shape := c.Layer.productShape(c.N)
+	if filterGrad, ok := grad[c.Layer.Filters]; ok {
+		shape.gradB(upstream, c.Patches, filterGrad)
+	}
+	if c.Layer.Biases != nil {
+		if biasGrad, ok := grad[c.Layer.Biases]; ok {
+			sumBiasGrad(upstream, biasGrad)
+		}
+	}
+	if !c.Input.Constant(grad) {
+		patchGrad := c.Scope.Alloc(len(c.Patches))
+		shape.gradA(upstream, c.Layer.Filters.Vector, patchGrad)
+		c.Input.PropagateGradient(c.Layer.windows().col2im(c.Scope, patchGrad, c.N), grad)
+	}
+}
+
+type conv2DRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Input      RResult
+	Patches    linalg.Vector
+	PatchesR   linalg.Vector
+	Filters    *RVariable
+	Biases     *RVariable
+	Layer      *Conv2D
+	N          int
+}
+
+func (c *conv2DRResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *conv2DRResult) ROutput() linalg.Vector {
+	return c.ROutputVec
+}
+
+func (c *conv2DRResult) Constant(rg RGradient, g Gradient) bool {
+	if c.Biases != nil && !c.Biases.Constant(rg, g) {
+		return false
+	}
+	return c.Filters.Constant(rg, g) && c.Input.Constant(rg, g)
+}
+
+func (c *conv2DRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rgrad RGradient, grad Gradient) {
+	shape := c.Layer.productShape(c.N)
+	filters := c.Layer.Filters
+	if grad != nil {
+		if filterGrad, ok := grad[filters]; ok {
+			shape.gradB(upstream, c.Patches, filterGrad)
+		}
+	}
+	if filterGrad, ok := rgrad[filters]; ok {
+		shape.gradB(upstreamR, c.Patches, filterGrad)
+		shape.gradB(upstream, c.PatchesR, filterGrad)
+	}
+	if c.Biases != nil {
+		if biasGrad, ok := grad[c.Layer.Biases]; ok {
+			sumBiasGrad(upstream, biasGrad)
+		}
+		if biasGrad, ok := rgrad[c.Layer.Biases]; ok {
+			sumBiasGrad(upstreamR, biasGrad)
+		}
+	}
+	if !c.Input.Constant(rgrad, grad) {
+		w := c.Layer.windows()
+		patchGrad := c.Scope.Alloc(len(c.Patches))
+		patchGradR := c.Scope.Alloc(len(c.Patches))
+		shape.gradA(upstream, c.Filters.Output(), patchGrad)
+		shape.gradA(upstreamR, c.Filters.Output(), patchGradR)
+		shape.gradA(upstream, c.Filters.ROutput(), patchGradR)
+		c.Input.PropagateRGradient(w.col2im(c.Scope, patchGrad, c.N),
+			w.col2im(c.Scope, patchGradR, c.N), rgrad, grad)
+	}
+}
+
+// addBiases adds a bias to every channel of every pixel
+// in a packed batch of images.
+func addBiases(out, biases linalg.Vector) {
+	for i := range out {
+		out[i] += biases[i%len(biases)]
+	}
+}
+
+// sumBiasGrad accumulates the gradient of addBiases.
+func sumBiasGrad(upstream, biasGrad linalg.Vector) {
+	for i, x := range upstream {
+		biasGrad[i%len(biasGrad)] += x
+	}
+}
+
+// imageWindows describes the windows of an image which
+// are visited by a convolution or pooling operation.
+type imageWindows struct {
+	Width  int
+	Height int
+	Depth  int
+
+	SpanX int
+	SpanY int
+
+	StrideX int
+	StrideY int
+
+	PaddingX int
+	PaddingY int
+}
+
+func (w imageWindows) outWidth() int {
+	return (w.Width+2*w.PaddingX-w.SpanX)/w.StrideX + 1
+}
+
+func (w imageWindows) outHeight() int {
+	return (w.Height+2*w.PaddingY-w.SpanY)/w.StrideY + 1
+}
+
+func (w imageWindows) inputSize() int {
+	return w.Width * w.Height * w.Depth
+}
+
+func (w imageWindows) patchSize() int {
+	return w.SpanX * w.SpanY * w.Depth
+}
+
+func (w imageWindows) checkInput(in linalg.Vector, n int) {
+	if len(in) != n*w.inputSize() {
+		panic(fmt.Sprintf("input length should be %d but got %d", n*w.inputSize(),
+			len(in)))
+	}
+	if w.Width+2*w.PaddingX < w.SpanX || w.Height+2*w.PaddingY < w.SpanY {
+		panic("window is larger than padded input")
+	}
+}
+
+// offset returns the index of the first channel of the
+// pixel at position (fx, fy) in the window for output
+// pixel (x, y), or -1 if the pixel is in the padding.
+func (w imageWindows) offset(x, y, fx, fy int) int {
+	inX := x*w.StrideX - w.PaddingX + fx
+	inY := y*w.StrideY - w.PaddingY + fy
+	if inX < 0 || inY < 0 || inX >= w.Width || inY >= w.Height {
+		return -1
+	}
+	return (inY*w.Width + inX) * w.Depth
+}
+
+// im2col creates a matrix with one row per output pixel,
+// where each row is the packed window for that pixel.
+func (w imageWindows) im2col(s *Scope, in linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(n * w.outWidth() * w.outHeight() * w.patchSize())
+	var dst int
+	for sample := 0; sample < n; sample++ {
+		img := in[sample*w.inputSize() : (sample+1)*w.inputSize()]
+		for y := 0; y < w.outHeight(); y++ {
+			for x := 0; x < w.outWidth(); x++ {
+				for fy := 0; fy < w.SpanY; fy++ {
+					for fx := 0; fx < w.SpanX; fx++ {
+						if off := w.offset(x, y, fx, fy); off >= 0 {
+							copy(res[dst:dst+w.Depth], img[off:off+w.Depth])
+						}
+						dst += w.Depth
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// col2im computes the gradient of im2col, given the
+// gradient of its output.
+func (w imageWindows) col2im(s *Scope, patches linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(n * w.inputSize())
+	var src int
+	for sample := 0; sample < n; sample++ {
+		img := res[sample*w.inputSize() : (sample+1)*w.inputSize()]
+		for y := 0; y < w.outHeight(); y++ {
+			for x := 0; x < w.outWidth(); x++ {
+				for fy := 0; fy < w.SpanY; fy++ {
+					for fx := 0; fx < w.SpanX; fx++ {
+						if off := w.offset(x, y, fx, fy); off >= 0 {
+							img[off : off+w.Depth].Add(patches[src : src+w.Depth])
+						}
+						src += w.Depth
+					}
+				}
+			}
+		}
+	}
+	return res
+}
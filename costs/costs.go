@@ -0,0 +1,123 @@
+// Package costs implements differentiable cost functions
+// which compare the actual output of a function to some
+// expected output.
+package costs
+
+import "github.com/unixpickle/autofunc"
+
+// A Cost computes a scalar cost by comparing an actual
+// output to an expected output.
+type Cost interface {
+	// Cost returns a one-element Result containing the
+	// cost of a single sample.
+	Cost(actual, expected autofunc.Result) autofunc.Result
+
+	// BatchCost is like Cost, except that actual and
+	// expected contain n samples packed side-by-side, as
+	// documented for autofunc.Batcher.
+	// The result is the sum of the samples' costs.
+	BatchCost(actual, expected autofunc.Result, n int) autofunc.Result
+}
+
+// An RCost is a Cost which can also operate on RResults.
+type RCost interface {
+	Cost
+
+	// CostR is like Cost, but for RResults.
+	CostR(v autofunc.RVector, actual, expected autofunc.RResult) autofunc.RResult
+
+	// BatchCostR is like BatchCost, but for RResults.
+	BatchCostR(v autofunc.RVector, actual, expected autofunc.RResult,
+		n int) autofunc.RResult
+}
+
+// MeanSquaredCost is an RCost which computes the mean of
+// the squared differences between the actual and expected
+// components of each sample.
+type MeanSquaredCost struct{}
+
+func (m MeanSquaredCost) Cost(actual, expected autofunc.Result) autofunc.Result {
+	return m.BatchCost(actual, expected, 1)
+}
+
+func (m MeanSquaredCost) CostR(v autofunc.RVector, actual,
+	expected autofunc.RResult) autofunc.RResult {
+	return m.BatchCostR(v, actual, expected, 1)
+}
+
+func (_ MeanSquaredCost) BatchCost(actual, expected autofunc.Result, n int) autofunc.Result {
+	scale := meanScale(sampleSize(actual.Output(), expected.Output(), n))
+	diff := autofunc.Sub(actual, expected)
+	return autofunc.Scale(autofunc.SquaredNorm{}.Apply(diff), scale)
+}
+
+func (_ MeanSquaredCost) BatchCostR(v autofunc.RVector, actual, expected autofunc.RResult,
+	n int) autofunc.RResult {
+	scale := meanScale(sampleSize(actual.Output(), expected.Output(), n))
+	diff := autofunc.SubR(actual, expected)
+	return autofunc.ScaleR(autofunc.SquaredNorm{}.ApplyR(v, diff), scale)
+}
+
+// HingeCost is an RCost which computes the binary hinge
+// loss, max(0, 1-y*x), summed over the components of a
+// sample.
+// Each expected component y should be -1 or 1.
+type HingeCost struct{}
+
+func (h HingeCost) Cost(actual, expected autofunc.Result) autofunc.Result {
+	return h.BatchCost(actual, expected, 1)
+}
+
+func (h HingeCost) CostR(v autofunc.RVector, actual,
+	expected autofunc.RResult) autofunc.RResult {
+	return h.BatchCostR(v, actual, expected, 1)
+}
+
+func (_ HingeCost) BatchCost(actual, expected autofunc.Result, n int) autofunc.Result {
+	sampleSize(actual.Output(), expected.Output(), n)
+	margins := autofunc.Mul(actual, expected)
+	losses := autofunc.ReLU{}.Apply(autofunc.AddScaler(autofunc.Scale(margins, -1), 1))
+	return autofunc.SumAll(losses)
+}
+
+func (_ HingeCost) BatchCostR(v autofunc.RVector, actual, expected autofunc.RResult,
+	n int) autofunc.RResult {
+	sampleSize(actual.Output(), expected.Output(), n)
+	margins := autofunc.MulR(actual, expected)
+	losses := autofunc.ReLU{}.ApplyR(v, autofunc.AddScalerR(autofunc.ScaleR(margins, -1), 1))
+	return autofunc.SumAllR(losses)
+}
+
+// sampleSize returns the size of each of the n samples
+// packed into actual and expected, panicking if the two
+// vectors do not have matching, evenly divisible lengths.
+//
+// An empty batch (n = 0) must have empty vectors, and its
+// samples are said to have size 0.
+func sampleSize(actual, expected []float64, n int) int {
+	if len(actual) != len(expected) {
+		panic("actual and expected lengths must match")
+	}
+	if n < 0 {
+		panic("sample count must not be negative")
+	} else if n == 0 {
+		if len(actual) != 0 {
+			panic("empty batch must have empty vectors")
+		}
+		return 0
+	}
+	if len(actual)%n != 0 {
+		panic("sample count does not divide input length")
+	}
+	return len(actual) / n
+}
+
+// meanScale returns the factor which turns a sum over a
+// sample's components into a mean.
+// Empty samples have a mean of 0.
+func meanScale(sampleLen int) float64 {
+	if sampleLen == 0 {
+		return 0
+	}
+	return 1 / float64(sampleLen)
+}
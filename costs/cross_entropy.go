@@ -0,0 +1,284 @@
+package costs
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// CrossEntropyCost is an RCost which computes the cross
+// entropy -sum(y*ln(x)) between an expected distribution
+// y and an actual distribution x.
+//
+// The actual components must be probabilities.
+// Components where both x and y are 0 contribute 0 to the
+// cost and its derivatives.
+// For unnormalized log probabilities, use
+// SoftmaxCrossEntropyCost instead.
+type CrossEntropyCost struct{}
+
+func (c CrossEntropyCost) Cost(actual, expected autofunc.Result) autofunc.Result {
+	return c.BatchCost(actual, expected, 1)
+}
+
+func (c CrossEntropyCost) CostR(v autofunc.RVector, actual,
+	expected autofunc.RResult) autofunc.RResult {
+	return c.BatchCostR(v, actual, expected, 1)
+}
+
+func (_ CrossEntropyCost) BatchCost(actual, expected autofunc.Result, n int) autofunc.Result {
+	sampleSize(actual.Output(), expected.Output(), n)
+	return crossEntropy(actual, expected)
+}
+
+func (_ CrossEntropyCost) BatchCostR(v autofunc.RVector, actual, expected autofunc.RResult,
+	n int) autofunc.RResult {
+	sampleSize(actual.Output(), expected.Output(), n)
+	return crossEntropyR(actual, expected)
+}
+
+// SoftmaxCrossEntropyCost is like CrossEntropyCost, but
+// the actual components of each sample are logits which
+// are fed through a softmax before the cross entropy is
+// computed.
+// This is more numerically reliable than applying a
+// Softmax and a CrossEntropyCost in two steps.
+type SoftmaxCrossEntropyCost struct{}
+
+func (s SoftmaxCrossEntropyCost) Cost(actual, expected autofunc.Result) autofunc.Result {
+	return s.BatchCost(actual, expected, 1)
+}
+
+func (s SoftmaxCrossEntropyCost) CostR(v autofunc.RVector, actual,
+	expected autofunc.RResult) autofunc.RResult {
+	return s.BatchCostR(v, actual, expected, 1)
+}
+
+func (_ SoftmaxCrossEntropyCost) BatchCost(actual, expected autofunc.Result,
+	n int) autofunc.Result {
+	if sampleSize(actual.Output(), expected.Output(), n) == 0 {
+		// An empty batch has nothing to normalize.
+		return negDot(actual, expected)
+	}
+	logProbs := autofunc.PoolSplit(n, actual, func(samples []autofunc.Result) autofunc.Result {
+		for i, sample := range samples {
+			samples[i] = (&autofunc.LogSoftmax{}).Apply(sample)
+		}
+		return autofunc.Concat(samples...)
+	})
+	return negDot(logProbs, expected)
+}
+
+func (_ SoftmaxCrossEntropyCost) BatchCostR(v autofunc.RVector, actual,
+	expected autofunc.RResult, n int) autofunc.RResult {
+	if sampleSize(actual.Output(), expected.Output(), n) == 0 {
+		return negDotR(actual, expected)
+	}
+	logProbs := autofunc.PoolSplitR(n, actual, func(samples []autofunc.RResult) autofunc.RResult {
+		for i, sample := range samples {
+			samples[i] = (&autofunc.LogSoftmax{}).ApplyR(v, sample)
+		}
+		return autofunc.ConcatR(samples...)
+	})
+	return negDotR(logProbs, expected)
+}
+
+// BinaryCrossEntropyCost is an RCost which computes the
+// binary cross entropy -y*ln(x)-(1-y)*ln(1-x), summed over
+// every pair of expected and actual components y and x.
+//
+// Like in CrossEntropyCost, terms of the form 0*ln(0)
+// contribute 0.
+type BinaryCrossEntropyCost struct {
+	// Logits indicates that the actual components are
+	// logits which should be fed through a sigmoid.
+	// This is more numerically reliable than applying
+	// a Sigmoid and a BinaryCrossEntropyCost in two steps.
+	Logits bool
+}
+
+func (b BinaryCrossEntropyCost) Cost(actual, expected autofunc.Result) autofunc.Result {
+	return b.BatchCost(actual, expected, 1)
+}
+
+func (b BinaryCrossEntropyCost) CostR(v autofunc.RVector, actual,
+	expected autofunc.RResult) autofunc.RResult {
+	return b.BatchCostR(v, actual, expected, 1)
+}
+
+func (b BinaryCrossEntropyCost) BatchCost(actual, expected autofunc.Result,
+	n int) autofunc.Result {
+	sampleSize(actual.Output(), expected.Output(), n)
+	return autofunc.PoolAll([]autofunc.Result{actual, expected},
+		func(in []autofunc.Result) autofunc.Result {
+			actual, expected := in[0], in[1]
+			if !b.Logits {
+				return autofunc.Add(crossEntropy(actual, expected),
+					crossEntropy(oneMinus(actual), oneMinus(expected)))
+			}
+			logPos := autofunc.LogSigmoid{}.Apply(actual)
+			logNeg := autofunc.LogSigmoid{}.Apply(autofunc.Scale(actual, -1))
+			return autofunc.Add(negDot(logPos, expected), negDot(logNeg, oneMinus(expected)))
+		})
+}
+
+func (b BinaryCrossEntropyCost) BatchCostR(v autofunc.RVector, actual,
+	expected autofunc.RResult, n int) autofunc.RResult {
+	sampleSize(actual.Output(), expected.Output(), n)
+	return autofunc.PoolAllR([]autofunc.RResult{actual, expected},
+		func(in []autofunc.RResult) autofunc.RResult {
+			actual, expected := in[0], in[1]
+			if !b.Logits {
+				return autofunc.AddR(crossEntropyR(actual, expected),
+					crossEntropyR(oneMinusR(actual), oneMinusR(expected)))
+			}
+			logPos := autofunc.LogSigmoid{}.ApplyR(v, actual)
+			logNeg := autofunc.LogSigmoid{}.ApplyR(v, autofunc.ScaleR(actual, -1))
+			return autofunc.AddR(negDotR(logPos, expected),
+				negDotR(logNeg, oneMinusR(expected)))
+		})
+}
+
+func negDot(r1, r2 autofunc.Result) autofunc.Result {
+	return autofunc.Scale(autofunc.SumAll(autofunc.Mul(r1, r2)), -1)
+}
+
+func negDotR(r1, r2 autofunc.RResult) autofunc.RResult {
+	return autofunc.ScaleR(autofunc.SumAllR(autofunc.MulR(r1, r2)), -1)
+}
+
+func oneMinus(r autofunc.Result) autofunc.Result {
+	return autofunc.AddScaler(autofunc.Scale(r, -1), 1)
+}
+
+func oneMinusR(r autofunc.RResult) autofunc.RResult {
+	return autofunc.AddScalerR(autofunc.ScaleR(r, -1), 1)
+}
+
+// zeroTerm checks if y*ln(x) should be treated as 0
+// because x and y are both 0.
+func zeroTerm(x, y float64) bool {
+	return x == 0 && y == 0
+}
+
+type crossEntropyResult struct {
+	OutputVec linalg.Vector
+	Actual    autofunc.Result
+	Expected  autofunc.Result
+}
+
+// crossEntropy computes -sum(y*ln(x)).
+func crossEntropy(actual, expected autofunc.Result) autofunc.Result {
+	x, y := actual.Output(), expected.Output()
+	var sum float64
+	for i, yi := range y {
+		if !zeroTerm(x[i], yi) {
+			sum -= yi * math.Log(x[i])
+		}
+	}
+	return &crossEntropyResult{
+		OutputVec: linalg.Vector{sum},
+		Actual:    actual,
+		Expected:  expected,
+	}
+}
+
+func (c *crossEntropyResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *crossEntropyResult) Constant(g autofunc.Gradient) bool {
+	return c.Actual.Constant(g) && c.Expected.Constant(g)
+}
+
+func (c *crossEntropyResult) PropagateGradient(upstream linalg.Vector,
+	g autofunc.Gradient) {
+	x, y := c.Actual.Output(), c.Expected.Output()
+	u := upstream[0]
+	if !c.Actual.Constant(g) {
+		down := make(linalg.Vector, len(x))
+		for i, yi := range y {
+			if !zeroTerm(x[i], yi) {
+				down[i] = -u * yi / x[i]
+			}
+		}
+		c.Actual.PropagateGradient(down, g)
+	}
+	if !c.Expected.Constant(g) {
+		down := make(linalg.Vector, len(y))
+		for i, yi := range y {
+			if !zeroTerm(x[i], yi) {
+				down[i] = -u * math.Log(x[i])
+			}
+		}
+		c.Expected.PropagateGradient(down, g)
+	}
+}
+
+type crossEntropyRResult struct {
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Actual     autofunc.RResult
+	Expected   autofunc.RResult
+}
+
+// crossEntropyR is like crossEntropy, but for RResults.
+func crossEntropyR(actual, expected autofunc.RResult) autofunc.RResult {
+	x, y := actual.Output(), expected.Output()
+	xR, yR := actual.ROutput(), expected.ROutput()
+	var sum, sumR float64
+	for i, yi := range y {
+		if !zeroTerm(x[i], yi) {
+			sum -= yi * math.Log(x[i])
+			sumR -= yR[i]*math.Log(x[i]) + yi*xR[i]/x[i]
+		}
+	}
+	return &crossEntropyRResult{
+		OutputVec:  linalg.Vector{sum},
+		ROutputVec: linalg.Vector{sumR},
+		Actual:     actual,
+		Expected:   expected,
+	}
+}
+
+func (c *crossEntropyRResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *crossEntropyRResult) ROutput() linalg.Vector {
+	return c.ROutputVec
+}
+
+func (c *crossEntropyRResult) Constant(rg autofunc.RGradient, g autofunc.Gradient) bool {
+	return c.Actual.Constant(rg, g) && c.Expected.Constant(rg, g)
+}
+
+func (c *crossEntropyRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rg autofunc.RGradient, g autofunc.Gradient) {
+	x, y := c.Actual.Output(), c.Expected.Output()
+	xR, yR := c.Actual.ROutput(), c.Expected.ROutput()
+	u, uR := upstream[0], upstreamR[0]
+	if !c.Actual.Constant(rg, g) {
+		down := make(linalg.Vector, len(x))
+		downR := make(linalg.Vector, len(x))
+		for i, yi := range y {
+			if !zeroTerm(x[i], yi) {
+				down[i] = -u * yi / x[i]
+				downR[i] = -(uR*yi + u*yR[i] - u*yi*xR[i]/x[i]) / x[i]
+			}
+		}
+		c.Actual.PropagateRGradient(down, downR, rg, g)
+	}
+	if !c.Expected.Constant(rg, g) {
+		down := make(linalg.Vector, len(y))
+		downR := make(linalg.Vector, len(y))
+		for i, yi := range y {
+			if !zeroTerm(x[i], yi) {
+				down[i] = -u * math.Log(x[i])
+				downR[i] = -(uR*math.Log(x[i]) + u*xR[i]/x[i])
+			}
+		}
+		c.Expected.PropagateRGradient(down, downR, rg, g)
+	}
+}
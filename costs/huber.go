@@ -0,0 +1,138 @@
+package costs
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// HuberCost is an RCost which computes the Huber loss
+// of the differences between the actual and expected
+// components of each sample, summed over the components.
+//
+// The Huber loss of a difference d is d^2/2 when |d| is
+// at most Delta, and Delta*(|d|-Delta/2) otherwise.
+type HuberCost struct {
+	// Delta is the threshold at which the loss changes
+	// from quadratic to linear.
+	// If it is 0, a threshold of 1 is used.
+	Delta float64
+}
+
+func (h HuberCost) Cost(actual, expected autofunc.Result) autofunc.Result {
+	return h.BatchCost(actual, expected, 1)
+}
+
+func (h HuberCost) CostR(v autofunc.RVector, actual,
+	expected autofunc.RResult) autofunc.RResult {
+	return h.BatchCostR(v, actual, expected, 1)
+}
+
+func (h HuberCost) BatchCost(actual, expected autofunc.Result, n int) autofunc.Result {
+	sampleSize(actual.Output(), expected.Output(), n)
+	diff := autofunc.Sub(actual, expected)
+	outVec := make(linalg.Vector, len(diff.Output()))
+	derivs := make(linalg.Vector, len(outVec))
+	for i, d := range diff.Output() {
+		outVec[i], derivs[i], _ = h.huber(d)
+	}
+	return autofunc.SumAll(&huberResult{
+		OutputVec: outVec,
+		Derivs:    derivs,
+		Input:     diff,
+	})
+}
+
+func (h HuberCost) BatchCostR(v autofunc.RVector, actual, expected autofunc.RResult,
+	n int) autofunc.RResult {
+	sampleSize(actual.Output(), expected.Output(), n)
+	diff := autofunc.SubR(actual, expected)
+	diffR := diff.ROutput()
+	outVec := make(linalg.Vector, len(diff.Output()))
+	outVecR := make(linalg.Vector, len(outVec))
+	derivs := make(linalg.Vector, len(outVec))
+	derivsR := make(linalg.Vector, len(outVec))
+	for i, d := range diff.Output() {
+		var deriv2 float64
+		outVec[i], derivs[i], deriv2 = h.huber(d)
+		outVecR[i] = derivs[i] * diffR[i]
+		derivsR[i] = deriv2 * diffR[i]
+	}
+	return autofunc.SumAllR(&huberRResult{
+		OutputVec:  outVec,
+		ROutputVec: outVecR,
+		Derivs:     derivs,
+		DerivsR:    derivsR,
+		Input:      diff,
+	})
+}
+
+func (h HuberCost) huber(d float64) (loss, deriv, deriv2 float64) {
+	delta := h.Delta
+	if delta == 0 {
+		delta = 1
+	}
+	if math.Abs(d) <= delta {
+		return d * d / 2, d, 1
+	}
+	if d < 0 {
+		return delta * (-d - delta/2), -delta, 0
+	}
+	return delta * (d - delta/2), delta, 0
+}
+
+type huberResult struct {
+	OutputVec linalg.Vector
+	Derivs    linalg.Vector
+	Input     autofunc.Result
+}
+
+func (h *huberResult) Output() linalg.Vector {
+	return h.OutputVec
+}
+
+func (h *huberResult) Constant(g autofunc.Gradient) bool {
+	return h.Input.Constant(g)
+}
+
+func (h *huberResult) PropagateGradient(upstream linalg.Vector, g autofunc.Gradient) {
+	if !h.Input.Constant(g) {
+		for i, d := range h.Derivs {
+			upstream[i] *= d
+		}
+		h.Input.PropagateGradient(upstream, g)
+	}
+}
+
+type huberRResult struct {
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Derivs     linalg.Vector
+	DerivsR    linalg.Vector
+	Input      autofunc.RResult
+}
+
+func (h *huberRResult) Output() linalg.Vector {
+	return h.OutputVec
+}
+
+func (h *huberRResult) ROutput() linalg.Vector {
+	return h.ROutputVec
+}
+
+func (h *huberRResult) Constant(rg autofunc.RGradient, g autofunc.Gradient) bool {
+	return h.Input.Constant(rg, g)
+}
+
+func (h *huberRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rg autofunc.RGradient, g autofunc.Gradient) {
+	if !h.Input.Constant(rg, g) {
+		for i, d := range h.Derivs {
+			u := upstream[i]
+			upstream[i] = u * d
+			upstreamR[i] = upstreamR[i]*d + u*h.DerivsR[i]
+		}
+		h.Input.PropagateRGradient(upstream, upstreamR, rg, g)
+	}
+}
@@ -0,0 +1,208 @@
+package coststest
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/costs"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// costFunc is an autofunc.RFunc which applies a cost to
+// its input, using a fixed (but variable) expected vector.
+type costFunc struct {
+	Cost     costs.RCost
+	Expected *autofunc.Variable
+	N        int
+}
+
+func (c *costFunc) Apply(in autofunc.Result) autofunc.Result {
+	if c.N == 0 {
+		return c.Cost.Cost(in, c.Expected)
+	}
+	return c.Cost.BatchCost(in, c.Expected, c.N)
+}
+
+func (c *costFunc) ApplyR(rv autofunc.RVector, in autofunc.RResult) autofunc.RResult {
+	expected := autofunc.NewRVariable(c.Expected, rv)
+	if c.N == 0 {
+		return c.Cost.CostR(rv, in, expected)
+	}
+	return c.Cost.BatchCostR(rv, in, expected, c.N)
+}
+
+type costTest struct {
+	Cost     costs.RCost
+	Actual   linalg.Vector
+	Expected linalg.Vector
+}
+
+var costTests = map[string]costTest{
+	"MeanSquared": {
+		Cost:     costs.MeanSquaredCost{},
+		Actual:   []float64{1, -0.5, 0.3, 0.7, 2, -1},
+		Expected: []float64{0.5, 0.5, -0.2, 0.1, 1, -3},
+	},
+	"Hinge": {
+		Cost:     costs.HingeCost{},
+		Actual:   []float64{1.5, -0.5, 0.3, 0.7, -2, 0.2},
+		Expected: []float64{1, 1, -1, 1, -1, -1},
+	},
+	"Huber": {
+		Cost:     costs.HuberCost{Delta: 0.5},
+		Actual:   []float64{1.2, -0.5, 0.4, 0.7, 2, -1},
+		Expected: []float64{0.5, 0.4, -0.2, 0.1, 1.9, -3},
+	},
+	"CrossEntropy": {
+		Cost:     costs.CrossEntropyCost{},
+		Actual:   []float64{0.2, 0.5, 0.3, 0.1, 0.6, 0.3},
+		Expected: []float64{0, 1, 0, 0.2, 0.3, 0.5},
+	},
+	"SoftmaxCrossEntropy": {
+		Cost:     costs.SoftmaxCrossEntropyCost{},
+		Actual:   []float64{1, -0.5, 0.3, 0.7, 2, -1},
+		Expected: []float64{0, 1, 0, 0.2, 0.3, 0.5},
+	},
+	"BinaryCrossEntropy": {
+		Cost:     costs.BinaryCrossEntropyCost{},
+		Actual:   []float64{0.2, 0.5, 0.3, 0.1, 0.6, 0.9},
+		Expected: []float64{0, 1, 0, 0.2, 0.3, 0.5},
+	},
+	"BinaryCrossEntropyLogits": {
+		Cost:     costs.BinaryCrossEntropyCost{Logits: true},
+		Actual:   []float64{1, -0.5, 0.3, 0.7, 2, -1},
+		Expected: []float64{0, 1, 0, 0.2, 0.3, 0.5},
+	},
+}
+
+func TestCostChecks(t *testing.T) {
+	for name, test := range costTests {
+		for _, n := range []int{0, 2} {
+			t.Run(fmt.Sprintf("%s(n=%d)", name, n), func(t *testing.T) {
+				actual := &autofunc.Variable{Vector: test.Actual.Copy()}
+				expected := &autofunc.Variable{Vector: test.Expected.Copy()}
+				checker := &functest.RFuncChecker{
+					F:     &costFunc{Cost: test.Cost, Expected: expected, N: n},
+					Vars:  []*autofunc.Variable{actual, expected},
+					Input: actual,
+					RV: autofunc.RVector{
+						actual:   []float64{0.05, -0.1, 0.02, 0.03, 0.1, 0.07},
+						expected: []float64{0.01, 0.03, -0.02, 0.1, -0.05, 0.04},
+					},
+				}
+				checker.FullCheck(t)
+			})
+		}
+	}
+}
+
+func TestCostBatchOutputs(t *testing.T) {
+	for name, test := range costTests {
+		actual := &autofunc.Variable{Vector: test.Actual}
+		expected := &autofunc.Variable{Vector: test.Expected}
+		batchOut := test.Cost.BatchCost(actual, expected, 2).Output()
+		var sum float64
+		for i := 0; i < 2; i++ {
+			a := &autofunc.Variable{Vector: test.Actual[i*3 : (i+1)*3]}
+			e := &autofunc.Variable{Vector: test.Expected[i*3 : (i+1)*3]}
+			sum += test.Cost.Cost(a, e).Output()[0]
+		}
+		if len(batchOut) != 1 || math.Abs(batchOut[0]-sum) > 1e-8 {
+			t.Errorf("%s: expected %f but got %v", name, sum, batchOut)
+		}
+	}
+}
+
+func TestCostOutputs(t *testing.T) {
+	actual := &autofunc.Variable{Vector: []float64{1, -2, 0.5}}
+	expected := &autofunc.Variable{Vector: []float64{0, 1, 0}}
+	tests := []struct {
+		Cost     costs.Cost
+		Expected float64
+	}{
+		{costs.MeanSquaredCost{}, (1 + 9 + 0.25) / 3},
+		{costs.HingeCost{}, 1 + 3 + 1},
+		{costs.HuberCost{}, 0.5 + 2.5 + 0.125},
+		{costs.SoftmaxCrossEntropyCost{},
+			2 + math.Log(math.Exp(1)+math.Exp(-2)+math.Exp(0.5))},
+		{costs.BinaryCrossEntropyCost{Logits: true},
+			math.Log(1+math.Exp(1)) + math.Log(1+math.Exp(2)) + math.Log(1+math.Exp(0.5))},
+	}
+	for i, test := range tests {
+		actual := test.Cost.Cost(actual, expected).Output()[0]
+		if math.Abs(actual-test.Expected) > 1e-8 {
+			t.Errorf("test %d: expected %f but got %f", i, test.Expected, actual)
+		}
+	}
+}
+
+func TestSoftmaxCrossEntropyExtreme(t *testing.T) {
+	actual := &autofunc.Variable{Vector: []float64{800, -800, 0, -900}}
+	expected := &autofunc.Variable{Vector: []float64{0, 1, 1, 0}}
+	out := costs.SoftmaxCrossEntropyCost{}.BatchCost(actual, expected, 2).Output()[0]
+	exp := 1600 + math.Log(1+math.Exp(-900))
+	if math.Abs(out-exp) > 1e-8 {
+		t.Errorf("expected %f but got %f", exp, out)
+	}
+}
+
+func TestCostEmptyBatch(t *testing.T) {
+	for name, test := range costTests {
+		actual := &autofunc.Variable{Vector: linalg.Vector{}}
+		expected := &autofunc.Variable{Vector: linalg.Vector{}}
+		rv := autofunc.RVector{}
+		out := test.Cost.BatchCost(actual, expected, 0)
+		outR := test.Cost.BatchCostR(rv, autofunc.NewRVariable(actual, rv),
+			autofunc.NewRVariable(expected, rv), 0)
+		for _, vec := range []linalg.Vector{out.Output(), outR.Output(), outR.ROutput()} {
+			if len(vec) != 1 || vec[0] != 0 {
+				t.Errorf("%s: expected 0 but got %v", name, vec)
+			}
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for non-empty batch with n=0")
+			}
+		}()
+		v := &autofunc.Variable{Vector: linalg.Vector{1}}
+		costs.MeanSquaredCost{}.BatchCost(v, v, 0)
+	}()
+}
+
+func TestCrossEntropyZeroProbs(t *testing.T) {
+	actual := &autofunc.Variable{Vector: []float64{0, 0.5, 0.5, 1}}
+	expected := &autofunc.Variable{Vector: []float64{0, 1, 0.5, 1}}
+	rv := autofunc.RVector{
+		actual:   []float64{0.1, -0.1, 0.2, 0.3},
+		expected: []float64{0.2, 0.1, -0.3, 0.1},
+	}
+	for _, cost := range []costs.RCost{costs.CrossEntropyCost{},
+		costs.BinaryCrossEntropyCost{}} {
+		grad := autofunc.NewGradient([]*autofunc.Variable{actual, expected})
+		rgrad := autofunc.NewRGradient([]*autofunc.Variable{actual, expected})
+		out := cost.CostR(rv, autofunc.NewRVariable(actual, rv),
+			autofunc.NewRVariable(expected, rv))
+		out.PropagateRGradient([]float64{1}, []float64{1}, rgrad, grad)
+		vecs := []linalg.Vector{out.Output(), out.ROutput()}
+		for _, v := range []*autofunc.Variable{actual, expected} {
+			vecs = append(vecs, grad[v], rgrad[v])
+		}
+		for _, vec := range vecs {
+			for _, x := range vec {
+				if math.IsNaN(x) || math.IsInf(x, 0) {
+					t.Errorf("%T: unexpected non-finite values %v", cost, vecs)
+					break
+				}
+			}
+		}
+	}
+	out := costs.CrossEntropyCost{}.Cost(actual, expected).Output()[0]
+	if exp := 1.5 * math.Log(2); math.Abs(out-exp) > 1e-8 {
+		t.Errorf("expected %f but got %f", exp, out)
+	}
+}
@@ -0,0 +1,230 @@
+package autofunc
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+var debugMode int32
+
+// SetDebug enables or disables debug mode and returns the
+// previous setting.
+//
+// In debug mode, Debug and DebugR check Results for NaN
+// and infinite values.
+// To check every Result in a computation, attach its
+// inputs to a Scope with Debug set.
+// Debug mode is slow and should only be used to track down
+// numerical problems.
+func SetDebug(enabled bool) bool {
+	var val int32
+	if enabled {
+		val = 1
+	}
+	return atomic.SwapInt32(&debugMode, val) != 0
+}
+
+// Debug is equivalent to CheckFinite in debug mode (see
+// SetDebug) and returns r unchanged otherwise.
+// This makes it possible to leave checks in place and
+// toggle them globally.
+func Debug(r Result) Result {
+	if atomic.LoadInt32(&debugMode) == 0 {
+		return r
+	}
+	return CheckFinite(r)
+}
+
+// DebugR is like Debug, but for RResults.
+func DebugR(r RResult) RResult {
+	if atomic.LoadInt32(&debugMode) == 0 {
+		return r
+	}
+	return CheckFiniteR(r)
+}
+
+// A NonFiniteError is the panic value used when a NaN or
+// infinite value is found by a checked Result.
+type NonFiniteError struct {
+	// Op is the type of the Result which produced the bad
+	// vector, such as "*autofunc.resultQuotient".
+	// For upstream vectors, it is "caller" if the vector
+	// did not come from a checked Result in a Scope with
+	// Debug set.
+	Op string
+
+	// Vector is the name of the bad vector: "Output",
+	// "ROutput", "upstream", or "upstreamR".
+	Vector string
+
+	// Input is set for bad upstream vectors, and is the
+	// type of the Result to which Op passed the vector
+	// during back propagation, such as "*autofunc.Variable".
+	Input string
+
+	// Index is the index of the first bad component.
+	Index int
+
+	// Value is the first bad component.
+	Value float64
+
+	// Stack is the stack trace from when Op was checked,
+	// which is when it was created for built-in Results in
+	// a Scope with Debug set.
+	// It is nil if Op is "caller".
+	Stack []byte
+}
+
+// Error returns a description of the bad value.
+func (n *NonFiniteError) Error() string {
+	msg := fmt.Sprintf("%s: %s[%d] is %f", n.Op, n.Vector, n.Index, n.Value)
+	if n.Input != "" {
+		msg = fmt.Sprintf("%s: %s[%d] passed to %s is %f", n.Op, n.Vector, n.Index,
+			n.Input, n.Value)
+	}
+	if n.Stack != nil {
+		msg += "\n\ncreated at:\n" + string(n.Stack)
+	}
+	return msg
+}
+
+// CheckFinite checks the output of r for NaN and infinite
+// values, and returns a Result which checks the upstream
+// vectors passed to r during back propagation.
+// If a bad value is found, CheckFinite or back propagation
+// panics with a *NonFiniteError.
+//
+// Only r itself is checked.
+// To check every Result in a computation, attach its
+// inputs to a Scope with Debug set.
+func CheckFinite(r Result) Result {
+	return newFiniteChecker(scopeOf(r), r)
+}
+
+// CheckFiniteR is like CheckFinite, but for RResults.
+// It also checks r-outputs and r-upstream vectors.
+func CheckFiniteR(r RResult) RResult {
+	return newFiniteRChecker(scopeOfR(r), r)
+}
+
+// finiteInfo describes a checked Result.
+type finiteInfo struct {
+	op    string
+	stack []byte
+}
+
+func newFiniteInfo(r interface{}) finiteInfo {
+	buf := make([]byte, 1<<14)
+	return finiteInfo{
+		op:    fmt.Sprintf("%T", r),
+		stack: append([]byte{}, buf[:runtime.Stack(buf, false)]...),
+	}
+}
+
+func (f *finiteInfo) check(v linalg.Vector, vector string) {
+	checkFinite(v, f.op, vector, "", f.stack)
+}
+
+// checkUpstream checks an upstream vector passed to the
+// Result described by dest.
+// The vector is attributed to the innermost checked Result
+// which is back-propagating in s.
+func (s *Scope) checkUpstream(v linalg.Vector, vector string, dest *finiteInfo) {
+	src := &finiteInfo{op: "caller"}
+	if s != nil && s.Debug && len(s.propagating) > 0 {
+		src = s.propagating[len(s.propagating)-1]
+	}
+	checkFinite(v, src.op, vector, dest.op, src.stack)
+}
+
+// enter records that the Result described by f is
+// back-propagating in s, and returns a function to
+// undo this.
+func (s *Scope) enter(f *finiteInfo) func() {
+	if s == nil || !s.Debug {
+		return func() {}
+	}
+	s.propagating = append(s.propagating, f)
+	return func() {
+		s.propagating = s.propagating[:len(s.propagating)-1]
+	}
+}
+
+func checkFinite(v linalg.Vector, op, vector, input string, stack []byte) {
+	for i, x := range v {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			panic(&NonFiniteError{
+				Op:     op,
+				Vector: vector,
+				Input:  input,
+				Index:  i,
+				Value:  x,
+				Stack:  stack,
+			})
+		}
+	}
+}
+
+// finiteChecker wraps a Result, checking its output and
+// the upstream vectors it receives.
+type finiteChecker struct {
+	scopeRef
+	finiteInfo
+	Input Result
+}
+
+func newFiniteChecker(s *Scope, r Result) *finiteChecker {
+	res := &finiteChecker{scopeRef: scopeRef{s}, finiteInfo: newFiniteInfo(r), Input: r}
+	res.check(r.Output(), "Output")
+	return res
+}
+
+func (f *finiteChecker) Output() linalg.Vector {
+	return f.Input.Output()
+}
+
+func (f *finiteChecker) Constant(g Gradient) bool {
+	return f.Input.Constant(g)
+}
+
+func (f *finiteChecker) PropagateGradient(u linalg.Vector, g Gradient) {
+	f.Scope.checkUpstream(u, "upstream", &f.finiteInfo)
+	defer f.Scope.enter(&f.finiteInfo)()
+	f.Input.PropagateGradient(u, g)
+}
+
+type finiteRChecker struct {
+	scopeRef
+	finiteInfo
+	Input RResult
+}
+
+func newFiniteRChecker(s *Scope, r RResult) *finiteRChecker {
+	res := &finiteRChecker{scopeRef: scopeRef{s}, finiteInfo: newFiniteInfo(r), Input: r}
+	res.check(r.Output(), "Output")
+	res.check(r.ROutput(), "ROutput")
+	return res
+}
+
+func (f *finiteRChecker) Output() linalg.Vector {
+	return f.Input.Output()
+}
+
+func (f *finiteRChecker) ROutput() linalg.Vector {
+	return f.Input.ROutput()
+}
+
+func (f *finiteRChecker) Constant(rg RGradient, g Gradient) bool {
+	return f.Input.Constant(rg, g)
+}
+
+func (f *finiteRChecker) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	f.Scope.checkUpstream(u, "upstream", &f.finiteInfo)
+	f.Scope.checkUpstream(uR, "upstreamR", &f.finiteInfo)
+	defer f.Scope.enter(&f.finiteInfo)()
+	f.Input.PropagateRGradient(u, uR, rg, g)
+}
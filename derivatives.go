@@ -0,0 +1,85 @@
+package autofunc
+
+import "github.com/unixpickle/num-analysis/linalg"
+
+// JacobianVectorProduct computes the product of the
+// Jacobian of f (evaluated at input) and the vector v.
+// The Jacobian is taken with respect to every variable
+// that has an entry in v, including input itself.
+//
+// The result has one entry per output of f.
+func JacobianVectorProduct(f RFunc, input *Variable, v RVector) linalg.Vector {
+	return f.ApplyR(v, NewRVariable(input, v)).ROutput()
+}
+
+// VectorJacobianProduct computes the product of upstream
+// and the Jacobian of f (evaluated at input) with respect
+// to vars.
+// This is simply the gradient of the dot product between
+// upstream and the output of f.
+//
+// The upstream vector is not modified.
+func VectorJacobianProduct(f Func, input *Variable, vars []*Variable,
+	upstream linalg.Vector) Gradient {
+	grad := NewGradient(vars)
+	f.Apply(input).PropagateGradient(upstream.Copy(), grad)
+	return grad
+}
+
+// HessianVectorProduct computes the product of v and the
+// Hessian of f (evaluated at input) with respect to vars.
+//
+// The output of f must have exactly one component.
+// Variables in v which are not in vars are treated as
+// moving with r, but their rows of the Hessian are not
+// included in the result.
+func HessianVectorProduct(f RFunc, input *Variable, vars []*Variable, v RVector) Gradient {
+	out := f.ApplyR(v, NewRVariable(input, v))
+	if len(out.Output()) != 1 {
+		panic("output must have exactly one component")
+	}
+	rgrad := NewRGradient(vars)
+	out.PropagateRGradient(linalg.Vector{1}, linalg.Vector{0}, rgrad, nil)
+	return Gradient(rgrad)
+}
+
+// Jacobian computes the dense Jacobian of f (evaluated at
+// input) with respect to vars.
+// There is one Gradient per output of f, containing the
+// partial derivatives of that output.
+func Jacobian(f Func, input *Variable, vars []*Variable) []Gradient {
+	out := f.Apply(input)
+	outLen := len(out.Output())
+	res := make([]Gradient, outLen)
+	for i := range res {
+		upstream := make(linalg.Vector, outLen)
+		upstream[i] = 1
+		res[i] = NewGradient(vars)
+		out.PropagateGradient(upstream, res[i])
+	}
+	return res
+}
+
+// Hessian computes the dense Hessian of f (evaluated at
+// input) with respect to vars.
+// The output of f must have exactly one component.
+//
+// There is one Gradient per variable component, ordered
+// first by the variable's index in vars and then by the
+// component's index in the variable.
+// Each Gradient is a row of the Hessian, containing the
+// partial derivatives of the gradient entry for its
+// component.
+func Hessian(f RFunc, input *Variable, vars []*Variable) []Gradient {
+	var res []Gradient
+	v := RVector(NewGradient(vars))
+	for _, variable := range vars {
+		basis := v[variable]
+		for i := range basis {
+			basis[i] = 1
+			res = append(res, HessianVectorProduct(f, input, vars, v))
+			basis[i] = 0
+		}
+	}
+	return res
+}
@@ -0,0 +1,274 @@
+package autofunc
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// An Embedding maps integer IDs, such as tokens, to rows
+// of a matrix.
+//
+// Looking up a row is equivalent to multiplying the
+// matrix by a one-hot vector, but it takes time which is
+// proportional to the row size rather than to the number
+// of rows.
+//
+// Every row has a corresponding Variable (see Row) which
+// shares its memory with Matrix.
+// Row Variables are created on demand, so an Embedding
+// with a large vocabulary only pays for the rows which
+// are actually used.
+// A Gradient which contains these row Variables instead
+// of Matrix is sparse: back propagation only touches the
+// rows which were looked up, and Gradient.AddToVars and
+// the optimizers only update those rows.
+// If a Gradient contains both a row and Matrix, only the
+// row receives gradients.
+//
+// If Matrix or its vector is replaced, the existing row
+// Variables are discarded, since they no longer share
+// memory with Matrix.
+// Row Variables obtained before the change should not be
+// used with the Embedding afterwards.
+type Embedding struct {
+	// Matrix stores the rows one after another.
+	Matrix *Variable
+
+	// Cols is the number of components in each row.
+	Cols int
+
+	rowsLock   sync.Mutex
+	rows       map[int]*Variable
+	rowsMatrix linalg.Vector
+}
+
+// NewEmbedding creates an Embedding with normally
+// distributed entries.
+func NewEmbedding(rows, cols int) *Embedding {
+	res := &Embedding{
+		Matrix: &Variable{Vector: make(linalg.Vector, rows*cols)},
+		Cols:   cols,
+	}
+	for i := range res.Matrix.Vector {
+		res.Matrix.Vector[i] = rand.NormFloat64()
+	}
+	return res
+}
+
+// Parameters returns the variables in e.
+func (e *Embedding) Parameters() []*Variable {
+	return []*Variable{e.Matrix}
+}
+
+// NumRows returns the number of rows (i.e. the number of
+// valid IDs) in the embedding.
+func (e *Embedding) NumRows() int {
+	return len(e.Matrix.Vector) / e.Cols
+}
+
+// Row returns the Variable for the given row.
+// The Variable's vector is a slice of e.Matrix.Vector.
+//
+// The same Variable is returned every time, as long as
+// Matrix is not replaced.
+func (e *Embedding) Row(id int) *Variable {
+	if id < 0 || id >= e.NumRows() {
+		panic("row index out of range")
+	}
+	rows := e.lockRows()
+	defer e.rowsLock.Unlock()
+	if row, ok := rows[id]; ok {
+		return row
+	}
+	row := &Variable{Vector: e.Matrix.Vector[id*e.Cols : (id+1)*e.Cols]}
+	rows[id] = row
+	return row
+}
+
+// Rows returns the Variables for every row, in order.
+// These are suitable for the Vars of an optimizer which
+// should consume sparse gradients.
+//
+// Unlike Row and RowVars, Rows creates a Variable for
+// every row in the embedding.
+func (e *Embedding) Rows() []*Variable {
+	res := make([]*Variable, e.NumRows())
+	for i := range res {
+		res[i] = e.Row(i)
+	}
+	return res
+}
+
+// RowVars returns the row Variables for the given IDs,
+// without duplicates.
+// It can be used to create a sparse Gradient:
+//
+//	grad := NewGradient(e.RowVars(ids))
+func (e *Embedding) RowVars(ids []int) []*Variable {
+	var res []*Variable
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, e.Row(id))
+		}
+	}
+	return res
+}
+
+// Lookup returns the concatenation of the rows for the
+// given IDs.
+func (e *Embedding) Lookup(ids []int) Result {
+	return e.LookupIn(nil, ids)
+}
+
+// LookupIn is like Lookup, but the result belongs to the
+// given Scope, which may be nil.
+func (e *Embedding) LookupIn(s *Scope, ids []int) Result {
+	return s.result(&embeddingResult{
+		Embedding: e,
+		IDs:       ids,
+		OutputVec: e.lookupVec(s, e.Matrix.Vector, ids),
+	})
+}
+
+// LookupR is like Lookup, but for RResults.
+//
+// The r-output is taken from the rows' entries in v if
+// they are present, and from the entry for Matrix
+// otherwise.
+func (e *Embedding) LookupR(v RVector, ids []int) RResult {
+	return e.LookupInR(nil, v, ids)
+}
+
+// LookupInR is like LookupIn, but for RResults.
+func (e *Embedding) LookupInR(s *Scope, v RVector, ids []int) RResult {
+	res := &embeddingRResult{
+		Embedding:  e,
+		IDs:        ids,
+		OutputVec:  e.lookupVec(s, e.Matrix.Vector, ids),
+		ROutputVec: s.Alloc(len(ids) * e.Cols),
+	}
+	matrixR := v[e.Matrix]
+	rows := e.lockRows()
+	defer e.rowsLock.Unlock()
+	for i, id := range ids {
+		dest := res.ROutputVec[i*e.Cols : (i+1)*e.Cols]
+		if rowR, ok := v[rows[id]]; ok {
+			copy(dest, rowR)
+		} else if matrixR != nil {
+			copy(dest, matrixR[id*e.Cols:(id+1)*e.Cols])
+		}
+	}
+	return s.rresult(res)
+}
+
+func (e *Embedding) lookupVec(s *Scope, matrix linalg.Vector, ids []int) linalg.Vector {
+	res := s.Alloc(len(ids) * e.Cols)
+	for i, id := range ids {
+		copy(res[i*e.Cols:], matrix[id*e.Cols:(id+1)*e.Cols])
+	}
+	return res
+}
+
+// propagate adds the rows of upstream to the gradient
+// of each row.
+func (e *Embedding) propagate(ids []int, upstream linalg.Vector,
+	g map[*Variable]linalg.Vector) {
+	matrixGrad := g[e.Matrix]
+	rows := e.lockRows()
+	defer e.rowsLock.Unlock()
+	for i, id := range ids {
+		u := upstream[i*e.Cols : (i+1)*e.Cols]
+		if rowGrad, ok := g[rows[id]]; ok {
+			rowGrad.Add(u)
+		} else if matrixGrad != nil {
+			matrixGrad[id*e.Cols : (id+1)*e.Cols].Add(u)
+		}
+	}
+}
+
+// constant returns true if none of the rows for the ids
+// are in g.
+func (e *Embedding) constant(ids []int, g map[*Variable]linalg.Vector) bool {
+	if _, ok := g[e.Matrix]; ok {
+		return false
+	}
+	rows := e.lockRows()
+	defer e.rowsLock.Unlock()
+	for _, id := range ids {
+		if _, ok := g[rows[id]]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lockRows locks rowsLock and returns the row Variables
+// which have been created so far.
+// Rows which have not been created are missing from the
+// map, and thus cannot be in any Gradient or RVector.
+//
+// If Matrix has been replaced since the rows were
+// created, the rows are discarded.
+func (e *Embedding) lockRows() map[int]*Variable {
+	e.rowsLock.Lock()
+	vec := e.Matrix.Vector
+	if e.rows == nil || len(vec) != len(e.rowsMatrix) ||
+		(len(vec) > 0 && &vec[0] != &e.rowsMatrix[0]) {
+		e.rows = map[int]*Variable{}
+		e.rowsMatrix = vec
+	}
+	return e.rows
+}
+
+type embeddingResult struct {
+	scopeRef
+
+	Embedding *Embedding
+	IDs       []int
+	OutputVec linalg.Vector
+}
+
+func (e *embeddingResult) Output() linalg.Vector {
+	return e.OutputVec
+}
+
+func (e *embeddingResult) Constant(g Gradient) bool {
+	return e.Embedding.constant(e.IDs, g)
+}
+
+func (e *embeddingResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
+	e.Embedding.propagate(e.IDs, upstream, grad)
+}
+
+type embeddingRResult struct {
+	scopeRef
+
+	Embedding  *Embedding
+	IDs        []int
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+}
+
+func (e *embeddingRResult) Output() linalg.Vector {
+	return e.OutputVec
+}
+
+func (e *embeddingRResult) ROutput() linalg.Vector {
+	return e.ROutputVec
+}
+
+func (e *embeddingRResult) Constant(rg RGradient, g Gradient) bool {
+	return e.Embedding.constant(e.IDs, g) && e.Embedding.constant(e.IDs, rg)
+}
+
+func (e *embeddingRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rgrad RGradient, grad Gradient) {
+	if grad != nil {
+		e.Embedding.propagate(e.IDs, upstream, grad)
+	}
+	e.Embedding.propagate(e.IDs, upstreamR, rgrad)
+}
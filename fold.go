@@ -3,6 +3,8 @@ package autofunc
 import "github.com/unixpickle/num-analysis/linalg"
 
 type foldResult struct {
+	scopeRef
+
 	Pool         []*Variable
 	Intermediate []Result
 	Final        Result
@@ -17,15 +19,21 @@ type foldResult struct {
 // input to f() might be a different underlying object
 // than the previous output of f().
 func Fold(state Result, ins []Result, f func(state, in Result) Result) Result {
-	res := &foldResult{}
-	for _, in := range ins {
-		res.Intermediate = append(res.Intermediate, state)
-		pool := &Variable{Vector: state.Output()}
-		res.Pool = append(res.Pool, pool)
-		state = f(pool, in)
+	scope := scopeOf(state)
+	if scope == nil {
+		scope = scopeOf(ins...)
 	}
+	res := &foldResult{}
+	scope.Untaped(func() {
+		for _, in := range ins {
+			res.Intermediate = append(res.Intermediate, state)
+			pool := &Variable{Vector: state.Output()}
+			res.Pool = append(res.Pool, pool)
+			state = f(scope.Result(pool), in)
+		}
+	})
 	res.Final = state
-	return res
+	return scope.result(res)
 }
 
 func (f *foldResult) Output() linalg.Vector {
@@ -42,7 +50,7 @@ func (f *foldResult) Constant(g Gradient) bool {
 		}
 		if i > 0 {
 			p := f.Pool[i-1]
-			g[p] = make(linalg.Vector, len(p.Vector))
+			g[p] = f.Scope.Alloc(len(p.Vector))
 			c := f.Intermediate[i].Constant(g)
 			delete(g, p)
 			if c {
@@ -68,7 +76,7 @@ func (f *foldResult) PropagateGradient(u linalg.Vector, g Gradient) {
 			res = f.Intermediate[i]
 		}
 		p := f.Pool[i-1]
-		g[p] = make(linalg.Vector, len(p.Vector))
+		g[p] = f.Scope.Alloc(len(p.Vector))
 		if res.Constant(g) {
 			delete(g, p)
 			break
@@ -83,6 +91,8 @@ func (f *foldResult) PropagateGradient(u linalg.Vector, g Gradient) {
 }
 
 type foldRResult struct {
+	scopeRef
+
 	Pool         []*Variable
 	Intermediate []RResult
 	Final        RResult
@@ -90,18 +100,24 @@ type foldRResult struct {
 
 // FoldR is like Fold, but for RResults.
 func FoldR(state RResult, ins []RResult, f func(state, in RResult) RResult) RResult {
-	res := &foldRResult{}
-	for _, in := range ins {
-		res.Intermediate = append(res.Intermediate, state)
-		pool := &Variable{Vector: state.Output()}
-		res.Pool = append(res.Pool, pool)
-		state = f(&RVariable{
-			Variable:   pool,
-			ROutputVec: state.ROutput(),
-		}, in)
+	scope := scopeOfR(state)
+	if scope == nil {
+		scope = scopeOfR(ins...)
 	}
+	res := &foldRResult{}
+	scope.Untaped(func() {
+		for _, in := range ins {
+			res.Intermediate = append(res.Intermediate, state)
+			pool := &Variable{Vector: state.Output()}
+			res.Pool = append(res.Pool, pool)
+			state = f(scope.RResult(&RVariable{
+				Variable:   pool,
+				ROutputVec: state.ROutput(),
+			}), in)
+		}
+	})
 	res.Final = state
-	return res
+	return scope.rresult(res)
 }
 
 func (f *foldRResult) Output() linalg.Vector {
@@ -123,9 +139,9 @@ func (f *foldRResult) Constant(rg RGradient, g Gradient) bool {
 		if i > 0 {
 			p := f.Pool[i-1]
 			if g != nil {
-				g[p] = make(linalg.Vector, len(p.Vector))
+				g[p] = f.Scope.Alloc(len(p.Vector))
 			}
-			rg[p] = make(linalg.Vector, len(p.Vector))
+			rg[p] = f.Scope.Alloc(len(p.Vector))
 			c := f.Intermediate[i].Constant(rg, g)
 			if g != nil {
 				delete(g, p)
@@ -159,8 +175,8 @@ func (f *foldRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gr
 			res = f.Intermediate[i]
 		}
 		p := f.Pool[i-1]
-		g[p] = make(linalg.Vector, len(p.Vector))
-		rg[p] = make(linalg.Vector, len(p.Vector))
+		g[p] = f.Scope.Alloc(len(p.Vector))
+		rg[p] = f.Scope.Alloc(len(p.Vector))
 		if res.Constant(rg, g) {
 			delete(g, p)
 			delete(rg, p)
@@ -0,0 +1,182 @@
+package autofunc
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A HessianEstimator estimates properties of the Hessian
+// of a scalar-valued function using Hessian-vector
+// products computed with the R operator.
+type HessianEstimator struct {
+	// F is the function whose Hessian is estimated.
+	// Its output must have exactly one component.
+	F RFunc
+
+	// Input is the input to F.
+	Input *Variable
+
+	// Vars are the variables with respect to which the
+	// Hessian is taken.
+	Vars []*Variable
+
+	// Probes is the number of random probe vectors used
+	// by Trace and Diagonal.
+	// If it is 0, DefaultHessianProbes is used.
+	Probes int
+
+	// Gaussian indicates that probe vectors should be
+	// drawn from a standard normal distribution instead
+	// of a Rademacher distribution.
+	Gaussian bool
+
+	// Rand is the source of randomness.
+	// If it is nil, the math/rand top-level functions
+	// are used.
+	// Probe vectors are drawn in the order of Vars, so a
+	// seeded Rand gives reproducible estimates.
+	Rand *rand.Rand
+}
+
+// DefaultHessianProbes is the default number of probes
+// used by a HessianEstimator.
+const DefaultHessianProbes = 10
+
+// Trace estimates the trace of the Hessian using
+// Hutchinson's estimator, which averages v'*H*v over
+// random probe vectors v.
+func (h *HessianEstimator) Trace() float64 {
+	var sum float64
+	probes := h.probes()
+	for i := 0; i < probes; i++ {
+		v := h.probeVector()
+		sum += varMapDot(v, h.product(v))
+	}
+	return sum / float64(probes)
+}
+
+// Diagonal estimates the diagonal of the Hessian by
+// averaging v*(H*v) over random probe vectors v and
+// dividing component-wise by the average of v*v.
+func (h *HessianEstimator) Diagonal() Gradient {
+	numerator := NewGradient(h.Vars)
+	denominator := NewGradient(h.Vars)
+	for i := 0; i < h.probes(); i++ {
+		v := h.probeVector()
+		product := h.product(v)
+		for variable, vec := range v {
+			num := numerator[variable]
+			denom := denominator[variable]
+			prod := product[variable]
+			for j, x := range vec {
+				num[j] += x * prod[j]
+				denom[j] += x * x
+			}
+		}
+	}
+	for variable, num := range numerator {
+		for j, x := range denominator[variable] {
+			num[j] /= x
+		}
+	}
+	return numerator
+}
+
+// TopEigen estimates the eigenvalue of the Hessian with
+// the largest magnitude, along with a unit eigenvector,
+// using power iteration.
+//
+// Iteration stops after maxIters iterations or once the
+// eigenvalue estimate changes by less than tol (relative
+// to its magnitude).
+func (h *HessianEstimator) TopEigen(maxIters int, tol float64) (float64, Gradient) {
+	vec := h.gaussianVector()
+	normalizeVarMap(vec)
+
+	var eigVal float64
+	for i := 0; i < maxIters; i++ {
+		product := h.product(vec)
+		newVal := varMapDot(vec, product)
+		if normalizeVarMap(product) == 0 {
+			return 0, Gradient(vec)
+		}
+		vec = RVector(product)
+		converged := i > 0 && math.Abs(newVal-eigVal) <= tol*math.Abs(newVal)
+		eigVal = newVal
+		if converged {
+			break
+		}
+	}
+	return eigVal, Gradient(vec)
+}
+
+func (h *HessianEstimator) probes() int {
+	if h.Probes == 0 {
+		return DefaultHessianProbes
+	}
+	return h.Probes
+}
+
+func (h *HessianEstimator) product(v RVector) Gradient {
+	return HessianVectorProduct(h.F, h.Input, h.Vars, v)
+}
+
+func (h *HessianEstimator) probeVector() RVector {
+	if h.Gaussian {
+		return h.gaussianVector()
+	}
+	res := RVector(NewGradient(h.Vars))
+	for _, variable := range h.Vars {
+		vec := res[variable]
+		for i := range vec {
+			if h.uniform() < 0.5 {
+				vec[i] = -1
+			} else {
+				vec[i] = 1
+			}
+		}
+	}
+	return res
+}
+
+func (h *HessianEstimator) gaussianVector() RVector {
+	res := RVector(NewGradient(h.Vars))
+	for _, variable := range h.Vars {
+		vec := res[variable]
+		for i := range vec {
+			if h.Rand != nil {
+				vec[i] = h.Rand.NormFloat64()
+			} else {
+				vec[i] = rand.NormFloat64()
+			}
+		}
+	}
+	return res
+}
+
+func (h *HessianEstimator) uniform() float64 {
+	if h.Rand != nil {
+		return h.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+func varMapDot(m1, m2 map[*Variable]linalg.Vector) float64 {
+	var res float64
+	for v, vec := range m1 {
+		res += vec.DotFast(m2[v])
+	}
+	return res
+}
+
+// normalizeVarMap scales a variable map to have unit
+// norm and returns its original norm.
+func normalizeVarMap(m map[*Variable]linalg.Vector) float64 {
+	norm := math.Sqrt(varMapDot(m, m))
+	if norm != 0 {
+		scaleVariableMap(m, 1/norm)
+	}
+	return norm
+}
@@ -0,0 +1,354 @@
+package autofunc
+
+import (
+	"math"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A MaxPool2D is a Func, RFunc, and RBatcher which
+// computes the maximum of each channel over windows of
+// an image.
+//
+// Images are packed the same way as for Conv2D.
+// Padding pixels never contribute to a maximum.
+type MaxPool2D struct {
+	InputWidth  int
+	InputHeight int
+	InputDepth  int
+
+	SpanX int
+	SpanY int
+
+	// StrideX and StrideY are the distances between
+	// neighboring windows.
+	// A value of 0 is treated as the span, so that the
+	// windows do not overlap.
+	StrideX int
+	StrideY int
+
+	// PaddingX and PaddingY are the number of padding
+	// pixels added to each side of the input.
+	PaddingX int
+	PaddingY int
+}
+
+// OutputWidth returns the width of output images.
+func (m *MaxPool2D) OutputWidth() int {
+	return m.windows().outWidth()
+}
+
+// OutputHeight returns the height of output images.
+func (m *MaxPool2D) OutputHeight() int {
+	return m.windows().outHeight()
+}
+
+// Apply applies the pooling operation to a single image.
+func (m *MaxPool2D) Apply(in Result) Result {
+	return m.Batch(in, 1)
+}
+
+// ApplyR is like Apply but for RResults.
+func (m *MaxPool2D) ApplyR(v RVector, in RResult) RResult {
+	return m.BatchR(v, in, 1)
+}
+
+// Batch applies the pooling operation to n packed images.
+func (m *MaxPool2D) Batch(in Result, n int) Result {
+	scope := scopeOf(in)
+	w := m.windows()
+	w.checkInput(in.Output(), n)
+	indices := w.maxIndices(in.Output(), n)
+	return scope.result(&linearPoolResult{
+		OutputVec: gatherIndices(scope, in.Output(), indices),
+		Input:     in,
+		Backward: func(u linalg.Vector) linalg.Vector {
+			return scatterIndices(scope, u, indices, len(in.Output()))
+		},
+	})
+}
+
+// BatchR is like Batch but for RResults.
+func (m *MaxPool2D) BatchR(v RVector, in RResult, n int) RResult {
+	scope := scopeOfR(in)
+	w := m.windows()
+	w.checkInput(in.Output(), n)
+	indices := w.maxIndices(in.Output(), n)
+	return scope.rresult(&linearPoolRResult{
+		OutputVec:  gatherIndices(scope, in.Output(), indices),
+		ROutputVec: gatherIndices(scope, in.ROutput(), indices),
+		Input:      in,
+		Backward: func(u linalg.Vector) linalg.Vector {
+			return scatterIndices(scope, u, indices, len(in.Output()))
+		},
+	})
+}
+
+func (m *MaxPool2D) windows() imageWindows {
+	return poolWindows(m.InputWidth, m.InputHeight, m.InputDepth, m.SpanX, m.SpanY,
+		m.StrideX, m.StrideY, m.PaddingX, m.PaddingY)
+}
+
+// An AvgPool2D is a Func, RFunc, and RBatcher which
+// computes the mean of each channel over windows of an
+// image.
+//
+// Images are packed the same way as for Conv2D.
+// Padding pixels are treated as zeros, so every mean is
+// divided by SpanX*SpanY.
+type AvgPool2D struct {
+	InputWidth  int
+	InputHeight int
+	InputDepth  int
+
+	SpanX int
+	SpanY int
+
+	// StrideX and StrideY are the distances between
+	// neighboring windows.
+	// A value of 0 is treated as the span, so that the
+	// windows do not overlap.
+	StrideX int
+	StrideY int
+
+	// PaddingX and PaddingY are the number of zero pixels
+	// added to each side of the input.
+	PaddingX int
+	PaddingY int
+}
+
+// OutputWidth returns the width of output images.
+func (a *AvgPool2D) OutputWidth() int {
+	return a.windows().outWidth()
+}
+
+// OutputHeight returns the height of output images.
+func (a *AvgPool2D) OutputHeight() int {
+	return a.windows().outHeight()
+}
+
+// Apply applies the pooling operation to a single image.
+func (a *AvgPool2D) Apply(in Result) Result {
+	return a.Batch(in, 1)
+}
+
+// ApplyR is like Apply but for RResults.
+func (a *AvgPool2D) ApplyR(v RVector, in RResult) RResult {
+	return a.BatchR(v, in, 1)
+}
+
+// Batch applies the pooling operation to n packed images.
+func (a *AvgPool2D) Batch(in Result, n int) Result {
+	scope := scopeOf(in)
+	w := a.windows()
+	w.checkInput(in.Output(), n)
+	return scope.result(&linearPoolResult{
+		OutputVec: w.average(scope, in.Output(), n),
+		Input:     in,
+		Backward: func(u linalg.Vector) linalg.Vector {
+			return w.averageGrad(scope, u, n)
+		},
+	})
+}
+
+// BatchR is like Batch but for RResults.
+func (a *AvgPool2D) BatchR(v RVector, in RResult, n int) RResult {
+	scope := scopeOfR(in)
+	w := a.windows()
+	w.checkInput(in.Output(), n)
+	return scope.rresult(&linearPoolRResult{
+		OutputVec:  w.average(scope, in.Output(), n),
+		ROutputVec: w.average(scope, in.ROutput(), n),
+		Input:      in,
+		Backward: func(u linalg.Vector) linalg.Vector {
+			return w.averageGrad(scope, u, n)
+		},
+	})
+}
+
+func (a *AvgPool2D) windows() imageWindows {
+	return poolWindows(a.InputWidth, a.InputHeight, a.InputDepth, a.SpanX, a.SpanY,
+		a.StrideX, a.StrideY, a.PaddingX, a.PaddingY)
+}
+
+// linearPoolResult is the result of a pooling operation
+// which is linear in its input, at least locally.
+type linearPoolResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Input     Result
+
+	// Backward maps an upstream vector to a downstream
+	// vector by applying the transposed operation.
+	Backward func(upstream linalg.Vector) linalg.Vector
+}
+
+func (l *linearPoolResult) Output() linalg.Vector {
+	return l.OutputVec
+}
+
+func (l *linearPoolResult) Constant(g Gradient) bool {
+	return l.Input.Constant(g)
+}
+
+func (l *linearPoolResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
+	if !l.Input.Constant(grad) {
+		l.Input.PropagateGradient(l.Backward(upstream), grad)
+	}
+}
+
+type linearPoolRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Input      RResult
+	Backward   func(upstream linalg.Vector) linalg.Vector
+}
+
+func (l *linearPoolRResult) Output() linalg.Vector {
+	return l.OutputVec
+}
+
+func (l *linearPoolRResult) ROutput() linalg.Vector {
+	return l.ROutputVec
+}
+
+func (l *linearPoolRResult) Constant(rg RGradient, g Gradient) bool {
+	return l.Input.Constant(rg, g)
+}
+
+func (l *linearPoolRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rgrad RGradient, grad Gradient) {
+	if !l.Input.Constant(rgrad, grad) {
+		l.Input.PropagateRGradient(l.Backward(upstream), l.Backward(upstreamR),
+			rgrad, grad)
+	}
+}
+
+func poolWindows(width, height, depth, spanX, spanY, strideX, strideY, padX,
+	padY int) imageWindows {
+	if strideX == 0 {
+		strideX = spanX
+	}
+	if strideY == 0 {
+		strideY = spanY
+	}
+	return imageWindows{
+		Width:    width,
+		Height:   height,
+		Depth:    depth,
+		SpanX:    spanX,
+		SpanY:    spanY,
+		StrideX:  strideX,
+		StrideY:  strideY,
+		PaddingX: padX,
+		PaddingY: padY,
+	}
+}
+
+// maxIndices finds the index of the maximum input for
+// each output, or -1 if a window is entirely padding.
+func (w imageWindows) maxIndices(in linalg.Vector, n int) []int {
+	outPixels := w.outWidth() * w.outHeight()
+	res := make([]int, 0, n*outPixels*w.Depth)
+	for sample := 0; sample < n; sample++ {
+		start := sample * w.inputSize()
+		for y := 0; y < w.outHeight(); y++ {
+			for x := 0; x < w.outWidth(); x++ {
+				for z := 0; z < w.Depth; z++ {
+					maxIdx := -1
+					maxVal := math.Inf(-1)
+					for fy := 0; fy < w.SpanY; fy++ {
+						for fx := 0; fx < w.SpanX; fx++ {
+							off := w.offset(x, y, fx, fy)
+							if off < 0 {
+								continue
+							}
+							idx := start + off + z
+							if maxIdx < 0 || in[idx] > maxVal {
+								maxIdx = idx
+								maxVal = in[idx]
+							}
+						}
+					}
+					res = append(res, maxIdx)
+				}
+			}
+		}
+	}
+	return res
+}
+
+func gatherIndices(s *Scope, in linalg.Vector, indices []int) linalg.Vector {
+	res := s.Alloc(len(indices))
+	for i, idx := range indices {
+		if idx >= 0 {
+			res[i] = in[idx]
+		}
+	}
+	return res
+}
+
+func scatterIndices(s *Scope, upstream linalg.Vector, indices []int,
+	size int) linalg.Vector {
+	res := s.Alloc(size)
+	for i, idx := range indices {
+		if idx >= 0 {
+			res[idx] += upstream[i]
+		}
+	}
+	return res
+}
+
+func (w imageWindows) average(s *Scope, in linalg.Vector, n int) linalg.Vector {
+	outPixels := w.outWidth() * w.outHeight()
+	res := s.Alloc(n * outPixels * w.Depth)
+	scale := 1 / float64(w.SpanX*w.SpanY)
+	var dst int
+	for sample := 0; sample < n; sample++ {
+		img := in[sample*w.inputSize() : (sample+1)*w.inputSize()]
+		for y := 0; y < w.outHeight(); y++ {
+			for x := 0; x < w.outWidth(); x++ {
+				out := res[dst : dst+w.Depth]
+				for fy := 0; fy < w.SpanY; fy++ {
+					for fx := 0; fx < w.SpanX; fx++ {
+						if off := w.offset(x, y, fx, fy); off >= 0 {
+							out.Add(img[off : off+w.Depth])
+						}
+					}
+				}
+				out.Scale(scale)
+				dst += w.Depth
+			}
+		}
+	}
+	return res
+}
+
+func (w imageWindows) averageGrad(s *Scope, upstream linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(n * w.inputSize())
+	scale := 1 / float64(w.SpanX*w.SpanY)
+	var src int
+	for sample := 0; sample < n; sample++ {
+		img := res[sample*w.inputSize() : (sample+1)*w.inputSize()]
+		for y := 0; y < w.outHeight(); y++ {
+			for x := 0; x < w.outWidth(); x++ {
+				for fy := 0; fy < w.SpanY; fy++ {
+					for fx := 0; fx < w.SpanX; fx++ {
+						off := w.offset(x, y, fx, fy)
+						if off < 0 {
+							continue
+						}
+						for z := 0; z < w.Depth; z++ {
+							img[off+z] += upstream[src+z] * scale
+						}
+					}
+				}
+				src += w.Depth
+			}
+		}
+	}
+	return res
+}
@@ -11,19 +11,21 @@ type LinAdd struct {
 // Apply applies the addition operation to
 // the input.
 func (l LinAdd) Apply(in Result) Result {
-	outVec := make(linalg.Vector, len(l.Var.Vector))
+	scope := scopeOf(in)
+	outVec := scope.Alloc(len(l.Var.Vector))
 	for i, x := range in.Output() {
 		outVec[i] = x + l.Var.Vector[i]
 	}
-	return &linAddResult{
+	return scope.result(&linAddResult{
 		OutputVec: outVec,
 		SumVar:    l.Var,
 		Input:     in,
-	}
+	})
 }
 
 // ApplyR is like Apply but for RResults.
 func (l LinAdd) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	rVar := NewRVariable(l.Var, v)
 
 	value1 := rVar.Output()
@@ -31,8 +33,8 @@ func (l LinAdd) ApplyR(v RVector, in RResult) RResult {
 	value1R := rVar.ROutput()
 	value2R := in.ROutput()
 
-	sum := make(linalg.Vector, len(value1))
-	sumR := make(linalg.Vector, len(value1))
+	sum := scope.Alloc(len(value1))
+	sumR := scope.Alloc(len(value1))
 
 	for i, x := range value1 {
 		sum[i] = x + value2[i]
@@ -41,15 +43,17 @@ func (l LinAdd) ApplyR(v RVector, in RResult) RResult {
 		sumR[i] = x + value2R[i]
 	}
 
-	return &linAddRResult{
+	return scope.rresult(&linAddRResult{
 		OutputVec:  sum,
 		ROutputVec: sumR,
 		SumVar:     rVar,
 		Input:      in,
-	}
+	})
 }
 
 type linAddResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	SumVar    *Variable
 	Input     Result
@@ -73,6 +77,8 @@ func (l *linAddResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type linAddRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	SumVar     *RVariable
@@ -7,6 +7,8 @@ import (
 )
 
 type matMulResult struct {
+	scopeRef
+
 	MatIn  Result
 	MatVar *Variable
 	Res    Result
@@ -26,6 +28,7 @@ func MatMulVec(mat Result, rows, cols int, vec Result) Result {
 // by dividing its length by the number of columns in the
 // left matrix.
 func MatMulVecs(mat Result, rows, cols int, vecs Result) Result {
+	scope := scopeOf(mat, vecs)
 	if len(mat.Output()) != rows*cols {
 		panic("invalid matrix data size")
 	}
@@ -39,11 +42,15 @@ func MatMulVecs(mat Result, rows, cols int, vecs Result) Result {
 		Rows: rows,
 		Cols: cols,
 	}
-	return &matMulResult{
+	var res Result
+	scope.Untaped(func() {
+		res = lt.Batch(scope.Result(vecs), n)
+	})
+	return scope.result(&matMulResult{
 		MatIn:  mat,
 		MatVar: v,
-		Res:    lt.Batch(vecs, n),
-	}
+		Res:    res,
+	})
 }
 
 func (m *matMulResult) Output() linalg.Vector {
@@ -57,7 +64,7 @@ func (m *matMulResult) Constant(g Gradient) bool {
 func (m *matMulResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 	matConst := m.MatIn.Constant(grad)
 	if !matConst {
-		grad[m.MatVar] = make(linalg.Vector, len(m.MatVar.Vector))
+		grad[m.MatVar] = m.Scope.Alloc(len(m.MatVar.Vector))
 	}
 	m.Res.PropagateGradient(upstream, grad)
 	if !matConst {
@@ -68,6 +75,8 @@ func (m *matMulResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type matMulRResult struct {
+	scopeRef
+
 	MatIn  RResult
 	MatVar *Variable
 	Res    RResult
@@ -80,6 +89,7 @@ func MatMulVecR(mat RResult, rows, cols int, vec RResult) RResult {
 
 // MatMulVecsR is like MatMulVecs, but for RResults.
 func MatMulVecsR(mat RResult, rows, cols int, vecs RResult) RResult {
+	scope := scopeOfR(mat, vecs)
 	if len(mat.Output()) != rows*cols {
 		panic("invalid matrix data size")
 	}
@@ -93,11 +103,15 @@ func MatMulVecsR(mat RResult, rows, cols int, vecs RResult) RResult {
 		Rows: rows,
 		Cols: cols,
 	}
-	return &matMulRResult{
+	var res RResult
+	scope.Untaped(func() {
+		res = lt.BatchR(RVector{v: mat.ROutput()}, scope.RResult(vecs), n)
+	})
+	return scope.rresult(&matMulRResult{
 		MatIn:  mat,
 		MatVar: v,
-		Res:    lt.BatchR(RVector{v: mat.ROutput()}, vecs, n),
-	}
+		Res:    res,
+	})
 }
 
 func (m *matMulRResult) Output() linalg.Vector {
@@ -119,8 +133,8 @@ func (m *matMulRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 		if grad == nil {
 			grad = Gradient{}
 		}
-		grad[m.MatVar] = make(linalg.Vector, len(m.MatVar.Vector))
-		rgrad[m.MatVar] = make(linalg.Vector, len(m.MatVar.Vector))
+		grad[m.MatVar] = m.Scope.Alloc(len(m.MatVar.Vector))
+		rgrad[m.MatVar] = m.Scope.Alloc(len(m.MatVar.Vector))
 	}
 	m.Res.PropagateRGradient(upstream, upstreamR, rgrad, grad)
 	if !matConst {
@@ -132,7 +146,229 @@ func (m *matMulRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	}
 }
 
+// A MatMulShape describes the layout of the matrices
+// passed to MatMul.
+type MatMulShape struct {
+	// ARows and ACols are the dimensions of the row-major
+	// left matrix, before it is transposed.
+	ARows int
+	ACols int
+
+	// BRows and BCols are the dimensions of the row-major
+	// right matrix, before it is transposed.
+	BRows int
+	BCols int
+
+	// TransA and TransB indicate whether the left and
+	// right matrices should be transposed before they
+	// are multiplied.
+	TransA bool
+	TransB bool
+}
+
+// OutRows returns the number of rows in the product.
+func (m MatMulShape) OutRows() int {
+	if m.TransA {
+		return m.ACols
+	}
+	return m.ARows
+}
+
+// OutCols returns the number of columns in the product.
+func (m MatMulShape) OutCols() int {
+	if m.TransB {
+		return m.BRows
+	}
+	return m.BCols
+}
+
+func (m MatMulShape) validate(a, b linalg.Vector) {
+	if len(a) != m.ARows*m.ACols {
+		panic("invalid left matrix size")
+	}
+	if len(b) != m.BRows*m.BCols {
+		panic("invalid right matrix size")
+	}
+	innerA, innerB := m.ACols, m.BRows
+	if m.TransA {
+		innerA = m.ARows
+	}
+	if m.TransB {
+		innerB = m.BCols
+	}
+	if innerA != innerB {
+		panic("inner dimensions do not match")
+	}
+}
+
+// product adds the product of a and b to out.
+func (m MatMulShape) product(a, b, out linalg.Vector) {
+	matMulGemm(m.TransA, m.general(a, true), m.TransB, m.general(b, false),
+		m.outGeneral(out))
+}
+
+// gradA adds the gradient of the left matrix to dst,
+// given the upstream gradient u and the right matrix b.
+func (m MatMulShape) gradA(u, b, dst linalg.Vector) {
+	if m.TransA {
+		matMulGemm(m.TransB, m.general(b, false), true, m.outGeneral(u),
+			m.general(dst, true))
+	} else {
+		matMulGemm(false, m.outGeneral(u), !m.TransB, m.general(b, false),
+			m.general(dst, true))
+	}
+}
+
+// gradB adds the gradient of the right matrix to dst,
+// given the upstream gradient u and the left matrix a.
+func (m MatMulShape) gradB(u, a, dst linalg.Vector) {
+	if m.TransB {
+		matMulGemm(true, m.outGeneral(u), m.TransA, m.general(a, true),
+			m.general(dst, false))
+	} else {
+		matMulGemm(!m.TransA, m.general(a, true), false, m.outGeneral(u),
+			m.general(dst, false))
+	}
+}
+
+func (m MatMulShape) general(data linalg.Vector, left bool) blas64.General {
+	if left {
+		return blas64.General{Rows: m.ARows, Cols: m.ACols, Stride: m.ACols, Data: data}
+	}
+	return blas64.General{Rows: m.BRows, Cols: m.BCols, Stride: m.BCols, Data: data}
+}
+
+func (m MatMulShape) outGeneral(data linalg.Vector) blas64.General {
+	return blas64.General{
+		Rows:   m.OutRows(),
+		Cols:   m.OutCols(),
+		Stride: m.OutCols(),
+		Data:   data,
+	}
+}
+
+func matMulGemm(transX bool, x blas64.General, transY bool, y, out blas64.General) {
+	tX, tY := blas.NoTrans, blas.NoTrans
+	if transX {
+		tX = blas.Trans
+	}
+	if transY {
+		tY = blas.Trans
+	}
+	blas64.Gemm(tX, tY, 1, x, y, 1, out)
+}
+
+type matProductResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	A         Result
+	B         Result
+	Shape     MatMulShape
+}
+
+// MatMul multiplies two row-major matrices, optionally
+// transposing either of them first.
+// The result is a row-major matrix with shape.OutRows()
+// rows and shape.OutCols() columns.
+func MatMul(a, b Result, shape MatMulShape) Result {
+	scope := scopeOf(a, b)
+	shape.validate(a.Output(), b.Output())
+	out := scope.Alloc(shape.OutRows() * shape.OutCols())
+	shape.product(a.Output(), b.Output(), out)
+	return scope.result(&matProductResult{
+		OutputVec: out,
+		A:         a,
+		B:         b,
+		Shape:     shape,
+	})
+}
+
+func (m *matProductResult) Output() linalg.Vector {
+	return m.OutputVec
+}
+
+func (m *matProductResult) Constant(g Gradient) bool {
+	return m.A.Constant(g) && m.B.Constant(g)
+}
+
+func (m *matProductResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if !m.A.Constant(g) {
+		downstream := m.Scope.Alloc(len(m.A.Output()))
+		m.Shape.gradA(u, m.B.Output(), downstream)
+		m.A.PropagateGradient(downstream, g)
+	}
+	if !m.B.Constant(g) {
+		downstream := m.Scope.Alloc(len(m.B.Output()))
+		m.Shape.gradB(u, m.A.Output(), downstream)
+		m.B.PropagateGradient(downstream, g)
+	}
+}
+
+type matProductRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	A          RResult
+	B          RResult
+	Shape      MatMulShape
+}
+
+// MatMulR is like MatMul, but for RResults.
+func MatMulR(a, b RResult, shape MatMulShape) RResult {
+	scope := scopeOfR(a, b)
+	shape.validate(a.Output(), b.Output())
+	outSize := shape.OutRows() * shape.OutCols()
+	out := scope.Alloc(outSize)
+	outR := scope.Alloc(outSize)
+	shape.product(a.Output(), b.Output(), out)
+	shape.product(a.ROutput(), b.Output(), outR)
+	shape.product(a.Output(), b.ROutput(), outR)
+	return scope.rresult(&matProductRResult{
+		OutputVec:  out,
+		ROutputVec: outR,
+		A:          a,
+		B:          b,
+		Shape:      shape,
+	})
+}
+
+func (m *matProductRResult) Output() linalg.Vector {
+	return m.OutputVec
+}
+
+func (m *matProductRResult) ROutput() linalg.Vector {
+	return m.ROutputVec
+}
+
+func (m *matProductRResult) Constant(rg RGradient, g Gradient) bool {
+	return m.A.Constant(rg, g) && m.B.Constant(rg, g)
+}
+
+func (m *matProductRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
+	g Gradient) {
+	if !m.A.Constant(rg, g) {
+		downstream := m.Scope.Alloc(len(m.A.Output()))
+		downstreamR := m.Scope.Alloc(len(m.A.Output()))
+		m.Shape.gradA(u, m.B.Output(), downstream)
+		m.Shape.gradA(uR, m.B.Output(), downstreamR)
+		m.Shape.gradA(u, m.B.ROutput(), downstreamR)
+		m.A.PropagateRGradient(downstream, downstreamR, rg, g)
+	}
+	if !m.B.Constant(rg, g) {
+		downstream := m.Scope.Alloc(len(m.B.Output()))
+		downstreamR := m.Scope.Alloc(len(m.B.Output()))
+		m.Shape.gradB(u, m.A.Output(), downstream)
+		m.Shape.gradB(uR, m.A.Output(), downstreamR)
+		m.Shape.gradB(u, m.A.ROutput(), downstreamR)
+		m.B.PropagateRGradient(downstream, downstreamR, rg, g)
+	}
+}
+
 type outerProductResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	LeftIn    Result
 	RightIn   Result
@@ -142,8 +378,9 @@ type outerProductResult struct {
 // vectors, expressed as left*transpose(right) where both
 // vectors are column vectors.
 func OuterProduct(left, right Result) Result {
+	scope := scopeOf(left, right)
 	outMat := blas64.General{
-		Data:   make([]float64, len(left.Output())*len(right.Output())),
+		Data:   scope.Alloc(len(left.Output()) * len(right.Output())),
 		Rows:   len(left.Output()),
 		Cols:   len(right.Output()),
 		Stride: len(right.Output()),
@@ -157,11 +394,11 @@ func OuterProduct(left, right Result) Result {
 		Inc:  1,
 	}
 	blas64.Ger(1, leftVec, rightVec, outMat)
-	return &outerProductResult{
+	return scope.result(&outerProductResult{
 		OutputVec: outMat.Data,
 		LeftIn:    left,
 		RightIn:   right,
-	}
+	})
 }
 
 func (o *outerProductResult) Output() linalg.Vector {
@@ -185,7 +422,7 @@ func (o *outerProductResult) PropagateGradient(upstream linalg.Vector, grad Grad
 			Inc:  1,
 		}
 		leftDownstream := blas64.Vector{
-			Data: make(linalg.Vector, len(o.LeftIn.Output())),
+			Data: o.Scope.Alloc(len(o.LeftIn.Output())),
 			Inc:  1,
 		}
 		blas64.Gemv(blas.NoTrans, 1, upstreamMatrix, rightVec, 0, leftDownstream)
@@ -197,7 +434,7 @@ func (o *outerProductResult) PropagateGradient(upstream linalg.Vector, grad Grad
 			Inc:  1,
 		}
 		rightDownstream := blas64.Vector{
-			Data: make(linalg.Vector, len(o.RightIn.Output())),
+			Data: o.Scope.Alloc(len(o.RightIn.Output())),
 			Inc:  1,
 		}
 		blas64.Gemv(blas.Trans, 1, upstreamMatrix, leftVec, 0, rightDownstream)
@@ -206,6 +443,8 @@ func (o *outerProductResult) PropagateGradient(upstream linalg.Vector, grad Grad
 }
 
 type outerProductRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	LeftIn     RResult
@@ -214,14 +453,15 @@ type outerProductRResult struct {
 
 // OuterProductR is like OuterProduct but for RResults.
 func OuterProductR(left, right RResult) RResult {
+	scope := scopeOfR(left, right)
 	outMat := blas64.General{
-		Data:   make([]float64, len(left.Output())*len(right.Output())),
+		Data:   scope.Alloc(len(left.Output()) * len(right.Output())),
 		Rows:   len(left.Output()),
 		Cols:   len(right.Output()),
 		Stride: len(right.Output()),
 	}
 	outMatR := outMat
-	outMatR.Data = make([]float64, len(outMat.Data))
+	outMatR.Data = scope.Alloc(len(outMat.Data))
 
 	leftVec := blas64.Vector{
 		Data: left.Output(),
@@ -243,12 +483,12 @@ func OuterProductR(left, right RResult) RResult {
 	blas64.Ger(1, leftVec, rightVec, outMat)
 	blas64.Ger(1, leftVecR, rightVec, outMatR)
 	blas64.Ger(1, leftVec, rightVecR, outMatR)
-	return &outerProductRResult{
+	return scope.rresult(&outerProductRResult{
 		OutputVec:  outMat.Data,
 		ROutputVec: outMatR.Data,
 		LeftIn:     left,
 		RightIn:    right,
-	}
+	})
 }
 
 func (o *outerProductRResult) Output() linalg.Vector {
@@ -287,11 +527,11 @@ func (o *outerProductRResult) PropagateRGradient(upstream, upstreamR linalg.Vect
 			Inc:  1,
 		}
 		leftDownstream := blas64.Vector{
-			Data: make(linalg.Vector, len(o.LeftIn.Output())),
+			Data: o.Scope.Alloc(len(o.LeftIn.Output())),
 			Inc:  1,
 		}
 		leftDownstreamR := blas64.Vector{
-			Data: make(linalg.Vector, len(o.LeftIn.Output())),
+			Data: o.Scope.Alloc(len(o.LeftIn.Output())),
 			Inc:  1,
 		}
 		blas64.Gemv(blas.NoTrans, 1, upstreamMatrix, rightVec, 0, leftDownstream)
@@ -310,11 +550,11 @@ func (o *outerProductRResult) PropagateRGradient(upstream, upstreamR linalg.Vect
 			Inc:  1,
 		}
 		rightDownstream := blas64.Vector{
-			Data: make(linalg.Vector, len(o.RightIn.Output())),
+			Data: o.Scope.Alloc(len(o.RightIn.Output())),
 			Inc:  1,
 		}
 		rightDownstreamR := blas64.Vector{
-			Data: make(linalg.Vector, len(o.RightIn.Output())),
+			Data: o.Scope.Alloc(len(o.RightIn.Output())),
 			Inc:  1,
 		}
 		blas64.Gemv(blas.Trans, 1, upstreamMatrix, leftVec, 0, rightDownstream)
@@ -326,6 +566,8 @@ func (o *outerProductRResult) PropagateRGradient(upstream, upstreamR linalg.Vect
 }
 
 type transposeResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	In        Result
 	InRows    int
@@ -334,12 +576,13 @@ type transposeResult struct {
 
 // Transpose transposes a row-major matrix.
 func Transpose(in Result, rows, cols int) Result {
-	return &transposeResult{
-		OutputVec: transposeVector(in.Output(), rows, cols),
+	scope := scopeOf(in)
+	return scope.result(&transposeResult{
+		OutputVec: transposeVector(scope, in.Output(), rows, cols),
 		In:        in,
 		InRows:    rows,
 		InCols:    cols,
-	}
+	})
 }
 
 func (t *transposeResult) Output() linalg.Vector {
@@ -351,11 +594,13 @@ func (t *transposeResult) Constant(g Gradient) bool {
 }
 
 func (t *transposeResult) PropagateGradient(u linalg.Vector, g Gradient) {
-	uTrans := transposeVector(u, t.InCols, t.InRows)
+	uTrans := transposeVector(t.Scope, u, t.InCols, t.InRows)
 	t.In.PropagateGradient(uTrans, g)
 }
 
 type transposeRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	In         RResult
@@ -365,13 +610,14 @@ type transposeRResult struct {
 
 // TransposeR transposes a row-major matrix.
 func TransposeR(in RResult, rows, cols int) RResult {
-	return &transposeRResult{
-		OutputVec:  transposeVector(in.Output(), rows, cols),
-		ROutputVec: transposeVector(in.ROutput(), rows, cols),
+	scope := scopeOfR(in)
+	return scope.rresult(&transposeRResult{
+		OutputVec:  transposeVector(scope, in.Output(), rows, cols),
+		ROutputVec: transposeVector(scope, in.ROutput(), rows, cols),
 		In:         in,
 		InRows:     rows,
 		InCols:     cols,
-	}
+	})
 }
 
 func (t *transposeRResult) Output() linalg.Vector {
@@ -387,17 +633,24 @@ func (t *transposeRResult) Constant(rg RGradient, g Gradient) bool {
 }
 
 func (t *transposeRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
-	uTrans := transposeVector(u, t.InCols, t.InRows)
-	uTransR := transposeVector(uR, t.InCols, t.InRows)
+	uTrans := transposeVector(t.Scope, u, t.InCols, t.InRows)
+	uTransR := transposeVector(t.Scope, uR, t.InCols, t.InRows)
 	t.In.PropagateRGradient(uTrans, uTransR, rg, g)
 }
 
-func transposeVector(vec linalg.Vector, rows, cols int) linalg.Vector {
-	m := &linalg.Matrix{Data: vec, Rows: rows, Cols: cols}
-	return m.Transpose().Data
+func transposeVector(s *Scope, vec linalg.Vector, rows, cols int) linalg.Vector {
+	res := s.Alloc(len(vec))
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			res[j*rows+i] = vec[i*cols+j]
+		}
+	}
+	return res
 }
 
 type scaleRowsResult struct {
+	scopeRef
+
 	Matrix    Result
 	Scalers   Result
 	OutputVec linalg.Vector
@@ -409,13 +662,14 @@ type scaleRowsResult struct {
 // The number of entries in the matrix must be divisible
 // by the number of scalers.
 func ScaleRows(mat, scalers Result) Result {
+	scope := scopeOf(mat, scalers)
 	matData := mat.Output()
 	scalerData := scalers.Output()
 	if len(matData)%len(scalerData) != 0 {
 		panic("scaler count must divide entry count")
 	}
 	cols := len(matData) / len(scalerData)
-	res := make(linalg.Vector, len(matData))
+	res := scope.Alloc(len(matData))
 	copy(res, matData)
 	for i, scaler := range scalerData {
 		dest := res[i*cols : (i+1)*cols]
@@ -424,11 +678,11 @@ func ScaleRows(mat, scalers Result) Result {
 			Inc:  1,
 		})
 	}
-	return &scaleRowsResult{
+	return scope.result(&scaleRowsResult{
 		Matrix:    mat,
 		Scalers:   scalers,
 		OutputVec: res,
-	}
+	})
 }
 
 func (s *scaleRowsResult) Output() linalg.Vector {
@@ -442,7 +696,7 @@ func (s *scaleRowsResult) Constant(g Gradient) bool {
 func (s *scaleRowsResult) PropagateGradient(u linalg.Vector, g Gradient) {
 	matData := s.Matrix.Output()
 	if !s.Scalers.Constant(g) {
-		su := make(linalg.Vector, len(s.Scalers.Output()))
+		su := s.Scope.Alloc(len(s.Scalers.Output()))
 		cols := len(matData) / len(su)
 		for row := range su {
 			matRow := matData[row*cols : (row+1)*cols]
@@ -465,6 +719,8 @@ func (s *scaleRowsResult) PropagateGradient(u linalg.Vector, g Gradient) {
 }
 
 type scaleRowsRResult struct {
+	scopeRef
+
 	Matrix     RResult
 	Scalers    RResult
 	OutputVec  linalg.Vector
@@ -473,6 +729,7 @@ type scaleRowsRResult struct {
 
 // ScaleRowsR is like ScaleRows but for RResults.
 func ScaleRowsR(mat, scalers RResult) RResult {
+	scope := scopeOfR(mat, scalers)
 	matData := mat.Output()
 	matDataR := mat.ROutput()
 	scalerData := scalers.Output()
@@ -481,8 +738,8 @@ func ScaleRowsR(mat, scalers RResult) RResult {
 		panic("scaler count must divide entry count")
 	}
 	cols := len(matData) / len(scalerData)
-	res := make(linalg.Vector, len(matData))
-	resR := make(linalg.Vector, len(matData))
+	res := scope.Alloc(len(matData))
+	resR := scope.Alloc(len(matData))
 	copy(res, matData)
 	for i, scaler := range scalerData {
 		scalerR := scalerDataR[i]
@@ -495,14 +752,16 @@ func ScaleRowsR(mat, scalers RResult) RResult {
 			Inc:  1,
 		})
 		destR.Add(source).Scale(scalerR)
-		destR.Add(sourceR.Copy().Scale(scaler))
+		for j, x := range sourceR {
+			destR[j] += x * scaler
+		}
 	}
-	return &scaleRowsRResult{
+	return scope.rresult(&scaleRowsRResult{
 		Matrix:     mat,
 		Scalers:    scalers,
 		OutputVec:  res,
 		ROutputVec: resR,
-	}
+	})
 }
 
 func (s *scaleRowsRResult) Output() linalg.Vector {
@@ -522,8 +781,8 @@ func (s *scaleRowsRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
 	matDataR := s.Matrix.ROutput()
 
 	if !s.Scalers.Constant(rg, g) {
-		su := make(linalg.Vector, len(s.Scalers.Output()))
-		suR := make(linalg.Vector, len(su))
+		su := s.Scope.Alloc(len(s.Scalers.Output()))
+		suR := s.Scope.Alloc(len(su))
 		cols := len(matData) / len(su)
 		for row := range su {
 			matRow := matData[row*cols : (row+1)*cols]
@@ -546,7 +805,10 @@ func (s *scaleRowsRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
 				Inc:  1,
 			})
 			uRow := u[row*cols : (row+1)*cols]
-			uR[row*cols : (row+1)*cols].Add(uRow.Copy().Scale(scalerR))
+			uRowR := uR[row*cols : (row+1)*cols]
+			for j, x := range uRow {
+				uRowR[j] += x * scalerR
+			}
 			blas64.Scal(cols, scaler, blas64.Vector{Data: uRow, Inc: 1})
 		}
 		s.Matrix.PropagateRGradient(u, uR, rg, g)
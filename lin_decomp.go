@@ -0,0 +1,675 @@
+package autofunc
+
+import (
+	"math"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type matInverseResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Input     Result
+	N         int
+}
+
+// MatInverse inverts an n by n row-major matrix.
+// It panics if the matrix is singular.
+func MatInverse(mat Result, n int) Result {
+	scope := scopeOf(mat)
+	checkSquare(mat.Output(), n)
+	return scope.result(&matInverseResult{
+		OutputVec: luFactorize(scope, mat.Output(), n).inverse(),
+		Input:     mat,
+		N:         n,
+	})
+}
+
+func (m *matInverseResult) Output() linalg.Vector {
+	return m.OutputVec
+}
+
+func (m *matInverseResult) Constant(g Gradient) bool {
+	return m.Input.Constant(g)
+}
+
+func (m *matInverseResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if !m.Input.Constant(g) {
+		n, inv := m.N, m.OutputVec
+		uInv := squareProduct(m.Scope, n, 1, u, false, inv, true)
+		downstream := squareProduct(m.Scope, n, -1, inv, true, uInv, false)
+		m.Input.PropagateGradient(downstream, g)
+	}
+}
+
+type matInverseRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Input      RResult
+	N          int
+}
+
+// MatInverseR is like MatInverse, but for RResults.
+func MatInverseR(mat RResult, n int) RResult {
+	scope := scopeOfR(mat)
+	checkSquare(mat.Output(), n)
+	inv := luFactorize(scope, mat.Output(), n).inverse()
+	invR := squareProduct(scope, n, -1, inv, false,
+		squareProduct(scope, n, 1, mat.ROutput(), false, inv, false), false)
+	return scope.rresult(&matInverseRResult{
+		OutputVec:  inv,
+		ROutputVec: invR,
+		Input:      mat,
+		N:          n,
+	})
+}
+
+func (m *matInverseRResult) Output() linalg.Vector {
+	return m.OutputVec
+}
+
+func (m *matInverseRResult) ROutput() linalg.Vector {
+	return m.ROutputVec
+}
+
+func (m *matInverseRResult) Constant(rg RGradient, g Gradient) bool {
+	return m.Input.Constant(rg, g)
+}
+
+func (m *matInverseRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
+	g Gradient) {
+	if !m.Input.Constant(rg, g) {
+		n, inv, invR := m.N, m.OutputVec, m.ROutputVec
+
+		// The gradient is -inv'*u*inv', so its derivative is
+		// -(invR'*u*inv' + inv'*uR*inv' + inv'*u*invR').
+		uInv := squareProduct(m.Scope, n, 1, u, false, inv, true)
+		downstream := squareProduct(m.Scope, n, -1, inv, true, uInv, false)
+		downstreamR := squareProduct(m.Scope, n, -1, invR, true, uInv, false)
+		downstreamR.Add(squareProduct(m.Scope, n, -1, inv, true,
+			squareProduct(m.Scope, n, 1, uR, false, inv, true), false))
+		downstreamR.Add(squareProduct(m.Scope, n, -1, inv, true,
+			squareProduct(m.Scope, n, 1, u, false, invR, true), false))
+		m.Input.PropagateRGradient(downstream, downstreamR, rg, g)
+	}
+}
+
+type matSolveResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Mat       Result
+	RHS       Result
+	LU        *luFactors
+	N         int
+}
+
+// MatSolve solves the linear system A*X = B for X, where
+// A is an n by n row-major matrix and B is a row-major
+// matrix with n rows.
+// The number of columns in B is inferred from its size.
+// The result is a row-major matrix shaped like B.
+// It panics if A is singular.
+func MatSolve(mat Result, n int, rhs Result) Result {
+	scope := scopeOf(mat, rhs)
+	checkSquare(mat.Output(), n)
+	k := rhsColumns(rhs.Output(), n)
+	lu := luFactorize(scope, mat.Output(), n)
+	return scope.result(&matSolveResult{
+		OutputVec: lu.solve(rhs.Output(), k, false),
+		Mat:       mat,
+		RHS:       rhs,
+		LU:        lu,
+		N:         n,
+	})
+}
+
+func (m *matSolveResult) Output() linalg.Vector {
+	return m.OutputVec
+}
+
+func (m *matSolveResult) Constant(g Gradient) bool {
+	return m.Mat.Constant(g) && m.RHS.Constant(g)
+}
+
+func (m *matSolveResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if m.Constant(g) {
+		return
+	}
+	n := m.N
+	k := len(u) / n
+	rhsGrad := m.LU.solve(u, k, true)
+	if !m.Mat.Constant(g) {
+		matGrad := outerProducts(m.Scope, n, k, -1, rhsGrad, m.OutputVec)
+		m.Mat.PropagateGradient(matGrad, g)
+	}
+	if !m.RHS.Constant(g) {
+		m.RHS.PropagateGradient(rhsGrad, g)
+	}
+}
+
+type matSolveRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Mat        RResult
+	RHS        RResult
+	LU         *luFactors
+	N          int
+}
+
+// MatSolveR is like MatSolve, but for RResults.
+func MatSolveR(mat RResult, n int, rhs RResult) RResult {
+	scope := scopeOfR(mat, rhs)
+	checkSquare(mat.Output(), n)
+	k := rhsColumns(rhs.Output(), n)
+	lu := luFactorize(scope, mat.Output(), n)
+	x := lu.solve(rhs.Output(), k, false)
+
+	// xR = inv(A)*(rhsR - AR*x)
+	shape := MatMulShape{ARows: n, ACols: n, BRows: n, BCols: k}
+	residual := scope.copyVector(rhs.ROutput())
+	shape.product(scope.copyVector(mat.ROutput()).Scale(-1), x, residual)
+
+	return scope.rresult(&matSolveRResult{
+		OutputVec:  x,
+		ROutputVec: lu.solve(residual, k, false),
+		Mat:        mat,
+		RHS:        rhs,
+		LU:         lu,
+		N:          n,
+	})
+}
+
+func (m *matSolveRResult) Output() linalg.Vector {
+	return m.OutputVec
+}
+
+func (m *matSolveRResult) ROutput() linalg.Vector {
+	return m.ROutputVec
+}
+
+func (m *matSolveRResult) Constant(rg RGradient, g Gradient) bool {
+	return m.Mat.Constant(rg, g) && m.RHS.Constant(rg, g)
+}
+
+func (m *matSolveRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
+	g Gradient) {
+	if m.Constant(rg, g) {
+		return
+	}
+	n := m.N
+	k := len(u) / n
+	rhsGrad := m.LU.solve(u, k, true)
+
+	// rhsGradR = inv(A)'*(uR - AR'*rhsGrad)
+	shape := MatMulShape{ARows: n, ACols: n, BRows: n, BCols: k, TransA: true}
+	residual := m.Scope.copyVector(uR)
+	shape.product(m.Scope.copyVector(m.Mat.ROutput()).Scale(-1), rhsGrad, residual)
+	rhsGradR := m.LU.solve(residual, k, true)
+
+	if !m.Mat.Constant(rg, g) {
+		matGrad := outerProducts(m.Scope, n, k, -1, rhsGrad, m.OutputVec)
+		matGradR := outerProducts(m.Scope, n, k, -1, rhsGradR, m.OutputVec)
+		matGradR.Add(outerProducts(m.Scope, n, k, -1, rhsGrad, m.ROutputVec))
+		m.Mat.PropagateRGradient(matGrad, matGradR, rg, g)
+	}
+	if !m.RHS.Constant(rg, g) {
+		m.RHS.PropagateRGradient(rhsGrad, rhsGradR, rg, g)
+	}
+}
+
+type logDetResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Input     Result
+	InvTrans  linalg.Vector
+}
+
+// LogDet computes the natural logarithm of the absolute
+// value of the determinant of an n by n row-major matrix.
+// It panics if the matrix is singular.
+func LogDet(mat Result, n int) Result {
+	scope := scopeOf(mat)
+	checkSquare(mat.Output(), n)
+	lu := luFactorize(scope, mat.Output(), n)
+	return scope.result(&logDetResult{
+		OutputVec: scope.copyVector(linalg.Vector{lu.logAbsDet()}),
+		Input:     mat,
+		InvTrans:  transposeVector(scope, lu.inverse(), n, n),
+	})
+}
+
+func (l *logDetResult) Output() linalg.Vector {
+	return l.OutputVec
+}
+
+func (l *logDetResult) Constant(g Gradient) bool {
+	return l.Input.Constant(g)
+}
+
+func (l *logDetResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if !l.Input.Constant(g) {
+		l.Input.PropagateGradient(l.Scope.copyVector(l.InvTrans).Scale(u[0]), g)
+	}
+}
+
+type logDetRResult struct {
+	scopeRef
+
+	OutputVec     linalg.Vector
+	ROutputVec    linalg.Vector
+	Input         RResult
+	InvTrans      linalg.Vector
+	InvTransDeriv linalg.Vector
+}
+
+// LogDetR is like LogDet, but for RResults.
+func LogDetR(mat RResult, n int) RResult {
+	scope := scopeOfR(mat)
+	checkSquare(mat.Output(), n)
+	lu := luFactorize(scope, mat.Output(), n)
+	inv := lu.inverse()
+	invTrans := transposeVector(scope, inv, n, n)
+
+	// The derivative of inv' is -(inv*matR*inv)'.
+	invDeriv := squareProduct(scope, n, -1, inv, false,
+		squareProduct(scope, n, 1, mat.ROutput(), false, inv, false), false)
+
+	return scope.rresult(&logDetRResult{
+		OutputVec:     scope.copyVector(linalg.Vector{lu.logAbsDet()}),
+		ROutputVec:    scope.copyVector(linalg.Vector{invTrans.DotFast(mat.ROutput())}),
+		Input:         mat,
+		InvTrans:      invTrans,
+		InvTransDeriv: transposeVector(scope, invDeriv, n, n),
+	})
+}
+
+func (l *logDetRResult) Output() linalg.Vector {
+	return l.OutputVec
+}
+
+func (l *logDetRResult) ROutput() linalg.Vector {
+	return l.ROutputVec
+}
+
+func (l *logDetRResult) Constant(rg RGradient, g Gradient) bool {
+	return l.Input.Constant(rg, g)
+}
+
+func (l *logDetRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	if !l.Input.Constant(rg, g) {
+		downstream := l.Scope.copyVector(l.InvTrans).Scale(u[0])
+		downstreamR := l.Scope.copyVector(l.InvTrans).Scale(uR[0])
+		downstreamR.Add(l.Scope.copyVector(l.InvTransDeriv).Scale(u[0]))
+		l.Input.PropagateRGradient(downstream, downstreamR, rg, g)
+	}
+}
+
+type choleskyResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Input     Result
+	N         int
+}
+
+// Cholesky computes the lower-triangular Cholesky factor
+// L of a symmetric positive-definite n by n row-major
+// matrix A, such that A = L*L'.
+//
+// Only the lower triangle of A is read, so the gradient
+// with respect to the strict upper triangle is zero.
+// It panics if A is not positive-definite.
+func Cholesky(mat Result, n int) Result {
+	scope := scopeOf(mat)
+	checkSquare(mat.Output(), n)
+	return scope.result(&choleskyResult{
+		OutputVec: choleskyFactorize(scope, mat.Output(), n),
+		Input:     mat,
+		N:         n,
+	})
+}
+
+func (c *choleskyResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *choleskyResult) Constant(g Gradient) bool {
+	return c.Input.Constant(g)
+}
+
+func (c *choleskyResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if !c.Input.Constant(g) {
+		lInv := lowerInverse(c.Scope, c.OutputVec, c.N)
+		c.Input.PropagateGradient(choleskyGrad(c.Scope, c.N, c.OutputVec, lInv, u), g)
+	}
+}
+
+type choleskyRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Input      RResult
+	N          int
+}
+
+// CholeskyR is like Cholesky, but for RResults.
+func CholeskyR(mat RResult, n int) RResult {
+	scope := scopeOfR(mat)
+	checkSquare(mat.Output(), n)
+	l := choleskyFactorize(scope, mat.Output(), n)
+	lInv := lowerInverse(scope, l, n)
+
+	// For a symmetric perturbation dA,
+	// dL = L*phi(inv(L)*dA*inv(L)').
+	matR := symmetricFromLower(scope, mat.ROutput(), n)
+	inner := squareProduct(scope, n, 1, lInv, false,
+		squareProduct(scope, n, 1, matR, false, lInv, true), false)
+	lR := squareProduct(scope, n, 1, l, false, choleskyPhi(scope, inner, n), false)
+
+	return scope.rresult(&choleskyRResult{
+		OutputVec:  l,
+		ROutputVec: lR,
+		Input:      mat,
+		N:          n,
+	})
+}
+
+func (c *choleskyRResult) Output() linalg.Vector {
+	return c.OutputVec
+}
+
+func (c *choleskyRResult) ROutput() linalg.Vector {
+	return c.ROutputVec
+}
+
+func (c *choleskyRResult) Constant(rg RGradient, g Gradient) bool {
+	return c.Input.Constant(rg, g)
+}
+
+func (c *choleskyRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	if c.Input.Constant(rg, g) {
+		return
+	}
+	n, l, lR := c.N, c.OutputVec, c.ROutputVec
+	lInv := lowerInverse(c.Scope, l, n)
+	lInvR := squareProduct(c.Scope, n, -1, lInv, false,
+		squareProduct(c.Scope, n, 1, lR, false, lInv, false), false)
+
+	// The gradient is lowerSym(inv(L)'*phi(L'*u)*inv(L)),
+	// which is linear in its intermediate G, so its
+	// derivative is lowerSym of the derivative of G.
+	phiM := choleskyPhi(c.Scope, squareProduct(c.Scope, n, 1, l, true, u, false), n)
+	mR := squareProduct(c.Scope, n, 1, lR, true, u, false)
+	mR.Add(squareProduct(c.Scope, n, 1, l, true, uR, false))
+	phiMR := choleskyPhi(c.Scope, mR, n)
+
+	gR := squareProduct(c.Scope, n, 1, lInvR, true,
+		squareProduct(c.Scope, n, 1, phiM, false, lInv, false), false)
+	gR.Add(squareProduct(c.Scope, n, 1, lInv, true,
+		squareProduct(c.Scope, n, 1, phiMR, false, lInv, false), false))
+	gR.Add(squareProduct(c.Scope, n, 1, lInv, true,
+		squareProduct(c.Scope, n, 1, phiM, false, lInvR, false), false))
+
+	downstream := choleskyGrad(c.Scope, n, l, lInv, u)
+	c.Input.PropagateRGradient(downstream, lowerSymmetric(c.Scope, gR, n), rg, g)
+}
+
+// choleskyGrad computes the gradient of a Cholesky
+// factorization with respect to the lower triangle of
+// its input, given the upstream gradient u.
+func choleskyGrad(s *Scope, n int, l, lInv, u linalg.Vector) linalg.Vector {
+	phiM := choleskyPhi(s, squareProduct(s, n, 1, l, true, u, false), n)
+	inner := squareProduct(s, n, 1, lInv, true,
+		squareProduct(s, n, 1, phiM, false, lInv, false), false)
+	return lowerSymmetric(s, inner, n)
+}
+
+// choleskyPhi returns the lower triangle of a matrix,
+// with the diagonal halved.
+func choleskyPhi(s *Scope, mat linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(len(mat))
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			res[i*n+j] = mat[i*n+j]
+		}
+		res[i*n+i] = mat[i*n+i] / 2
+	}
+	return res
+}
+
+// lowerSymmetric maps a gradient with respect to a
+// symmetric matrix to a gradient with respect to the
+// lower triangle which determines that matrix.
+func lowerSymmetric(s *Scope, mat linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(len(mat))
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			res[i*n+j] = mat[i*n+j] + mat[j*n+i]
+		}
+		res[i*n+i] = mat[i*n+i]
+	}
+	return res
+}
+
+// symmetricFromLower creates a symmetric matrix from the
+// lower triangle of a matrix.
+func symmetricFromLower(s *Scope, mat linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(len(mat))
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			res[i*n+j] = mat[i*n+j]
+			res[j*n+i] = mat[i*n+j]
+		}
+	}
+	return res
+}
+
+func choleskyFactorize(s *Scope, mat linalg.Vector, n int) linalg.Vector {
+	l := s.Alloc(n * n)
+	for j := 0; j < n; j++ {
+		diag := mat[j*n+j]
+		for k := 0; k < j; k++ {
+			diag -= l[j*n+k] * l[j*n+k]
+		}
+		if !(diag > 0) {
+			panic("matrix is not positive-definite")
+		}
+		diag = math.Sqrt(diag)
+		l[j*n+j] = diag
+		for i := j + 1; i < n; i++ {
+			sum := mat[i*n+j]
+			for k := 0; k < j; k++ {
+				sum -= l[i*n+k] * l[j*n+k]
+			}
+			l[i*n+j] = sum / diag
+		}
+	}
+	return l
+}
+
+// lowerInverse inverts a lower-triangular matrix.
+func lowerInverse(s *Scope, l linalg.Vector, n int) linalg.Vector {
+	res := s.Alloc(n * n)
+	for col := 0; col < n; col++ {
+		for i := col; i < n; i++ {
+			var sum float64
+			if i == col {
+				sum = 1
+			}
+			for k := col; k < i; k++ {
+				sum -= l[i*n+k] * res[k*n+col]
+			}
+			res[i*n+col] = sum / l[i*n+i]
+		}
+	}
+	return res
+}
+
+// luFactors stores an LU decomposition with partial
+// pivoting, such that P*A = L*U.
+type luFactors struct {
+	Scope *Scope
+	N     int
+	LU    linalg.Vector
+	Perm  []int
+}
+
+func luFactorize(s *Scope, mat linalg.Vector, n int) *luFactors {
+	lu := s.copyVector(mat)
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(lu[row*n+col]) > math.Abs(lu[pivot*n+col]) {
+				pivot = row
+			}
+		}
+		if pivot != col {
+			perm[pivot], perm[col] = perm[col], perm[pivot]
+			for i := 0; i < n; i++ {
+				lu[pivot*n+i], lu[col*n+i] = lu[col*n+i], lu[pivot*n+i]
+			}
+		}
+		diag := lu[col*n+col]
+		if diag == 0 {
+			panic("matrix is singular")
+		}
+		for row := col + 1; row < n; row++ {
+			scale := lu[row*n+col] / diag
+			lu[row*n+col] = scale
+			for i := col + 1; i < n; i++ {
+				lu[row*n+i] -= scale * lu[col*n+i]
+			}
+		}
+	}
+	return &luFactors{Scope: s, N: n, LU: lu, Perm: perm}
+}
+
+// solve solves A*X = B (or A'*X = B if trans is set),
+// where B is a row-major matrix with k columns.
+func (l *luFactors) solve(b linalg.Vector, k int, trans bool) linalg.Vector {
+	x := l.Scope.Alloc(len(b))
+	if !trans {
+		for i, p := range l.Perm {
+			copy(x[i*k:(i+1)*k], b[p*k:(p+1)*k])
+		}
+		l.forwardSub(x, k, false)
+		l.backSub(x, k, false)
+		return x
+	}
+
+	// A' = U'*L'*P, so solve U'*L'*y = B and then x = P'*y.
+	y := l.Scope.copyVector(b)
+	l.forwardSub(y, k, true)
+	l.backSub(y, k, true)
+	for i, p := range l.Perm {
+		copy(x[p*k:(p+1)*k], y[i*k:(i+1)*k])
+	}
+	return x
+}
+
+// forwardSub solves L*y = x (or U'*y = x) in place.
+func (l *luFactors) forwardSub(x linalg.Vector, k int, trans bool) {
+	n := l.N
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			var coeff float64
+			if trans {
+				coeff = l.LU[j*n+i]
+			} else {
+				coeff = l.LU[i*n+j]
+			}
+			for c := 0; c < k; c++ {
+				x[i*k+c] -= coeff * x[j*k+c]
+			}
+		}
+		if trans {
+			for c := 0; c < k; c++ {
+				x[i*k+c] /= l.LU[i*n+i]
+			}
+		}
+	}
+}
+
+// backSub solves U*y = x (or L'*y = x) in place.
+func (l *luFactors) backSub(x linalg.Vector, k int, trans bool) {
+	n := l.N
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			var coeff float64
+			if trans {
+				coeff = l.LU[j*n+i]
+			} else {
+				coeff = l.LU[i*n+j]
+			}
+			for c := 0; c < k; c++ {
+				x[i*k+c] -= coeff * x[j*k+c]
+			}
+		}
+		if !trans {
+			for c := 0; c < k; c++ {
+				x[i*k+c] /= l.LU[i*n+i]
+			}
+		}
+	}
+}
+
+func (l *luFactors) inverse() linalg.Vector {
+	identity := l.Scope.Alloc(l.N * l.N)
+	for i := 0; i < l.N; i++ {
+		identity[i*l.N+i] = 1
+	}
+	return l.solve(identity, l.N, false)
+}
+
+func (l *luFactors) logAbsDet() float64 {
+	var res float64
+	for i := 0; i < l.N; i++ {
+		res += math.Log(math.Abs(l.LU[i*l.N+i]))
+	}
+	return res
+}
+
+// squareProduct computes scale*op(a)*op(b) for n by n
+// row-major matrices a and b.
+func squareProduct(s *Scope, n int, scale float64, a linalg.Vector, transA bool,
+	b linalg.Vector, transB bool) linalg.Vector {
+	res := s.Alloc(n * n)
+	shape := MatMulShape{ARows: n, ACols: n, BRows: n, BCols: n, TransA: transA,
+		TransB: transB}
+	shape.product(a, b, res)
+	return res.Scale(scale)
+}
+
+// outerProducts computes scale*a*b', where a and b are
+// n by k row-major matrices.
+func outerProducts(s *Scope, n, k int, scale float64, a, b linalg.Vector) linalg.Vector {
+	res := s.Alloc(n * n)
+	shape := MatMulShape{ARows: n, ACols: k, BRows: n, BCols: k, TransB: true}
+	shape.product(a, b, res)
+	return res.Scale(scale)
+}
+
+func checkSquare(mat linalg.Vector, n int) {
+	if len(mat) != n*n {
+		panic("invalid matrix data size")
+	}
+}
+
+func rhsColumns(rhs linalg.Vector, n int) int {
+	if len(rhs)%n != 0 {
+		panic("invalid right-hand side size")
+	}
+	return len(rhs) / n
+}
@@ -31,54 +31,57 @@ func (l *LinTran) Apply(in Result) Result {
 
 // ApplyR is like Apply but for RResults.
 func (l *LinTran) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	if len(in.Output()) != l.Cols {
 		panic(fmt.Sprintf("input length should be %d but got %d",
 			l.Cols, len(in.Output())))
 	}
 	rData := NewRVariable(l.Data, v)
-	return &linTranRResult{
+	return scope.rresult(&linTranRResult{
 		Matrix:     l,
-		OutputVec:  l.multiply(in.Output()),
-		ROutputVec: l.multiplyR(rData, in),
+		OutputVec:  l.multiply(scope, in.Output()),
+		ROutputVec: l.multiplyR(scope, rData, in),
 		Input:      in,
 		RData:      rData,
-	}
+	})
 }
 
 // Batch performs matrix multiplication on all
 // of the input vectors.
 func (l *LinTran) Batch(in Result, n int) Result {
+	scope := scopeOf(in)
 	if l.Cols*n != len(in.Output()) {
 		panic(fmt.Sprintf("input length should be %d but got %d",
 			l.Cols*n, len(in.Output())))
 	}
-	return &linTranResult{
+	return scope.result(&linTranResult{
 		Matrix:    l,
 		Input:     in,
-		OutputVec: l.multiply(in.Output()),
-	}
+		OutputVec: l.multiply(scope, in.Output()),
+	})
 }
 
 // BatchR performs matrix multiplication on all
 // of the input vectors.
 func (l *LinTran) BatchR(v RVector, in RResult, n int) RResult {
+	scope := scopeOfR(in)
 	if l.Cols*n != len(in.Output()) {
 		panic(fmt.Sprintf("input length should be %d but got %d",
 			l.Cols*n, len(in.Output())))
 	}
 	rData := NewRVariable(l.Data, v)
-	return &linTranRResult{
+	return scope.rresult(&linTranRResult{
 		Matrix:     l,
 		Input:      in,
-		OutputVec:  l.multiply(in.Output()),
-		ROutputVec: l.multiplyR(rData, in),
+		OutputVec:  l.multiply(scope, in.Output()),
+		ROutputVec: l.multiplyR(scope, rData, in),
 		RData:      rData,
-	}
+	})
 }
 
-func (l *LinTran) multiply(vec linalg.Vector) linalg.Vector {
+func (l *LinTran) multiply(s *Scope, vec linalg.Vector) linalg.Vector {
 	n := len(vec) / l.Cols
-	res := make(linalg.Vector, l.Rows*n)
+	res := s.Alloc(l.Rows * n)
 
 	mat := blas64.General{
 		Rows:   l.Rows,
@@ -116,12 +119,13 @@ func (l *LinTran) multiply(vec linalg.Vector) linalg.Vector {
 	return res
 }
 
-func (l *LinTran) multiplyR(rData *RVariable, rVec RResult) linalg.Vector {
+func (l *LinTran) multiplyR(s *Scope, rData *RVariable,
+	rVec RResult) linalg.Vector {
 	vec := rVec.Output()
 	vecR := rVec.ROutput()
 
 	n := len(vec) / l.Cols
-	res := make(linalg.Vector, l.Rows*n)
+	res := s.Alloc(l.Rows * n)
 
 	mat := blas64.General{
 		Rows:   l.Rows,
@@ -269,11 +273,11 @@ func (l *LinTran) dataGradientR(upstream, upstreamR linalg.Vector, input, inputR
 	}
 }
 
-func (l *LinTran) inputGradient(upstream linalg.Vector) linalg.Vector {
+func (l *LinTran) inputGradient(s *Scope, upstream linalg.Vector) linalg.Vector {
 	n := len(upstream) / l.Rows
 
 	matData := l.Data.Output()
-	gradVal := make(linalg.Vector, l.Cols*n)
+	gradVal := s.Alloc(l.Cols * n)
 
 	matrix := blas64.General{
 		Rows:   l.Rows,
@@ -305,13 +309,13 @@ func (l *LinTran) inputGradient(upstream linalg.Vector) linalg.Vector {
 	return gradVal
 }
 
-func (l *LinTran) inputGradientR(rData *RVariable, upstream,
+func (l *LinTran) inputGradientR(s *Scope, rData *RVariable, upstream,
 	upstreamR linalg.Vector) linalg.Vector {
 	n := len(upstream) / l.Rows
 
 	matData := rData.Output()
 	matDataR := rData.ROutput()
-	gradVal := make(linalg.Vector, l.Cols*n)
+	gradVal := s.Alloc(l.Cols * n)
 
 	matrix := blas64.General{
 		Rows:   l.Rows,
@@ -361,6 +365,8 @@ func (l *LinTran) inputGradientR(rData *RVariable, upstream,
 // linTranResult represents the result of applying
 // a LinTran to a Result.
 type linTranResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 	Matrix    *LinTran
@@ -376,7 +382,7 @@ func (l *linTranResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 	}
 
 	if !l.Input.Constant(grad) {
-		gradVal := l.Matrix.inputGradient(upstream)
+		gradVal := l.Matrix.inputGradient(l.Scope, upstream)
 		l.Input.PropagateGradient(gradVal, grad)
 	}
 }
@@ -386,6 +392,8 @@ func (l *linTranResult) Constant(g Gradient) bool {
 }
 
 type linTranRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -418,8 +426,8 @@ func (l *linTranRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	}
 
 	if !l.Input.Constant(rgrad, grad) {
-		downstreamRVec := l.Matrix.inputGradientR(l.RData, upstream, upstreamR)
-		downstreamVec := l.Matrix.inputGradient(upstream)
+		downstreamRVec := l.Matrix.inputGradientR(l.Scope, l.RData, upstream, upstreamR)
+		downstreamVec := l.Matrix.inputGradient(l.Scope, upstream)
 		l.Input.PropagateRGradient(downstreamVec, downstreamRVec, rgrad, grad)
 	}
 }
@@ -12,35 +12,39 @@ import (
 type Exp struct{}
 
 func (_ Exp) Apply(in Result) Result {
+	scope := scopeOf(in)
 	input := in.Output()
-	output := make(linalg.Vector, len(input))
+	output := scope.Alloc(len(input))
 	for i, x := range input {
 		output[i] = math.Exp(x)
 	}
-	return &expResult{
+	return scope.result(&expResult{
 		OutputVec: output,
 		Input:     in,
-	}
+	})
 }
 
 func (_ Exp) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	input := in.Output()
 	inputR := in.ROutput()
-	output := make(linalg.Vector, len(input))
-	outputR := make(linalg.Vector, len(input))
+	output := scope.Alloc(len(input))
+	outputR := scope.Alloc(len(input))
 	for i, x := range input {
 		exp := math.Exp(x)
 		output[i] = exp
 		outputR[i] = exp * inputR[i]
 	}
-	return &expRResult{
+	return scope.rresult(&expRResult{
 		OutputVec:  output,
 		ROutputVec: outputR,
 		Input:      in,
-	}
+	})
 }
 
 type expResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -63,6 +67,8 @@ func (e *expResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 }
 
 type expRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -101,34 +107,38 @@ func (e *expRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 type Log struct{}
 
 func (_ Log) Apply(in Result) Result {
+	scope := scopeOf(in)
 	inVec := in.Output()
-	outVec := make(linalg.Vector, len(inVec))
+	outVec := scope.Alloc(len(inVec))
 	for i, in := range inVec {
 		outVec[i] = math.Log(in)
 	}
-	return &logResult{
+	return scope.result(&logResult{
 		OutputVec: outVec,
 		Input:     in,
-	}
+	})
 }
 
 func (_ Log) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	inVec := in.Output()
 	inVecR := in.ROutput()
-	outVec := make(linalg.Vector, len(inVec))
-	outVecR := make(linalg.Vector, len(inVec))
+	outVec := scope.Alloc(len(inVec))
+	outVecR := scope.Alloc(len(inVec))
 	for i, in := range inVec {
 		outVec[i] = math.Log(in)
 		outVecR[i] = inVecR[i] / in
 	}
-	return &logRResult{
+	return scope.rresult(&logRResult{
 		OutputVec:  outVec,
 		ROutputVec: outVecR,
 		Input:      in,
-	}
+	})
 }
 
 type logResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -153,6 +163,8 @@ func (l *logResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 }
 
 type logRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -203,26 +215,30 @@ func (_ SquaredNorm) ApplyR(v RVector, r RResult) RResult {
 type Norm struct{}
 
 func (_ Norm) Apply(r Result) Result {
+	scope := scopeOf(r)
 	v := blas64.Vector{Data: r.Output(), Inc: 1}
 	output := blas64.Nrm2(len(v.Data), v)
-	return &normResult{
+	return scope.result(&normResult{
 		Input:     r,
-		OutputVec: []float64{output},
-	}
+		OutputVec: scope.copyVector(linalg.Vector{output}),
+	})
 }
 
 func (_ Norm) ApplyR(rv RVector, r RResult) RResult {
+	scope := scopeOfR(r)
 	v := blas64.Vector{Data: r.Output(), Inc: 1}
 	output := blas64.Nrm2(len(v.Data), v)
 	rout := (1 / output) * r.Output().DotFast(r.ROutput())
-	return &normRResult{
+	return scope.rresult(&normRResult{
 		Input:      r,
-		OutputVec:  []float64{output},
-		ROutputVec: []float64{rout},
-	}
+		OutputVec:  scope.copyVector(linalg.Vector{output}),
+		ROutputVec: scope.copyVector(linalg.Vector{rout}),
+	})
 }
 
 type normResult struct {
+	scopeRef
+
 	Input     Result
 	OutputVec linalg.Vector
 }
@@ -240,13 +256,15 @@ func (n *normResult) PropagateGradient(u linalg.Vector, g Gradient) {
 		return
 	}
 	scale := u[0] / n.Output()[0]
-	downstream := make(linalg.Vector, len(n.Input.Output()))
+	downstream := n.Scope.Alloc(len(n.Input.Output()))
 	copy(downstream, n.Input.Output())
 	blas64.Scal(len(downstream), scale, blas64.Vector{Data: downstream, Inc: 1})
 	n.Input.PropagateGradient(downstream, g)
 }
 
 type normRResult struct {
+	scopeRef
+
 	Input      RResult
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
@@ -270,13 +288,13 @@ func (n *normRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gr
 	}
 	scale := u[0] / n.Output()[0]
 	scaleR := -u[0]*n.ROutput()[0]/(n.Output()[0]*n.Output()[0]) + uR[0]/n.Output()[0]
-	downstream := make(linalg.Vector, len(n.Input.Output()))
-	downstreamR := make(linalg.Vector, len(n.Input.Output()))
+	downstream := n.Scope.Alloc(len(n.Input.Output()))
+	downstreamR := n.Scope.Alloc(len(n.Input.Output()))
 	copy(downstream, n.Input.Output())
 	copy(downstreamR, downstream)
 	blas64.Scal(len(downstream), scale, blas64.Vector{Data: downstream, Inc: 1})
 	blas64.Scal(len(downstream), scaleR, blas64.Vector{Data: downstreamR, Inc: 1})
-	downstreamR.Add(n.Input.ROutput().Copy().Scale(scale))
+	downstreamR.Add(n.Scope.copyVector(n.Input.ROutput()).Scale(scale))
 	n.Input.PropagateRGradient(downstream, downstreamR, rg, g)
 }
 
@@ -286,35 +304,39 @@ func (n *normRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gr
 type Sigmoid struct{}
 
 func (s Sigmoid) Apply(in Result) Result {
+	scope := scopeOf(in)
 	inVec := in.Output()
-	res := make(linalg.Vector, len(inVec))
+	res := scope.Alloc(len(inVec))
 	for i, x := range inVec {
 		res[i] = 1 / (1 + math.Exp(-x))
 	}
-	return &sigmoidResult{
+	return scope.result(&sigmoidResult{
 		OutputVec: res,
 		Input:     in,
-	}
+	})
 }
 
 func (s Sigmoid) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	inVec := in.Output()
 	inVecR := in.ROutput()
-	res := make(linalg.Vector, len(inVec))
-	resR := make(linalg.Vector, len(inVec))
+	res := scope.Alloc(len(inVec))
+	resR := scope.Alloc(len(inVec))
 	for i, x := range inVec {
 		sigVal := 1 / (1 + math.Exp(-x))
 		res[i] = sigVal
 		resR[i] = sigVal * (1 - sigVal) * inVecR[i]
 	}
-	return &sigmoidRResult{
+	return scope.rresult(&sigmoidRResult{
 		OutputVec:  res,
 		ROutputVec: resR,
 		Input:      in,
-	}
+	})
 }
 
 type sigmoidResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -337,6 +359,8 @@ func (s *sigmoidResult) Constant(g Gradient) bool {
 }
 
 type sigmoidRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -381,23 +405,25 @@ func (s *sigmoidRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 type LogSigmoid struct{}
 
 func (l LogSigmoid) Apply(in Result) Result {
-	return &logSigmoidResult{
-		OutputVec: l.logSigmoid(in.Output()),
+	scope := scopeOf(in)
+	return scope.result(&logSigmoidResult{
+		OutputVec: l.logSigmoid(scope, in.Output()),
 		Input:     in,
-	}
+	})
 }
 
 func (l LogSigmoid) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	inVec := in.Output()
-	return &logSigmoidRResult{
-		OutputVec:  l.logSigmoid(inVec),
-		ROutputVec: l.logSigmoidR(inVec, in.ROutput()),
+	return scope.rresult(&logSigmoidRResult{
+		OutputVec:  l.logSigmoid(scope, inVec),
+		ROutputVec: l.logSigmoidR(scope, inVec, in.ROutput()),
 		Input:      in,
-	}
+	})
 }
 
-func (l LogSigmoid) logSigmoid(inVec linalg.Vector) linalg.Vector {
-	res := make(linalg.Vector, len(inVec))
+func (l LogSigmoid) logSigmoid(s *Scope, inVec linalg.Vector) linalg.Vector {
+	res := s.Alloc(len(inVec))
 	for i, x := range inVec {
 		// Avoid taking the log of a big number.
 		if x > 0 {
@@ -409,8 +435,8 @@ func (l LogSigmoid) logSigmoid(inVec linalg.Vector) linalg.Vector {
 	return res
 }
 
-func (l LogSigmoid) logSigmoidR(inVec, inVecR linalg.Vector) linalg.Vector {
-	res := make(linalg.Vector, len(inVec))
+func (l LogSigmoid) logSigmoidR(s *Scope, inVec, inVecR linalg.Vector) linalg.Vector {
+	res := s.Alloc(len(inVec))
 	for i, x := range inVec {
 		res[i] = inVecR[i] / (1 + math.Exp(x))
 	}
@@ -418,6 +444,8 @@ func (l LogSigmoid) logSigmoidR(inVec, inVecR linalg.Vector) linalg.Vector {
 }
 
 type logSigmoidResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -440,6 +468,8 @@ func (l *logSigmoidResult) PropagateGradient(upstream linalg.Vector, grad Gradie
 }
 
 type logSigmoidRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -476,6 +506,11 @@ func (l *logSigmoidRResult) PropagateRGradient(upstream, upstreamR linalg.Vector
 
 // Softmax is a Func and RFunc which evaluates the
 // softmax function with a given temperature.
+//
+// Before exponentiating, the inputs are shifted so that
+// the largest one is 0, preventing overflow.
+// Since softmax is invariant to such shifts, the shift
+// is treated as a constant during back propagation.
 type Softmax struct {
 	// Temperature is used to divide the input values
 	// before they are exponentiated.
@@ -489,7 +524,8 @@ func (s *Softmax) Apply(in Result) Result {
 	if s.Temperature != 0 && s.Temperature != 1 {
 		scaledInputs = Scale(in, 1/s.Temperature)
 	}
-	exps := Exp{}.Apply(scaledInputs)
+	shifted := AddScaler(scaledInputs, -maxValue(scaledInputs.Output()))
+	exps := Exp{}.Apply(shifted)
 	return Pool(exps, func(exps Result) Result {
 		sum := SumAll(exps)
 		return ScaleFirst(exps, Inverse(sum))
@@ -501,46 +537,85 @@ func (s *Softmax) ApplyR(v RVector, in RResult) RResult {
 	if s.Temperature != 0 && s.Temperature != 1 {
 		scaledInputs = ScaleR(in, 1/s.Temperature)
 	}
-	exps := Exp{}.ApplyR(v, scaledInputs)
-	return PoolR(exps, func(in RResult) RResult {
+	shifted := AddScalerR(scaledInputs, -maxValue(scaledInputs.Output()))
+	exps := Exp{}.ApplyR(v, shifted)
+	return PoolR(exps, func(exps RResult) RResult {
 		sum := SumAllR(exps)
 		return ScaleFirstR(exps, InverseR(sum))
 	})
 }
 
+// LogSoftmax is a Func and RFunc which computes the
+// natural logarithm of the softmax function.
+// This is more numerically reliable than taking the
+// log of the softmax in two steps.
+type LogSoftmax struct {
+	// Temperature is used to divide the input values
+	// before they are exponentiated.
+	// If the temperature is 0, then a temperature of 1
+	// is used like in the standard softmax function.
+	Temperature float64
+}
+
+func (l *LogSoftmax) Apply(in Result) Result {
+	scaledInputs := in
+	if l.Temperature != 0 && l.Temperature != 1 {
+		scaledInputs = Scale(in, 1/l.Temperature)
+	}
+	return Pool(scaledInputs, func(in Result) Result {
+		logSum := SumAllLogDomain(in)
+		return AddFirst(in, Scale(logSum, -1))
+	})
+}
+
+func (l *LogSoftmax) ApplyR(v RVector, in RResult) RResult {
+	scaledInputs := in
+	if l.Temperature != 0 && l.Temperature != 1 {
+		scaledInputs = ScaleR(in, 1/l.Temperature)
+	}
+	return PoolR(scaledInputs, func(in RResult) RResult {
+		logSum := SumAllLogDomainR(in)
+		return AddFirstR(in, ScaleR(logSum, -1))
+	})
+}
+
 // Sin is a Func and RFunc which evaluates the sine
 // (in radians) of each of its input components.
 type Sin struct{}
 
 func (_ Sin) Apply(in Result) Result {
+	scope := scopeOf(in)
 	input := in.Output()
-	res := make(linalg.Vector, len(input))
+	res := scope.Alloc(len(input))
 	for i, x := range input {
 		res[i] = math.Sin(x)
 	}
-	return &sinResult{
+	return scope.result(&sinResult{
 		OutputVec: res,
 		Input:     in,
-	}
+	})
 }
 
 func (_ Sin) ApplyR(v RVector, in RResult) RResult {
+	scope := scopeOfR(in)
 	input := in.Output()
 	inputR := in.ROutput()
-	res := make(linalg.Vector, len(input))
-	resR := make(linalg.Vector, len(inputR))
+	res := scope.Alloc(len(input))
+	resR := scope.Alloc(len(inputR))
 	for i, x := range input {
 		res[i] = math.Sin(x)
 		resR[i] = math.Cos(x) * inputR[i]
 	}
-	return &sinRResult{
+	return scope.rresult(&sinRResult{
 		OutputVec:  res,
 		ROutputVec: resR,
 		Input:      in,
-	}
+	})
 }
 
 type sinResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Input     Result
 }
@@ -563,6 +638,8 @@ func (s *sinResult) PropagateGradient(upstream linalg.Vector, g Gradient) {
 }
 
 type sinRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Input      RResult
@@ -0,0 +1,508 @@
+package autofunc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/serializer"
+)
+
+const (
+	defaultNormEpsilon  = 1e-5
+	defaultNormMomentum = 0.9
+)
+
+func init() {
+	var b BatchNorm
+	serializer.RegisterTypedDeserializer(b.SerializerType(), DeserializeBatchNorm)
+}
+
+// LayerNorm is an RFunc and RBatcher which normalizes
+// each input vector to have zero mean and unit variance,
+// then scales and shifts each component by a learned
+// gain and bias.
+type LayerNorm struct {
+	// Gains and Biases are applied after normalizing.
+	// If either one is nil, it is omitted.
+	Gains  *Variable
+	Biases *Variable
+
+	// Epsilon is added to the variance to prevent division
+	// by zero.
+	// If it is 0, 1e-5 is used.
+	Epsilon float64
+}
+
+// NewLayerNorm creates a LayerNorm with unit gains and
+// zero biases.
+func NewLayerNorm(size int) *LayerNorm {
+	res := &LayerNorm{
+		Gains:  &Variable{Vector: make(linalg.Vector, size)},
+		Biases: &Variable{Vector: make(linalg.Vector, size)},
+	}
+	for i := range res.Gains.Vector {
+		res.Gains.Vector[i] = 1
+	}
+	return res
+}
+
+// Parameters returns the non-nil gains and biases.
+func (l *LayerNorm) Parameters() []*Variable {
+	var res []*Variable
+	for _, v := range []*Variable{l.Gains, l.Biases} {
+		if v != nil {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Apply normalizes a single vector.
+func (l *LayerNorm) Apply(in Result) Result {
+	return l.Batch(in, 1)
+}
+
+// ApplyR normalizes a single vector.
+func (l *LayerNorm) ApplyR(v RVector, in RResult) RResult {
+	return l.BatchR(v, in, 1)
+}
+
+// Batch normalizes each of the n vectors separately.
+func (l *LayerNorm) Batch(in Result, n int) Result {
+	scope := scopeOf(in)
+	var res Result = newNormalizeResult(in, n, l.epsilon())
+	if l.Gains != nil {
+		res = Mul(res, Repeat(scope.Result(l.Gains), n))
+	}
+	if l.Biases != nil {
+		res = Add(res, Repeat(scope.Result(l.Biases), n))
+	}
+	return res
+}
+
+// BatchR is like Batch, but for RResults.
+func (l *LayerNorm) BatchR(v RVector, in RResult, n int) RResult {
+	scope := scopeOfR(in)
+	var res RResult = newNormalizeRResult(in, n, l.epsilon())
+	if l.Gains != nil {
+		res = MulR(res, RepeatR(scope.RResult(NewRVariable(l.Gains, v)), n))
+	}
+	if l.Biases != nil {
+		res = AddR(res, RepeatR(scope.RResult(NewRVariable(l.Biases, v)), n))
+	}
+	return res
+}
+
+func (l *LayerNorm) epsilon() float64 {
+	if l.Epsilon == 0 {
+		return defaultNormEpsilon
+	}
+	return l.Epsilon
+}
+
+// BatchNorm is an RFunc and RBatcher which normalizes
+// each component to have zero mean and unit variance
+// across the samples in a batch, then scales and shifts
+// each component by a learned gain and bias.
+//
+// In training mode, the statistics of each batch are used
+// and gradients flow through them.
+// Otherwise, the running statistics are used, making the
+// normalization a fixed affine function.
+type BatchNorm struct {
+	// Gains and Biases are applied after normalizing.
+	// If either one is nil, it is omitted.
+	Gains  *Variable
+	Biases *Variable
+
+	// RunningMean and RunningVariance store exponential
+	// moving averages of the batch statistics seen in
+	// training mode.
+	RunningMean     linalg.Vector
+	RunningVariance linalg.Vector
+
+	// Momentum is the weight of the old running statistics
+	// when they are updated.
+	// If it is 0, 0.9 is used.
+	Momentum float64
+
+	// Epsilon is added to the variance to prevent division
+	// by zero.
+	// If it is 0, 1e-5 is used.
+	Epsilon float64
+
+	// Training indicates that batch statistics should be
+	// used and accumulated into the running statistics.
+	Training bool
+}
+
+// NewBatchNorm creates a BatchNorm in training mode with
+// unit gains, zero biases, and running statistics for a
+// standard normal distribution.
+func NewBatchNorm(size int) *BatchNorm {
+	l := NewLayerNorm(size)
+	res := &BatchNorm{
+		Gains:           l.Gains,
+		Biases:          l.Biases,
+		RunningMean:     make(linalg.Vector, size),
+		RunningVariance: make(linalg.Vector, size),
+		Training:        true,
+	}
+	for i := range res.RunningVariance {
+		res.RunningVariance[i] = 1
+	}
+	return res
+}
+
+// DeserializeBatchNorm deserializes a BatchNorm.
+func DeserializeBatchNorm(d []byte) (*BatchNorm, error) {
+	reader := bytes.NewBuffer(d)
+	var res BatchNorm
+	var vecs [4]linalg.Vector
+	for i := range vecs {
+		var err error
+		if vecs[i], err = readOptionalVector(reader); err != nil {
+			return nil, err
+		}
+	}
+	if vecs[0] != nil {
+		res.Gains = &Variable{Vector: vecs[0]}
+	}
+	if vecs[1] != nil {
+		res.Biases = &Variable{Vector: vecs[1]}
+	}
+	res.RunningMean, res.RunningVariance = vecs[2], vecs[3]
+	var training uint8
+	for _, x := range []interface{}{&res.Momentum, &res.Epsilon, &training} {
+		if err := binary.Read(reader, binary.LittleEndian, x); err != nil {
+			return nil, err
+		}
+	}
+	res.Training = training != 0
+	return &res, nil
+}
+
+// Parameters returns the non-nil gains and biases.
+// The running statistics are not parameters, since they
+// are not learned by gradient descent.
+func (b *BatchNorm) Parameters() []*Variable {
+	return (&LayerNorm{Gains: b.Gains, Biases: b.Biases}).Parameters()
+}
+
+// Apply normalizes a batch containing a single vector.
+// In training mode, this always produces the biases.
+func (b *BatchNorm) Apply(in Result) Result {
+	return b.Batch(in, 1)
+}
+
+// ApplyR is like Apply, but for RResults.
+func (b *BatchNorm) ApplyR(v RVector, in RResult) RResult {
+	return b.BatchR(v, in, 1)
+}
+
+// Batch normalizes a batch of n vectors.
+// In training mode, it updates the running statistics.
+func (b *BatchNorm) Batch(in Result, n int) Result {
+	scope := scopeOf(in)
+	var res Result
+	size := len(in.Output()) / n
+	if b.Training {
+		b.updateStats(in.Output(), n)
+		res = Transpose(newNormalizeResult(Transpose(in, n, size), size, b.epsilon()),
+			size, n)
+	} else {
+		scales, shifts := b.inferenceAffine(scope)
+		res = Add(Mul(in, Repeat(scope.Result(scales), n)),
+			Repeat(scope.Result(shifts), n))
+	}
+	if b.Gains != nil {
+		res = Mul(res, Repeat(scope.Result(b.Gains), n))
+	}
+	if b.Biases != nil {
+		res = Add(res, Repeat(scope.Result(b.Biases), n))
+	}
+	return res
+}
+
+// BatchR is like Batch, but for RResults.
+// Unlike Batch, it never updates the running statistics,
+// so that R-operator passes do not count the same batch
+// more than once.
+func (b *BatchNorm) BatchR(v RVector, in RResult, n int) RResult {
+	scope := scopeOfR(in)
+	var res RResult
+	size := len(in.Output()) / n
+	if b.Training {
+		normalized := newNormalizeRResult(TransposeR(in, n, size), size, b.epsilon())
+		res = TransposeR(normalized, size, n)
+	} else {
+		scales, shifts := b.inferenceAffine(scope)
+		res = AddR(MulR(in, RepeatR(scope.RResult(NewRVariable(scales, v)), n)),
+			RepeatR(scope.RResult(NewRVariable(shifts, v)), n))
+	}
+	if b.Gains != nil {
+		res = MulR(res, RepeatR(scope.RResult(NewRVariable(b.Gains, v)), n))
+	}
+	if b.Biases != nil {
+		res = AddR(res, RepeatR(scope.RResult(NewRVariable(b.Biases, v)), n))
+	}
+	return res
+}
+
+// SerializerType returns the unique ID used to serialize
+// a BatchNorm using the serializer package.
+func (b *BatchNorm) SerializerType() string {
+	return "github.com/unixpickle/autofunc.BatchNorm"
+}
+
+// Serialize serializes the parameters, running
+// statistics, and settings of the BatchNorm.
+func (b *BatchNorm) Serialize() ([]byte, error) {
+	var w bytes.Buffer
+	for _, v := range []*Variable{b.Gains, b.Biases} {
+		if v == nil {
+			writeOptionalVector(&w, nil)
+		} else {
+			writeOptionalVector(&w, v.Vector)
+		}
+	}
+	writeOptionalVector(&w, b.RunningMean)
+	writeOptionalVector(&w, b.RunningVariance)
+	var training uint8
+	if b.Training {
+		training = 1
+	}
+	binary.Write(&w, binary.LittleEndian, b.Momentum)
+	binary.Write(&w, binary.LittleEndian, b.Epsilon)
+	binary.Write(&w, binary.LittleEndian, training)
+	return w.Bytes(), nil
+}
+
+func (b *BatchNorm) updateStats(vecs linalg.Vector, n int) {
+	size := len(vecs) / n
+	if b.RunningMean == nil {
+		b.RunningMean = make(linalg.Vector, size)
+		b.RunningVariance = make(linalg.Vector, size)
+		for i := range b.RunningVariance {
+			b.RunningVariance[i] = 1
+		}
+	}
+	momentum := b.Momentum
+	if momentum == 0 {
+		momentum = defaultNormMomentum
+	}
+	for i := 0; i < size; i++ {
+		var mean, variance float64
+		for j := 0; j < n; j++ {
+			mean += vecs[j*size+i]
+		}
+		mean /= float64(n)
+		for j := 0; j < n; j++ {
+			diff := vecs[j*size+i] - mean
+			variance += diff * diff
+		}
+		variance /= float64(n)
+		b.RunningMean[i] = momentum*b.RunningMean[i] + (1-momentum)*mean
+		b.RunningVariance[i] = momentum*b.RunningVariance[i] + (1-momentum)*variance
+	}
+}
+
+// inferenceAffine returns the constant scales and shifts
+// which normalize vectors using the running statistics.
+func (b *BatchNorm) inferenceAffine(s *Scope) (scales, shifts *Variable) {
+	scales = &Variable{Vector: s.Alloc(len(b.RunningMean))}
+	shifts = &Variable{Vector: s.Alloc(len(b.RunningMean))}
+	for i, mean := range b.RunningMean {
+		scales.Vector[i] = 1 / math.Sqrt(b.RunningVariance[i]+b.epsilon())
+		shifts.Vector[i] = -mean * scales.Vector[i]
+	}
+	return
+}
+
+func (b *BatchNorm) epsilon() float64 {
+	if b.Epsilon == 0 {
+		return defaultNormEpsilon
+	}
+	return b.Epsilon
+}
+
+type normalizeResult struct {
+	scopeRef
+
+	OutputVec linalg.Vector
+	Input     Result
+	InvStds   []float64
+}
+
+func newNormalizeResult(in Result, n int, eps float64) Result {
+	scope := scopeOf(in)
+	out, invStds := normalizeVecs(scope, in.Output(), n, eps)
+	return scope.result(&normalizeResult{
+		OutputVec: out,
+		Input:     in,
+		InvStds:   invStds,
+	})
+}
+
+func (n *normalizeResult) Output() linalg.Vector {
+	return n.OutputVec
+}
+
+func (n *normalizeResult) Constant(g Gradient) bool {
+	return n.Input.Constant(g)
+}
+
+func (n *normalizeResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if !n.Input.Constant(g) {
+		n.Input.PropagateGradient(normalizeGrad(n.Scope, n.OutputVec, u, n.InvStds), g)
+	}
+}
+
+type normalizeRResult struct {
+	scopeRef
+
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Input      RResult
+	InvStds    []float64
+}
+
+func newNormalizeRResult(in RResult, n int, eps float64) RResult {
+	scope := scopeOfR(in)
+	out, invStds := normalizeVecs(scope, in.Output(), n, eps)
+	return scope.rresult(&normalizeRResult{
+		OutputVec: out,
+		// The Jacobian of the normalization is symmetric, so
+		// the r-output is computed like a gradient.
+		ROutputVec: normalizeGrad(scope, out, in.ROutput(), invStds),
+		Input:      in,
+		InvStds:    invStds,
+	})
+}
+
+func (n *normalizeRResult) Output() linalg.Vector {
+	return n.OutputVec
+}
+
+func (n *normalizeRResult) ROutput() linalg.Vector {
+	return n.ROutputVec
+}
+
+func (n *normalizeRResult) Constant(rg RGradient, g Gradient) bool {
+	return n.Input.Constant(rg, g)
+}
+
+func (n *normalizeRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient,
+	g Gradient) {
+	if n.Input.Constant(rg, g) {
+		return
+	}
+	downstream := normalizeGrad(n.Scope, n.OutputVec, u, n.InvStds)
+	downstreamR := normalizeGrad(n.Scope, n.OutputVec, uR, n.InvStds)
+	size := len(u) / len(n.InvStds)
+	for i, invStd := range n.InvStds {
+		start, end := i*size, (i+1)*size
+		y, yR := n.OutputVec[start:end], n.ROutputVec[start:end]
+		inR := n.Input.ROutput()[start:end]
+		uPart := u[start:end]
+		// The derivative of the standard deviation is the
+		// mean of y*inR.
+		stdR := y.Dot(inR) / float64(size)
+		meanUY := y.Dot(uPart) / float64(size)
+		meanUYR := yR.Dot(uPart) / float64(size)
+		for j := start; j < end; j++ {
+			downstreamR[j] += -stdR*invStd*downstream[j] -
+				invStd*(yR[j-start]*meanUY+y[j-start]*meanUYR)
+		}
+	}
+	n.Input.PropagateRGradient(downstream, downstreamR, rg, g)
+}
+
+// normalizeVecs normalizes each of n packed vectors,
+// returning the results and the reciprocal standard
+// deviation of each vector.
+func normalizeVecs(s *Scope, vecs linalg.Vector, n int,
+	eps float64) (linalg.Vector, []float64) {
+	if len(vecs)%n != 0 {
+		panic("batch size must divide input size")
+	}
+	size := len(vecs) / n
+	out := s.Alloc(len(vecs))
+	invStds := s.Alloc(n)
+	for i := range invStds {
+		vec := vecs[i*size : (i+1)*size]
+		var mean float64
+		for _, x := range vec {
+			mean += x
+		}
+		mean /= float64(size)
+		var variance float64
+		for _, x := range vec {
+			variance += (x - mean) * (x - mean)
+		}
+		variance /= float64(size)
+		invStds[i] = 1 / math.Sqrt(variance+eps)
+		for j, x := range vec {
+			out[i*size+j] = (x - mean) * invStds[i]
+		}
+	}
+	return out, invStds
+}
+
+// normalizeGrad computes the gradient of normalizeVecs,
+// given its output and the upstream gradient.
+func normalizeGrad(s *Scope, out, u linalg.Vector, invStds []float64) linalg.Vector {
+	size := len(u) / len(invStds)
+	res := s.Alloc(len(u))
+	for i, invStd := range invStds {
+		start, end := i*size, (i+1)*size
+		y, uPart := out[start:end], u[start:end]
+		var meanU float64
+		for _, x := range uPart {
+			meanU += x
+		}
+		meanU /= float64(size)
+		meanUY := y.Dot(uPart) / float64(size)
+		for j, x := range uPart {
+			res[start+j] = invStd * (x - meanU - y[j]*meanUY)
+		}
+	}
+	return res
+}
+
+// writeOptionalVector writes a vector which may be nil.
+func writeOptionalVector(w *bytes.Buffer, vec linalg.Vector) {
+	if vec == nil {
+		binary.Write(w, binary.LittleEndian, int64(-1))
+		return
+	}
+	binary.Write(w, binary.LittleEndian, int64(len(vec)))
+	for _, x := range vec {
+		binary.Write(w, binary.LittleEndian, x)
+	}
+}
+
+// readOptionalVector reads a vector written by
+// writeOptionalVector.
+func readOptionalVector(r *bytes.Buffer) (linalg.Vector, error) {
+	var size int64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, nil
+	}
+	if size*8 > int64(r.Len()) {
+		return nil, errors.New("vector data out of bounds")
+	}
+	vec := make(linalg.Vector, int(size))
+	for i := range vec {
+		if err := binary.Read(r, binary.LittleEndian, &vec[i]); err != nil {
+			return nil, err
+		}
+	}
+	return vec, nil
+}
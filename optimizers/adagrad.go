@@ -0,0 +1,87 @@
+package optimizers
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/serializer"
+)
+
+// DefaultAdagradEpsilon is the default Epsilon for Adagrad.
+const DefaultAdagradEpsilon = 1e-8
+
+func init() {
+	var a Adagrad
+	serializer.RegisterTypedDeserializer(a.SerializerType(), DeserializeAdagrad)
+}
+
+// Adagrad is an Optimizer which divides each gradient
+// component by the root of the sum of that component's
+// past squared values.
+type Adagrad struct {
+	// Vars are the variables to update.
+	// The optimizer's state is associated with each
+	// variable by its index in this list.
+	Vars []*autofunc.Variable
+
+	// Schedule determines the step size.
+	Schedule Schedule
+
+	// Epsilon is added to the denominator of each update
+	// for numerical stability.
+	// If it is 0, DefaultAdagradEpsilon is used.
+	Epsilon float64
+
+	// WeightDecay is the coefficient for an L2 penalty
+	// which is added to the gradient.
+	WeightDecay float64
+
+	state varState
+}
+
+// DeserializeAdagrad deserializes an Adagrad optimizer.
+//
+// The resulting optimizer has no Vars.
+// Before it is used, Vars must be set to the variables
+// the optimizer was originally using, in the same order.
+func DeserializeAdagrad(d []byte) (*Adagrad, error) {
+	hyper, sched, state, err := deserializeState(d, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &Adagrad{
+		Schedule:    sched,
+		Epsilon:     hyper[0],
+		WeightDecay: hyper[1],
+		state:       *state,
+	}, nil
+}
+
+// Step performs a step of Adagrad.
+func (a *Adagrad) Step(grad autofunc.Gradient) {
+	epsilon := defaultValue(a.Epsilon, DefaultAdagradEpsilon)
+	rate := a.Schedule.Rate(a.state.Steps)
+	for _, i := range a.state.gradIndices(a.Vars, grad) {
+		v := a.Vars[i]
+		g := decayedGradient(v, grad[v], a.WeightDecay)
+		squareSum := a.state.vectors(i, v, 1)[0]
+		for j, x := range g {
+			squareSum[j] += x * x
+			v.Vector[j] -= rate * x / (math.Sqrt(squareSum[j]) + epsilon)
+		}
+	}
+	a.state.Steps++
+}
+
+// SerializerType returns the unique ID used to serialize
+// an Adagrad optimizer using the serializer package.
+func (a *Adagrad) SerializerType() string {
+	return "github.com/unixpickle/autofunc/optimizers.Adagrad"
+}
+
+// Serialize serializes the optimizer's hyper-parameters,
+// schedule, and state.
+// The Schedule must implement serializer.Serializer.
+func (a *Adagrad) Serialize() ([]byte, error) {
+	return serializeState([]float64{a.Epsilon, a.WeightDecay}, a.Schedule, &a.state)
+}
@@ -0,0 +1,111 @@
+package optimizers
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/serializer"
+)
+
+// Default hyper-parameters for Adam.
+const (
+	DefaultAdamBeta1   = 0.9
+	DefaultAdamBeta2   = 0.999
+	DefaultAdamEpsilon = 1e-8
+)
+
+func init() {
+	var a Adam
+	serializer.RegisterTypedDeserializer(a.SerializerType(), DeserializeAdam)
+}
+
+// Adam is an Optimizer which implements the Adam update
+// rule from https://arxiv.org/abs/1412.6980.
+type Adam struct {
+	// Vars are the variables to update.
+	// The optimizer's state is associated with each
+	// variable by its index in this list.
+	Vars []*autofunc.Variable
+
+	// Schedule determines the step size.
+	Schedule Schedule
+
+	// Beta1 is the decay rate for the first moment.
+	// If it is 0, DefaultAdamBeta1 is used.
+	Beta1 float64
+
+	// Beta2 is the decay rate for the second moment.
+	// If it is 0, DefaultAdamBeta2 is used.
+	Beta2 float64
+
+	// Epsilon is added to the denominator of each update
+	// for numerical stability.
+	// If it is 0, DefaultAdamEpsilon is used.
+	Epsilon float64
+
+	// WeightDecay is the coefficient for an L2 penalty
+	// which is added to the gradient.
+	WeightDecay float64
+
+	state varState
+}
+
+// DeserializeAdam deserializes an Adam optimizer.
+//
+// The resulting optimizer has no Vars.
+// Before it is used, Vars must be set to the variables
+// the optimizer was originally using, in the same order.
+func DeserializeAdam(d []byte) (*Adam, error) {
+	hyper, sched, state, err := deserializeState(d, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &Adam{
+		Schedule:    sched,
+		Beta1:       hyper[0],
+		Beta2:       hyper[1],
+		Epsilon:     hyper[2],
+		WeightDecay: hyper[3],
+		state:       *state,
+	}, nil
+}
+
+// Step performs a step of Adam.
+func (a *Adam) Step(grad autofunc.Gradient) {
+	beta1 := defaultValue(a.Beta1, DefaultAdamBeta1)
+	beta2 := defaultValue(a.Beta2, DefaultAdamBeta2)
+	epsilon := defaultValue(a.Epsilon, DefaultAdamEpsilon)
+
+	a.state.Steps++
+	rate := a.Schedule.Rate(a.state.Steps - 1)
+	correction1 := 1 - math.Pow(beta1, float64(a.state.Steps))
+	correction2 := 1 - math.Pow(beta2, float64(a.state.Steps))
+
+	for _, i := range a.state.gradIndices(a.Vars, grad) {
+		v := a.Vars[i]
+		g := decayedGradient(v, grad[v], a.WeightDecay)
+		moments := a.state.vectors(i, v, 2)
+		first, second := moments[0], moments[1]
+		for j, x := range g {
+			first[j] = beta1*first[j] + (1-beta1)*x
+			second[j] = beta2*second[j] + (1-beta2)*x*x
+			m := first[j] / correction1
+			s := second[j] / correction2
+			v.Vector[j] -= rate * m / (math.Sqrt(s) + epsilon)
+		}
+	}
+}
+
+// SerializerType returns the unique ID used to serialize
+// an Adam optimizer using the serializer package.
+func (a *Adam) SerializerType() string {
+	return "github.com/unixpickle/autofunc/optimizers.Adam"
+}
+
+// Serialize serializes the optimizer's hyper-parameters,
+// schedule, and state.
+// The Schedule must implement serializer.Serializer.
+func (a *Adam) Serialize() ([]byte, error) {
+	hyper := []float64{a.Beta1, a.Beta2, a.Epsilon, a.WeightDecay}
+	return serializeState(hyper, a.Schedule, &a.state)
+}
@@ -0,0 +1,263 @@
+package optimizers
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// Default hyper-parameters for HessianFree.
+const (
+	DefaultHFDamping     = 1
+	DefaultHFMaxCGIters  = 50
+	DefaultHFCGTolerance = 1e-6
+)
+
+// HessianFree is a truncated Newton optimizer which
+// approximately minimizes a local quadratic model of
+// the cost at each step using conjugate gradients.
+//
+// Curvature-vector products are computed with the R
+// operator, so the curvature matrix is never formed.
+// The curvature matrix is either the Hessian or the
+// Gauss-Newton matrix of the cost.
+//
+// Since HessianFree evaluates the cost itself, it does
+// not implement Optimizer.
+type HessianFree struct {
+	// Vars are the variables to update.
+	Vars []*autofunc.Variable
+
+	// Output evaluates the model on the training data.
+	Output func(rv autofunc.RVector) autofunc.RResult
+
+	// Cost maps the output of the model to a scalar cost.
+	//
+	// When GaussNewton is set, the cost must not depend on
+	// any of the Vars except through output.
+	Cost func(rv autofunc.RVector, output autofunc.RResult) autofunc.RResult
+
+	// GaussNewton indicates that the Gauss-Newton matrix
+	// should be used instead of the Hessian.
+	// The Gauss-Newton matrix is positive semi-definite
+	// when the cost is convex in the model's output, making
+	// it better suited to conjugate gradients.
+	GaussNewton bool
+
+	// Damping is the Levenberg-Marquardt coefficient which
+	// is added to the diagonal of the curvature matrix.
+	// It is adjusted after every step based on how well
+	// the quadratic model predicted the change in cost.
+	// If it is 0, DefaultHFDamping is used.
+	Damping float64
+
+	// MaxCGIters is the maximum number of conjugate
+	// gradient iterations per step.
+	// If it is 0, DefaultHFMaxCGIters is used.
+	MaxCGIters int
+
+	// CGTolerance is the residual norm, relative to the
+	// gradient norm, at which conjugate gradients stops.
+	// If it is 0, DefaultHFCGTolerance is used.
+	CGTolerance float64
+
+	// Precondition enables a diagonal preconditioner for
+	// conjugate gradients, based on the squared gradient
+	// and the damping coefficient.
+	Precondition bool
+
+	lastDir autofunc.Gradient
+}
+
+// Step performs one truncated Newton step and returns
+// the cost before the step.
+// If the step fails to decrease the cost, the variables
+// are left unchanged and only the damping is updated.
+func (h *HessianFree) Step() float64 {
+	if h.Damping == 0 {
+		h.Damping = DefaultHFDamping
+	}
+
+	cost, grad := h.costAndGrad()
+	dir, curvDir := h.solve(grad)
+
+	// Undamped quadratic model of the change in cost.
+	predicted := varMapDot(grad, dir) + 0.5*varMapDot(dir, curvDir)
+
+	h.addToVars(dir, 1)
+	newCost := h.cost()
+	actual := newCost - cost
+	if actual < 0 {
+		h.lastDir = dir
+	} else {
+		// A rejected direction is a poor warm start.
+		h.addToVars(dir, -1)
+		h.lastDir = nil
+	}
+
+	if predicted < 0 {
+		rho := actual / predicted
+		if rho < 0.25 || math.IsNaN(rho) {
+			h.Damping *= 3.0 / 2
+		} else if rho > 0.75 {
+			h.Damping *= 2.0 / 3
+		}
+	} else {
+		h.Damping *= 3.0 / 2
+	}
+
+	return cost
+}
+
+// CurvatureProduct computes the product of the undamped
+// curvature matrix and a vector.
+func (h *HessianFree) CurvatureProduct(vec autofunc.RVector) autofunc.Gradient {
+	if h.GaussNewton {
+		return h.gaussNewtonProduct(vec)
+	}
+	return h.hessianProduct(vec)
+}
+
+func (h *HessianFree) solve(grad autofunc.Gradient) (dir, curvDir autofunc.Gradient) {
+	maxIters := h.MaxCGIters
+	if maxIters == 0 {
+		maxIters = DefaultHFMaxCGIters
+	}
+	tol := h.CGTolerance
+	if tol == 0 {
+		tol = DefaultHFCGTolerance
+	}
+	tol *= math.Sqrt(varMapDot(grad, grad))
+
+	precond := h.preconditioner(grad)
+
+	// Warm start from the previous direction, which is
+	// often a good guess for the next one.
+	dir = autofunc.NewGradient(h.Vars)
+	if h.lastDir != nil {
+		varMapAdd(dir, h.lastDir, 0.95)
+	}
+
+	curvDir = h.CurvatureProduct(autofunc.RVector(dir))
+	residual := grad.Copy()
+	residual.Scale(-1)
+	varMapAdd(residual, curvDir, -1)
+	varMapAdd(residual, dir, -h.Damping)
+
+	z := varMapMul(residual, precond)
+	search := z.Copy()
+	rz := varMapDot(residual, z)
+	for i := 0; i < maxIters && math.Sqrt(varMapDot(residual, residual)) > tol; i++ {
+		curvSearch := h.CurvatureProduct(autofunc.RVector(search))
+		dampedProd := varMapDot(search, curvSearch) + h.Damping*varMapDot(search, search)
+		if dampedProd <= 0 {
+			// The curvature is not positive definite in this
+			// direction, so the quadratic has no minimum.
+			break
+		}
+		alpha := rz / dampedProd
+		varMapAdd(dir, search, alpha)
+		varMapAdd(curvDir, curvSearch, alpha)
+		varMapAdd(residual, curvSearch, -alpha)
+		varMapAdd(residual, search, -alpha*h.Damping)
+
+		z = varMapMul(residual, precond)
+		newRZ := varMapDot(residual, z)
+		search.Scale(newRZ / rz)
+		varMapAdd(search, z, 1)
+		rz = newRZ
+	}
+	return
+}
+
+// preconditioner returns the inverse of the diagonal
+// preconditioning matrix.
+func (h *HessianFree) preconditioner(grad autofunc.Gradient) autofunc.Gradient {
+	res := autofunc.NewGradient(h.Vars)
+	for v, vec := range res {
+		g, ok := grad[v]
+		if !h.Precondition || !ok {
+			for i := range vec {
+				vec[i] = 1
+			}
+			continue
+		}
+		for i, x := range g {
+			vec[i] = 1 / math.Pow(x*x+h.Damping, 0.75)
+		}
+	}
+	return res
+}
+
+func (h *HessianFree) cost() float64 {
+	rv := autofunc.RVector{}
+	return h.Cost(rv, h.Output(rv)).Output()[0]
+}
+
+func (h *HessianFree) costAndGrad() (float64, autofunc.Gradient) {
+	rv := autofunc.RVector{}
+	grad := autofunc.NewGradient(h.Vars)
+	rgrad := autofunc.NewRGradient(h.Vars)
+	out := h.Cost(rv, h.Output(rv))
+	out.PropagateRGradient(linalg.Vector{1}, linalg.Vector{0}, rgrad, grad)
+	return out.Output()[0], grad
+}
+
+func (h *HessianFree) hessianProduct(vec autofunc.RVector) autofunc.Gradient {
+	rgrad := autofunc.NewRGradient(h.Vars)
+	out := h.Cost(vec, h.Output(vec))
+	out.PropagateRGradient(linalg.Vector{1}, linalg.Vector{0}, rgrad, nil)
+	return autofunc.Gradient(rgrad)
+}
+
+func (h *HessianFree) gaussNewtonProduct(vec autofunc.RVector) autofunc.Gradient {
+	// The Gauss-Newton product is J'*H*J*v, where J is the
+	// Jacobian of the output and H is the Hessian of the
+	// cost with respect to the output.
+	out := h.Output(vec)
+	outVar := &autofunc.Variable{Vector: out.Output()}
+	outRV := autofunc.RVector{outVar: out.ROutput()}
+	outRGrad := autofunc.NewRGradient([]*autofunc.Variable{outVar})
+	cost := h.Cost(outRV, autofunc.NewRVariable(outVar, outRV))
+	cost.PropagateRGradient(linalg.Vector{1}, linalg.Vector{0}, outRGrad, nil)
+
+	grad := autofunc.NewGradient(h.Vars)
+	rgrad := autofunc.NewRGradient(h.Vars)
+	upstreamR := make(linalg.Vector, len(out.Output()))
+	out.PropagateRGradient(outRGrad[outVar], upstreamR, rgrad, grad)
+	return grad
+}
+
+func (h *HessianFree) addToVars(dir autofunc.Gradient, scale float64) {
+	for _, v := range h.Vars {
+		axpy(scale, dir[v], v.Vector)
+	}
+}
+
+func varMapDot(m1, m2 map[*autofunc.Variable]linalg.Vector) float64 {
+	var res float64
+	for v, vec := range m1 {
+		res += vec.DotFast(m2[v])
+	}
+	return res
+}
+
+// varMapAdd adds scale*m1 to m.
+func varMapAdd(m, m1 map[*autofunc.Variable]linalg.Vector, scale float64) {
+	for v, vec := range m {
+		axpy(scale, m1[v], vec)
+	}
+}
+
+// varMapMul returns the component-wise product of two
+// variable maps.
+func varMapMul(m1, m2 autofunc.Gradient) autofunc.Gradient {
+	res := m1.Copy()
+	for v, vec := range res {
+		for i, x := range m2[v] {
+			vec[i] *= x
+		}
+	}
+	return res
+}
@@ -0,0 +1,121 @@
+// Package optimizers implements first-order optimizers
+// which update autofunc Variables using gradients.
+package optimizers
+
+import (
+	"sort"
+
+	"github.com/gonum/blas/blas64"
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// An Optimizer minimizes a cost by updating Variables
+// based on the gradient of the cost.
+type Optimizer interface {
+	// Step updates the Variables using the gradient of
+	// the cost with respect to them.
+	// Variables missing from the gradient are left alone.
+	//
+	// The gradient is not modified.
+	Step(grad autofunc.Gradient)
+}
+
+// varState stores the step count and the per-variable
+// state vectors of an Optimizer.
+//
+// The state vectors for the i-th variable in an
+// optimizer's variable list are stored in Vecs[i].
+type varState struct {
+	Steps int
+	Vecs  [][]linalg.Vector
+
+	// index caches the position of each variable in the
+	// optimizer's variable list.
+	index map[*autofunc.Variable]int
+}
+
+// gradIndices returns, in ascending order, the indices of
+// the variables in vars which have entries in grad.
+//
+// When grad has fewer entries than vars (e.g. when it
+// only has the rows of an autofunc.Embedding which were
+// looked up), the time taken is proportional to the size
+// of grad rather than to the size of vars.
+func (v *varState) gradIndices(vars []*autofunc.Variable, grad autofunc.Gradient) []int {
+	var res []int
+	if len(grad) >= len(vars) {
+		for i, variable := range vars {
+			if _, ok := grad[variable]; ok {
+				res = append(res, i)
+			}
+		}
+		return res
+	}
+	rebuilt := false
+	for variable := range grad {
+		idx, ok := v.index[variable]
+		if !ok || idx >= len(vars) || vars[idx] != variable {
+			if rebuilt {
+				continue
+			}
+			// The variable list has changed since the index
+			// was built.
+			v.index = make(map[*autofunc.Variable]int, len(vars))
+			for i := len(vars) - 1; i >= 0; i-- {
+				v.index[vars[i]] = i
+			}
+			rebuilt = true
+			if idx, ok = v.index[variable]; !ok {
+				continue
+			}
+		}
+		res = append(res, idx)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// vectors returns the n state vectors for a variable,
+// allocating them if necessary.
+func (v *varState) vectors(idx int, variable *autofunc.Variable, n int) []linalg.Vector {
+	for len(v.Vecs) <= idx {
+		v.Vecs = append(v.Vecs, nil)
+	}
+	if v.Vecs[idx] == nil {
+		vecs := make([]linalg.Vector, n)
+		for i := range vecs {
+			vecs[i] = make(linalg.Vector, len(variable.Vector))
+		}
+		v.Vecs[idx] = vecs
+	}
+	for _, vec := range v.Vecs[idx] {
+		if len(vec) != len(variable.Vector) {
+			panic("optimizer state does not match variable")
+		}
+	}
+	return v.Vecs[idx]
+}
+
+// decayedGradient returns the gradient for a variable
+// with an L2 weight decay term added.
+// The original gradient is not modified.
+func decayedGradient(v *autofunc.Variable, grad linalg.Vector, decay float64) linalg.Vector {
+	if decay == 0 {
+		return grad
+	}
+	return v.Vector.Copy().Scale(decay).Add(grad)
+}
+
+// axpy adds scale*x to y.
+func axpy(scale float64, x, y linalg.Vector) {
+	blas64.Axpy(len(x), scale, blas64.Vector{Data: x, Inc: 1},
+		blas64.Vector{Data: y, Inc: 1})
+}
+
+func defaultValue(val, def float64) float64 {
+	if val == 0 {
+		return def
+	}
+	return val
+}
@@ -0,0 +1,98 @@
+package optimizers
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/serializer"
+)
+
+// Default hyper-parameters for RMSProp.
+const (
+	DefaultRMSPropDecay   = 0.9
+	DefaultRMSPropEpsilon = 1e-8
+)
+
+func init() {
+	var r RMSProp
+	serializer.RegisterTypedDeserializer(r.SerializerType(), DeserializeRMSProp)
+}
+
+// RMSProp is an Optimizer which divides each gradient
+// component by a running root-mean-square of that
+// component's past values.
+type RMSProp struct {
+	// Vars are the variables to update.
+	// The optimizer's state is associated with each
+	// variable by its index in this list.
+	Vars []*autofunc.Variable
+
+	// Schedule determines the step size.
+	Schedule Schedule
+
+	// Decay is the decay rate for the running average
+	// of squared gradients.
+	// If it is 0, DefaultRMSPropDecay is used.
+	Decay float64
+
+	// Epsilon is added to the denominator of each update
+	// for numerical stability.
+	// If it is 0, DefaultRMSPropEpsilon is used.
+	Epsilon float64
+
+	// WeightDecay is the coefficient for an L2 penalty
+	// which is added to the gradient.
+	WeightDecay float64
+
+	state varState
+}
+
+// DeserializeRMSProp deserializes an RMSProp optimizer.
+//
+// The resulting optimizer has no Vars.
+// Before it is used, Vars must be set to the variables
+// the optimizer was originally using, in the same order.
+func DeserializeRMSProp(d []byte) (*RMSProp, error) {
+	hyper, sched, state, err := deserializeState(d, 3)
+	if err != nil {
+		return nil, err
+	}
+	return &RMSProp{
+		Schedule:    sched,
+		Decay:       hyper[0],
+		Epsilon:     hyper[1],
+		WeightDecay: hyper[2],
+		state:       *state,
+	}, nil
+}
+
+// Step performs a step of RMSProp.
+func (r *RMSProp) Step(grad autofunc.Gradient) {
+	decay := defaultValue(r.Decay, DefaultRMSPropDecay)
+	epsilon := defaultValue(r.Epsilon, DefaultRMSPropEpsilon)
+	rate := r.Schedule.Rate(r.state.Steps)
+	for _, i := range r.state.gradIndices(r.Vars, grad) {
+		v := r.Vars[i]
+		g := decayedGradient(v, grad[v], r.WeightDecay)
+		meanSquare := r.state.vectors(i, v, 1)[0]
+		for j, x := range g {
+			meanSquare[j] = decay*meanSquare[j] + (1-decay)*x*x
+			v.Vector[j] -= rate * x / (math.Sqrt(meanSquare[j]) + epsilon)
+		}
+	}
+	r.state.Steps++
+}
+
+// SerializerType returns the unique ID used to serialize
+// an RMSProp optimizer using the serializer package.
+func (r *RMSProp) SerializerType() string {
+	return "github.com/unixpickle/autofunc/optimizers.RMSProp"
+}
+
+// Serialize serializes the optimizer's hyper-parameters,
+// schedule, and state.
+// The Schedule must implement serializer.Serializer.
+func (r *RMSProp) Serialize() ([]byte, error) {
+	hyper := []float64{r.Decay, r.Epsilon, r.WeightDecay}
+	return serializeState(hyper, r.Schedule, &r.state)
+}
@@ -0,0 +1,107 @@
+package optimizers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var c ConstSchedule
+	serializer.RegisterTypedDeserializer(c.SerializerType(), DeserializeConstSchedule)
+	var e ExpSchedule
+	serializer.RegisterTypedDeserializer(e.SerializerType(), DeserializeExpSchedule)
+}
+
+// A Schedule determines the learning rate (step size)
+// for each step of an optimizer.
+//
+// In order for an optimizer to be serialized, its
+// Schedule must implement serializer.Serializer.
+type Schedule interface {
+	// Rate returns the learning rate for the given
+	// step, where the first step is 0.
+	Rate(step int) float64
+}
+
+// A ConstSchedule is a Schedule which always returns
+// the same learning rate.
+type ConstSchedule float64
+
+// DeserializeConstSchedule deserializes a ConstSchedule.
+func DeserializeConstSchedule(d []byte) (ConstSchedule, error) {
+	var rate float64
+	if err := binary.Read(bytes.NewBuffer(d), binary.LittleEndian, &rate); err != nil {
+		return 0, err
+	}
+	return ConstSchedule(rate), nil
+}
+
+// Rate returns c.
+func (c ConstSchedule) Rate(step int) float64 {
+	return float64(c)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a ConstSchedule using the serializer package.
+func (c ConstSchedule) SerializerType() string {
+	return "github.com/unixpickle/autofunc/optimizers.ConstSchedule"
+}
+
+// Serialize serializes the schedule.
+func (c ConstSchedule) Serialize() ([]byte, error) {
+	var w bytes.Buffer
+	binary.Write(&w, binary.LittleEndian, float64(c))
+	return w.Bytes(), nil
+}
+
+// An ExpSchedule is a Schedule which decays the learning
+// rate exponentially.
+// The rate at step t is Initial*Decay^(t/Interval).
+type ExpSchedule struct {
+	Initial float64
+	Decay   float64
+
+	// Interval is the number of steps it takes for the
+	// rate to be scaled by Decay.
+	// If it is 0, an interval of 1 is used.
+	Interval float64
+}
+
+// DeserializeExpSchedule deserializes an ExpSchedule.
+func DeserializeExpSchedule(d []byte) (*ExpSchedule, error) {
+	var res ExpSchedule
+	reader := bytes.NewBuffer(d)
+	for _, x := range []*float64{&res.Initial, &res.Decay, &res.Interval} {
+		if err := binary.Read(reader, binary.LittleEndian, x); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+// Rate returns the decayed learning rate.
+func (e *ExpSchedule) Rate(step int) float64 {
+	interval := e.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	return e.Initial * math.Pow(e.Decay, float64(step)/interval)
+}
+
+// SerializerType returns the unique ID used to serialize
+// an ExpSchedule using the serializer package.
+func (e *ExpSchedule) SerializerType() string {
+	return "github.com/unixpickle/autofunc/optimizers.ExpSchedule"
+}
+
+// Serialize serializes the schedule.
+func (e *ExpSchedule) Serialize() ([]byte, error) {
+	var w bytes.Buffer
+	for _, x := range []float64{e.Initial, e.Decay, e.Interval} {
+		binary.Write(&w, binary.LittleEndian, x)
+	}
+	return w.Bytes(), nil
+}
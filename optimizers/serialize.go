@@ -0,0 +1,125 @@
+package optimizers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/serializer"
+)
+
+// serializeState encodes an optimizer's hyper-parameters,
+// learning rate schedule, and variable state.
+func serializeState(hyper []float64, sched Schedule, state *varState) ([]byte, error) {
+	s, ok := sched.(serializer.Serializer)
+	if !ok {
+		return nil, errors.New("schedule does not implement serializer.Serializer")
+	}
+	schedData, err := serializer.SerializeWithType(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var w bytes.Buffer
+	for _, x := range hyper {
+		binary.Write(&w, binary.LittleEndian, x)
+	}
+	binary.Write(&w, binary.LittleEndian, uint64(len(schedData)))
+	w.Write(schedData)
+	binary.Write(&w, binary.LittleEndian, uint64(state.Steps))
+	binary.Write(&w, binary.LittleEndian, uint64(len(state.Vecs)))
+	for _, vecs := range state.Vecs {
+		binary.Write(&w, binary.LittleEndian, uint64(len(vecs)))
+		for _, vec := range vecs {
+			binary.Write(&w, binary.LittleEndian, uint64(len(vec)))
+			for _, x := range vec {
+				binary.Write(&w, binary.LittleEndian, x)
+			}
+		}
+	}
+	return w.Bytes(), nil
+}
+
+// deserializeState decodes data from serializeState.
+func deserializeState(d []byte, numHyper int) (hyper []float64, sched Schedule,
+	state *varState, err error) {
+	reader := bytes.NewBuffer(d)
+
+	hyper = make([]float64, numHyper)
+	for i := range hyper {
+		if err = binary.Read(reader, binary.LittleEndian, &hyper[i]); err != nil {
+			return
+		}
+	}
+
+	var schedSize uint64
+	if err = binary.Read(reader, binary.LittleEndian, &schedSize); err != nil {
+		return
+	}
+	if schedSize > uint64(reader.Len()) {
+		err = errors.New("schedule data out of bounds")
+		return
+	}
+	schedObj, err := serializer.DeserializeWithType(reader.Next(int(schedSize)))
+	if err != nil {
+		return
+	}
+	var ok bool
+	if sched, ok = schedObj.(Schedule); !ok {
+		err = errors.New("deserialized object is not a Schedule")
+		return
+	}
+
+	var steps, numVars uint64
+	if err = binary.Read(reader, binary.LittleEndian, &steps); err != nil {
+		return
+	}
+	if err = binary.Read(reader, binary.LittleEndian, &numVars); err != nil {
+		return
+	}
+	if err = checkCount(numVars, reader); err != nil {
+		return
+	}
+	state = &varState{Steps: int(steps), Vecs: make([][]linalg.Vector, int(numVars))}
+	for i := range state.Vecs {
+		var numVecs uint64
+		if err = binary.Read(reader, binary.LittleEndian, &numVecs); err != nil {
+			return
+		}
+		if numVecs == 0 {
+			continue
+		}
+		if err = checkCount(numVecs, reader); err != nil {
+			return
+		}
+		state.Vecs[i] = make([]linalg.Vector, int(numVecs))
+		for j := range state.Vecs[i] {
+			var size uint64
+			if err = binary.Read(reader, binary.LittleEndian, &size); err != nil {
+				return
+			}
+			if err = checkCount(size, reader); err != nil {
+				return
+			}
+			vec := make(linalg.Vector, int(size))
+			for k := range vec {
+				if err = binary.Read(reader, binary.LittleEndian, &vec[k]); err != nil {
+					return
+				}
+			}
+			state.Vecs[i][j] = vec
+		}
+	}
+	return
+}
+
+// checkCount makes sure that the reader has enough data
+// left for count 8-byte values, so that corrupt sizes do
+// not cause huge or negative allocations.
+func checkCount(count uint64, r *bytes.Buffer) error {
+	if count > uint64(r.Len())/8 {
+		return errors.New("state data out of bounds")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package optimizers
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var s SGD
+	serializer.RegisterTypedDeserializer(s.SerializerType(), DeserializeSGD)
+}
+
+// SGD is an Optimizer which performs stochastic gradient
+// descent with optional momentum.
+type SGD struct {
+	// Vars are the variables to update.
+	// The optimizer's state is associated with each
+	// variable by its index in this list.
+	Vars []*autofunc.Variable
+
+	// Schedule determines the step size.
+	Schedule Schedule
+
+	// Momentum is the decay rate for the velocity.
+	// If it is 0, plain SGD is used.
+	Momentum float64
+
+	// WeightDecay is the coefficient for an L2 penalty
+	// which is added to the gradient.
+	WeightDecay float64
+
+	state varState
+}
+
+// DeserializeSGD deserializes an SGD optimizer.
+//
+// The resulting optimizer has no Vars.
+// Before it is used, Vars must be set to the variables
+// the optimizer was originally using, in the same order.
+func DeserializeSGD(d []byte) (*SGD, error) {
+	hyper, sched, state, err := deserializeState(d, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &SGD{
+		Schedule:    sched,
+		Momentum:    hyper[0],
+		WeightDecay: hyper[1],
+		state:       *state,
+	}, nil
+}
+
+// Step performs a step of gradient descent.
+func (s *SGD) Step(grad autofunc.Gradient) {
+	rate := s.Schedule.Rate(s.state.Steps)
+	for _, i := range s.state.gradIndices(s.Vars, grad) {
+		v := s.Vars[i]
+		g := decayedGradient(v, grad[v], s.WeightDecay)
+		if s.Momentum == 0 {
+			axpy(-rate, g, v.Vector)
+			continue
+		}
+		velocity := s.state.vectors(i, v, 1)[0]
+		velocity.Scale(s.Momentum).Add(g)
+		axpy(-rate, velocity, v.Vector)
+	}
+	s.state.Steps++
+}
+
+// SerializerType returns the unique ID used to serialize
+// an SGD optimizer using the serializer package.
+func (s *SGD) SerializerType() string {
+	return "github.com/unixpickle/autofunc/optimizers.SGD"
+}
+
+// Serialize serializes the optimizer's hyper-parameters,
+// schedule, and state.
+// The Schedule must implement serializer.Serializer.
+func (s *SGD) Serialize() ([]byte, error) {
+	return serializeState([]float64{s.Momentum, s.WeightDecay}, s.Schedule, &s.state)
+}
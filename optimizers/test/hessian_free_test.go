@@ -0,0 +1,149 @@
+package optimizerstest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/costs"
+	"github.com/unixpickle/autofunc/optimizers"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+func TestHessianFreeQuadratic(t *testing.T) {
+	for _, gaussNewton := range []bool{false, true} {
+		v := &autofunc.Variable{Vector: make(linalg.Vector, len(quadraticTarget))}
+		target := &autofunc.Variable{Vector: quadraticTarget}
+		hf := &optimizers.HessianFree{
+			Vars: []*autofunc.Variable{v},
+			Output: func(rv autofunc.RVector) autofunc.RResult {
+				return autofunc.NewRVariable(v, rv)
+			},
+			Cost: func(rv autofunc.RVector, out autofunc.RResult) autofunc.RResult {
+				return costs.MeanSquaredCost{}.CostR(rv, out, autofunc.NewRVariable(target, rv))
+			},
+			GaussNewton: gaussNewton,
+			Damping:     1e-4,
+		}
+		hf.Step()
+		for i, x := range quadraticTarget {
+			if math.Abs(v.Vector[i]-x) > 1e-3 {
+				t.Errorf("GaussNewton=%v: expected %v but got %v", gaussNewton,
+					quadraticTarget, v.Vector)
+				break
+			}
+		}
+	}
+}
+
+func TestHessianFreeCurvature(t *testing.T) {
+	// For a linear model with a squared error cost, the
+	// Hessian and Gauss-Newton matrices are both 2*A'*A.
+	mat := &autofunc.LinTran{
+		Data: &autofunc.Variable{Vector: linalg.Vector{1, 2, -1, 0.5, 3, 1}},
+		Rows: 2,
+		Cols: 3,
+	}
+	v := &autofunc.Variable{Vector: linalg.Vector{0.3, -0.2, 1}}
+	vec := autofunc.RVector{v: linalg.Vector{1, -1, 2}}
+	expected := linalg.Vector{-6.5, -15, 5}
+	for _, gaussNewton := range []bool{false, true} {
+		hf := &optimizers.HessianFree{
+			Vars: []*autofunc.Variable{v},
+			Output: func(rv autofunc.RVector) autofunc.RResult {
+				return mat.ApplyR(rv, autofunc.NewRVariable(v, rv))
+			},
+			Cost: func(rv autofunc.RVector, out autofunc.RResult) autofunc.RResult {
+				return autofunc.SquaredNorm{}.ApplyR(rv, out)
+			},
+			GaussNewton: gaussNewton,
+		}
+		actual := hf.CurvatureProduct(vec)[v]
+		for i, x := range expected {
+			if math.Abs(actual[i]-x) > 1e-8 {
+				t.Errorf("GaussNewton=%v: expected %v but got %v", gaussNewton, expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestHessianFreeLogistic(t *testing.T) {
+	rand.Seed(1337)
+	const numSamples = 20
+	inputs := &autofunc.Variable{Vector: linalg.RandVector(numSamples * 3)}
+	labels := &autofunc.Variable{Vector: make(linalg.Vector, numSamples)}
+	for i := range labels.Vector {
+		x := inputs.Vector[i*3 : (i+1)*3]
+		if x[0]-2*x[1]+0.5*x[2]+0.3*rand.NormFloat64() > 0 {
+			labels.Vector[i] = 1
+		}
+	}
+	weights := &autofunc.Variable{Vector: make(linalg.Vector, 3)}
+	layer := &autofunc.LinTran{Data: weights, Rows: 1, Cols: 3}
+
+	for _, gaussNewton := range []bool{false, true} {
+		for _, precond := range []bool{false, true} {
+			for i := range weights.Vector {
+				weights.Vector[i] = 0
+			}
+			hf := &optimizers.HessianFree{
+				Vars: []*autofunc.Variable{weights},
+				Output: func(rv autofunc.RVector) autofunc.RResult {
+					return layer.BatchR(rv, autofunc.NewRVariable(inputs, rv), numSamples)
+				},
+				Cost: func(rv autofunc.RVector, out autofunc.RResult) autofunc.RResult {
+					c := costs.BinaryCrossEntropyCost{Logits: true}
+					return c.BatchCostR(rv, out, autofunc.NewRVariable(labels, rv), numSamples)
+				},
+				GaussNewton:  gaussNewton,
+				Precondition: precond,
+			}
+			initial := hf.Step()
+			var last float64
+			for i := 0; i < 10; i++ {
+				last = hf.Step()
+			}
+			if !(last < initial/2) {
+				t.Errorf("GaussNewton=%v Precondition=%v: cost went from %f to %f",
+					gaussNewton, precond, initial, last)
+			}
+		}
+	}
+}
+
+func TestHessianFreeRejectedStep(t *testing.T) {
+	// The cost sum(sqrt(1+x^2)) has very little curvature
+	// away from the origin, so the first step overshoots.
+	newHF := func(v *autofunc.Variable, damping float64) *optimizers.HessianFree {
+		return &optimizers.HessianFree{
+			Vars: []*autofunc.Variable{v},
+			Output: func(rv autofunc.RVector) autofunc.RResult {
+				return autofunc.NewRVariable(v, rv)
+			},
+			Cost: func(rv autofunc.RVector, out autofunc.RResult) autofunc.RResult {
+				return autofunc.SumAllR(autofunc.PowR(autofunc.AddScalerR(
+					autofunc.MulR(out, out), 1), 0.5))
+			},
+			Damping:    damping,
+			MaxCGIters: 1,
+		}
+	}
+	start := linalg.Vector{2, 3}
+	v := &autofunc.Variable{Vector: start.Copy()}
+	hf := newHF(v, 0.11)
+	hf.Step()
+	if v.Vector.Copy().Scale(-1).Add(start).MaxAbs() != 0 {
+		t.Fatalf("expected rejected step but got %v", v.Vector)
+	}
+
+	// After a rejected step, the next step should not be
+	// warm-started from the rejected direction.
+	fresh := &autofunc.Variable{Vector: start.Copy()}
+	newHF(fresh, hf.Damping).Step()
+	hf.Step()
+	if v.Vector.Copy().Scale(-1).Add(fresh.Vector).MaxAbs() > 1e-10 {
+		t.Errorf("expected %v but got %v", fresh.Vector, v.Vector)
+	}
+}
@@ -0,0 +1,209 @@
+package optimizerstest
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/optimizers"
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/serializer"
+)
+
+var quadraticTarget = linalg.Vector{1, -2, 0.5, 3}
+
+type optimizerTest struct {
+	Steps int
+	Make  func(vars []*autofunc.Variable) optimizers.Optimizer
+}
+
+var optimizerTests = map[string]optimizerTest{
+	"SGD": {
+		Steps: 200,
+		Make: func(vars []*autofunc.Variable) optimizers.Optimizer {
+			return &optimizers.SGD{Vars: vars, Schedule: optimizers.ConstSchedule(0.1)}
+		},
+	},
+	"SGDMomentum": {
+		Steps: 200,
+		Make: func(vars []*autofunc.Variable) optimizers.Optimizer {
+			return &optimizers.SGD{
+				Vars:     vars,
+				Schedule: &optimizers.ExpSchedule{Initial: 0.05, Decay: 0.5, Interval: 100},
+				Momentum: 0.9,
+			}
+		},
+	},
+	"Adam": {
+		Steps: 2000,
+		Make: func(vars []*autofunc.Variable) optimizers.Optimizer {
+			return &optimizers.Adam{Vars: vars, Schedule: optimizers.ConstSchedule(0.01)}
+		},
+	},
+	"RMSProp": {
+		Steps: 2000,
+		Make: func(vars []*autofunc.Variable) optimizers.Optimizer {
+			return &optimizers.RMSProp{
+				Vars:     vars,
+				Schedule: &optimizers.ExpSchedule{Initial: 0.01, Decay: 0.5, Interval: 1000},
+			}
+		},
+	},
+	"Adagrad": {
+		Steps: 2000,
+		Make: func(vars []*autofunc.Variable) optimizers.Optimizer {
+			return &optimizers.Adagrad{Vars: vars, Schedule: optimizers.ConstSchedule(0.5)}
+		},
+	},
+}
+
+func TestOptimizerConvergence(t *testing.T) {
+	for name, test := range optimizerTests {
+		v := &autofunc.Variable{Vector: make(linalg.Vector, len(quadraticTarget))}
+		opt := test.Make([]*autofunc.Variable{v})
+		for i := 0; i < test.Steps; i++ {
+			opt.Step(quadraticGradient(v))
+		}
+		for i, x := range quadraticTarget {
+			if math.Abs(v.Vector[i]-x) > 1e-2 {
+				t.Errorf("%s: expected %v but got %v", name, quadraticTarget, v.Vector)
+				break
+			}
+		}
+	}
+}
+
+func TestOptimizerWeightDecay(t *testing.T) {
+	v := &autofunc.Variable{Vector: make(linalg.Vector, len(quadraticTarget))}
+	opt := &optimizers.SGD{
+		Vars:        []*autofunc.Variable{v},
+		Schedule:    optimizers.ConstSchedule(0.1),
+		WeightDecay: 2,
+	}
+	for i := 0; i < 200; i++ {
+		opt.Step(quadraticGradient(v))
+	}
+	// The minimum of |x-c|^2 + |x|^2 is c/2.
+	for i, x := range quadraticTarget {
+		if math.Abs(v.Vector[i]-x/2) > 1e-4 {
+			t.Errorf("expected %v but got %v", quadraticTarget.Copy().Scale(0.5), v.Vector)
+			break
+		}
+	}
+}
+
+func TestOptimizerSerialize(t *testing.T) {
+	for name, test := range optimizerTests {
+		v := &autofunc.Variable{Vector: make(linalg.Vector, len(quadraticTarget))}
+		opt := test.Make([]*autofunc.Variable{v})
+		for i := 0; i < 10; i++ {
+			opt.Step(quadraticGradient(v))
+		}
+
+		data, err := serializer.SerializeWithType(opt.(serializer.Serializer))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		obj, err := serializer.DeserializeWithType(data)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		resumedVar := &autofunc.Variable{Vector: v.Vector.Copy()}
+		resumed := obj.(optimizers.Optimizer)
+		setVars(resumed, []*autofunc.Variable{resumedVar})
+
+		for i := 0; i < 10; i++ {
+			opt.Step(quadraticGradient(v))
+			resumed.Step(quadraticGradient(resumedVar))
+		}
+		for i, x := range v.Vector {
+			if x != resumedVar.Vector[i] {
+				t.Errorf("%s: expected %v but got %v", name, v.Vector, resumedVar.Vector)
+				break
+			}
+		}
+	}
+}
+
+func TestOptimizerDeserializeCorrupt(t *testing.T) {
+	v := &autofunc.Variable{Vector: make(linalg.Vector, len(quadraticTarget))}
+	opt := &optimizers.Adam{Vars: []*autofunc.Variable{v}, Schedule: optimizers.ConstSchedule(0.01)}
+	opt.Step(quadraticGradient(v))
+	data, err := opt.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(data); i++ {
+		if _, err := optimizers.DeserializeAdam(data[:i]); err == nil {
+			t.Errorf("no error for data truncated to %d bytes", i)
+		}
+	}
+	// Skip the hyper-parameters and schedule, leaving the
+	// encoded variable state.
+	schedSize := binary.LittleEndian.Uint64(data[32:])
+	for i := 40 + int(schedSize); i+8 <= len(data); i++ {
+		corrupt := append([]byte{}, data...)
+		for j := i; j < i+8; j++ {
+			corrupt[j] = 0xff
+		}
+		// Must not panic or allocate huge buffers.
+		optimizers.DeserializeAdam(corrupt)
+	}
+}
+
+func quadraticGradient(v *autofunc.Variable) autofunc.Gradient {
+	grad := autofunc.NewGradient([]*autofunc.Variable{v})
+	target := &autofunc.Variable{Vector: quadraticTarget}
+	cost := autofunc.SquaredNorm{}.Apply(autofunc.Sub(v, target))
+	cost.PropagateGradient(linalg.Vector{1}, grad)
+	return grad
+}
+
+func setVars(opt optimizers.Optimizer, vars []*autofunc.Variable) {
+	switch opt := opt.(type) {
+	case *optimizers.SGD:
+		opt.Vars = vars
+	case *optimizers.Adam:
+		opt.Vars = vars
+	case *optimizers.RMSProp:
+		opt.Vars = vars
+	case *optimizers.Adagrad:
+		opt.Vars = vars
+	}
+}
+
+func TestOptimizerSparseRows(t *testing.T) {
+	for name, test := range optimizerTests {
+		dense := &autofunc.Embedding{
+			Matrix: &autofunc.Variable{Vector: []float64{1, 2, 3, 4, 5, 6}},
+			Cols:   2,
+		}
+		sparse := &autofunc.Embedding{Matrix: &autofunc.Variable{Vector: dense.Matrix.Vector.Copy()},
+			Cols: 2}
+		denseOpt := test.Make(dense.Parameters())
+		sparseOpt := test.Make(sparse.Rows())
+
+		ids := []int{2, 0}
+		upstream := linalg.Vector{1, -2, 0.5, 3}
+		denseGrad := autofunc.NewGradient(dense.Parameters())
+		dense.Lookup(ids).PropagateGradient(upstream, denseGrad)
+		sparseGrad := autofunc.NewGradient(sparse.RowVars(ids))
+		sparse.Lookup(ids).PropagateGradient(upstream, sparseGrad)
+
+		// With no previous steps, untouched rows have zero
+		// gradients and zero state, so both updates agree.
+		denseOpt.Step(denseGrad)
+		sparseOpt.Step(sparseGrad)
+		for i, x := range dense.Matrix.Vector {
+			if x != sparse.Matrix.Vector[i] {
+				t.Errorf("%s: expected %v but got %v", name, dense.Matrix.Vector,
+					sparse.Matrix.Vector)
+				break
+			}
+		}
+	}
+}
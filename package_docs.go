@@ -57,4 +57,7 @@
 // multiple times.
 // This can, in part, be alleviated using the Pool()
 // and PoolR() functions.
+// Alternatively, a Tape (typically set on a Scope) can
+// record Results as they are created, ensuring that each
+// one is back-propagated through exactly once.
 package autofunc
@@ -0,0 +1,142 @@
+package autofunc
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A BatchFunc computes a Result for the samples with
+// indices in [start, end) of a mini-batch.
+// The components of the Result are summed, so it is
+// typically a total cost.
+type BatchFunc func(start, end int) Result
+
+// An RBatchFunc is like a BatchFunc, but for RResults.
+type RBatchFunc func(start, end int) RResult
+
+// ParallelGradient computes gradients for a mini-batch by
+// splitting it into shards and processing every shard on
+// its own goroutine with its own Gradient.
+//
+// The shards are contiguous and are determined only by
+// the batch size and the number of workers.
+// The shards' gradients are summed in order, so the
+// result does not depend on goroutine scheduling.
+//
+// The functions being evaluated must be safe to call
+// concurrently.
+// In particular, the Variables must not be modified and
+// random sources (e.g. Dropout.Rand) must not be shared
+// between goroutines.
+type ParallelGradient struct {
+	// Vars are the variables whose gradients are computed.
+	Vars []*Variable
+
+	// Workers is the maximum number of goroutines to use.
+	// If it is 0 or negative, runtime.GOMAXPROCS(0) is
+	// used.
+	Workers int
+}
+
+// Gradient computes the gradient of the sum of the
+// Results for all n samples.
+// It also returns the sum itself.
+func (p *ParallelGradient) Gradient(n int, f BatchFunc) (Gradient, float64) {
+	shards := p.shards(n)
+	grads := make([]Gradient, len(shards))
+	sums := make([]float64, len(shards))
+	p.run(shards, func(i, start, end int) {
+		grads[i] = NewGradient(p.Vars)
+		res := f(start, end)
+		out := res.Output()
+		sums[i] = sumVector(out)
+		if !res.Constant(grads[i]) {
+			res.PropagateGradient(onesVector(len(out)), grads[i])
+		}
+	})
+	if len(shards) == 0 {
+		return NewGradient(p.Vars), 0
+	}
+	for i := 1; i < len(grads); i++ {
+		grads[0].Add(grads[i])
+		sums[0] += sums[i]
+	}
+	return grads[0], sums[0]
+}
+
+// RGradient is like Gradient, but it also computes the
+// derivative of the gradient with respect to r.
+func (p *ParallelGradient) RGradient(v RVector, n int,
+	f RBatchFunc) (Gradient, RGradient, float64) {
+	shards := p.shards(n)
+	grads := make([]Gradient, len(shards))
+	rgrads := make([]RGradient, len(shards))
+	sums := make([]float64, len(shards))
+	p.run(shards, func(i, start, end int) {
+		grads[i] = NewGradient(p.Vars)
+		rgrads[i] = NewRGradient(p.Vars)
+		res := f(start, end)
+		out := res.Output()
+		sums[i] = sumVector(out)
+		if !res.Constant(rgrads[i], grads[i]) {
+			res.PropagateRGradient(onesVector(len(out)), make(linalg.Vector, len(out)),
+				rgrads[i], grads[i])
+		}
+	})
+	if len(shards) == 0 {
+		return NewGradient(p.Vars), NewRGradient(p.Vars), 0
+	}
+	for i := 1; i < len(grads); i++ {
+		grads[0].Add(grads[i])
+		rgrads[0].Add(rgrads[i])
+		sums[0] += sums[i]
+	}
+	return grads[0], rgrads[0], sums[0]
+}
+
+// shards splits n samples into contiguous, non-empty
+// ranges, one per worker.
+func (p *ParallelGradient) shards(n int) [][2]int {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	res := make([][2]int, workers)
+	for i := range res {
+		res[i] = [2]int{i * n / workers, (i + 1) * n / workers}
+	}
+	return res
+}
+
+func (p *ParallelGradient) run(shards [][2]int, f func(i, start, end int)) {
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard [2]int) {
+			defer wg.Done()
+			f(i, shard[0], shard[1])
+		}(i, shard)
+	}
+	wg.Wait()
+}
+
+func sumVector(v linalg.Vector) float64 {
+	var res float64
+	for _, x := range v {
+		res += x
+	}
+	return res
+}
+
+func onesVector(size int) linalg.Vector {
+	res := make(linalg.Vector, size)
+	for i := range res {
+		res[i] = 1
+	}
+	return res
+}
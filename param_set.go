@@ -0,0 +1,318 @@
+package autofunc
+
+import (
+	"github.com/gonum/blas/blas64"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A ParamSet assigns each of a list of Variables a dense
+// index and a range in a contiguous buffer.
+//
+// ParamSets are used to create ParamVectors, which store
+// gradients (or RVectors) for all of the Variables in a
+// single buffer.
+//
+// A ParamSet stores the sizes of its Variables when it is
+// created, so Variables should not be resized afterwards.
+type ParamSet struct {
+	vars    []*Variable
+	indices map[*Variable]int
+	offsets []int
+}
+
+// NewParamSet creates a ParamSet for the Variables.
+// The i-th Variable is given index i.
+//
+// The list must not contain duplicates.
+func NewParamSet(vars []*Variable) *ParamSet {
+	res := &ParamSet{
+		vars:    append([]*Variable{}, vars...),
+		indices: make(map[*Variable]int, len(vars)),
+		offsets: make([]int, len(vars)+1),
+	}
+	for i, v := range vars {
+		if _, ok := res.indices[v]; ok {
+			panic("duplicate variable in ParamSet")
+		}
+		res.indices[v] = i
+		res.offsets[i+1] = res.offsets[i] + len(v.Vector)
+	}
+	return res
+}
+
+// Vars returns the Variables in the set, ordered by
+// index.
+// The result should not be modified.
+func (p *ParamSet) Vars() []*Variable {
+	return p.vars
+}
+
+// Index returns the index of a Variable, or -1 if the
+// Variable is not in the set.
+func (p *ParamSet) Index(v *Variable) int {
+	if idx, ok := p.indices[v]; ok {
+		return idx
+	}
+	return -1
+}
+
+// Size returns the total number of components in all of
+// the Variables.
+func (p *ParamSet) Size() int {
+	return p.offsets[len(p.vars)]
+}
+
+// Pack copies the values of the Variables into a single
+// vector.
+func (p *ParamSet) Pack() linalg.Vector {
+	res := make(linalg.Vector, p.Size())
+	for i, v := range p.vars {
+		copy(res[p.offsets[i]:], v.Vector)
+	}
+	return res
+}
+
+// Unpack copies the values from a packed vector into the
+// Variables.
+// It is the inverse of Pack.
+func (p *ParamSet) Unpack(packed linalg.Vector) {
+	if len(packed) != p.Size() {
+		panic("packed vector has wrong size")
+	}
+	for i, v := range p.vars {
+		copy(v.Vector, packed[p.offsets[i]:p.offsets[i+1]])
+	}
+}
+
+// NewVector creates a zero ParamVector for the set.
+func (p *ParamSet) NewVector() *ParamVector {
+	return &ParamVector{
+		Params: p,
+		Buffer: make(linalg.Vector, p.Size()),
+	}
+}
+
+// VectorFromMap creates a ParamVector with the values
+// from a Gradient, RGradient, or RVector.
+// Variables missing from the map are given zero entries,
+// and Variables missing from the set are ignored.
+func (p *ParamSet) VectorFromMap(m map[*Variable]linalg.Vector) *ParamVector {
+	res := p.NewVector()
+	for i, v := range p.vars {
+		if vec, ok := m[v]; ok {
+			copy(res.At(i), vec)
+		}
+	}
+	return res
+}
+
+// A ParamVector stores a vector for every Variable in a
+// ParamSet, packed one after another in Buffer.
+//
+// The per-Variable vectors are views into Buffer, so
+// whole-set operations like Zero and Add are performed
+// on one contiguous vector.
+//
+// Existing Results operate on maps, so a ParamVector can
+// be viewed as a Gradient, RGradient, or RVector whose
+// entries share memory with Buffer.
+type ParamVector struct {
+	Params *ParamSet
+	Buffer linalg.Vector
+
+	views map[*Variable]linalg.Vector
+}
+
+// At returns the vector for the Variable with the given
+// index.
+// The result is a view into p.Buffer.
+func (p *ParamVector) At(idx int) linalg.Vector {
+	offsets := p.Params.offsets
+	return p.Buffer[offsets[idx]:offsets[idx+1]:offsets[idx+1]]
+}
+
+// View returns the vector for a Variable, or nil if the
+// Variable is not in the set.
+// The result is a view into p.Buffer.
+func (p *ParamVector) View(v *Variable) linalg.Vector {
+	idx := p.Params.Index(v)
+	if idx < 0 {
+		return nil
+	}
+	return p.At(idx)
+}
+
+// Gradient returns a Gradient whose entries are views
+// into p.Buffer.
+//
+// The same map is returned every time, so it should not
+// have entries added or removed.
+func (p *ParamVector) Gradient() Gradient {
+	return Gradient(p.viewMap())
+}
+
+// RGradient is like Gradient, but for an RGradient.
+func (p *ParamVector) RGradient() RGradient {
+	return RGradient(p.viewMap())
+}
+
+// RVector is like Gradient, but for an RVector.
+func (p *ParamVector) RVector() RVector {
+	return RVector(p.viewMap())
+}
+
+// Zero sets all of the entries to 0.
+func (p *ParamVector) Zero() {
+	for i := range p.Buffer {
+		p.Buffer[i] = 0
+	}
+}
+
+// Add adds the entries of p1 to p.
+// Both vectors must come from the same ParamSet.
+func (p *ParamVector) Add(p1 *ParamVector) {
+	p.checkSet(p1)
+	p.Buffer.Add(p1.Buffer)
+}
+
+// Scale scales all of the entries by f.
+func (p *ParamVector) Scale(f float64) {
+	p.Buffer.Scale(f)
+}
+
+// Dot returns the dot product of p and p1.
+// Both vectors must come from the same ParamSet.
+func (p *ParamVector) Dot(p1 *ParamVector) float64 {
+	p.checkSet(p1)
+	return p.Buffer.Dot(p1.Buffer)
+}
+
+// AddToVars adds the entries, scaled by scale, to their
+// corresponding Variables.
+func (p *ParamVector) AddToVars(scale float64) {
+	for i, v := range p.Params.vars {
+		grad := p.At(i)
+		blas64.Axpy(len(grad), scale, blas64.Vector{Data: grad, Inc: 1},
+			blas64.Vector{Data: v.Vector, Inc: 1})
+	}
+}
+
+// Copy creates a copy of the vector with its own buffer.
+func (p *ParamVector) Copy() *ParamVector {
+	return &ParamVector{Params: p.Params, Buffer: p.Buffer.Copy()}
+}
+
+func (p *ParamVector) viewMap() map[*Variable]linalg.Vector {
+	if p.views == nil {
+		p.views = make(map[*Variable]linalg.Vector, len(p.Params.vars))
+		for i, v := range p.Params.vars {
+			p.views[v] = p.At(i)
+		}
+	}
+	return p.views
+}
+
+func (p *ParamVector) mustView(v *Variable) linalg.Vector {
+	res := p.View(v)
+	if res == nil {
+		panic("variable not in ParamSet")
+	}
+	return res
+}
+
+func (p *ParamVector) checkSet(p1 *ParamVector) {
+	if p.Params != p1.Params {
+		panic("ParamVectors come from different ParamSets")
+	}
+}
+
+// A ParamVar is a Result for a Variable in a ParamSet
+// which adds its gradient directly to a ParamVector.
+//
+// A Variable looks itself up in the Gradient whenever it
+// is back-propagated through or checked for constancy.
+// A ParamVar finds its entry in the ParamVector once, when
+// it is created, and ignores the Gradient passed to its
+// methods, so it is never constant.
+//
+// ParamVars are opt-in: to use them, a model must be
+// built with them in place of its Variables.
+type ParamVar struct {
+	Variable *Variable
+
+	// Grad is the Variable's entry in a ParamVector.
+	Grad linalg.Vector
+}
+
+// Var creates a ParamVar which adds its gradient to p.
+// It panics if v is not in p's ParamSet.
+func (p *ParamVector) Var(v *Variable) *ParamVar {
+	return &ParamVar{Variable: v, Grad: p.mustView(v)}
+}
+
+func (p *ParamVar) Output() linalg.Vector {
+	return p.Variable.Vector
+}
+
+func (p *ParamVar) Constant(g Gradient) bool {
+	return false
+}
+
+func (p *ParamVar) PropagateGradient(upstream linalg.Vector, grad Gradient) {
+	p.Grad.Add(upstream)
+}
+
+// A ParamRVar is like a ParamVar, but it is an RResult.
+type ParamRVar struct {
+	Variable   *Variable
+	ROutputVec linalg.Vector
+
+	// Grad and RGrad are the Variable's entries in two
+	// ParamVectors.
+	// Grad may be nil, in which case the gradient is
+	// discarded.
+	Grad  linalg.Vector
+	RGrad linalg.Vector
+}
+
+// RVar creates a ParamRVar which adds its r-gradient to p.
+//
+// The r-output is taken from rv and the gradient is added
+// to grad.
+// Either may be nil, in which case the r-output is zero
+// or the gradient is discarded, respectively.
+// The ParamVectors must all come from the same ParamSet.
+func (p *ParamVector) RVar(v *Variable, rv, grad *ParamVector) *ParamRVar {
+	res := &ParamRVar{Variable: v, RGrad: p.mustView(v)}
+	if rv != nil {
+		p.checkSet(rv)
+		res.ROutputVec = rv.View(v)
+	} else {
+		res.ROutputVec = make(linalg.Vector, len(v.Vector))
+	}
+	if grad != nil {
+		p.checkSet(grad)
+		res.Grad = grad.View(v)
+	}
+	return res
+}
+
+func (p *ParamRVar) Output() linalg.Vector {
+	return p.Variable.Vector
+}
+
+func (p *ParamRVar) ROutput() linalg.Vector {
+	return p.ROutputVec
+}
+
+func (p *ParamRVar) Constant(rg RGradient, g Gradient) bool {
+	return false
+}
+
+func (p *ParamRVar) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rgrad RGradient, grad Gradient) {
+	if grad != nil && p.Grad != nil {
+		p.Grad.Add(upstream)
+	}
+	p.RGrad.Add(upstreamR)
+}
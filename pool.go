@@ -36,35 +36,45 @@ func PoolR(r RResult, f func(RResult) RResult) RResult {
 // one time for each back-propagation through the result
 // of the pool.
 func PoolAll(ins []Result, f func([]Result) Result) Result {
+	scope := scopeOf(ins...)
 	poolVars := make([]*Variable, len(ins))
-	poolRes := make([]Result, len(ins))
-	for i, in := range ins {
-		poolVars[i] = &Variable{Vector: in.Output()}
-		poolRes[i] = poolVars[i]
-	}
-	return &pooledResult{
+	var out Result
+	scope.Untaped(func() {
+		poolRes := make([]Result, len(ins))
+		for i, in := range ins {
+			poolVars[i] = &Variable{Vector: in.Output()}
+			poolRes[i] = scope.Result(poolVars[i])
+		}
+		out = f(poolRes)
+	})
+	return scope.result(&pooledResult{
 		Inputs:   ins,
 		PoolVars: poolVars,
-		FOutput:  f(poolRes),
-	}
+		FOutput:  out,
+	})
 }
 
 // PoolAllR is like PoolAll, but for RResults.
 func PoolAllR(ins []RResult, f func([]RResult) RResult) RResult {
+	scope := scopeOfR(ins...)
 	poolVars := make([]*Variable, len(ins))
-	poolRes := make([]RResult, len(ins))
-	for i, in := range ins {
-		poolVars[i] = &Variable{Vector: in.Output()}
-		poolRes[i] = &RVariable{
-			Variable:   poolVars[i],
-			ROutputVec: in.ROutput(),
+	var out RResult
+	scope.Untaped(func() {
+		poolRes := make([]RResult, len(ins))
+		for i, in := range ins {
+			poolVars[i] = &Variable{Vector: in.Output()}
+			poolRes[i] = scope.RResult(&RVariable{
+				Variable:   poolVars[i],
+				ROutputVec: in.ROutput(),
+			})
 		}
-	}
-	return &pooledRResult{
+		out = f(poolRes)
+	})
+	return scope.rresult(&pooledRResult{
 		Inputs:   ins,
 		PoolVars: poolVars,
-		FOutput:  f(poolRes),
-	}
+		FOutput:  out,
+	})
 }
 
 // PoolSplit slices a Result into n even parts and passes
@@ -76,7 +86,8 @@ func PoolAllR(ins []RResult, f func([]RResult) RResult) RResult {
 func PoolSplit(n int, r Result, f func([]Result) Result) Result {
 	return Pool(r, func(in Result) Result {
 		// This is efficient because Slice's result checks if
-		// its input was a *Variable.
+		// its input was a *Variable (possibly attached to a
+		// Scope).
 		return f(Split(n, in))
 	})
 }
@@ -89,6 +100,8 @@ func PoolSplitR(n int, r RResult, f func([]RResult) RResult) RResult {
 }
 
 type pooledResult struct {
+	scopeRef
+
 	Inputs   []Result
 	PoolVars []*Variable
 	FOutput  Result
@@ -117,7 +130,7 @@ func (p *pooledResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 		constants[i] = p.FOutput.Constant(Gradient{v: linalg.Vector{}}) ||
 			p.Inputs[i].Constant(grad)
 		if !constants[i] {
-			grad[v] = make(linalg.Vector, len(v.Vector))
+			grad[v] = p.Scope.Alloc(len(v.Vector))
 		}
 	}
 	p.FOutput.PropagateGradient(upstream, grad)
@@ -136,6 +149,8 @@ func (p *pooledResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type pooledRResult struct {
+	scopeRef
+
 	Inputs   []RResult
 	PoolVars []*Variable
 	FOutput  RResult
@@ -172,8 +187,8 @@ func (p *pooledRResult) PropagateRGradient(upstream, upstreamR linalg.Vector, rg
 		constants[i] = p.FOutput.Constant(RGradient{v: linalg.Vector{}}, nil) ||
 			p.Inputs[i].Constant(rgrad, grad)
 		if !constants[i] {
-			grad[v] = make(linalg.Vector, len(v.Vector))
-			rgrad[v] = make(linalg.Vector, len(v.Vector))
+			grad[v] = p.Scope.Alloc(len(v.Vector))
+			rgrad[v] = p.Scope.Alloc(len(v.Vector))
 		}
 	}
 	p.FOutput.PropagateRGradient(upstream, upstreamR, rgrad, grad)
@@ -0,0 +1,230 @@
+package autofunc
+
+import "github.com/unixpickle/num-analysis/linalg"
+
+type scanResult struct {
+	scopeRef
+
+	Initial   Result
+	Pool      []*Variable
+	States    []Result
+	StateVars []*Variable
+	FOutput   Result
+}
+
+// Scan is like Fold, but it makes every intermediate
+// state available rather than just the final one.
+//
+// The recurrence step is applied to each input in turn,
+// and the resulting states (not including the initial
+// state) are passed to f as a list of Results.
+// The states may have different sizes.
+//
+// Like Pool, back propagation through the result of f
+// visits each step of the recurrence exactly once, even
+// when gradients arrive at several states.
+func Scan(state Result, ins []Result, step func(state, in Result) Result,
+	f func(states []Result) Result) Result {
+	scope := scopeOf(state)
+	if scope == nil {
+		scope = scopeOf(ins...)
+	}
+	res := &scanResult{Initial: state}
+	scope.Untaped(func() {
+		for _, in := range ins {
+			pool := &Variable{Vector: state.Output()}
+			state = step(scope.Result(pool), in)
+			res.Pool = append(res.Pool, pool)
+			res.States = append(res.States, state)
+			res.StateVars = append(res.StateVars, &Variable{Vector: state.Output()})
+		}
+		states := make([]Result, len(res.StateVars))
+		for i, v := range res.StateVars {
+			states[i] = scope.Result(v)
+		}
+		res.FOutput = f(states)
+	})
+	return scope.result(res)
+}
+
+func (s *scanResult) Output() linalg.Vector {
+	return s.FOutput.Output()
+}
+
+func (s *scanResult) Constant(g Gradient) bool {
+	if !s.FOutput.Constant(g) {
+		return false
+	}
+	constants := s.constantStates(g)
+	for i, v := range s.StateVars {
+		if !constants[i] && !s.FOutput.Constant(Gradient{v: linalg.Vector{}}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scanResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	constants := s.constantStates(g)
+	for i, v := range s.StateVars {
+		if !constants[i] {
+			g[v] = s.Scope.Alloc(len(v.Vector))
+		}
+	}
+	s.FOutput.PropagateGradient(u, g)
+
+	var stateUp linalg.Vector
+	for i := len(s.States) - 1; i >= 0; i-- {
+		if constants[i] {
+			// Every earlier state is constant as well.
+			break
+		}
+		v := s.StateVars[i]
+		up := g[v]
+		delete(g, v)
+		if stateUp != nil {
+			up.Add(stateUp)
+		}
+		stateUp = nil
+		res := s.States[i]
+		p := s.Pool[i]
+		g[p] = s.Scope.Alloc(len(p.Vector))
+		if !res.Constant(g) {
+			res.PropagateGradient(up, g)
+			stateUp = g[p]
+		}
+		delete(g, p)
+	}
+	if stateUp != nil && !s.Initial.Constant(g) {
+		s.Initial.PropagateGradient(stateUp, g)
+	}
+}
+
+// constantStates determines, for each state, whether
+// the state is certainly constant with respect to g.
+func (s *scanResult) constantStates(g Gradient) []bool {
+	res := make([]bool, len(s.States))
+	constant := s.Initial.Constant(g)
+	for i, state := range s.States {
+		// The pool variables are not in g, so this checks
+		// the step's dependencies other than the state.
+		constant = constant && state.Constant(g)
+		res[i] = constant
+	}
+	return res
+}
+
+type scanRResult struct {
+	scopeRef
+
+	Initial   RResult
+	Pool      []*Variable
+	States    []RResult
+	StateVars []*Variable
+	FOutput   RResult
+}
+
+// ScanR is like Scan, but for RResults.
+func ScanR(state RResult, ins []RResult, step func(state, in RResult) RResult,
+	f func(states []RResult) RResult) RResult {
+	scope := scopeOfR(state)
+	if scope == nil {
+		scope = scopeOfR(ins...)
+	}
+	res := &scanRResult{Initial: state}
+	scope.Untaped(func() {
+		var states []RResult
+		for _, in := range ins {
+			pool := &Variable{Vector: state.Output()}
+			state = step(scope.RResult(&RVariable{
+				Variable:   pool,
+				ROutputVec: state.ROutput(),
+			}), in)
+			stateVar := &Variable{Vector: state.Output()}
+			res.Pool = append(res.Pool, pool)
+			res.States = append(res.States, state)
+			res.StateVars = append(res.StateVars, stateVar)
+			states = append(states, scope.RResult(&RVariable{
+				Variable:   stateVar,
+				ROutputVec: state.ROutput(),
+			}))
+		}
+		res.FOutput = f(states)
+	})
+	return scope.rresult(res)
+}
+
+func (s *scanRResult) Output() linalg.Vector {
+	return s.FOutput.Output()
+}
+
+func (s *scanRResult) ROutput() linalg.Vector {
+	return s.FOutput.ROutput()
+}
+
+func (s *scanRResult) Constant(rg RGradient, g Gradient) bool {
+	if !s.FOutput.Constant(rg, g) {
+		return false
+	}
+	constants := s.constantStates(rg, g)
+	for i, v := range s.StateVars {
+		if !constants[i] && !s.FOutput.Constant(RGradient{v: linalg.Vector{}}, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scanRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	if g == nil {
+		g = Gradient{}
+	}
+
+	constants := s.constantStates(rg, g)
+	for i, v := range s.StateVars {
+		if !constants[i] {
+			g[v] = s.Scope.Alloc(len(v.Vector))
+			rg[v] = s.Scope.Alloc(len(v.Vector))
+		}
+	}
+	s.FOutput.PropagateRGradient(u, uR, rg, g)
+
+	var stateUp, stateUpR linalg.Vector
+	for i := len(s.States) - 1; i >= 0; i-- {
+		if constants[i] {
+			break
+		}
+		v := s.StateVars[i]
+		up, upR := g[v], rg[v]
+		delete(g, v)
+		delete(rg, v)
+		if stateUp != nil {
+			up.Add(stateUp)
+			upR.Add(stateUpR)
+		}
+		stateUp, stateUpR = nil, nil
+		res := s.States[i]
+		p := s.Pool[i]
+		g[p] = s.Scope.Alloc(len(p.Vector))
+		rg[p] = s.Scope.Alloc(len(p.Vector))
+		if !res.Constant(rg, g) {
+			res.PropagateRGradient(up, upR, rg, g)
+			stateUp, stateUpR = g[p], rg[p]
+		}
+		delete(g, p)
+		delete(rg, p)
+	}
+	if stateUp != nil && !s.Initial.Constant(rg, g) {
+		s.Initial.PropagateRGradient(stateUp, stateUpR, rg, g)
+	}
+}
+
+func (s *scanRResult) constantStates(rg RGradient, g Gradient) []bool {
+	res := make([]bool, len(s.States))
+	constant := s.Initial.Constant(rg, g)
+	for i, state := range s.States {
+		constant = constant && state.Constant(rg, g)
+		res[i] = constant
+	}
+	return res
+}
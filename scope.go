@@ -0,0 +1,234 @@
+package autofunc
+
+import "github.com/unixpickle/num-analysis/linalg"
+
+// A Scope holds settings for a computation, such as the
+// Allocator it should use.
+//
+// A Scope is attached to the inputs of a computation with
+// Result or RResult.
+// Built-in Results look for a Scope among their inputs
+// and keep it, so everything computed from the inputs
+// shares the Scope.
+// Thus, computations on different Goroutines (e.g. the
+// shards of a ParallelGradient) can use different Scopes.
+// If the inputs of a Result have different Scopes, the
+// first one is used.
+//
+// Results of other types do not pass Scopes on to the
+// Results computed from them, unless they are attached
+// to the Scope as well.
+type Scope struct {
+	// Allocator provides the vectors which built-in
+	// Results use for their outputs and for intermediate
+	// values during back propagation.
+	// If it is nil, vectors are allocated with make.
+	Allocator Allocator
+
+	// Debug enables checks for NaN and infinite values.
+	// Every Result attached to the Scope, including the
+	// built-in Results computed in it, is wrapped as if by
+	// CheckFinite, and a NonFiniteError names the Result
+	// which produced the first bad value.
+	//
+	// A Scope with Debug set should only be used on one
+	// Goroutine at a time.
+	Debug bool
+
+	// Tape, if non-nil, records every Result attached to
+	// the Scope, including the built-in Results computed in
+	// it, so that Tape.Output back-propagates through each
+	// of them once.
+	//
+	// A Scope with a Tape should only be used on one
+	// Goroutine at a time.
+	Tape *Tape
+
+	// propagating stores the checked Results which are
+	// currently back-propagating, innermost last.
+	propagating []*finiteInfo
+}
+
+// Result attaches s to r, returning a Result which should
+// be used in place of r.
+// If s is nil or r already belongs to s, r is returned
+// as-is.
+func (s *Scope) Result(r Result) Result {
+	if s == nil || scopeOf(r) == s {
+		return r
+	}
+	return s.attach(r)
+}
+
+// RResult is like Result, but for RResults.
+func (s *Scope) RResult(r RResult) RResult {
+	if s == nil || scopeOfR(r) == s {
+		return r
+	}
+	return s.attachR(r)
+}
+
+// Untaped calls f without recording Results in the
+// Scope's Tape.
+// It is used for Results which are back-propagated by
+// another Result rather than by the Tape, such as the
+// Results computed by the function passed to Pool.
+// It may be called on a nil Scope.
+func (s *Scope) Untaped(f func()) {
+	if s == nil || s.Tape == nil {
+		f()
+		return
+	}
+	s.Tape.paused++
+	defer func() {
+		s.Tape.paused--
+	}()
+	f()
+}
+
+// result attaches s to a new built-in Result.
+func (s *Scope) result(r Result) Result {
+	if s == nil {
+		return r
+	}
+	r.(scoper).setScope(s)
+	return s.attach(r)
+}
+
+// rresult attaches s to a new built-in RResult.
+func (s *Scope) rresult(r RResult) RResult {
+	if s == nil {
+		return r
+	}
+	r.(scoper).setScope(s)
+	return s.attachR(r)
+}
+
+// attach checks and records r as required by s, and makes
+// sure that the resulting Result belongs to s.
+func (s *Scope) attach(r Result) Result {
+	if s.Debug {
+		r = newFiniteChecker(s, r)
+	}
+	if s.taping() {
+		r = s.Tape.Record(r)
+	}
+	if scopeOf(r) != s {
+		r = &scopedResult{scopeRef: scopeRef{s}, Input: r}
+	}
+	return r
+}
+
+// attachR is like attach, but for RResults.
+func (s *Scope) attachR(r RResult) RResult {
+	if s.Debug {
+		r = newFiniteRChecker(s, r)
+	}
+	if s.taping() {
+		r = s.Tape.RecordR(r)
+	}
+	if scopeOfR(r) != s {
+		r = &scopedRResult{scopeRef: scopeRef{s}, Input: r}
+	}
+	return r
+}
+
+func (s *Scope) taping() bool {
+	return s.Tape != nil && s.Tape.paused == 0
+}
+
+// scopeRef is embedded in built-in Results to store the
+// Scope they were created in.
+type scopeRef struct {
+	Scope *Scope
+}
+
+func (s *scopeRef) scope() *Scope {
+	return s.Scope
+}
+
+func (s *scopeRef) setScope(scope *Scope) {
+	s.Scope = scope
+}
+
+type scoper interface {
+	scope() *Scope
+	setScope(s *Scope)
+}
+
+// scopeOf returns the Scope of the first Result which has
+// one, or nil if none of them do.
+func scopeOf(rs ...Result) *Scope {
+	for _, r := range rs {
+		if s, ok := r.(scoper); ok && s.scope() != nil {
+			return s.scope()
+		}
+	}
+	return nil
+}
+
+// scopeOfR is like scopeOf, but for RResults.
+func scopeOfR(rs ...RResult) *Scope {
+	for _, r := range rs {
+		if s, ok := r.(scoper); ok && s.scope() != nil {
+			return s.scope()
+		}
+	}
+	return nil
+}
+
+// unscoped returns the Result which was attached to a
+// Scope with Scope.Result, or r if r was not created by
+// Scope.Result.
+func unscoped(r Result) Result {
+	if s, ok := r.(*scopedResult); ok {
+		return s.Input
+	}
+	return r
+}
+
+// unscopedR is like unscoped, but for RResults.
+func unscopedR(r RResult) RResult {
+	if s, ok := r.(*scopedRResult); ok {
+		return s.Input
+	}
+	return r
+}
+
+type scopedResult struct {
+	scopeRef
+	Input Result
+}
+
+func (s *scopedResult) Output() linalg.Vector {
+	return s.Input.Output()
+}
+
+func (s *scopedResult) Constant(g Gradient) bool {
+	return s.Input.Constant(g)
+}
+
+func (s *scopedResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	s.Input.PropagateGradient(u, g)
+}
+
+type scopedRResult struct {
+	scopeRef
+	Input RResult
+}
+
+func (s *scopedRResult) Output() linalg.Vector {
+	return s.Input.Output()
+}
+
+func (s *scopedRResult) ROutput() linalg.Vector {
+	return s.Input.ROutput()
+}
+
+func (s *scopedRResult) Constant(rg RGradient, g Gradient) bool {
+	return s.Input.Constant(rg, g)
+}
+
+func (s *scopedRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	s.Input.PropagateRGradient(u, uR, rg, g)
+}
@@ -0,0 +1,630 @@
+package seqfunc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// An AttentionMask restricts which keys each query may
+// attend to.
+type AttentionMask struct {
+	// Causal prevents the query at timestep t from
+	// attending to keys after timestep t.
+	Causal bool
+
+	// Padding marks keys which should never be attended
+	// to, such as padding at the end of a sequence.
+	// Padding[i][j] corresponds to key j of sequence i.
+	// If Padding is nil, no keys are padding.
+	Padding [][]bool
+}
+
+// allowed returns a row-major matrix indicating which
+// keys each query in a sequence may attend to.
+func (a *AttentionMask) allowed(seq, queries, keys int) []bool {
+	res := make([]bool, queries*keys)
+	for q := 0; q < queries; q++ {
+		for k := 0; k < keys; k++ {
+			ok := true
+			if a != nil {
+				if a.Causal && k > q {
+					ok = false
+				} else if a.Padding != nil && a.Padding[seq][k] {
+					ok = false
+				}
+			}
+			res[q*keys+k] = ok
+		}
+	}
+	return res
+}
+
+// Attention computes scaled dot-product attention.
+//
+// The queries in each sequence of query attend to the
+// keys in the corresponding sequence of key.
+// Each output vector is a weighted sum of the vectors in
+// the corresponding sequence of value, which must have
+// the same length as the key sequence.
+// The weights are the softmax of the dot products between
+// the query and the keys, divided by the square root of
+// the key size.
+//
+// The query, key, and value vectors are split into heads
+// equally sized chunks, and attention is computed for
+// each chunk separately.
+// The outputs of the heads are concatenated.
+//
+// If mask is nil, every query may attend to every key.
+// Queries which cannot attend to any key produce zero
+// vectors.
+//
+// A Result may be passed for several of the arguments,
+// as in Attention(x, x, x, ...), in which case it is
+// only back-propagated through once.
+func Attention(query, key, value Result, heads int, mask *AttentionMask) Result {
+	return AttentionIn(nil, query, key, value, heads, mask)
+}
+
+// AttentionIn is like Attention, but the computation
+// belongs to the given Scope, which may be nil.
+func AttentionIn(s *autofunc.Scope, query, key, value Result, heads int,
+	mask *AttentionMask) Result {
+	l := newAttentionLayout(query.OutputSeqs(), key.OutputSeqs(), value.OutputSeqs(),
+		heads)
+	pools := [3]*autofunc.Variable{
+		{Vector: l.packHeads(s, query.OutputSeqs(), l.KeySize)},
+		{Vector: l.packHeads(s, key.OutputSeqs(), l.KeySize)},
+		{Vector: l.packHeads(s, value.OutputSeqs(), l.ValueSize)},
+	}
+	var joined autofunc.Result
+	// The Results are back-propagated by the attention
+	// Result, so they must not be recorded by a Tape.
+	s.Untaped(func() {
+		var outs []autofunc.Result
+		l.iterate(func(seq, qStart, kStart, vStart int) {
+			lens := l.Lens[seq]
+			if lens[1] == 0 {
+				outs = append(outs, s.Result(&autofunc.Variable{
+					Vector: s.Alloc(lens[0] * l.headValueSize()),
+				}))
+				return
+			}
+			q := autofunc.Slice(s.Result(pools[0]), qStart,
+				qStart+lens[0]*l.headKeySize())
+			k := autofunc.Slice(s.Result(pools[1]), kStart,
+				kStart+lens[1]*l.headKeySize())
+			v := autofunc.Slice(s.Result(pools[2]), vStart,
+				vStart+lens[1]*l.headValueSize())
+			scores := autofunc.MatMul(q, k, l.scoreShape(seq))
+			weights := newAttentionWeights(s, scores, lens[1], l.scale(),
+				mask.allowed(seq, lens[0], lens[1]))
+			outs = append(outs, autofunc.MatMul(s.Result(weights), v, l.outputShape(seq)))
+		})
+		joined = autofunc.Concat(outs...)
+	})
+	return &attentionResult{
+		Scope:  s,
+		Inputs: [3]Result{query, key, value},
+		Pools:  pools,
+		Layout: l,
+		Joined: joined,
+		Output: l.unpackHeads(s, joined.Output(), l.ValueSize),
+	}
+}
+
+// AttentionR is like Attention, but for RResults.
+func AttentionR(query, key, value RResult, heads int, mask *AttentionMask) RResult {
+	return AttentionInR(nil, query, key, value, heads, mask)
+}
+
+// AttentionInR is like AttentionIn, but for RResults.
+func AttentionInR(s *autofunc.Scope, query, key, value RResult, heads int,
+	mask *AttentionMask) RResult {
+	l := newAttentionLayout(query.OutputSeqs(), key.OutputSeqs(), value.OutputSeqs(),
+		heads)
+	pools := [3]*autofunc.RVariable{
+		{
+			Variable:   &autofunc.Variable{Vector: l.packHeads(s, query.OutputSeqs(), l.KeySize)},
+			ROutputVec: l.packHeads(s, query.ROutputSeqs(), l.KeySize),
+		},
+		{
+			Variable:   &autofunc.Variable{Vector: l.packHeads(s, key.OutputSeqs(), l.KeySize)},
+			ROutputVec: l.packHeads(s, key.ROutputSeqs(), l.KeySize),
+		},
+		{
+			Variable: &autofunc.Variable{
+				Vector: l.packHeads(s, value.OutputSeqs(), l.ValueSize),
+			},
+			ROutputVec: l.packHeads(s, value.ROutputSeqs(), l.ValueSize),
+		},
+	}
+	var joined autofunc.RResult
+	s.Untaped(func() {
+		var outs []autofunc.RResult
+		l.iterate(func(seq, qStart, kStart, vStart int) {
+			lens := l.Lens[seq]
+			if lens[1] == 0 {
+				zero := &autofunc.Variable{Vector: s.Alloc(lens[0] * l.headValueSize())}
+				zeroR := autofunc.NewRVariable(zero, autofunc.RVector{})
+				outs = append(outs, s.RResult(zeroR))
+				return
+			}
+			q := autofunc.SliceR(s.RResult(pools[0]), qStart,
+				qStart+lens[0]*l.headKeySize())
+			k := autofunc.SliceR(s.RResult(pools[1]), kStart,
+				kStart+lens[1]*l.headKeySize())
+			v := autofunc.SliceR(s.RResult(pools[2]), vStart,
+				vStart+lens[1]*l.headValueSize())
+			scores := autofunc.MatMulR(q, k, l.scoreShape(seq))
+			weights := newAttentionWeightsR(s, scores, lens[1], l.scale(),
+				mask.allowed(seq, lens[0], lens[1]))
+			out := autofunc.MatMulR(s.RResult(weights), v, l.outputShape(seq))
+			outs = append(outs, out)
+		})
+		joined = autofunc.ConcatR(outs...)
+	})
+	return &attentionRResult{
+		Scope:   s,
+		Inputs:  [3]RResult{query, key, value},
+		Pools:   [3]*autofunc.Variable{pools[0].Variable, pools[1].Variable, pools[2].Variable},
+		Layout:  l,
+		Joined:  joined,
+		Output:  l.unpackHeads(s, joined.Output(), l.ValueSize),
+		ROutput: l.unpackHeads(s, joined.ROutput(), l.ValueSize),
+	}
+}
+
+type attentionResult struct {
+	Scope  *autofunc.Scope
+	Inputs [3]Result
+	Pools  [3]*autofunc.Variable
+	Layout *attentionLayout
+	Joined autofunc.Result
+	Output [][]linalg.Vector
+}
+
+func (a *attentionResult) OutputSeqs() [][]linalg.Vector {
+	return a.Output
+}
+
+func (a *attentionResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	for _, p := range a.Pools {
+		g[p] = a.Scope.Alloc(len(p.Vector))
+	}
+	a.Joined.PropagateGradient(a.Layout.packHeads(a.Scope, u, a.Layout.ValueSize), g)
+	var downstream [3][][]linalg.Vector
+	sizes := a.Layout.inputSizes()
+	for i, p := range a.Pools {
+		downstream[i] = a.Layout.unpackInput(a.Scope, g[p], i, sizes[i])
+		delete(g, p)
+	}
+	owners := attentionInputOwners(a.Inputs[0], a.Inputs[1], a.Inputs[2])
+	for i, j := range owners {
+		if j != i {
+			addSeqs(downstream[j], downstream[i])
+		}
+	}
+	for i, j := range owners {
+		if j == i {
+			a.Inputs[i].PropagateGradient(downstream[i], g)
+		}
+	}
+}
+
+type attentionRResult struct {
+	Scope   *autofunc.Scope
+	Inputs  [3]RResult
+	Pools   [3]*autofunc.Variable
+	Layout  *attentionLayout
+	Joined  autofunc.RResult
+	Output  [][]linalg.Vector
+	ROutput [][]linalg.Vector
+}
+
+func (a *attentionRResult) OutputSeqs() [][]linalg.Vector {
+	return a.Output
+}
+
+func (a *attentionRResult) ROutputSeqs() [][]linalg.Vector {
+	return a.ROutput
+}
+
+func (a *attentionRResult) PropagateRGradient(u, uR [][]linalg.Vector, rg autofunc.RGradient,
+	g autofunc.Gradient) {
+	if g == nil {
+		// We use g for temporary gradients.
+		g = autofunc.Gradient{}
+	}
+	for _, p := range a.Pools {
+		g[p] = a.Scope.Alloc(len(p.Vector))
+		rg[p] = a.Scope.Alloc(len(p.Vector))
+	}
+	l := a.Layout
+	a.Joined.PropagateRGradient(l.packHeads(a.Scope, u, l.ValueSize),
+		l.packHeads(a.Scope, uR, l.ValueSize), rg, g)
+	var downstream, downstreamR [3][][]linalg.Vector
+	sizes := l.inputSizes()
+	for i, p := range a.Pools {
+		downstream[i] = l.unpackInput(a.Scope, g[p], i, sizes[i])
+		downstreamR[i] = l.unpackInput(a.Scope, rg[p], i, sizes[i])
+		delete(g, p)
+		delete(rg, p)
+	}
+	owners := attentionInputOwners(a.Inputs[0], a.Inputs[1], a.Inputs[2])
+	for i, j := range owners {
+		if j != i {
+			addSeqs(downstream[j], downstream[i])
+			addSeqs(downstreamR[j], downstreamR[i])
+		}
+	}
+	for i, j := range owners {
+		if j == i {
+			a.Inputs[i].PropagateRGradient(downstream[i], downstreamR[i], rg, g)
+		}
+	}
+}
+
+// attentionInputOwners finds, for each of the query, key,
+// and value inputs, the index of the first input which is
+// the same Result.
+// This way, an input which is passed for several
+// arguments (e.g. for self-attention) is only back
+// propagated through once.
+//
+// Only pointers are compared, since other dynamic types
+// may not be comparable, and equal non-pointer values
+// need not share state.
+func attentionInputOwners(query, key, value interface{}) [3]int {
+	ins := [3]interface{}{query, key, value}
+	var res [3]int
+	for i := range ins {
+		res[i] = i
+		if reflect.ValueOf(ins[i]).Kind() != reflect.Ptr {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if ins[j] == ins[i] {
+				res[i] = j
+				break
+			}
+		}
+	}
+	return res
+}
+
+// addSeqs adds the vectors in src to the vectors in dst.
+func addSeqs(dst, src [][]linalg.Vector) {
+	for i, seq := range src {
+		for j, vec := range seq {
+			dst[i][j].Add(vec)
+		}
+	}
+}
+
+// attentionLayout describes how the sequences given to
+// Attention are packed into vectors.
+//
+// Each packed vector stores one row-major matrix per
+// sequence and head, with one row per timestep.
+type attentionLayout struct {
+	Heads     int
+	KeySize   int
+	ValueSize int
+
+	// Lens stores the query and key lengths of each
+	// sequence.
+	Lens [][2]int
+}
+
+func newAttentionLayout(query, key, value [][]linalg.Vector, heads int) *attentionLayout {
+	if len(query) != len(key) || len(key) != len(value) {
+		panic("query, key, and value sequence counts must match")
+	}
+	if heads < 1 {
+		panic("at least one head is required")
+	}
+	res := &attentionLayout{Heads: heads, KeySize: -1, ValueSize: -1}
+	for i, seq := range query {
+		if len(key[i]) != len(value[i]) {
+			panic("key and value sequence lengths must match")
+		}
+		res.Lens = append(res.Lens, [2]int{len(seq), len(key[i])})
+		for _, vec := range seq {
+			res.KeySize = checkAttentionSize(res.KeySize, len(vec), "query")
+		}
+		for _, vec := range key[i] {
+			res.KeySize = checkAttentionSize(res.KeySize, len(vec), "key")
+		}
+		for _, vec := range value[i] {
+			res.ValueSize = checkAttentionSize(res.ValueSize, len(vec), "value")
+		}
+	}
+	if res.KeySize < 0 {
+		res.KeySize = 0
+	}
+	if res.ValueSize < 0 {
+		res.ValueSize = 0
+	}
+	if res.KeySize%heads != 0 || res.ValueSize%heads != 0 {
+		panic(fmt.Sprintf("vector sizes must be divisible by head count %d", heads))
+	}
+	return res
+}
+
+func checkAttentionSize(expected, actual int, name string) int {
+	if expected >= 0 && expected != actual {
+		panic(fmt.Sprintf("%s size should be %d but got %d", name, expected, actual))
+	}
+	return actual
+}
+
+func (a *attentionLayout) headKeySize() int {
+	return a.KeySize / a.Heads
+}
+
+func (a *attentionLayout) headValueSize() int {
+	return a.ValueSize / a.Heads
+}
+
+func (a *attentionLayout) scale() float64 {
+	return 1 / math.Sqrt(float64(a.headKeySize()))
+}
+
+func (a *attentionLayout) inputSizes() [3]int {
+	return [3]int{a.KeySize, a.KeySize, a.ValueSize}
+}
+
+func (a *attentionLayout) scoreShape(seq int) autofunc.MatMulShape {
+	lens := a.Lens[seq]
+	return autofunc.MatMulShape{
+		ARows:  lens[0],
+		ACols:  a.headKeySize(),
+		BRows:  lens[1],
+		BCols:  a.headKeySize(),
+		TransB: true,
+	}
+}
+
+func (a *attentionLayout) outputShape(seq int) autofunc.MatMulShape {
+	lens := a.Lens[seq]
+	return autofunc.MatMulShape{
+		ARows: lens[0],
+		ACols: lens[1],
+		BRows: lens[1],
+		BCols: a.headValueSize(),
+	}
+}
+
+// iterate calls f for every head of every sequence with
+// at least one query, passing the offsets of the head's
+// queries, keys, and values in the packed vectors.
+func (a *attentionLayout) iterate(f func(seq, qStart, kStart, vStart int)) {
+	var qStart, kStart, vStart int
+	for seq, lens := range a.Lens {
+		for h := 0; h < a.Heads; h++ {
+			if lens[0] > 0 {
+				f(seq, qStart, kStart, vStart)
+			}
+			qStart += lens[0] * a.headKeySize()
+			kStart += lens[1] * a.headKeySize()
+			vStart += lens[1] * a.headValueSize()
+		}
+	}
+}
+
+// packHeads packs a list of sequences, which need not
+// have the query or key lengths of the layout.
+func (a *attentionLayout) packHeads(s *autofunc.Scope, seqs [][]linalg.Vector,
+	size int) linalg.Vector {
+	headSize := size / a.Heads
+	var total int
+	for _, seq := range seqs {
+		total += len(seq) * size
+	}
+	res := s.Alloc(total)
+	var idx int
+	for _, seq := range seqs {
+		for h := 0; h < a.Heads; h++ {
+			for _, vec := range seq {
+				idx += copy(res[idx:], vec[h*headSize:(h+1)*headSize])
+			}
+		}
+	}
+	return res
+}
+
+// unpackHeads is the inverse of packHeads for sequences
+// with the query lengths of the layout.
+func (a *attentionLayout) unpackHeads(s *autofunc.Scope, packed linalg.Vector,
+	size int) [][]linalg.Vector {
+	lens := make([]int, len(a.Lens))
+	for i, l := range a.Lens {
+		lens[i] = l[0]
+	}
+	return a.unpack(s, packed, lens, size)
+}
+
+// unpackInput is the inverse of packHeads for the query
+// (idx 0), key (idx 1), or value (idx 2) sequences.
+func (a *attentionLayout) unpackInput(s *autofunc.Scope, packed linalg.Vector, idx,
+	size int) [][]linalg.Vector {
+	lens := make([]int, len(a.Lens))
+	for i, l := range a.Lens {
+		if idx == 0 {
+			lens[i] = l[0]
+		} else {
+			lens[i] = l[1]
+		}
+	}
+	return a.unpack(s, packed, lens, size)
+}
+
+func (a *attentionLayout) unpack(s *autofunc.Scope, packed linalg.Vector, lens []int,
+	size int) [][]linalg.Vector {
+	headSize := size / a.Heads
+	res := make([][]linalg.Vector, len(lens))
+	for i, l := range lens {
+		res[i] = make([]linalg.Vector, l)
+		for t := range res[i] {
+			res[i][t] = s.Alloc(size)
+		}
+		for h := 0; h < a.Heads; h++ {
+			for t := 0; t < l; t++ {
+				copy(res[i][t][h*headSize:], packed[:headSize])
+				packed = packed[headSize:]
+			}
+		}
+	}
+	return res
+}
+
+// attentionWeights applies a masked softmax to each row
+// of a matrix of scaled attention scores.
+type attentionWeights struct {
+	Scope     *autofunc.Scope
+	OutputVec linalg.Vector
+	Scores    autofunc.Result
+	Cols      int
+	Scale     float64
+}
+
+func newAttentionWeights(s *autofunc.Scope, scores autofunc.Result, cols int,
+	scale float64, allowed []bool) *attentionWeights {
+	return &attentionWeights{
+		Scope:     s,
+		OutputVec: maskedSoftmax(s, scores.Output(), cols, scale, allowed),
+		Scores:    scores,
+		Cols:      cols,
+		Scale:     scale,
+	}
+}
+
+func (a *attentionWeights) Output() linalg.Vector {
+	return a.OutputVec
+}
+
+func (a *attentionWeights) Constant(g autofunc.Gradient) bool {
+	return a.Scores.Constant(g)
+}
+
+func (a *attentionWeights) PropagateGradient(u linalg.Vector, g autofunc.Gradient) {
+	if !a.Scores.Constant(g) {
+		downstream := maskedSoftmaxGrad(a.Scope, a.OutputVec, u, a.Cols)
+		downstream.Scale(a.Scale)
+		a.Scores.PropagateGradient(downstream, g)
+	}
+}
+
+type attentionWeightsR struct {
+	Scope      *autofunc.Scope
+	OutputVec  linalg.Vector
+	ROutputVec linalg.Vector
+	Scores     autofunc.RResult
+	Cols       int
+	Scale      float64
+}
+
+func newAttentionWeightsR(s *autofunc.Scope, scores autofunc.RResult, cols int,
+	scale float64, allowed []bool) *attentionWeightsR {
+	out := maskedSoftmax(s, scores.Output(), cols, scale, allowed)
+	rIn := s.Alloc(len(out))
+	copy(rIn, scores.ROutput())
+	rIn.Scale(scale)
+	return &attentionWeightsR{
+		Scope:      s,
+		OutputVec:  out,
+		ROutputVec: maskedSoftmaxGrad(s, out, rIn, cols),
+		Scores:     scores,
+		Cols:       cols,
+		Scale:      scale,
+	}
+}
+
+func (a *attentionWeightsR) Output() linalg.Vector {
+	return a.OutputVec
+}
+
+func (a *attentionWeightsR) ROutput() linalg.Vector {
+	return a.ROutputVec
+}
+
+func (a *attentionWeightsR) Constant(rg autofunc.RGradient, g autofunc.Gradient) bool {
+	return a.Scores.Constant(rg, g)
+}
+
+func (a *attentionWeightsR) PropagateRGradient(u, uR linalg.Vector, rg autofunc.RGradient,
+	g autofunc.Gradient) {
+	if a.Scores.Constant(rg, g) {
+		return
+	}
+	downstream := maskedSoftmaxGrad(a.Scope, a.OutputVec, u, a.Cols)
+	downstreamR := maskedSoftmaxGrad(a.Scope, a.OutputVec, uR, a.Cols)
+	for row := 0; row < len(u)/a.Cols; row++ {
+		start, end := row*a.Cols, (row+1)*a.Cols
+		p, pR := a.OutputVec[start:end], a.ROutputVec[start:end]
+		rowU := u[start:end]
+		var dot, dotR float64
+		for i, x := range p {
+			dot += x * rowU[i]
+			dotR += pR[i] * rowU[i]
+		}
+		for i, x := range p {
+			downstreamR[start+i] += pR[i]*(rowU[i]-dot) - x*dotR
+		}
+	}
+	downstream.Scale(a.Scale)
+	downstreamR.Scale(a.Scale)
+	a.Scores.PropagateRGradient(downstream, downstreamR, rg, g)
+}
+
+// maskedSoftmax computes the softmax of every row of a
+// scaled matrix, using only the allowed entries.
+// Entries which are not allowed are set to 0.
+func maskedSoftmax(s *autofunc.Scope, scores linalg.Vector, cols int, scale float64,
+	allowed []bool) linalg.Vector {
+	res := s.Alloc(len(scores))
+	for start := 0; start < len(scores); start += cols {
+		max := math.Inf(-1)
+		for i := start; i < start+cols; i++ {
+			if allowed[i] {
+				max = math.Max(max, scores[i]*scale)
+			}
+		}
+		var sum float64
+		for i := start; i < start+cols; i++ {
+			if allowed[i] {
+				res[i] = math.Exp(scores[i]*scale - max)
+				sum += res[i]
+			}
+		}
+		for i := start; i < start+cols; i++ {
+			if sum != 0 {
+				res[i] /= sum
+			}
+		}
+	}
+	return res
+}
+
+// maskedSoftmaxGrad computes the gradient of a row-wise
+// softmax with respect to its unscaled input, given the
+// softmax output.
+// It can also compute the r-output of the softmax given
+// the r-input.
+func maskedSoftmaxGrad(s *autofunc.Scope, probs, u linalg.Vector,
+	cols int) linalg.Vector {
+	res := s.Alloc(len(u))
+	for start := 0; start < len(u); start += cols {
+		p, rowU := probs[start:start+cols], u[start:start+cols]
+		dot := p.Dot(rowU)
+		for i, x := range p {
+			res[start+i] = x * (rowU[i] - dot)
+		}
+	}
+	return res
+}
@@ -0,0 +1,301 @@
+package seqfunc
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A Conv1D is an RFunc which applies a learned
+// one-dimensional convolution across time.
+//
+// Every output vector is an affine function of a window
+// of input vectors from the same sequence.
+// Windows which extend past either end of a sequence are
+// padded with zero vectors, so each output sequence has
+// the same length as its input sequence.
+type Conv1D struct {
+	InputSize  int
+	OutputSize int
+
+	// KernelWidth is the number of timesteps in each
+	// window.
+	KernelWidth int
+
+	// Dilation is the number of timesteps between the
+	// entries of a window.
+	// A value of 0 is treated as 1.
+	Dilation int
+
+	// Causal indicates that the window for timestep t
+	// should end at t, so that outputs never depend on
+	// future inputs.
+	// Otherwise, windows are centered around t, with the
+	// extra entry on the right for even kernel widths.
+	Causal bool
+
+	// Filters is a row-major matrix with OutputSize rows
+	// and KernelWidth*InputSize columns.
+	// Each row is a concatenation of the weights for each
+	// entry in the window, from earliest to latest.
+	Filters *autofunc.Variable
+
+	// Biases stores one bias per output component.
+	// It may be nil, in which case no biases are added.
+	Biases *autofunc.Variable
+}
+
+// ApplySeqs applies the convolution to every sequence.
+func (c *Conv1D) ApplySeqs(in Result) Result {
+	seqs := in.OutputSeqs()
+	pool := &autofunc.Variable{Vector: c.patches(seqs)}
+	res := &conv1DResult{
+		Input:  in,
+		Pool:   pool,
+		Layer:  c,
+		Output: c.emptyOutput(seqs),
+	}
+	if n := len(pool.Vector) / c.patchSize(); n > 0 {
+		res.Product = c.linTran().Batch(pool, n)
+		out := res.Product.Output()
+		if c.Biases != nil {
+			out = addBiases(out, c.Biases.Vector)
+		}
+		splitTimesteps(out, res.Output)
+	}
+	return res
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (c *Conv1D) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	seqs := in.OutputSeqs()
+	pool := &autofunc.RVariable{
+		Variable:   &autofunc.Variable{Vector: c.patches(seqs)},
+		ROutputVec: c.patches(in.ROutputSeqs()),
+	}
+	res := &conv1DRResult{
+		Input:   in,
+		Pool:    pool.Variable,
+		Layer:   c,
+		Output:  c.emptyOutput(seqs),
+		ROutput: c.emptyOutput(seqs),
+	}
+	if n := len(pool.Output()) / c.patchSize(); n > 0 {
+		res.Product = c.linTran().BatchR(rv, pool, n)
+		out := res.Product.Output()
+		outR := res.Product.ROutput()
+		if c.Biases != nil {
+			biases := autofunc.NewRVariable(c.Biases, rv)
+			out = addBiases(out, biases.Output())
+			outR = addBiases(outR, biases.ROutput())
+		}
+		splitTimesteps(out, res.Output)
+		splitTimesteps(outR, res.ROutput)
+	}
+	return res
+}
+
+func (c *Conv1D) linTran() *autofunc.LinTran {
+	return &autofunc.LinTran{
+		Data: c.Filters,
+		Rows: c.OutputSize,
+		Cols: c.patchSize(),
+	}
+}
+
+func (c *Conv1D) patchSize() int {
+	return c.KernelWidth * c.InputSize
+}
+
+// offsets returns the time offset of each window entry,
+// relative to the output timestep.
+func (c *Conv1D) offsets() []int {
+	dilation := c.Dilation
+	if dilation == 0 {
+		dilation = 1
+	}
+	first := c.KernelWidth - 1
+	if !c.Causal {
+		first /= 2
+	}
+	res := make([]int, c.KernelWidth)
+	for i := range res {
+		res[i] = (i - first) * dilation
+	}
+	return res
+}
+
+// patches packs the window for every timestep of every
+// sequence into one vector.
+func (c *Conv1D) patches(seqs [][]linalg.Vector) linalg.Vector {
+	var res linalg.Vector
+	offsets := c.offsets()
+	for _, seq := range seqs {
+		for t := range seq {
+			for _, off := range offsets {
+				if t+off < 0 || t+off >= len(seq) {
+					res = append(res, make(linalg.Vector, c.InputSize)...)
+					continue
+				}
+				vec := seq[t+off]
+				if len(vec) != c.InputSize {
+					panic(fmt.Sprintf("input size should be %d but got %d",
+						c.InputSize, len(vec)))
+				}
+				res = append(res, vec...)
+			}
+		}
+	}
+	return res
+}
+
+// patchGrad computes the gradient of patches, given the
+// gradient of its output.
+func (c *Conv1D) patchGrad(shaper [][]linalg.Vector, grad linalg.Vector) [][]linalg.Vector {
+	res := make([][]linalg.Vector, len(shaper))
+	for i, seq := range shaper {
+		res[i] = make([]linalg.Vector, len(seq))
+		for t := range seq {
+			res[i][t] = make(linalg.Vector, c.InputSize)
+		}
+	}
+	offsets := c.offsets()
+	for i, seq := range shaper {
+		for t := range seq {
+			for _, off := range offsets {
+				if t+off >= 0 && t+off < len(seq) {
+					res[i][t+off].Add(grad[:c.InputSize])
+				}
+				grad = grad[c.InputSize:]
+			}
+		}
+	}
+	return res
+}
+
+func (c *Conv1D) emptyOutput(seqs [][]linalg.Vector) [][]linalg.Vector {
+	res := make([][]linalg.Vector, len(seqs))
+	for i, seq := range seqs {
+		res[i] = make([]linalg.Vector, len(seq))
+		for t := range seq {
+			res[i][t] = make(linalg.Vector, c.OutputSize)
+		}
+	}
+	return res
+}
+
+type conv1DResult struct {
+	Input   Result
+	Pool    *autofunc.Variable
+	Product autofunc.Result
+	Layer   *Conv1D
+	Output  [][]linalg.Vector
+}
+
+func (c *conv1DResult) OutputSeqs() [][]linalg.Vector {
+	return c.Output
+}
+
+func (c *conv1DResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	if c.Product == nil {
+		return
+	}
+	upstream := joinTimesteps(u)
+	if c.Layer.Biases != nil {
+		if biasGrad, ok := g[c.Layer.Biases]; ok {
+			sumBiasGrad(upstream, biasGrad)
+		}
+	}
+	g[c.Pool] = make(linalg.Vector, len(c.Pool.Vector))
+	c.Product.PropagateGradient(upstream, g)
+	downstream := c.Layer.patchGrad(u, g[c.Pool])
+	delete(g, c.Pool)
+	c.Input.PropagateGradient(downstream, g)
+}
+
+type conv1DRResult struct {
+	Input   RResult
+	Pool    *autofunc.Variable
+	Product autofunc.RResult
+	Layer   *Conv1D
+	Output  [][]linalg.Vector
+	ROutput [][]linalg.Vector
+}
+
+func (c *conv1DRResult) OutputSeqs() [][]linalg.Vector {
+	return c.Output
+}
+
+func (c *conv1DRResult) ROutputSeqs() [][]linalg.Vector {
+	return c.ROutput
+}
+
+func (c *conv1DRResult) PropagateRGradient(u, uR [][]linalg.Vector, rg autofunc.RGradient,
+	g autofunc.Gradient) {
+	if c.Product == nil {
+		return
+	}
+	if g == nil {
+		// We use g for temporary gradients.
+		g = autofunc.Gradient{}
+	}
+	upstream := joinTimesteps(u)
+	upstreamR := joinTimesteps(uR)
+	if c.Layer.Biases != nil {
+		if biasGrad, ok := g[c.Layer.Biases]; ok {
+			sumBiasGrad(upstream, biasGrad)
+		}
+		if biasGrad, ok := rg[c.Layer.Biases]; ok {
+			sumBiasGrad(upstreamR, biasGrad)
+		}
+	}
+	g[c.Pool] = make(linalg.Vector, len(c.Pool.Vector))
+	rg[c.Pool] = make(linalg.Vector, len(c.Pool.Vector))
+	c.Product.PropagateRGradient(upstream, upstreamR, rg, g)
+	downstream := c.Layer.patchGrad(u, g[c.Pool])
+	downstreamR := c.Layer.patchGrad(u, rg[c.Pool])
+	delete(g, c.Pool)
+	delete(rg, c.Pool)
+	c.Input.PropagateRGradient(downstream, downstreamR, rg, g)
+}
+
+// joinTimesteps concatenates every vector in a sequence
+// list.
+func joinTimesteps(seqs [][]linalg.Vector) linalg.Vector {
+	var res linalg.Vector
+	for _, seq := range seqs {
+		for _, vec := range seq {
+			res = append(res, vec...)
+		}
+	}
+	return res
+}
+
+// splitTimesteps is the inverse of joinTimesteps, filling
+// in the pre-allocated vectors of dest.
+func splitTimesteps(joined linalg.Vector, dest [][]linalg.Vector) {
+	for _, seq := range dest {
+		for _, vec := range seq {
+			copy(vec, joined)
+			joined = joined[len(vec):]
+		}
+	}
+}
+
+// addBiases adds a bias vector to every packed vector
+// in a batch, returning a new vector.
+func addBiases(batch, biases linalg.Vector) linalg.Vector {
+	res := make(linalg.Vector, len(batch))
+	for i, x := range batch {
+		res[i] = x + biases[i%len(biases)]
+	}
+	return res
+}
+
+// sumBiasGrad accumulates the gradient of addBiases.
+func sumBiasGrad(upstream, biasGrad linalg.Vector) {
+	for i, x := range upstream {
+		biasGrad[i%len(biasGrad)] += x
+	}
+}
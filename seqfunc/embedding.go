@@ -0,0 +1,90 @@
+package seqfunc
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// Embed looks up every ID of every sequence in an
+// embedding, producing a sequence of row vectors for
+// each sequence of IDs.
+//
+// All of the rows are looked up at once, so back
+// propagation only touches the rows which appear in the
+// sequences (see autofunc.Embedding).
+func Embed(e *autofunc.Embedding, seqs [][]int) Result {
+	lookup := e.Lookup(joinIDs(seqs))
+	return &embedResult{
+		Lookup: lookup,
+		Output: splitIDVecs(lookup.Output(), seqs, e.Cols),
+	}
+}
+
+// EmbedR is like Embed, but for RResults.
+func EmbedR(rv autofunc.RVector, e *autofunc.Embedding, seqs [][]int) RResult {
+	lookup := e.LookupR(rv, joinIDs(seqs))
+	return &embedRResult{
+		Lookup:  lookup,
+		Output:  splitIDVecs(lookup.Output(), seqs, e.Cols),
+		ROutput: splitIDVecs(lookup.ROutput(), seqs, e.Cols),
+	}
+}
+
+type embedResult struct {
+	Lookup autofunc.Result
+	Output [][]linalg.Vector
+}
+
+func (e *embedResult) OutputSeqs() [][]linalg.Vector {
+	return e.Output
+}
+
+func (e *embedResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	if !e.Lookup.Constant(g) {
+		e.Lookup.PropagateGradient(joinTimesteps(u), g)
+	}
+}
+
+type embedRResult struct {
+	Lookup  autofunc.RResult
+	Output  [][]linalg.Vector
+	ROutput [][]linalg.Vector
+}
+
+func (e *embedRResult) OutputSeqs() [][]linalg.Vector {
+	return e.Output
+}
+
+func (e *embedRResult) ROutputSeqs() [][]linalg.Vector {
+	return e.ROutput
+}
+
+func (e *embedRResult) PropagateRGradient(u, uR [][]linalg.Vector, rg autofunc.RGradient,
+	g autofunc.Gradient) {
+	if !e.Lookup.Constant(rg, g) {
+		e.Lookup.PropagateRGradient(joinTimesteps(u), joinTimesteps(uR), rg, g)
+	}
+}
+
+func joinIDs(seqs [][]int) []int {
+	var res []int
+	for _, seq := range seqs {
+		res = append(res, seq...)
+	}
+	return res
+}
+
+// splitIDVecs splits a joined vector of rows into
+// sequences shaped like seqs.
+// The resulting vectors are slices of joined.
+func splitIDVecs(joined linalg.Vector, seqs [][]int, cols int) [][]linalg.Vector {
+	res := make([][]linalg.Vector, len(seqs))
+	for i, seq := range seqs {
+		res[i] = make([]linalg.Vector, len(seq))
+		for j := range seq {
+			res[i][j] = joined[:cols:cols]
+			joined = joined[cols:]
+		}
+	}
+	return res
+}
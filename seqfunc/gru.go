@@ -0,0 +1,110 @@
+package seqfunc
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A GRU is an RFunc which runs a gated recurrent unit
+// layer over each sequence, producing the hidden state at
+// every timestep.
+//
+// At each timestep, the new hidden state is computed as
+//
+//	r = sigmoid(ResetGate(x, h))
+//	z = sigmoid(UpdateGate(x, h))
+//	c = tanh(Candidate(x, r*h))
+//	h' = (1-z)*c + z*h
+//
+// Like LSTM, all of the sequences in a list are run in
+// one batch at each timestep.
+type GRU struct {
+	InputSize  int
+	HiddenSize int
+
+	ResetGate  *RecurrentWeights
+	UpdateGate *RecurrentWeights
+	Candidate  *RecurrentWeights
+
+	// InitHidden is the initial hidden state.
+	// If it is nil, the hidden state starts at zero.
+	InitHidden *autofunc.Variable
+}
+
+// NewGRU creates a randomly initialized GRU with a learned
+// initial state.
+func NewGRU(inputSize, hiddenSize int) *GRU {
+	return &GRU{
+		InputSize:  inputSize,
+		HiddenSize: hiddenSize,
+		ResetGate:  NewRecurrentWeights(inputSize, hiddenSize),
+		UpdateGate: NewRecurrentWeights(inputSize, hiddenSize),
+		Candidate:  NewRecurrentWeights(inputSize, hiddenSize),
+		InitHidden: &autofunc.Variable{Vector: make(linalg.Vector, hiddenSize)},
+	}
+}
+
+// Parameters returns the variables in g.
+func (g *GRU) Parameters() []*autofunc.Variable {
+	var res []*autofunc.Variable
+	for _, w := range []*RecurrentWeights{g.ResetGate, g.UpdateGate, g.Candidate} {
+		res = append(res, w.Parameters()...)
+	}
+	if g.InitHidden != nil {
+		res = append(res, g.InitHidden)
+	}
+	return res
+}
+
+// ApplySeqs applies the GRU to every sequence.
+// It panics if an input vector does not have InputSize
+// components.
+func (g *GRU) ApplySeqs(in Result) Result {
+	return applyRecurrent(g, in)
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (g *GRU) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	return applyRecurrentR(g, rv, in)
+}
+
+func (g *GRU) inputSize() int {
+	return g.InputSize
+}
+
+func (g *GRU) hiddenSize() int {
+	return g.HiddenSize
+}
+
+func (g *GRU) initStates() []*autofunc.Variable {
+	return []*autofunc.Variable{g.InitHidden}
+}
+
+func (g *GRU) step(states []autofunc.Result, in autofunc.Result, n int) autofunc.Result {
+	hidden := states[0]
+	sig := autofunc.Sigmoid{}
+	reset := sig.Apply(g.ResetGate.apply(in, hidden, n))
+	update := sig.Apply(g.UpdateGate.apply(in, hidden, n))
+	candidate := autofunc.Tanh{}.Apply(g.Candidate.apply(in, autofunc.Mul(reset, hidden), n))
+	return autofunc.Pool(update, func(update autofunc.Result) autofunc.Result {
+		keep := autofunc.Mul(update, hidden)
+		replace := autofunc.Mul(autofunc.AddScaler(autofunc.Scale(update, -1), 1), candidate)
+		return autofunc.Add(keep, replace)
+	})
+}
+
+func (g *GRU) stepR(rv autofunc.RVector, states []autofunc.RResult, in autofunc.RResult,
+	n int) autofunc.RResult {
+	hidden := states[0]
+	sig := autofunc.Sigmoid{}
+	reset := sig.ApplyR(rv, g.ResetGate.applyR(rv, in, hidden, n))
+	update := sig.ApplyR(rv, g.UpdateGate.applyR(rv, in, hidden, n))
+	candidate := autofunc.Tanh{}.ApplyR(rv, g.Candidate.applyR(rv, in,
+		autofunc.MulR(reset, hidden), n))
+	return autofunc.PoolR(update, func(update autofunc.RResult) autofunc.RResult {
+		keep := autofunc.MulR(update, hidden)
+		replace := autofunc.MulR(autofunc.AddScalerR(autofunc.ScaleR(update, -1), 1),
+			candidate)
+		return autofunc.AddR(keep, replace)
+	})
+}
@@ -0,0 +1,124 @@
+package seqfunc
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// An LSTM is an RFunc which runs a long short-term memory
+// layer over each sequence, producing the hidden state at
+// every timestep.
+//
+// All of the sequences in a list are run in one batch at
+// each timestep, and sequences may have different
+// lengths.
+//
+// See https://en.wikipedia.org/wiki/Long_short-term_memory
+// for more info on LSTM.
+type LSTM struct {
+	InputSize  int
+	HiddenSize int
+
+	InputGate  *RecurrentWeights
+	ForgetGate *RecurrentWeights
+	OutputGate *RecurrentWeights
+	CellInput  *RecurrentWeights
+
+	// InitHidden and InitCell are the initial hidden and
+	// cell states.
+	// Either may be nil, in which case the corresponding
+	// state starts at zero.
+	InitHidden *autofunc.Variable
+	InitCell   *autofunc.Variable
+}
+
+// NewLSTM creates a randomly initialized LSTM with learned
+// initial states.
+// The forget gate biases are initialized to 1 so that the
+// LSTM initially remembers its cell state.
+func NewLSTM(inputSize, hiddenSize int) *LSTM {
+	res := &LSTM{
+		InputSize:  inputSize,
+		HiddenSize: hiddenSize,
+		InputGate:  NewRecurrentWeights(inputSize, hiddenSize),
+		ForgetGate: NewRecurrentWeights(inputSize, hiddenSize),
+		OutputGate: NewRecurrentWeights(inputSize, hiddenSize),
+		CellInput:  NewRecurrentWeights(inputSize, hiddenSize),
+		InitHidden: &autofunc.Variable{Vector: make(linalg.Vector, hiddenSize)},
+		InitCell:   &autofunc.Variable{Vector: make(linalg.Vector, hiddenSize)},
+	}
+	for i := range res.ForgetGate.Biases.Vector {
+		res.ForgetGate.Biases.Vector[i] = 1
+	}
+	return res
+}
+
+// Parameters returns the variables in l.
+func (l *LSTM) Parameters() []*autofunc.Variable {
+	var res []*autofunc.Variable
+	for _, w := range []*RecurrentWeights{l.InputGate, l.ForgetGate, l.OutputGate,
+		l.CellInput} {
+		res = append(res, w.Parameters()...)
+	}
+	for _, v := range l.initStates() {
+		if v != nil {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// ApplySeqs applies the LSTM to every sequence.
+// It panics if an input vector does not have InputSize
+// components.
+func (l *LSTM) ApplySeqs(in Result) Result {
+	return applyRecurrent(l, in)
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (l *LSTM) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	return applyRecurrentR(l, rv, in)
+}
+
+func (l *LSTM) inputSize() int {
+	return l.InputSize
+}
+
+func (l *LSTM) hiddenSize() int {
+	return l.HiddenSize
+}
+
+func (l *LSTM) initStates() []*autofunc.Variable {
+	return []*autofunc.Variable{l.InitHidden, l.InitCell}
+}
+
+func (l *LSTM) step(states []autofunc.Result, in autofunc.Result, n int) autofunc.Result {
+	hidden, cell := states[0], states[1]
+	sig := autofunc.Sigmoid{}
+	tanh := autofunc.Tanh{}
+	inGate := sig.Apply(l.InputGate.apply(in, hidden, n))
+	forgetGate := sig.Apply(l.ForgetGate.apply(in, hidden, n))
+	outGate := sig.Apply(l.OutputGate.apply(in, hidden, n))
+	cellIn := tanh.Apply(l.CellInput.apply(in, hidden, n))
+	newCell := autofunc.Add(autofunc.Mul(forgetGate, cell), autofunc.Mul(inGate, cellIn))
+	return autofunc.Pool(newCell, func(newCell autofunc.Result) autofunc.Result {
+		newHidden := autofunc.Mul(outGate, tanh.Apply(newCell))
+		return autofunc.Concat(newHidden, newCell)
+	})
+}
+
+func (l *LSTM) stepR(rv autofunc.RVector, states []autofunc.RResult, in autofunc.RResult,
+	n int) autofunc.RResult {
+	hidden, cell := states[0], states[1]
+	sig := autofunc.Sigmoid{}
+	tanh := autofunc.Tanh{}
+	inGate := sig.ApplyR(rv, l.InputGate.applyR(rv, in, hidden, n))
+	forgetGate := sig.ApplyR(rv, l.ForgetGate.applyR(rv, in, hidden, n))
+	outGate := sig.ApplyR(rv, l.OutputGate.applyR(rv, in, hidden, n))
+	cellIn := tanh.ApplyR(rv, l.CellInput.applyR(rv, in, hidden, n))
+	newCell := autofunc.AddR(autofunc.MulR(forgetGate, cell), autofunc.MulR(inGate, cellIn))
+	return autofunc.PoolR(newCell, func(newCell autofunc.RResult) autofunc.RResult {
+		newHidden := autofunc.MulR(outGate, tanh.ApplyR(rv, newCell))
+		return autofunc.ConcatR(newHidden, newCell)
+	})
+}
@@ -0,0 +1,93 @@
+package seqfunc
+
+import "github.com/unixpickle/autofunc"
+
+// A MultiHeadAttention is an RFunc which applies
+// multi-head self-attention to each sequence.
+//
+// The queries, keys, and values are linear projections
+// of the inputs, and the concatenated outputs of the
+// heads are fed through another linear projection.
+// Apply can be used for attention between different
+// sequence lists, such as encoder-decoder attention.
+type MultiHeadAttention struct {
+	Heads int
+
+	// QueryProj, KeyProj, and ValueProj map input vectors
+	// to the model size, which must be divisible by Heads.
+	QueryProj *autofunc.LinTran
+	KeyProj   *autofunc.LinTran
+	ValueProj *autofunc.LinTran
+
+	// OutputProj maps the concatenated head outputs to the
+	// output vectors.
+	OutputProj *autofunc.LinTran
+
+	// Causal indicates that ApplySeqs should prevent each
+	// timestep from attending to later timesteps.
+	Causal bool
+}
+
+// NewMultiHeadAttention creates a randomly initialized
+// MultiHeadAttention whose outputs are the same size as
+// its inputs.
+func NewMultiHeadAttention(inputSize, modelSize, heads int) *MultiHeadAttention {
+	proj := func(rows, cols int) *autofunc.LinTran {
+		return &autofunc.LinTran{Data: randomMatrix(rows, cols), Rows: rows, Cols: cols}
+	}
+	return &MultiHeadAttention{
+		Heads:      heads,
+		QueryProj:  proj(modelSize, inputSize),
+		KeyProj:    proj(modelSize, inputSize),
+		ValueProj:  proj(modelSize, inputSize),
+		OutputProj: proj(inputSize, modelSize),
+	}
+}
+
+// Parameters returns the variables in m.
+func (m *MultiHeadAttention) Parameters() []*autofunc.Variable {
+	return []*autofunc.Variable{m.QueryProj.Data, m.KeyProj.Data, m.ValueProj.Data,
+		m.OutputProj.Data}
+}
+
+// ApplySeqs applies self-attention to every sequence.
+func (m *MultiHeadAttention) ApplySeqs(in Result) Result {
+	return Pool(in, func(in Result) Result {
+		return m.Apply(in, in, in, m.selfMask())
+	})
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (m *MultiHeadAttention) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	return PoolR(in, func(in RResult) RResult {
+		return m.ApplyR(rv, in, in, in, m.selfMask())
+	})
+}
+
+// Apply projects the queries, keys, and values, applies
+// Attention with the given mask, and projects the result.
+// The mask may be nil.
+func (m *MultiHeadAttention) Apply(query, key, value Result, mask *AttentionMask) Result {
+	q := (&MapBatcher{B: m.QueryProj}).ApplySeqs(query)
+	k := (&MapBatcher{B: m.KeyProj}).ApplySeqs(key)
+	v := (&MapBatcher{B: m.ValueProj}).ApplySeqs(value)
+	out := Attention(q, k, v, m.Heads, mask)
+	return (&MapBatcher{B: m.OutputProj}).ApplySeqs(out)
+}
+
+// ApplyR is like Apply, but for RResults.
+func (m *MultiHeadAttention) ApplyR(rv autofunc.RVector, query, key, value RResult,
+	mask *AttentionMask) RResult {
+	q := (&MapRBatcher{B: m.QueryProj}).ApplySeqsR(rv, query)
+	k := (&MapRBatcher{B: m.KeyProj}).ApplySeqsR(rv, key)
+	v := (&MapRBatcher{B: m.ValueProj}).ApplySeqsR(rv, value)
+	out := AttentionR(q, k, v, m.Heads, mask)
+	return (&MapRBatcher{B: m.OutputProj}).ApplySeqsR(rv, out)
+}
+
+func (m *MultiHeadAttention) selfMask() *AttentionMask {
+	if !m.Causal {
+		return nil
+	}
+	return &AttentionMask{Causal: true}
+}
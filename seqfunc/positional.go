@@ -0,0 +1,163 @@
+package seqfunc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A PositionalEncoding is an RFunc which adds a vector to
+// each timestep indicating its position in the sequence.
+type PositionalEncoding struct {
+	// Learned stores one learned vector per position, from
+	// the first position to the last.
+	// Sequences may not be longer than the number of
+	// learned vectors.
+	//
+	// If Learned is nil, the fixed sinusoidal encodings
+	// from "Attention Is All You Need" are used.
+	Learned *autofunc.Variable
+}
+
+// NewLearnedPositions creates a PositionalEncoding with
+// randomly initialized learned vectors.
+func NewLearnedPositions(size, maxLen int) *PositionalEncoding {
+	learned := &autofunc.Variable{Vector: make(linalg.Vector, size*maxLen)}
+	for i := range learned.Vector {
+		learned.Vector[i] = rand.NormFloat64() * 0.1
+	}
+	return &PositionalEncoding{Learned: learned}
+}
+
+// Parameters returns the learned vectors, if there are
+// any.
+func (p *PositionalEncoding) Parameters() []*autofunc.Variable {
+	if p.Learned == nil {
+		return nil
+	}
+	return []*autofunc.Variable{p.Learned}
+}
+
+// ApplySeqs adds the encodings to every sequence.
+func (p *PositionalEncoding) ApplySeqs(in Result) Result {
+	out := copySeqs(in.OutputSeqs())
+	for _, seq := range out {
+		for t, vec := range seq {
+			vec.Add(p.encoding(t, len(vec), p.learnedVec()))
+		}
+	}
+	return &positionalResult{Input: in, Encoding: p, Output: out}
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (p *PositionalEncoding) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	out := copySeqs(in.OutputSeqs())
+	outR := copySeqs(in.ROutputSeqs())
+	var learnedR linalg.Vector
+	if p.Learned != nil {
+		learnedR = rv[p.Learned]
+	}
+	for i, seq := range out {
+		for t, vec := range seq {
+			vec.Add(p.encoding(t, len(vec), p.learnedVec()))
+			if learnedR != nil {
+				outR[i][t].Add(p.encoding(t, len(vec), learnedR))
+			}
+		}
+	}
+	return &positionalRResult{Input: in, Encoding: p, Output: out, ROutput: outR}
+}
+
+func (p *PositionalEncoding) learnedVec() linalg.Vector {
+	if p.Learned == nil {
+		return nil
+	}
+	return p.Learned.Vector
+}
+
+// encoding returns the encoding for a timestep, reading
+// it from learned if it is non-nil.
+func (p *PositionalEncoding) encoding(t, size int, learned linalg.Vector) linalg.Vector {
+	if p.Learned == nil {
+		return sinusoidEncoding(t, size)
+	}
+	if (t+1)*size > len(learned) {
+		panic(fmt.Sprintf("no learned encoding for position %d", t))
+	}
+	return learned[t*size : (t+1)*size]
+}
+
+func (p *PositionalEncoding) propagateLearned(u [][]linalg.Vector, grad linalg.Vector) {
+	for _, seq := range u {
+		for t, vec := range seq {
+			p.encoding(t, len(vec), grad).Add(vec)
+		}
+	}
+}
+
+type positionalResult struct {
+	Input    Result
+	Encoding *PositionalEncoding
+	Output   [][]linalg.Vector
+}
+
+func (p *positionalResult) OutputSeqs() [][]linalg.Vector {
+	return p.Output
+}
+
+func (p *positionalResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	if learned := p.Encoding.Learned; learned != nil {
+		if grad, ok := g[learned]; ok {
+			p.Encoding.propagateLearned(u, grad)
+		}
+	}
+	p.Input.PropagateGradient(u, g)
+}
+
+type positionalRResult struct {
+	Input    RResult
+	Encoding *PositionalEncoding
+	Output   [][]linalg.Vector
+	ROutput  [][]linalg.Vector
+}
+
+func (p *positionalRResult) OutputSeqs() [][]linalg.Vector {
+	return p.Output
+}
+
+func (p *positionalRResult) ROutputSeqs() [][]linalg.Vector {
+	return p.ROutput
+}
+
+func (p *positionalRResult) PropagateRGradient(u, uR [][]linalg.Vector,
+	rg autofunc.RGradient, g autofunc.Gradient) {
+	if learned := p.Encoding.Learned; learned != nil {
+		if grad, ok := g[learned]; ok {
+			p.Encoding.propagateLearned(u, grad)
+		}
+		if grad, ok := rg[learned]; ok {
+			p.Encoding.propagateLearned(uR, grad)
+		}
+	}
+	p.Input.PropagateRGradient(u, uR, rg, g)
+}
+
+// sinusoidEncoding computes the sinusoidal encoding for
+// timestep t.
+// Even components use sines and odd components use
+// cosines, with wavelengths increasing geometrically.
+func sinusoidEncoding(t, size int) linalg.Vector {
+	res := make(linalg.Vector, size)
+	for i := range res {
+		angle := float64(t) / math.Pow(10000, float64(i-i%2)/float64(size))
+		if i%2 == 0 {
+			res[i] = math.Sin(angle)
+		} else {
+			res[i] = math.Cos(angle)
+		}
+	}
+	return res
+}
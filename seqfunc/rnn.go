@@ -0,0 +1,428 @@
+package seqfunc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// RecurrentWeights stores the parameters of an affine
+// function of an input vector and a hidden state, as
+// used by the gates of recurrent layers.
+type RecurrentWeights struct {
+	// Input is a row-major matrix with one row per hidden
+	// unit and one column per input component.
+	Input *autofunc.Variable
+
+	// Hidden is a square row-major matrix with one row and
+	// one column per hidden unit.
+	Hidden *autofunc.Variable
+
+	// Biases stores one bias per hidden unit.
+	Biases *autofunc.Variable
+}
+
+// NewRecurrentWeights creates randomly initialized
+// weights with zero biases.
+func NewRecurrentWeights(inputSize, hiddenSize int) *RecurrentWeights {
+	return &RecurrentWeights{
+		Input:  randomMatrix(hiddenSize, inputSize),
+		Hidden: randomMatrix(hiddenSize, hiddenSize),
+		Biases: &autofunc.Variable{Vector: make(linalg.Vector, hiddenSize)},
+	}
+}
+
+// Parameters returns the variables in r.
+func (r *RecurrentWeights) Parameters() []*autofunc.Variable {
+	return []*autofunc.Variable{r.Input, r.Hidden, r.Biases}
+}
+
+func (r *RecurrentWeights) apply(in, hidden autofunc.Result, n int) autofunc.Result {
+	inTran, hiddenTran := r.linTrans(len(in.Output()) / n)
+	return autofunc.Add(
+		autofunc.Add(inTran.Batch(in, n), hiddenTran.Batch(hidden, n)),
+		autofunc.Repeat(r.Biases, n),
+	)
+}
+
+func (r *RecurrentWeights) applyR(rv autofunc.RVector, in, hidden autofunc.RResult,
+	n int) autofunc.RResult {
+	inTran, hiddenTran := r.linTrans(len(in.Output()) / n)
+	return autofunc.AddR(
+		autofunc.AddR(inTran.BatchR(rv, in, n), hiddenTran.BatchR(rv, hidden, n)),
+		autofunc.RepeatR(autofunc.NewRVariable(r.Biases, rv), n),
+	)
+}
+
+func (r *RecurrentWeights) linTrans(inSize int) (in, hidden *autofunc.LinTran) {
+	hiddenSize := len(r.Biases.Vector)
+	in = &autofunc.LinTran{Data: r.Input, Rows: hiddenSize, Cols: inSize}
+	hidden = &autofunc.LinTran{Data: r.Hidden, Rows: hiddenSize, Cols: hiddenSize}
+	return
+}
+
+// A Bidirectional runs one RFunc forward in time and
+// another backward in time, concatenating their outputs
+// at each timestep.
+type Bidirectional struct {
+	Forward  RFunc
+	Backward RFunc
+}
+
+// ApplySeqs applies both directions to the input.
+func (b *Bidirectional) ApplySeqs(in Result) Result {
+	return Pool(in, func(in Result) Result {
+		backward := Reverse(b.Backward.ApplySeqs(Reverse(in)))
+		return ConcatInner(b.Forward.ApplySeqs(in), backward)
+	})
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (b *Bidirectional) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	return PoolR(in, func(in RResult) RResult {
+		backward := ReverseR(b.Backward.ApplySeqsR(rv, ReverseR(in)))
+		return ConcatInnerR(b.Forward.ApplySeqsR(rv, in), backward)
+	})
+}
+
+// A recurrentCell is a recurrent transition which can be
+// applied to a batch of states at once.
+//
+// The state consists of one or more components, each of
+// which has hiddenSize() entries per sequence.
+// The first component is used as the output.
+type recurrentCell interface {
+	inputSize() int
+	hiddenSize() int
+
+	// initStates returns the initial value for each state
+	// component, or nil for components which start at
+	// zero.
+	initStates() []*autofunc.Variable
+
+	// step computes the next state components, packed one
+	// after another, each packed like the inputs to a
+	// Batcher.
+	step(states []autofunc.Result, in autofunc.Result, n int) autofunc.Result
+	stepR(rv autofunc.RVector, states []autofunc.RResult, in autofunc.RResult,
+		n int) autofunc.RResult
+}
+
+type recurrentStep struct {
+	Lanes  []int
+	States []*autofunc.Variable
+	Input  *autofunc.Variable
+}
+
+type recurrentResult struct {
+	Cell    recurrentCell
+	Input   Result
+	Steps   []*recurrentStep
+	Results []autofunc.Result
+	Output  [][]linalg.Vector
+}
+
+// applyRecurrent runs a recurrent cell over every
+// sequence, feeding the cell the vectors from every
+// sequence at each timestep in one batch.
+func applyRecurrent(c recurrentCell, in Result) Result {
+	seqs := in.OutputSeqs()
+	checkRecurrentInput(c, seqs)
+	res := &recurrentResult{
+		Cell:   c,
+		Input:  in,
+		Output: make([][]linalg.Vector, len(seqs)),
+	}
+	var prevLanes []int
+	var prevStates []linalg.Vector
+	for t := 0; t < maxSequenceLen(seqs); t++ {
+		step := newRecurrentStep(c, seqs, t, prevLanes, prevStates, nil)
+		stateRes := make([]autofunc.Result, len(step.States))
+		for i, v := range step.States {
+			stateRes[i] = v
+		}
+		out := c.step(stateRes, step.Input, len(step.Lanes))
+		res.Steps = append(res.Steps, step)
+		res.Results = append(res.Results, out)
+		prevLanes = step.Lanes
+		prevStates = splitStates(out.Output(), len(step.States))
+		appendSplit(seqs, t, res.Output, prevStates[0])
+	}
+	return res
+}
+
+func (r *recurrentResult) OutputSeqs() [][]linalg.Vector {
+	return r.Output
+}
+
+func (r *recurrentResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	downstream := emptySeqGrad(r.Input.OutputSeqs())
+	var nextLanes []int
+	var stateGrads []linalg.Vector
+	for t := len(r.Steps) - 1; t >= 0; t-- {
+		step := r.Steps[t]
+		upstream := recurrentUpstream(r.Cell, step, u, t, nextLanes, stateGrads)
+		for _, v := range step.allVars() {
+			g[v] = make(linalg.Vector, len(v.Vector))
+		}
+		r.Results[t].PropagateGradient(upstream, g)
+		setTime(downstream, step.Lanes, t, g[step.Input])
+		stateGrads = make([]linalg.Vector, len(step.States))
+		for i, v := range step.States {
+			stateGrads[i] = g[v]
+		}
+		for _, v := range step.allVars() {
+			delete(g, v)
+		}
+		nextLanes = step.Lanes
+	}
+	if len(r.Steps) > 0 {
+		propagateInitStates(r.Cell, stateGrads, g)
+	}
+	r.Input.PropagateGradient(downstream, g)
+}
+
+type recurrentRResult struct {
+	Cell    recurrentCell
+	RV      autofunc.RVector
+	Input   RResult
+	Steps   []*recurrentStep
+	Results []autofunc.RResult
+	Output  [][]linalg.Vector
+	ROutput [][]linalg.Vector
+}
+
+// applyRecurrentR is like applyRecurrent for RResults.
+func applyRecurrentR(c recurrentCell, rv autofunc.RVector, in RResult) RResult {
+	seqs := in.OutputSeqs()
+	seqsR := in.ROutputSeqs()
+	checkRecurrentInput(c, seqs)
+	res := &recurrentRResult{
+		Cell:    c,
+		RV:      rv,
+		Input:   in,
+		Output:  make([][]linalg.Vector, len(seqs)),
+		ROutput: make([][]linalg.Vector, len(seqs)),
+	}
+	var prevLanes []int
+	var prevStates, prevStatesR []linalg.Vector
+	for t := 0; t < maxSequenceLen(seqs); t++ {
+		step := newRecurrentStep(c, seqs, t, prevLanes, prevStates, nil)
+		rStep := newRecurrentStep(c, seqsR, t, prevLanes, prevStatesR, rv)
+		stateRes := make([]autofunc.RResult, len(step.States))
+		for i, v := range step.States {
+			stateRes[i] = &autofunc.RVariable{
+				Variable:   v,
+				ROutputVec: rStep.States[i].Vector,
+			}
+		}
+		inRes := &autofunc.RVariable{
+			Variable:   step.Input,
+			ROutputVec: rStep.Input.Vector,
+		}
+		out := c.stepR(rv, stateRes, inRes, len(step.Lanes))
+		res.Steps = append(res.Steps, step)
+		res.Results = append(res.Results, out)
+		prevLanes = step.Lanes
+		prevStates = splitStates(out.Output(), len(step.States))
+		prevStatesR = splitStates(out.ROutput(), len(step.States))
+		appendSplit(seqs, t, res.Output, prevStates[0])
+		appendSplit(seqs, t, res.ROutput, prevStatesR[0])
+	}
+	return res
+}
+
+func (r *recurrentRResult) OutputSeqs() [][]linalg.Vector {
+	return r.Output
+}
+
+func (r *recurrentRResult) ROutputSeqs() [][]linalg.Vector {
+	return r.ROutput
+}
+
+func (r *recurrentRResult) PropagateRGradient(u, uR [][]linalg.Vector, rg autofunc.RGradient,
+	g autofunc.Gradient) {
+	if g == nil {
+		// We use g for temporary gradients.
+		g = autofunc.Gradient{}
+	}
+	downstream := emptySeqGrad(r.Input.OutputSeqs())
+	downstreamR := emptySeqGrad(r.Input.OutputSeqs())
+	var nextLanes []int
+	var stateGrads, stateGradsR []linalg.Vector
+	for t := len(r.Steps) - 1; t >= 0; t-- {
+		step := r.Steps[t]
+		upstream := recurrentUpstream(r.Cell, step, u, t, nextLanes, stateGrads)
+		upstreamR := recurrentUpstream(r.Cell, step, uR, t, nextLanes, stateGradsR)
+		for _, v := range step.allVars() {
+			g[v] = make(linalg.Vector, len(v.Vector))
+			rg[v] = make(linalg.Vector, len(v.Vector))
+		}
+		r.Results[t].PropagateRGradient(upstream, upstreamR, rg, g)
+		setTime(downstream, step.Lanes, t, g[step.Input])
+		setTime(downstreamR, step.Lanes, t, rg[step.Input])
+		stateGrads = make([]linalg.Vector, len(step.States))
+		stateGradsR = make([]linalg.Vector, len(step.States))
+		for i, v := range step.States {
+			stateGrads[i] = g[v]
+			stateGradsR[i] = rg[v]
+		}
+		for _, v := range step.allVars() {
+			delete(g, v)
+			delete(rg, v)
+		}
+		nextLanes = step.Lanes
+	}
+	if len(r.Steps) > 0 {
+		propagateInitStates(r.Cell, stateGrads, g)
+		propagateInitStates(r.Cell, stateGradsR, autofunc.Gradient(rg))
+	}
+	r.Input.PropagateRGradient(downstream, downstreamR, rg, g)
+}
+
+// checkRecurrentInput makes sure that every input vector
+// has the cell's input size.
+func checkRecurrentInput(c recurrentCell, seqs [][]linalg.Vector) {
+	for _, seq := range seqs {
+		for _, vec := range seq {
+			if len(vec) != c.inputSize() {
+				panic(fmt.Sprintf("input size should be %d but got %d", c.inputSize(),
+					len(vec)))
+			}
+		}
+	}
+}
+
+// newRecurrentStep creates the pooled variables for a
+// timestep.
+//
+// If rv is non-nil, the initial states are taken from rv
+// rather than from the initial state variables, making it
+// possible to compute the r-values of a step.
+func newRecurrentStep(c recurrentCell, seqs [][]linalg.Vector, t int, prevLanes []int,
+	prevStates []linalg.Vector, rv autofunc.RVector) *recurrentStep {
+	joined, _ := joinTime(seqs, t)
+	res := &recurrentStep{Input: &autofunc.Variable{Vector: joined}}
+	for lane, seq := range seqs {
+		if len(seq) > t {
+			res.Lanes = append(res.Lanes, lane)
+		}
+	}
+
+	hidden := c.hiddenSize()
+	for i, init := range c.initStates() {
+		vec := make(linalg.Vector, hidden*len(res.Lanes))
+		if t == 0 {
+			var initVec linalg.Vector
+			if init != nil {
+				if rv == nil {
+					initVec = init.Vector
+				} else {
+					initVec = rv[init]
+				}
+			}
+			if initVec != nil {
+				for j := range res.Lanes {
+					copy(vec[j*hidden:], initVec)
+				}
+			}
+		} else {
+			var prevIdx int
+			for j, lane := range res.Lanes {
+				for prevLanes[prevIdx] != lane {
+					prevIdx++
+				}
+				copy(vec[j*hidden:(j+1)*hidden], prevStates[i][prevIdx*hidden:])
+			}
+		}
+		res.States = append(res.States, &autofunc.Variable{Vector: vec})
+	}
+	return res
+}
+
+func (r *recurrentStep) allVars() []*autofunc.Variable {
+	return append([]*autofunc.Variable{r.Input}, r.States...)
+}
+
+// recurrentUpstream computes the upstream vector for a
+// timestep from the output gradients and the gradients of
+// the next timestep's state variables.
+func recurrentUpstream(c recurrentCell, step *recurrentStep, u [][]linalg.Vector, t int,
+	nextLanes []int, stateGrads []linalg.Vector) linalg.Vector {
+	hidden := c.hiddenSize()
+	n := len(step.Lanes)
+	res := make(linalg.Vector, n*hidden*len(step.States))
+	outGrad, _ := joinTime(u, t)
+	copy(res, outGrad)
+	if stateGrads == nil {
+		return res
+	}
+	var nextIdx int
+	for j, lane := range step.Lanes {
+		if nextIdx == len(nextLanes) || nextLanes[nextIdx] != lane {
+			continue
+		}
+		for i, grad := range stateGrads {
+			start := (i*n + j) * hidden
+			res[start : start+hidden].Add(grad[nextIdx*hidden : (nextIdx+1)*hidden])
+		}
+		nextIdx++
+	}
+	return res
+}
+
+// propagateInitStates adds the gradients of the first
+// timestep's state variables to the gradients of the
+// initial state variables.
+func propagateInitStates(c recurrentCell, stateGrads []linalg.Vector,
+	g map[*autofunc.Variable]linalg.Vector) {
+	hidden := c.hiddenSize()
+	for i, init := range c.initStates() {
+		if init == nil {
+			continue
+		}
+		if grad, ok := g[init]; ok {
+			for j := 0; j < len(stateGrads[i]); j += hidden {
+				grad.Add(stateGrads[i][j : j+hidden])
+			}
+		}
+	}
+}
+
+func splitStates(packed linalg.Vector, count int) []linalg.Vector {
+	res := make([]linalg.Vector, count)
+	size := len(packed) / count
+	for i := range res {
+		res[i] = packed[i*size : (i+1)*size]
+	}
+	return res
+}
+
+func emptySeqGrad(shaper [][]linalg.Vector) [][]linalg.Vector {
+	res := make([][]linalg.Vector, len(shaper))
+	for i, seq := range shaper {
+		res[i] = make([]linalg.Vector, len(seq))
+	}
+	return res
+}
+
+// setTime splits a joined vector and stores its parts at
+// timestep t of the given lanes.
+func setTime(dest [][]linalg.Vector, lanes []int, t int, joined linalg.Vector) {
+	size := len(joined) / len(lanes)
+	for i, lane := range lanes {
+		dest[lane][t] = joined[i*size : (i+1)*size]
+	}
+}
+
+func randomMatrix(rows, cols int) *autofunc.Variable {
+	res := &autofunc.Variable{Vector: make(linalg.Vector, rows*cols)}
+	scale := 1 / math.Sqrt(float64(cols))
+	for i := range res.Vector {
+		res.Vector[i] = rand.NormFloat64() * scale
+	}
+	return res
+}
@@ -0,0 +1,225 @@
+package seqfunctest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/autofunc/seqfunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type attentionTestFunc struct {
+	Heads int
+	Mask  *seqfunc.AttentionMask
+
+	// Self indicates that the input should be passed for
+	// every argument without being pooled.
+	Self bool
+}
+
+func (a *attentionTestFunc) ApplySeqs(in seqfunc.Result) seqfunc.Result {
+	if a.Self {
+		return seqfunc.Attention(in, in, in, a.Heads, a.Mask)
+	}
+	return seqfunc.Pool(in, func(in seqfunc.Result) seqfunc.Result {
+		return seqfunc.Attention(in, seqfunc.Reverse(in), in, a.Heads, a.Mask)
+	})
+}
+
+func (a *attentionTestFunc) ApplySeqsR(rv autofunc.RVector,
+	in seqfunc.RResult) seqfunc.RResult {
+	if a.Self {
+		return seqfunc.AttentionR(in, in, in, a.Heads, a.Mask)
+	}
+	return seqfunc.PoolR(in, func(in seqfunc.RResult) seqfunc.RResult {
+		return seqfunc.AttentionR(in, seqfunc.ReverseR(in), in, a.Heads, a.Mask)
+	})
+}
+
+func TestAttentionOutput(t *testing.T) {
+	in := seqfunc.ConstResult([][]linalg.Vector{{{1, 0}, {0, 2}}})
+	for _, causal := range []bool{false, true} {
+		actual := seqfunc.Attention(in, in, in, 1,
+			&seqfunc.AttentionMask{Causal: causal}).OutputSeqs()[0]
+		var expected []linalg.Vector
+		for i, scores := range [][]float64{{1, 0}, {0, 4}} {
+			weights := []float64{math.Exp(scores[0] / math.Sqrt2),
+				math.Exp(scores[1] / math.Sqrt2)}
+			if causal && i == 0 {
+				weights[1] = 0
+			}
+			sum := weights[0] + weights[1]
+			expected = append(expected, linalg.Vector{weights[0] / sum, 2 * weights[1] / sum})
+		}
+		for i, x := range expected {
+			if x.Copy().Scale(-1).Add(actual[i]).MaxAbs() > 1e-8 {
+				t.Errorf("causal=%v step %d: expected %v got %v", causal, i, x, actual[i])
+			}
+		}
+	}
+}
+
+func TestAttentionHeads(t *testing.T) {
+	in := seqfunc.VarResult(TestSeqs)
+	key := seqfunc.Reverse(in)
+	actual := seqfunc.Attention(in, key, in, 2, nil).OutputSeqs()
+	var heads [][][]linalg.Vector
+	for h := 0; h < 2; h++ {
+		slice := func(r seqfunc.Result) seqfunc.Result {
+			return seqfunc.Map(r, func(in autofunc.Result) autofunc.Result {
+				return autofunc.Slice(in, h*2, (h+1)*2)
+			})
+		}
+		head := seqfunc.Attention(slice(in), slice(key), slice(in), 1, nil)
+		heads = append(heads, head.OutputSeqs())
+	}
+	for i, seq := range actual {
+		for j, vec := range seq {
+			expected := append(append(linalg.Vector{}, heads[0][i][j]...), heads[1][i][j]...)
+			if expected.Copy().Scale(-1).Add(vec).MaxAbs() > 1e-8 {
+				t.Errorf("seq %d step %d: expected %v got %v", i, j, expected, vec)
+			}
+		}
+	}
+}
+
+func TestAttentionPadding(t *testing.T) {
+	mask := &seqfunc.AttentionMask{
+		Padding: [][]bool{{false, false, true}, {true, false, false}, {false}, {true},
+			{false, false}},
+	}
+	out := seqfunc.Attention(seqfunc.VarResult(TestSeqs), seqfunc.VarResult(TestSeqs),
+		seqfunc.VarResult(TestSeqs), 1, mask).OutputSeqs()
+	if out[3][0].MaxAbs() != 0 {
+		t.Errorf("fully padded sequence should give zero output, got %v", out[3][0])
+	}
+	if out[2][0].Copy().Scale(-1).Add(TestVars[1].Vector).MaxAbs() > 1e-8 {
+		t.Errorf("single key should give its value, got %v", out[2][0])
+	}
+}
+
+func TestAttentionSelfOnce(t *testing.T) {
+	in := &attentionCountResult{Result: seqfunc.VarResult(TestSeqs)}
+	out := seqfunc.Attention(in, in, in, 2, nil)
+	upstream := make([][]linalg.Vector, len(TestSeqs))
+	for i, seq := range out.OutputSeqs() {
+		for _, vec := range seq {
+			upstream[i] = append(upstream[i], vec.Copy())
+		}
+	}
+	out.PropagateGradient(upstream, autofunc.NewGradient(TestVars[:4]))
+	if in.Count != 1 {
+		t.Errorf("input was back-propagated %d times", in.Count)
+	}
+}
+
+// attentionCountResult counts calls to PropagateGradient.
+type attentionCountResult struct {
+	seqfunc.Result
+	Count int
+}
+
+func (a *attentionCountResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	a.Count++
+	a.Result.PropagateGradient(u, g)
+}
+
+func TestAttentionNonComparable(t *testing.T) {
+	in := attentionSliceResult{
+		Seqs:  seqfunc.VarResult(TestSeqs).OutputSeqs(),
+		Count: new(int),
+	}
+	out := seqfunc.Attention(in, in, in, 2, nil)
+	upstream := make([][]linalg.Vector, len(TestSeqs))
+	for i, seq := range out.OutputSeqs() {
+		for _, vec := range seq {
+			upstream[i] = append(upstream[i], vec.Copy())
+		}
+	}
+	out.PropagateGradient(upstream, autofunc.Gradient{})
+	if *in.Count != 3 {
+		t.Errorf("input was back-propagated %d times", *in.Count)
+	}
+}
+
+// attentionSliceResult is a Result whose dynamic type is
+// not comparable.
+type attentionSliceResult struct {
+	Seqs  [][]linalg.Vector
+	Count *int
+}
+
+func (a attentionSliceResult) OutputSeqs() [][]linalg.Vector {
+	return a.Seqs
+}
+
+func (a attentionSliceResult) PropagateGradient(u [][]linalg.Vector, g autofunc.Gradient) {
+	*a.Count++
+}
+
+func TestAttentionChecks(t *testing.T) {
+	funcs := map[string]*attentionTestFunc{
+		"Plain":  {Heads: 1},
+		"Heads":  {Heads: 2, Mask: &seqfunc.AttentionMask{Causal: true}},
+		"Causal": {Heads: 1, Mask: &seqfunc.AttentionMask{Causal: true}},
+		"Self":   {Heads: 2, Self: true},
+		"Padding": {Heads: 2, Mask: &seqfunc.AttentionMask{
+			Padding: [][]bool{{false, true, false}, {false, false, true}, {false}, {true},
+				{true, false}},
+		}},
+	}
+	for name, f := range funcs {
+		t.Run(name, func(t *testing.T) {
+			checker := &functest.SeqRFuncChecker{
+				F:     f,
+				Vars:  TestVars[:4],
+				Input: TestSeqs,
+				RV:    TestRV,
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func TestMultiHeadAttentionChecks(t *testing.T) {
+	rand.Seed(1337)
+	layer := seqfunc.NewMultiHeadAttention(4, 2, 2)
+	layer.Causal = true
+	vars, rv := rnnTestVars(layer.Parameters())
+	checker := &functest.SeqRFuncChecker{
+		F:     layer,
+		Vars:  vars,
+		Input: TestSeqs,
+		RV:    rv,
+	}
+	checker.FullCheck(t)
+}
+
+func TestAttentionScope(t *testing.T) {
+	var count attentionCountAllocator
+	scope := &autofunc.Scope{Allocator: &count}
+	in := seqfunc.VarResult(TestSeqs)
+	expected := seqfunc.Attention(in, in, in, 2, nil).OutputSeqs()
+	actual := seqfunc.AttentionIn(scope, in, in, in, 2, nil).OutputSeqs()
+	for i, seq := range expected {
+		for j, vec := range seq {
+			if vec.Copy().Scale(-1).Add(actual[i][j]).MaxAbs() > 1e-10 {
+				t.Errorf("output %d,%d: expected %v got %v", i, j, vec, actual[i][j])
+			}
+		}
+	}
+	if count == 0 {
+		t.Error("scope's allocator was not used")
+	}
+}
+
+// attentionCountAllocator counts calls to Alloc.
+type attentionCountAllocator int
+
+func (a *attentionCountAllocator) Alloc(size int) linalg.Vector {
+	*a++
+	return make(linalg.Vector, size)
+}
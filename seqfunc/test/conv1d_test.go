@@ -0,0 +1,125 @@
+package seqfunctest
+
+import (
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/autofunc/seqfunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+var (
+	Conv1DFilters = &autofunc.Variable{
+		Vector: []float64{
+			0.55, -0.32, 0.17, 0.91, -0.44, 0.08, 0.63, -0.71, 0.25, -0.19, 0.38, 0.52,
+			-0.27, 0.84, -0.66, 0.12, 0.47, -0.58, 0.03, 0.29, -0.93, 0.61, -0.14, 0.77,
+		},
+	}
+	Conv1DBiases = &autofunc.Variable{Vector: []float64{0.3, -0.6}}
+	Conv1DRV     = autofunc.RVector{
+		TestVars[0]: TestRV[TestVars[0]],
+		TestVars[3]: TestRV[TestVars[3]],
+		Conv1DFilters: []float64{
+			0.21, 0.65, -0.38, 0.04, -0.82, 0.49, 0.16, -0.27, 0.93, -0.51, 0.36, -0.09,
+			0.72, -0.13, 0.58, -0.67, 0.31, 0.02, -0.45, 0.88, -0.24, 0.11, 0.69, -0.36,
+		},
+		Conv1DBiases: []float64{-0.4, 0.7},
+	}
+	Conv1DVars = append(append([]*autofunc.Variable{}, TestVars[:4]...), Conv1DFilters,
+		Conv1DBiases)
+)
+
+var Conv1DLayers = []*seqfunc.Conv1D{
+	{
+		InputSize:   4,
+		OutputSize:  2,
+		KernelWidth: 3,
+		Dilation:    2,
+		Causal:      true,
+		Filters:     Conv1DFilters,
+		Biases:      Conv1DBiases,
+	},
+	{
+		InputSize:   4,
+		OutputSize:  2,
+		KernelWidth: 3,
+		Filters:     Conv1DFilters,
+		Biases:      Conv1DBiases,
+	},
+	{
+		InputSize:   4,
+		OutputSize:  3,
+		KernelWidth: 2,
+		Filters:     Conv1DFilters,
+	},
+}
+
+func TestConv1DOutput(t *testing.T) {
+	in := seqfunc.VarResult(TestSeqs)
+	offsets := [][]int{{-4, -2, 0}, {-1, 0, 1}, {0, 1}}
+	for i, layer := range Conv1DLayers {
+		actual := layer.ApplySeqs(in).OutputSeqs()
+		for j, seq := range in.OutputSeqs() {
+			if len(actual[j]) != len(seq) {
+				t.Errorf("layer %d seq %d: expected length %d got %d", i, j, len(seq),
+					len(actual[j]))
+				continue
+			}
+			for step := range seq {
+				expected := conv1DNaive(layer, seq, step, offsets[i])
+				if actual[j][step].Copy().Scale(-1).Add(expected).MaxAbs() > 1e-8 {
+					t.Errorf("layer %d seq %d step %d: expected %v got %v", i, j, step,
+						expected, actual[j][step])
+				}
+			}
+		}
+	}
+}
+
+func TestConv1DChecks(t *testing.T) {
+	for _, layer := range Conv1DLayers {
+		checker := &functest.SeqRFuncChecker{
+			F:     layer,
+			Vars:  Conv1DVars,
+			Input: TestSeqs,
+			RV:    Conv1DRV,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestConv1DEmpty(t *testing.T) {
+	in := seqfunc.ConstResult([][]linalg.Vector{{}, {}})
+	out := Conv1DLayers[0].ApplySeqs(in)
+	if len(out.OutputSeqs()) != 2 {
+		t.Fatalf("expected 2 sequences but got %d", len(out.OutputSeqs()))
+	}
+	g := autofunc.NewGradient(Conv1DVars)
+	out.PropagateGradient([][]linalg.Vector{{}, {}}, g)
+	for _, vec := range g {
+		if vec.MaxAbs() != 0 {
+			t.Error("expected zero gradient")
+		}
+	}
+}
+
+func conv1DNaive(layer *seqfunc.Conv1D, seq []linalg.Vector, step int,
+	offsets []int) linalg.Vector {
+	res := make(linalg.Vector, layer.OutputSize)
+	patchSize := layer.KernelWidth * layer.InputSize
+	for row := range res {
+		if layer.Biases != nil {
+			res[row] = layer.Biases.Vector[row]
+		}
+		for k, off := range offsets {
+			if step+off < 0 || step+off >= len(seq) {
+				continue
+			}
+			for col, x := range seq[step+off] {
+				res[row] += x * layer.Filters.Vector[row*patchSize+k*layer.InputSize+col]
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package seqfunctest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/autofunc/seqfunc"
+)
+
+type embedTestFunc struct {
+	Embedding *autofunc.Embedding
+	IDs       [][]int
+}
+
+func (e *embedTestFunc) ApplySeqs(in seqfunc.Result) seqfunc.Result {
+	return seqfunc.MapN(func(ins ...autofunc.Result) autofunc.Result {
+		return autofunc.Mul(ins[0], ins[1])
+	}, in, seqfunc.Embed(e.Embedding, e.IDs))
+}
+
+func (e *embedTestFunc) ApplySeqsR(rv autofunc.RVector, in seqfunc.RResult) seqfunc.RResult {
+	return seqfunc.MapNR(func(ins ...autofunc.RResult) autofunc.RResult {
+		return autofunc.MulR(ins[0], ins[1])
+	}, in, seqfunc.EmbedR(rv, e.Embedding, e.IDs))
+}
+
+func TestEmbedOutput(t *testing.T) {
+	e := &autofunc.Embedding{
+		Matrix: &autofunc.Variable{Vector: []float64{1, 2, 3, 4, 5, 6}},
+		Cols:   2,
+	}
+	out := seqfunc.Embed(e, [][]int{{1, 2}, {}, {0}}).OutputSeqs()
+	expected := [][][]float64{{{3, 4}, {5, 6}}, {}, {{1, 2}}}
+	if len(out) != len(expected) {
+		t.Fatalf("expected %d seqs but got %d", len(expected), len(out))
+	}
+	for i, seq := range expected {
+		if len(out[i]) != len(seq) {
+			t.Fatalf("seq %d: expected length %d but got %d", i, len(seq), len(out[i]))
+		}
+		for j, vec := range seq {
+			for k, x := range vec {
+				if out[i][j][k] != x {
+					t.Errorf("seq %d step %d: expected %v got %v", i, j, vec, out[i][j])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestEmbedChecks(t *testing.T) {
+	rand.Seed(1337)
+	e := autofunc.NewEmbedding(3, 4)
+	ids := [][]int{{0, 2, 0}, {1, 1, 2}, {2}, {0}, {1, 0}}
+	for _, params := range [][]*autofunc.Variable{e.Parameters(), e.Rows()[1:]} {
+		vars, rv := rnnTestVars(params)
+		checker := &functest.SeqRFuncChecker{
+			F:     &embedTestFunc{Embedding: e, IDs: ids},
+			Vars:  vars,
+			Input: TestSeqs,
+			RV:    rv,
+		}
+		checker.FullCheck(t)
+	}
+}
@@ -0,0 +1,148 @@
+package seqfunctest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/autofunc/seqfunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type rnnTestLayer interface {
+	seqfunc.RFunc
+	Parameters() []*autofunc.Variable
+}
+
+func rnnTestLayers() map[string]rnnTestLayer {
+	rand.Seed(1337)
+	lstm := seqfunc.NewLSTM(4, 3)
+	gru := seqfunc.NewGRU(4, 3)
+	noInit := seqfunc.NewLSTM(4, 2)
+	noInit.InitCell = nil
+	for _, l := range []rnnTestLayer{lstm, gru, noInit} {
+		for _, p := range l.Parameters() {
+			for i := range p.Vector {
+				p.Vector[i] = rand.NormFloat64()
+			}
+		}
+	}
+	return map[string]rnnTestLayer{"LSTM": lstm, "GRU": gru, "LSTMNoInitCell": noInit}
+}
+
+func TestRNNBatchOutput(t *testing.T) {
+	for name, layer := range rnnTestLayers() {
+		in := seqfunc.VarResult(TestSeqs)
+		actual := layer.ApplySeqs(in).OutputSeqs()
+		for i, seq := range TestSeqs {
+			single := seqfunc.VarResult([][]*autofunc.Variable{seq})
+			expected := layer.ApplySeqs(single).OutputSeqs()[0]
+			if len(actual[i]) != len(expected) {
+				t.Errorf("%s seq %d: expected length %d got %d", name, i, len(expected),
+					len(actual[i]))
+				continue
+			}
+			for j, vec := range expected {
+				if vec.Copy().Scale(-1).Add(actual[i][j]).MaxAbs() > 1e-8 {
+					t.Errorf("%s seq %d step %d: expected %v got %v", name, i, j, vec,
+						actual[i][j])
+				}
+			}
+		}
+	}
+}
+
+func TestRNNChecks(t *testing.T) {
+	for name, layer := range rnnTestLayers() {
+		t.Run(name, func(t *testing.T) {
+			vars, rv := rnnTestVars(layer.Parameters())
+			checker := &functest.SeqRFuncChecker{
+				F:     layer,
+				Vars:  vars,
+				Input: TestSeqs,
+				RV:    rv,
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func TestRNNInputSize(t *testing.T) {
+	in := [][]linalg.Vector{{{1, 2, 3, 4}, {1, 2, 3}}}
+	for name, layer := range rnnTestLayers() {
+		for _, r := range []bool{false, true} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s (R=%v): expected panic for bad input size", name, r)
+					}
+				}()
+				if r {
+					layer.ApplySeqsR(autofunc.RVector{}, seqfunc.ConstRResult(in))
+				} else {
+					layer.ApplySeqs(seqfunc.ConstResult(in))
+				}
+			}()
+		}
+	}
+}
+
+func TestBidirectionalChecks(t *testing.T) {
+	layers := rnnTestLayers()
+	bidir := &seqfunc.Bidirectional{
+		Forward:  layers["LSTM"],
+		Backward: layers["GRU"],
+	}
+	lstm := layers["LSTM"].(*seqfunc.LSTM)
+	gru := layers["GRU"].(*seqfunc.GRU)
+
+	// The cells are checked thoroughly elsewhere, so a few
+	// parameters from each direction suffice here.
+	vars, rv := rnnTestVars([]*autofunc.Variable{lstm.InputGate.Input, lstm.InitCell,
+		gru.Candidate.Hidden, gru.InitHidden})
+	checker := &functest.SeqRFuncChecker{
+		F:     bidir,
+		Vars:  vars,
+		Input: TestSeqs,
+		RV:    rv,
+	}
+	checker.FullCheck(t)
+}
+
+func TestBidirectionalOutput(t *testing.T) {
+	layers := rnnTestLayers()
+	bidir := &seqfunc.Bidirectional{
+		Forward:  layers["LSTM"],
+		Backward: layers["GRU"],
+	}
+	in := seqfunc.VarResult(TestSeqs)
+	actual := bidir.ApplySeqs(in).OutputSeqs()
+	forward := layers["LSTM"].ApplySeqs(in).OutputSeqs()
+	backward := layers["GRU"].ApplySeqs(seqfunc.Reverse(in)).OutputSeqs()
+	for i, seq := range actual {
+		for j, vec := range seq {
+			expected := append(linalg.Vector{}, forward[i][j]...)
+			expected = append(expected, backward[i][len(seq)-(j+1)]...)
+			if expected.Copy().Scale(-1).Add(vec).MaxAbs() > 1e-8 {
+				t.Errorf("seq %d step %d: expected %v got %v", i, j, expected, vec)
+			}
+		}
+	}
+}
+
+func rnnTestVars(params []*autofunc.Variable) ([]*autofunc.Variable, autofunc.RVector) {
+	vars := append(append([]*autofunc.Variable{}, TestVars[:4]...), params...)
+	rv := autofunc.RVector{}
+	for k, v := range TestRV {
+		rv[k] = v
+	}
+	for _, p := range params {
+		vec := make(linalg.Vector, len(p.Vector))
+		for i := range vec {
+			vec[i] = rand.NormFloat64()
+		}
+		rv[p] = vec
+	}
+	return vars, rv
+}
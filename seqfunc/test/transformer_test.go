@@ -0,0 +1,153 @@
+package seqfunctest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/autofunc/seqfunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type decoderTestFunc struct {
+	Decoder *seqfunc.TransformerDecoder
+}
+
+func (d *decoderTestFunc) ApplySeqs(in seqfunc.Result) seqfunc.Result {
+	return seqfunc.Pool(in, func(in seqfunc.Result) seqfunc.Result {
+		return d.Decoder.Apply(in, seqfunc.Reverse(in), nil)
+	})
+}
+
+func (d *decoderTestFunc) ApplySeqsR(rv autofunc.RVector,
+	in seqfunc.RResult) seqfunc.RResult {
+	return seqfunc.PoolR(in, func(in seqfunc.RResult) seqfunc.RResult {
+		return d.Decoder.ApplyR(rv, in, seqfunc.ReverseR(in), nil)
+	})
+}
+
+func TestSinusoidEncoding(t *testing.T) {
+	in := seqfunc.ConstResult([][]linalg.Vector{{{0, 0, 0, 0}, {1, 1, 1, 1}}})
+	out := (&seqfunc.PositionalEncoding{}).ApplySeqs(in).OutputSeqs()[0]
+	expected := []linalg.Vector{
+		{0, 1, 0, 1},
+		{1 + math.Sin(1), 1 + math.Cos(1), 1 + math.Sin(0.01), 1 + math.Cos(0.01)},
+	}
+	for i, x := range expected {
+		if x.Copy().Scale(-1).Add(out[i]).MaxAbs() > 1e-8 {
+			t.Errorf("step %d: expected %v got %v", i, x, out[i])
+		}
+	}
+}
+
+func TestPositionalEncodingChecks(t *testing.T) {
+	rand.Seed(1337)
+	for _, enc := range []*seqfunc.PositionalEncoding{{}, seqfunc.NewLearnedPositions(4, 3)} {
+		vars, rv := rnnTestVars(enc.Parameters())
+		checker := &functest.SeqRFuncChecker{
+			F:     enc,
+			Vars:  vars,
+			Input: TestSeqs,
+			RV:    rv,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestTransformerEncoderChecks(t *testing.T) {
+	rand.Seed(1337)
+	enc := seqfunc.NewTransformerEncoder(4, 2, 3)
+	randomizeTransformerNorms(enc.AttentionNorm, enc.FeedForwardNorm)
+
+	// The sub-layers are checked thoroughly elsewhere, so a
+	// few parameters from each one suffice here.
+	vars, rv := rnnTestVars([]*autofunc.Variable{enc.Attention.KeyProj.Data,
+		enc.AttentionNorm.Gains, enc.FeedForward.HiddenBiases, enc.FeedForwardNorm.Biases})
+	checker := &functest.SeqRFuncChecker{
+		F:     enc,
+		Vars:  vars,
+		Input: TestSeqs[1:],
+		RV:    rv,
+	}
+	checker.FullCheck(t)
+}
+
+func TestTransformerDecoderChecks(t *testing.T) {
+	rand.Seed(1337)
+	dec := seqfunc.NewTransformerDecoder(4, 2, 3)
+	randomizeTransformerNorms(dec.SelfNorm, dec.CrossNorm, dec.FeedForwardNorm)
+	vars, rv := rnnTestVars([]*autofunc.Variable{dec.SelfNorm.Biases,
+		dec.CrossAttention.ValueProj.Data, dec.CrossNorm.Gains, dec.FeedForward.OutputBiases})
+	checker := &functest.SeqRFuncChecker{
+		F:     &decoderTestFunc{Decoder: dec},
+		Vars:  vars,
+		Input: TestSeqs[1:],
+		RV:    rv,
+	}
+	checker.FullCheck(t)
+}
+
+func TestTransformerDecoderCausal(t *testing.T) {
+	rand.Seed(1337)
+	dec := seqfunc.NewTransformerDecoder(4, 2, 3)
+	memory := seqfunc.VarResult(TestSeqs[:1])
+	full := dec.Apply(seqfunc.VarResult(TestSeqs[:1]), memory, nil).OutputSeqs()[0]
+	prefix := [][]*autofunc.Variable{TestSeqs[0][:2]}
+	partial := dec.Apply(seqfunc.VarResult(prefix), memory, nil).OutputSeqs()[0]
+	for i, vec := range partial {
+		if vec.Copy().Scale(-1).Add(full[i]).MaxAbs() > 1e-8 {
+			t.Errorf("step %d depends on future inputs", i)
+		}
+	}
+}
+
+func TestTransformerDecoderMemoryOnce(t *testing.T) {
+	rand.Seed(1337)
+	var decs []*seqfunc.TransformerDecoder
+	for i := 0; i < 3; i++ {
+		decs = append(decs, seqfunc.NewTransformerDecoder(4, 2, 3))
+	}
+	memory := &attentionCountResult{Result: seqfunc.VarResult(TestSeqs[:2])}
+	in := seqfunc.VarResult(TestSeqs[1:3])
+
+	// Keys and values come from the same memory.
+	decoderTestBackprop(decs[0].Apply(in, memory, nil))
+	if memory.Count != 1 {
+		t.Errorf("memory was back-propagated %d times", memory.Count)
+	}
+
+	// A stack can share pooled memory.
+	memory.Count = 0
+	decoderTestBackprop(seqfunc.Pool(memory, func(memory seqfunc.Result) seqfunc.Result {
+		h := in
+		for _, dec := range decs {
+			h = dec.Apply(h, memory, nil)
+		}
+		return h
+	}))
+	if memory.Count != 1 {
+		t.Errorf("stack: memory was back-propagated %d times", memory.Count)
+	}
+}
+
+func decoderTestBackprop(out seqfunc.Result) {
+	upstream := make([][]linalg.Vector, len(out.OutputSeqs()))
+	for i, seq := range out.OutputSeqs() {
+		for _, vec := range seq {
+			upstream[i] = append(upstream[i], vec.Copy())
+		}
+	}
+	out.PropagateGradient(upstream, autofunc.NewGradient(TestVars[:4]))
+}
+
+func randomizeTransformerNorms(norms ...*autofunc.LayerNorm) {
+	for _, n := range norms {
+		for _, p := range n.Parameters() {
+			for i := range p.Vector {
+				p.Vector[i] = rand.NormFloat64()
+			}
+		}
+	}
+}
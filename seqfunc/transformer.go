@@ -0,0 +1,233 @@
+package seqfunc
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A FeedForward is an autofunc.RBatcher which applies a
+// two-layer network with a ReLU hidden layer to each of
+// its input vectors.
+// It is used as the position-wise sub-layer of the
+// Transformer blocks.
+type FeedForward struct {
+	Hidden       *autofunc.LinTran
+	HiddenBiases *autofunc.Variable
+	Output       *autofunc.LinTran
+	OutputBiases *autofunc.Variable
+}
+
+// NewFeedForward creates a randomly initialized
+// FeedForward with zero biases whose outputs are the same
+// size as its inputs.
+func NewFeedForward(size, hiddenSize int) *FeedForward {
+	return &FeedForward{
+		Hidden: &autofunc.LinTran{
+			Data: randomMatrix(hiddenSize, size),
+			Rows: hiddenSize,
+			Cols: size,
+		},
+		HiddenBiases: &autofunc.Variable{Vector: make(linalg.Vector, hiddenSize)},
+		Output: &autofunc.LinTran{
+			Data: randomMatrix(size, hiddenSize),
+			Rows: size,
+			Cols: hiddenSize,
+		},
+		OutputBiases: &autofunc.Variable{Vector: make(linalg.Vector, size)},
+	}
+}
+
+// Parameters returns the variables in f.
+func (f *FeedForward) Parameters() []*autofunc.Variable {
+	return []*autofunc.Variable{f.Hidden.Data, f.HiddenBiases, f.Output.Data,
+		f.OutputBiases}
+}
+
+// Apply applies the network to a single vector.
+func (f *FeedForward) Apply(in autofunc.Result) autofunc.Result {
+	return f.Batch(in, 1)
+}
+
+// ApplyR applies the network to a single vector.
+func (f *FeedForward) ApplyR(rv autofunc.RVector, in autofunc.RResult) autofunc.RResult {
+	return f.BatchR(rv, in, 1)
+}
+
+// Batch applies the network to n vectors.
+func (f *FeedForward) Batch(in autofunc.Result, n int) autofunc.Result {
+	hidden := autofunc.Add(f.Hidden.Batch(in, n), autofunc.Repeat(f.HiddenBiases, n))
+	hidden = autofunc.ReLU{}.Batch(hidden, n)
+	return autofunc.Add(f.Output.Batch(hidden, n), autofunc.Repeat(f.OutputBiases, n))
+}
+
+// BatchR is like Batch, but for RResults.
+func (f *FeedForward) BatchR(rv autofunc.RVector, in autofunc.RResult,
+	n int) autofunc.RResult {
+	hiddenBiases := autofunc.NewRVariable(f.HiddenBiases, rv)
+	outputBiases := autofunc.NewRVariable(f.OutputBiases, rv)
+	hidden := autofunc.AddR(f.Hidden.BatchR(rv, in, n), autofunc.RepeatR(hiddenBiases, n))
+	hidden = autofunc.ReLU{}.BatchR(rv, hidden, n)
+	return autofunc.AddR(f.Output.BatchR(rv, hidden, n), autofunc.RepeatR(outputBiases, n))
+}
+
+// A TransformerEncoder is an RFunc implementing a
+// Transformer encoder block.
+//
+// Each sub-layer is wrapped in a residual connection
+// followed by layer normalization:
+//
+//	h = AttentionNorm(x + Attention(x))
+//	y = FeedForwardNorm(h + FeedForward(h))
+//
+// Setting Attention.Causal produces a decoder-only block
+// for autoregressive models.
+type TransformerEncoder struct {
+	Attention       *MultiHeadAttention
+	AttentionNorm   *autofunc.LayerNorm
+	FeedForward     *FeedForward
+	FeedForwardNorm *autofunc.LayerNorm
+}
+
+// NewTransformerEncoder creates a randomly initialized
+// TransformerEncoder.
+// The size must be divisible by the number of heads.
+func NewTransformerEncoder(size, heads, hiddenSize int) *TransformerEncoder {
+	return &TransformerEncoder{
+		Attention:       NewMultiHeadAttention(size, size, heads),
+		AttentionNorm:   autofunc.NewLayerNorm(size),
+		FeedForward:     NewFeedForward(size, hiddenSize),
+		FeedForwardNorm: autofunc.NewLayerNorm(size),
+	}
+}
+
+// Parameters returns the variables in t.
+func (t *TransformerEncoder) Parameters() []*autofunc.Variable {
+	var res []*autofunc.Variable
+	res = append(res, t.Attention.Parameters()...)
+	res = append(res, t.AttentionNorm.Parameters()...)
+	res = append(res, t.FeedForward.Parameters()...)
+	res = append(res, t.FeedForwardNorm.Parameters()...)
+	return res
+}
+
+// ApplySeqs applies the block to every sequence.
+func (t *TransformerEncoder) ApplySeqs(in Result) Result {
+	h := residualNorm(in, t.AttentionNorm, t.Attention.ApplySeqs)
+	return residualNorm(h, t.FeedForwardNorm, (&MapBatcher{B: t.FeedForward}).ApplySeqs)
+}
+
+// ApplySeqsR is like ApplySeqs, but for RResults.
+func (t *TransformerEncoder) ApplySeqsR(rv autofunc.RVector, in RResult) RResult {
+	h := residualNormR(rv, in, t.AttentionNorm, func(in RResult) RResult {
+		return t.Attention.ApplySeqsR(rv, in)
+	})
+	return residualNormR(rv, h, t.FeedForwardNorm, func(in RResult) RResult {
+		return (&MapRBatcher{B: t.FeedForward}).ApplySeqsR(rv, in)
+	})
+}
+
+// A TransformerDecoder implements a Transformer decoder
+// block, which attends to its own inputs and to the
+// outputs of an encoder (the "memory").
+//
+// Like TransformerEncoder, each sub-layer is wrapped in a
+// residual connection followed by layer normalization.
+//
+// Since it takes two inputs, a TransformerDecoder is not
+// an RFunc; use Apply and ApplyR instead.
+type TransformerDecoder struct {
+	SelfAttention *MultiHeadAttention
+	SelfNorm      *autofunc.LayerNorm
+
+	CrossAttention *MultiHeadAttention
+	CrossNorm      *autofunc.LayerNorm
+
+	FeedForward     *FeedForward
+	FeedForwardNorm *autofunc.LayerNorm
+}
+
+// NewTransformerDecoder creates a randomly initialized
+// TransformerDecoder with causal self-attention.
+// The size must be divisible by the number of heads.
+func NewTransformerDecoder(size, heads, hiddenSize int) *TransformerDecoder {
+	res := &TransformerDecoder{
+		SelfAttention:   NewMultiHeadAttention(size, size, heads),
+		SelfNorm:        autofunc.NewLayerNorm(size),
+		CrossAttention:  NewMultiHeadAttention(size, size, heads),
+		CrossNorm:       autofunc.NewLayerNorm(size),
+		FeedForward:     NewFeedForward(size, hiddenSize),
+		FeedForwardNorm: autofunc.NewLayerNorm(size),
+	}
+	res.SelfAttention.Causal = true
+	return res
+}
+
+// Parameters returns the variables in t.
+func (t *TransformerDecoder) Parameters() []*autofunc.Variable {
+	var res []*autofunc.Variable
+	res = append(res, t.SelfAttention.Parameters()...)
+	res = append(res, t.SelfNorm.Parameters()...)
+	res = append(res, t.CrossAttention.Parameters()...)
+	res = append(res, t.CrossNorm.Parameters()...)
+	res = append(res, t.FeedForward.Parameters()...)
+	res = append(res, t.FeedForwardNorm.Parameters()...)
+	return res
+}
+
+// Apply applies the block to every sequence in in, where
+// each sequence attends to the corresponding sequence in
+// memory.
+//
+// The memory mask restricts which memory timesteps can be
+// attended to, and is typically used for padding.
+// It may be nil.
+//
+// The memory is back-propagated through once per call.
+// When a stack of decoders shares the same memory, pool
+// it once for the whole stack (see Pool), so that the
+// encoder is only back-propagated through once.
+func (t *TransformerDecoder) Apply(in, memory Result, memoryMask *AttentionMask) Result {
+	h := residualNorm(in, t.SelfNorm, t.SelfAttention.ApplySeqs)
+	h = residualNorm(h, t.CrossNorm, func(in Result) Result {
+		return Pool(memory, func(memory Result) Result {
+			return t.CrossAttention.Apply(in, memory, memory, memoryMask)
+		})
+	})
+	return residualNorm(h, t.FeedForwardNorm, (&MapBatcher{B: t.FeedForward}).ApplySeqs)
+}
+
+// ApplyR is like Apply, but for RResults.
+func (t *TransformerDecoder) ApplyR(rv autofunc.RVector, in, memory RResult,
+	memoryMask *AttentionMask) RResult {
+	h := residualNormR(rv, in, t.SelfNorm, func(in RResult) RResult {
+		return t.SelfAttention.ApplySeqsR(rv, in)
+	})
+	h = residualNormR(rv, h, t.CrossNorm, func(in RResult) RResult {
+		return PoolR(memory, func(memory RResult) RResult {
+			return t.CrossAttention.ApplyR(rv, in, memory, memory, memoryMask)
+		})
+	})
+	return residualNormR(rv, h, t.FeedForwardNorm, func(in RResult) RResult {
+		return (&MapRBatcher{B: t.FeedForward}).ApplySeqsR(rv, in)
+	})
+}
+
+// residualNorm computes norm(x + f(x)) at every timestep.
+func residualNorm(in Result, norm *autofunc.LayerNorm, f func(Result) Result) Result {
+	return Pool(in, func(in Result) Result {
+		sum := MapN(func(ins ...autofunc.Result) autofunc.Result {
+			return autofunc.Add(ins[0], ins[1])
+		}, in, f(in))
+		return (&MapBatcher{B: norm}).ApplySeqs(sum)
+	})
+}
+
+func residualNormR(rv autofunc.RVector, in RResult, norm *autofunc.LayerNorm,
+	f func(RResult) RResult) RResult {
+	return PoolR(in, func(in RResult) RResult {
+		sum := MapNR(func(ins ...autofunc.RResult) autofunc.RResult {
+			return autofunc.AddR(ins[0], ins[1])
+		}, in, f(in))
+		return (&MapRBatcher{B: norm}).ApplySeqsR(rv, sum)
+	})
+}
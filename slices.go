@@ -3,6 +3,8 @@ package autofunc
 import "github.com/unixpickle/num-analysis/linalg"
 
 type joinedResults struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Results   []Result
 }
@@ -11,6 +13,7 @@ type joinedResults struct {
 // The results are concatenated first to last,
 // so Concat({1,2,3}, {4,5,6}) = {1,2,3,4,5,6}.
 func Concat(rs ...Result) Result {
+	scope := scopeOf(rs...)
 	outputs := make([]linalg.Vector, len(rs))
 	var totalLen int
 	for i, x := range rs {
@@ -18,17 +21,17 @@ func Concat(rs ...Result) Result {
 		totalLen += len(outputs[i])
 	}
 
-	outVec := make(linalg.Vector, totalLen)
+	outVec := scope.Alloc(totalLen)
 	vecIdx := 0
 	for _, x := range outputs {
 		copy(outVec[vecIdx:], x)
 		vecIdx += len(x)
 	}
 
-	return &joinedResults{
+	return scope.result(&joinedResults{
 		OutputVec: outVec,
 		Results:   rs,
-	}
+	})
 }
 
 func (j *joinedResults) Output() linalg.Vector {
@@ -56,6 +59,8 @@ func (j *joinedResults) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type joinedRResults struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Results    []RResult
@@ -63,6 +68,7 @@ type joinedRResults struct {
 
 // ConcatR is like Concat, but for RResults.
 func ConcatR(rs ...RResult) RResult {
+	scope := scopeOfR(rs...)
 	outputs := make([]linalg.Vector, len(rs))
 	routputs := make([]linalg.Vector, len(rs))
 	var totalLen int
@@ -72,8 +78,8 @@ func ConcatR(rs ...RResult) RResult {
 		totalLen += len(outputs[i])
 	}
 
-	outVec := make(linalg.Vector, totalLen)
-	outVecR := make(linalg.Vector, totalLen)
+	outVec := scope.Alloc(totalLen)
+	outVecR := scope.Alloc(totalLen)
 	vecIdx := 0
 	for i, x := range outputs {
 		copy(outVec[vecIdx:], x)
@@ -81,11 +87,11 @@ func ConcatR(rs ...RResult) RResult {
 		vecIdx += len(x)
 	}
 
-	return &joinedRResults{
+	return scope.rresult(&joinedRResults{
 		OutputVec:  outVec,
 		ROutputVec: outVecR,
 		Results:    rs,
-	}
+	})
 }
 
 func (j *joinedRResults) Output() linalg.Vector {
@@ -119,6 +125,8 @@ func (j *joinedRResults) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type slicedResult struct {
+	scopeRef
+
 	Input    Result
 	StartIdx int
 	EndIdx   int
@@ -128,11 +136,12 @@ type slicedResult struct {
 // sub-range of input.Output() between the start
 // index (inclusive) and end index (exclusive).
 func Slice(in Result, start, end int) Result {
-	return &slicedResult{
+	scope := scopeOf(in)
+	return scope.result(&slicedResult{
 		Input:    in,
 		StartIdx: start,
 		EndIdx:   end,
-	}
+	})
 }
 
 func (s *slicedResult) Output() linalg.Vector {
@@ -145,11 +154,11 @@ func (s *slicedResult) Constant(g Gradient) bool {
 
 func (s *slicedResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
 	if !s.Input.Constant(grad) {
-		if variable, ok := s.Input.(*Variable); ok {
+		if variable, ok := unscoped(s.Input).(*Variable); ok {
 			sumGradVec := grad[variable]
 			sumGradVec[s.StartIdx:s.EndIdx].Add(upstream)
 		} else {
-			downstream := make(linalg.Vector, len(s.Input.Output()))
+			downstream := s.Scope.Alloc(len(s.Input.Output()))
 			copy(downstream[s.StartIdx:], upstream)
 			s.Input.PropagateGradient(downstream, grad)
 		}
@@ -157,6 +166,8 @@ func (s *slicedResult) PropagateGradient(upstream linalg.Vector, grad Gradient)
 }
 
 type slicedRResult struct {
+	scopeRef
+
 	Input    RResult
 	StartIdx int
 	EndIdx   int
@@ -164,11 +175,12 @@ type slicedRResult struct {
 
 // SliceR is like Slice, but for RResults.
 func SliceR(in RResult, start, end int) RResult {
-	return &slicedRResult{
+	scope := scopeOfR(in)
+	return scope.rresult(&slicedRResult{
 		Input:    in,
 		StartIdx: start,
 		EndIdx:   end,
-	}
+	})
 }
 
 func (s *slicedRResult) Output() linalg.Vector {
@@ -186,7 +198,7 @@ func (s *slicedRResult) Constant(rg RGradient, g Gradient) bool {
 func (s *slicedRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 	rgrad RGradient, grad Gradient) {
 	if !s.Input.Constant(rgrad, grad) {
-		if rVariable, ok := s.Input.(*RVariable); ok {
+		if rVariable, ok := unscopedR(s.Input).(*RVariable); ok {
 			gradVec := grad[rVariable.Variable]
 			if gradVec != nil {
 				gradVec[s.StartIdx:s.EndIdx].Add(upstream)
@@ -196,8 +208,8 @@ func (s *slicedRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 				rgradVec[s.StartIdx:s.EndIdx].Add(upstreamR)
 			}
 		} else {
-			downstream := make(linalg.Vector, len(s.Input.Output()))
-			downstreamR := make(linalg.Vector, len(s.Input.Output()))
+			downstream := s.Scope.Alloc(len(s.Input.Output()))
+			downstreamR := s.Scope.Alloc(len(s.Input.Output()))
 			copy(downstream[s.StartIdx:], upstream)
 			copy(downstreamR[s.StartIdx:], upstreamR)
 			s.Input.PropagateRGradient(downstream, downstreamR, rgrad, grad)
@@ -206,6 +218,8 @@ func (s *slicedRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
 }
 
 type repeatResult struct {
+	scopeRef
+
 	OutputVec linalg.Vector
 	Repeated  Result
 	N         int
@@ -214,16 +228,17 @@ type repeatResult struct {
 // Repeat concatenates a Result with itself n times.
 // This may be more efficient than using Concat.
 func Repeat(in Result, n int) Result {
+	scope := scopeOf(in)
 	inVec := in.Output()
-	outVec := make(linalg.Vector, len(inVec)*n)
+	outVec := scope.Alloc(len(inVec) * n)
 	for i := 0; i < n; i++ {
 		copy(outVec[i*len(inVec):], inVec)
 	}
-	return &repeatResult{
+	return scope.result(&repeatResult{
 		OutputVec: outVec,
 		Repeated:  in,
 		N:         n,
-	}
+	})
 }
 
 func (r *repeatResult) Output() linalg.Vector {
@@ -251,6 +266,8 @@ func (r *repeatResult) PropagateGradient(upstream linalg.Vector, g Gradient) {
 }
 
 type repeatRResult struct {
+	scopeRef
+
 	OutputVec  linalg.Vector
 	ROutputVec linalg.Vector
 	Repeated   RResult
@@ -259,20 +276,21 @@ type repeatRResult struct {
 
 // RepeatR is like Repeat, but for RResults.
 func RepeatR(in RResult, n int) RResult {
+	scope := scopeOfR(in)
 	inVec := in.Output()
 	inVecR := in.ROutput()
-	outVec := make(linalg.Vector, len(inVec)*n)
-	outVecR := make(linalg.Vector, len(inVec)*n)
+	outVec := scope.Alloc(len(inVec) * n)
+	outVecR := scope.Alloc(len(inVec) * n)
 	for i := 0; i < n; i++ {
 		copy(outVec[i*len(inVec):], inVec)
 		copy(outVecR[i*len(inVec):], inVecR)
 	}
-	return &repeatRResult{
+	return scope.rresult(&repeatRResult{
 		OutputVec:  outVec,
 		ROutputVec: outVecR,
 		Repeated:   in,
 		N:          n,
-	}
+	})
 }
 
 func (r *repeatRResult) Output() linalg.Vector {
@@ -0,0 +1,200 @@
+package autofunc
+
+import (
+	"math/rand"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// Dropout is an RFunc and RBatcher which randomly zeroes
+// components of its input during training.
+//
+// The components which are kept are divided by KeepProb,
+// so that the expected output equals the input and no
+// rescaling is needed at inference time.
+//
+// A new mask is drawn for every call to Apply, ApplyR,
+// Batch, or BatchR, and the resulting Result uses that
+// mask for all of its back propagation.
+type Dropout struct {
+	// KeepProb is the probability that each component is
+	// kept.
+	KeepProb float64
+
+	// Rand is the source of randomness.
+	// If it is nil, the global source from math/rand is
+	// used, making the masks irreproducible.
+	Rand *rand.Rand
+
+	// Training indicates that components should be dropped.
+	// Otherwise, the input is returned unchanged.
+	Training bool
+}
+
+// Apply applies dropout to a vector.
+func (d *Dropout) Apply(in Result) Result {
+	if !d.Training {
+		return in
+	}
+	return Mul(in, d.mask(scopeOf(in), len(in.Output())))
+}
+
+// ApplyR applies dropout to a vector.
+func (d *Dropout) ApplyR(v RVector, in RResult) RResult {
+	if !d.Training {
+		return in
+	}
+	return MulR(in, NewRVariable(d.mask(scopeOfR(in), len(in.Output())), v))
+}
+
+// Batch applies dropout to n vectors.
+// Every component of every vector is dropped
+// independently.
+func (d *Dropout) Batch(in Result, n int) Result {
+	return d.Apply(in)
+}
+
+// BatchR is like Batch, but for RResults.
+func (d *Dropout) BatchR(v RVector, in RResult, n int) RResult {
+	return d.ApplyR(v, in)
+}
+
+func (d *Dropout) mask(s *Scope, size int) *Variable {
+	return &Variable{Vector: dropoutMask(s, d.Rand, size, d.KeepProb)}
+}
+
+// GaussianNoise is an RFunc and RBatcher which adds
+// independent Gaussian noise to every component of its
+// input during training.
+//
+// A new noise vector is drawn for every call to Apply,
+// ApplyR, Batch, or BatchR.
+type GaussianNoise struct {
+	// Stddev is the standard deviation of the noise.
+	Stddev float64
+
+	// Rand is the source of randomness.
+	// If it is nil, the global source from math/rand is
+	// used.
+	Rand *rand.Rand
+
+	// Training indicates that noise should be added.
+	// Otherwise, the input is returned unchanged.
+	Training bool
+}
+
+// Apply adds noise to a vector.
+func (g *GaussianNoise) Apply(in Result) Result {
+	if !g.Training {
+		return in
+	}
+	return LinAdd{Var: g.noise(scopeOf(in), len(in.Output()))}.Apply(in)
+}
+
+// ApplyR adds noise to a vector.
+func (g *GaussianNoise) ApplyR(v RVector, in RResult) RResult {
+	if !g.Training {
+		return in
+	}
+	return LinAdd{Var: g.noise(scopeOfR(in), len(in.Output()))}.ApplyR(v, in)
+}
+
+// Batch adds noise to n vectors.
+func (g *GaussianNoise) Batch(in Result, n int) Result {
+	return g.Apply(in)
+}
+
+// BatchR is like Batch, but for RResults.
+func (g *GaussianNoise) BatchR(v RVector, in RResult, n int) RResult {
+	return g.ApplyR(v, in)
+}
+
+func (g *GaussianNoise) noise(s *Scope, size int) *Variable {
+	res := &Variable{Vector: s.Alloc(size)}
+	for i := range res.Vector {
+		if g.Rand == nil {
+			res.Vector[i] = rand.NormFloat64() * g.Stddev
+		} else {
+			res.Vector[i] = g.Rand.NormFloat64() * g.Stddev
+		}
+	}
+	return res
+}
+
+// DropConnect is an RFunc and RBatcher which applies a
+// LinTran with randomly dropped weights during training.
+//
+// Like Dropout, the kept weights are divided by KeepProb,
+// so the LinTran can be used directly at inference time.
+// One mask is drawn per call, and it is shared by every
+// vector in a batch.
+type DropConnect struct {
+	LinTran *LinTran
+
+	// KeepProb is the probability that each weight is
+	// kept.
+	KeepProb float64
+
+	// Rand is the source of randomness.
+	// If it is nil, the global source from math/rand is
+	// used.
+	Rand *rand.Rand
+
+	// Training indicates that weights should be dropped.
+	// Otherwise, the LinTran is applied unchanged.
+	Training bool
+}
+
+// Apply applies the masked LinTran to a vector.
+func (d *DropConnect) Apply(in Result) Result {
+	return d.Batch(in, 1)
+}
+
+// ApplyR applies the masked LinTran to a vector.
+func (d *DropConnect) ApplyR(v RVector, in RResult) RResult {
+	return d.BatchR(v, in, 1)
+}
+
+// Batch applies the masked LinTran to n vectors.
+func (d *DropConnect) Batch(in Result, n int) Result {
+	if !d.Training {
+		return d.LinTran.Batch(in, n)
+	}
+	l := d.LinTran
+	scope := scopeOf(in)
+	weights := Mul(scope.Result(l.Data), d.mask(scope))
+	return MatMulVecs(weights, l.Rows, l.Cols, in)
+}
+
+// BatchR is like Batch, but for RResults.
+func (d *DropConnect) BatchR(v RVector, in RResult, n int) RResult {
+	if !d.Training {
+		return d.LinTran.BatchR(v, in, n)
+	}
+	l := d.LinTran
+	scope := scopeOfR(in)
+	weights := MulR(scope.RResult(NewRVariable(l.Data, v)), NewRVariable(d.mask(scope), v))
+	return MatMulVecsR(weights, l.Rows, l.Cols, in)
+}
+
+func (d *DropConnect) mask(s *Scope) *Variable {
+	return &Variable{Vector: dropoutMask(s, d.Rand, len(d.LinTran.Data.Vector), d.KeepProb)}
+}
+
+// dropoutMask generates a vector whose components are
+// 1/keepProb with probability keepProb and 0 otherwise.
+func dropoutMask(s *Scope, r *rand.Rand, size int, keepProb float64) linalg.Vector {
+	res := s.Alloc(size)
+	for i := range res {
+		var x float64
+		if r == nil {
+			x = rand.Float64()
+		} else {
+			x = r.Float64()
+		}
+		if x < keepProb {
+			res[i] = 1 / keepProb
+		}
+	}
+	return res
+}
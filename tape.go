@@ -0,0 +1,197 @@
+package autofunc
+
+import "github.com/unixpickle/num-analysis/linalg"
+
+// A Tape records Results as they are created so that
+// back propagation can visit each of them exactly once.
+//
+// Every recorded Result is replaced by a stand-in which
+// should be used in its place when building further
+// Results.
+// Back propagation through the Result returned by Output
+// accumulates the upstream vectors for every stand-in,
+// then sweeps through the recorded Results in reverse
+// order, propagating through each of them once.
+// Since a Result can only depend on Results recorded
+// before it, this order is topologically sorted.
+//
+// This makes it unnecessary to use Pool or PoolAll for
+// Results that are used more than once.
+//
+// Results can be recorded explicitly with Record, RecordR,
+// Apply, and ApplyR, or automatically by computing them
+// in a Scope whose Tape is set.
+// In the latter case, every built-in Result computed from
+// the Scope's inputs is recorded as it is created, and
+// Results of other types are recorded when they are
+// attached to the Scope with Scope.Result or
+// Scope.RResult.
+//
+// Only recorded Results are propagated through once.
+// A Result of another type which is used several times
+// without being recorded or attached to the Scope is
+// still propagated through once per use.
+//
+// A Tape should be used for either Results or RResults,
+// but not both.
+type Tape struct {
+	nodes []*tapeNode
+
+	// paused is non-zero while Scope.Untaped is running.
+	paused int
+}
+
+type tapeNode struct {
+	Var     *Variable
+	Result  Result
+	RResult RResult
+}
+
+// Record adds r to the tape and returns a stand-in for r.
+func (t *Tape) Record(r Result) Result {
+	v := &Variable{Vector: r.Output()}
+	t.nodes = append(t.nodes, &tapeNode{Var: v, Result: r})
+	return v
+}
+
+// RecordR adds r to the tape and returns a stand-in for r.
+func (t *Tape) RecordR(r RResult) RResult {
+	v := &Variable{Vector: r.Output()}
+	t.nodes = append(t.nodes, &tapeNode{Var: v, RResult: r})
+	return &RVariable{Variable: v, ROutputVec: r.ROutput()}
+}
+
+// Apply applies f to in and records the result.
+func (t *Tape) Apply(f Func, in Result) Result {
+	return t.Record(f.Apply(in))
+}
+
+// ApplyR applies f to in and records the result.
+func (t *Tape) ApplyR(f RFunc, v RVector, in RResult) RResult {
+	return t.RecordR(f.ApplyR(v, in))
+}
+
+// Output wraps a Result which was computed from the
+// stand-ins in the tape.
+// Back propagation through the returned Result sweeps
+// through every Result recorded so far.
+func (t *Tape) Output(r Result) Result {
+	nodes := make([]*tapeNode, len(t.nodes))
+	copy(nodes, t.nodes)
+	return &tapeResult{Nodes: nodes, FOutput: r}
+}
+
+// OutputR is like Output, but for RResults.
+func (t *Tape) OutputR(r RResult) RResult {
+	nodes := make([]*tapeNode, len(t.nodes))
+	copy(nodes, t.nodes)
+	return &tapeRResult{Nodes: nodes, FOutput: r}
+}
+
+type tapeResult struct {
+	Nodes   []*tapeNode
+	FOutput Result
+}
+
+func (t *tapeResult) Output() linalg.Vector {
+	return t.FOutput.Output()
+}
+
+func (t *tapeResult) Constant(g Gradient) bool {
+	return t.FOutput.Constant(t.extendGradient(g))
+}
+
+func (t *tapeResult) PropagateGradient(upstream linalg.Vector, grad Gradient) {
+	ext := t.extendGradient(grad)
+	for _, n := range t.Nodes {
+		if vec, ok := ext[n.Var]; ok {
+			grad[n.Var] = vec
+		}
+	}
+	t.FOutput.PropagateGradient(upstream, grad)
+	for i := len(t.Nodes) - 1; i >= 0; i-- {
+		n := t.Nodes[i]
+		if nodeUpstream, ok := grad[n.Var]; ok {
+			delete(grad, n.Var)
+			n.Result.PropagateGradient(nodeUpstream, grad)
+		}
+	}
+}
+
+// extendGradient returns a copy of g (sharing vectors)
+// with zero entries for every non-constant node.
+func (t *tapeResult) extendGradient(g Gradient) Gradient {
+	ext := Gradient{}
+	for k, v := range g {
+		ext[k] = v
+	}
+	for _, n := range t.Nodes {
+		if !n.Result.Constant(ext) {
+			ext[n.Var] = make(linalg.Vector, len(n.Var.Vector))
+		}
+	}
+	return ext
+}
+
+type tapeRResult struct {
+	Nodes   []*tapeNode
+	FOutput RResult
+}
+
+func (t *tapeRResult) Output() linalg.Vector {
+	return t.FOutput.Output()
+}
+
+func (t *tapeRResult) ROutput() linalg.Vector {
+	return t.FOutput.ROutput()
+}
+
+func (t *tapeRResult) Constant(rg RGradient, g Gradient) bool {
+	extRG, extG := t.extendGradients(rg, g)
+	return t.FOutput.Constant(extRG, extG)
+}
+
+func (t *tapeRResult) PropagateRGradient(upstream, upstreamR linalg.Vector,
+	rgrad RGradient, grad Gradient) {
+	if grad == nil {
+		grad = Gradient{}
+	}
+	extRG, extG := t.extendGradients(rgrad, grad)
+	for _, n := range t.Nodes {
+		if vec, ok := extRG[n.Var]; ok {
+			rgrad[n.Var] = vec
+			grad[n.Var] = extG[n.Var]
+		}
+	}
+	t.FOutput.PropagateRGradient(upstream, upstreamR, rgrad, grad)
+	for i := len(t.Nodes) - 1; i >= 0; i-- {
+		n := t.Nodes[i]
+		if nodeUpstreamR, ok := rgrad[n.Var]; ok {
+			nodeUpstream := grad[n.Var]
+			delete(rgrad, n.Var)
+			delete(grad, n.Var)
+			n.RResult.PropagateRGradient(nodeUpstream, nodeUpstreamR, rgrad, grad)
+		}
+	}
+}
+
+// extendGradients is like tapeResult.extendGradient,
+// but for both an RGradient and a Gradient.
+// The g argument may be nil.
+func (t *tapeRResult) extendGradients(rg RGradient, g Gradient) (RGradient, Gradient) {
+	extRG := RGradient{}
+	extG := Gradient{}
+	for k, v := range rg {
+		extRG[k] = v
+	}
+	for k, v := range g {
+		extG[k] = v
+	}
+	for _, n := range t.Nodes {
+		if !n.RResult.Constant(extRG, extG) {
+			extRG[n.Var] = make(linalg.Vector, len(n.Var.Vector))
+			extG[n.Var] = make(linalg.Vector, len(n.Var.Vector))
+		}
+	}
+	return extRG, extG
+}
@@ -0,0 +1,90 @@
+package autofunc
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// The zero entries land on the kinks of the piecewise
+// activations; their r-values are zero so that the
+// second-order checks do not straddle the kink.
+var (
+	activationTestVec = &Variable{
+		Vector: linalg.Vector([]float64{1, -0.5, 0, 0.3, -2, 0}),
+	}
+	activationTestVars = []*Variable{activationTestVec}
+	activationTestRVec = RVector{
+		activationTestVec: linalg.Vector([]float64{0.5, -1, 0, 3, 0.7, 0}),
+	}
+)
+
+var activationTestFuncs = map[string]RBatcher{
+	"ReLU":      ReLU{},
+	"LeakyReLU": LeakyReLU{Leak: 0.1},
+	"ELU":       ELU{Alpha: 1.2},
+	"Softplus":  Softplus{},
+	"Tanh":      Tanh{},
+}
+
+type activationBatchTest struct {
+	B RBatcher
+}
+
+func (a activationBatchTest) Apply(in Result) Result {
+	return a.B.Batch(in, 2)
+}
+
+func (a activationBatchTest) ApplyR(v RVector, in RResult) RResult {
+	return a.B.BatchR(v, in, 2)
+}
+
+func TestActivationChecks(t *testing.T) {
+	for name, f := range activationTestFuncs {
+		t.Run(name, func(t *testing.T) {
+			checker := &functest.RFuncChecker{
+				F:     ComposedRFunc{f.(RFunc), Sigmoid{}},
+				Vars:  activationTestVars,
+				Input: activationTestVec,
+				RV:    activationTestRVec,
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func TestActivationBatchChecks(t *testing.T) {
+	for name, f := range activationTestFuncs {
+		t.Run(name, func(t *testing.T) {
+			checker := &functest.RFuncChecker{
+				F:     activationBatchTest{B: f},
+				Vars:  activationTestVars,
+				Input: activationTestVec,
+				RV:    activationTestRVec,
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func TestActivationOutputs(t *testing.T) {
+	in := []float64{-800, -1, 0, 2, 800}
+	expected := map[string][]float64{
+		"ReLU":      {0, 0, 0, 2, 800},
+		"LeakyReLU": {-80, -0.1, 0, 2, 800},
+		"ELU":       {-1.2, 1.2 * (math.Exp(-1) - 1), 0, 2, 800},
+		"Softplus":  {0, math.Log(1 + math.Exp(-1)), math.Log(2), math.Log(1 + math.Exp(2)), 800},
+		"Tanh":      {-1, math.Tanh(-1), 0, math.Tanh(2), 1},
+	}
+	for name, f := range activationTestFuncs {
+		actual := f.Batch(&Variable{Vector: in}, 1).Output()
+		for i, x := range expected[name] {
+			if math.Abs(actual[i]-x) > 1e-8 {
+				t.Errorf("%s: output %d should be %f but got %f", name, i, x, actual[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package autofunc
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+func TestArenaReuse(t *testing.T) {
+	var a Arena
+	v1 := a.Alloc(3)
+	v1[0] = 5
+	v2 := a.Alloc(3)
+	if &v1[0] == &v2[0] {
+		t.Fatal("vectors should not be shared before Reset")
+	}
+	a.Reset()
+	v3 := a.Alloc(3)
+	if &v3[0] != &v1[0] && &v3[0] != &v2[0] {
+		t.Error("vector was not reused after Reset")
+	}
+	if v3.MaxAbs() != 0 {
+		t.Errorf("reused vector should be zero but got %v", v3)
+	}
+	if len(a.Alloc(2)) != 2 {
+		t.Error("unexpected vector size")
+	}
+}
+
+func TestArenaGradients(t *testing.T) {
+	rand.Seed(1337)
+	lt := &LinTran{Data: &Variable{Vector: make(linalg.Vector, 12)}, Rows: 3, Cols: 4}
+	bias := &Variable{Vector: make(linalg.Vector, 6)}
+	in := &Variable{Vector: make(linalg.Vector, 8)}
+	vars := []*Variable{lt.Data, bias, in}
+	rv := RVector{}
+	for _, v := range vars {
+		rv[v] = make(linalg.Vector, len(v.Vector))
+		for i := range v.Vector {
+			v.Vector[i] = rand.NormFloat64()
+			rv[v][i] = rand.NormFloat64()
+		}
+	}
+	f := func(s *Scope) (Gradient, RGradient) {
+		inRes := s.RResult(NewRVariable(in, rv))
+		out := LinAdd{Var: bias}.ApplyR(rv, lt.BatchR(rv, inRes, 2))
+		out = MulR(Sigmoid{}.ApplyR(rv, out), SubR(Tanh{}.ApplyR(rv, out),
+			ScaleR(out, 0.5)))
+		grad := NewGradient(vars)
+		rgrad := NewRGradient(vars)
+		out.PropagateRGradient(out.Output().Copy(), out.ROutput().Copy(), rgrad, grad)
+		return grad, rgrad
+	}
+	expected, expectedR := f(nil)
+
+	arena := &Arena{}
+	scope := &Scope{Allocator: arena}
+	for i := 0; i < 3; i++ {
+		actual, actualR := f(scope)
+		for _, v := range vars {
+			for _, pair := range [][2]linalg.Vector{{expected[v], actual[v]},
+				{expectedR[v], actualR[v]}} {
+				if pair[0].Copy().Scale(-1).Add(pair[1]).MaxAbs() > 1e-10 {
+					t.Fatalf("iteration %d: expected %v got %v", i, pair[0], pair[1])
+				}
+			}
+		}
+		arena.Reset()
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	lt := &LinTran{Data: &Variable{Vector: make(linalg.Vector, 100)}, Rows: 10, Cols: 10}
+	in := &Variable{Vector: make(linalg.Vector, 10)}
+	grad := NewGradient([]*Variable{lt.Data})
+	upstream := make(linalg.Vector, 10)
+	f := func(x Result) {
+		out := Sigmoid{}.Apply(LinAdd{Var: in}.Apply(lt.Apply(x)))
+		out.PropagateGradient(upstream, grad)
+	}
+	plain := testing.AllocsPerRun(10, func() {
+		f(in)
+	})
+
+	arena := &Arena{}
+	scoped := (&Scope{Allocator: arena}).Result(in)
+	withArena := testing.AllocsPerRun(10, func() {
+		f(scoped)
+		arena.Reset()
+	})
+	if withArena >= plain {
+		t.Errorf("arena did not reduce allocations: %f vs %f", withArena, plain)
+	}
+}
+
+func TestScopeIsolation(t *testing.T) {
+	a := &Variable{Vector: linalg.Vector{1, 2, 3, 4}}
+	b := &Variable{Vector: linalg.Vector{2, 0, 1, 3}}
+	f := func(x Result) Result {
+		prod := MatMul(x, b, MatMulShape{ARows: 2, ACols: 2, BRows: 2, BCols: 2})
+		return SumAll(Concat(Sigmoid{}.Apply(prod), Slice(x, 1, 3)))
+	}
+	var counts [2]countingAllocator
+	scopes := []*Scope{{Allocator: &counts[0]}, {Allocator: &counts[1]}}
+
+	expected := f(a)
+	actual := f(scopes[0].Result(a))
+	if actual.Output()[0] != expected.Output()[0] {
+		t.Errorf("expected %v but got %v", expected.Output(), actual.Output())
+	}
+	actual.PropagateGradient(linalg.Vector{1}, NewGradient([]*Variable{a, b}))
+	if counts[0] == 0 {
+		t.Error("scope's allocator was not used")
+	}
+	if counts[1] != 0 {
+		t.Error("another scope's allocator was used")
+	}
+
+	counts[0] = 0
+	f(a).PropagateGradient(linalg.Vector{1}, NewGradient([]*Variable{a, b}))
+	if counts[0] != 0 {
+		t.Error("allocator was used outside of its scope")
+	}
+}
+
+// countingAllocator counts calls to Alloc.
+type countingAllocator int
+
+func (c *countingAllocator) Alloc(size int) linalg.Vector {
+	*c++
+	return make(linalg.Vector, size)
+}
@@ -0,0 +1,280 @@
+package autofunc
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type checkpointFoldTestFunc struct {
+	initState *Variable
+	interval  int
+}
+
+func (c *checkpointFoldTestFunc) Apply(in Result) Result {
+	ins := Split(len(in.Output())/2, in)
+	return CheckpointFold(c.initState, ins, c.interval, func(state, in Result) Result {
+		return Mul(Inverse(state), in)
+	})
+}
+
+func (c *checkpointFoldTestFunc) ApplyR(rv RVector, in RResult) RResult {
+	ins := SplitR(len(in.Output())/2, in)
+	s := NewRVariable(c.initState, rv)
+	return CheckpointFoldR(s, ins, c.interval, func(state, in RResult) RResult {
+		return MulR(InverseR(state), in)
+	})
+}
+
+func TestCheckpointFold(t *testing.T) {
+	vars := []*Variable{
+		&Variable{Vector: []float64{1, 2, 2.5, 1.5, 3, 0.5, 1, 2, 2, 1}},
+		&Variable{Vector: []float64{2, 1}},
+	}
+	rv := checkpointTestRV(vars)
+	for _, interval := range []int{0, 1, 2, 5} {
+		ch := &functest.RFuncChecker{
+			F:     &checkpointFoldTestFunc{initState: vars[1], interval: interval},
+			Vars:  vars,
+			Input: vars[0],
+			RV:    rv,
+		}
+		ch.FullCheck(t)
+	}
+}
+
+func TestCheckpointFoldExact(t *testing.T) {
+	vars := []*Variable{
+		&Variable{Vector: []float64{1, 2, 2.5, 1.5, 3, 0.5, 0.5, 0.25, 2, 1, -1, 0.5}},
+		&Variable{Vector: []float64{2, 1}},
+	}
+	rv := checkpointTestRV(vars)
+	f := func(state, in RResult) RResult {
+		return AddR(MulR(Tanh{}.ApplyR(nil, state), in), ScaleR(state, 0.5))
+	}
+	expected := FoldR(NewRVariable(vars[1], rv), SplitR(6, NewRVariable(vars[0], rv)), f)
+	for _, interval := range []int{0, 1, 2, 4, 6} {
+		actual := CheckpointFoldR(NewRVariable(vars[1], rv),
+			SplitR(6, NewRVariable(vars[0], rv)), interval, f)
+		checkpointExactCheck(t, vars, expected, actual)
+	}
+}
+
+func TestCheckpointFoldExactGradient(t *testing.T) {
+	vars := []*Variable{
+		&Variable{Vector: []float64{1, 2, 2.5, 1.5, 3, 0.5, 0.5, 0.25, 2, 1, -1, 0.5}},
+		&Variable{Vector: []float64{2, 1}},
+		&Variable{Vector: []float64{0.3, -0.7}},
+	}
+	f := func(state, in Result) Result {
+		return Add(Mul(Tanh{}.Apply(state), in), Mul(state, vars[2]))
+	}
+	expected := Fold(vars[1], Split(6, vars[0]), f)
+	for _, interval := range []int{0, 1, 2, 4, 6} {
+		actual := CheckpointFold(vars[1], Split(6, vars[0]), interval, f)
+		checkpointExactGradCheck(t, vars, expected, actual)
+	}
+
+	// When only the captured Variable is being
+	// differentiated, the result is still not constant.
+	constIn := []Result{&Variable{Vector: []float64{1, 2}}, &Variable{Vector: []float64{3, 4}}}
+	expected = Fold(vars[1], constIn, f)
+	actual := CheckpointFold(vars[1], constIn, 1, f)
+	checkpointExactGradCheck(t, vars[2:], expected, actual)
+}
+
+func TestCheckpointFunc(t *testing.T) {
+	vars, funcs := checkpointTestFuncs()
+	rv := checkpointTestRV(vars)
+	for _, interval := range []int{0, 1, 2, 5} {
+		ch := &functest.RFuncChecker{
+			F:     &CheckpointRFunc{Funcs: funcs, Interval: interval},
+			Vars:  vars,
+			Input: vars[0],
+			RV:    rv,
+		}
+		ch.FullCheck(t)
+	}
+}
+
+func TestCheckpointFuncExact(t *testing.T) {
+	vars, funcs := checkpointTestFuncs()
+	rv := checkpointTestRV(vars)
+	in := NewRVariable(vars[0], rv)
+	expected := ComposedRFunc(funcs).ApplyR(rv, in)
+	for _, interval := range []int{0, 1, 2, 4, 5} {
+		f := &CheckpointRFunc{Funcs: funcs, Interval: interval}
+		checkpointExactCheck(t, vars, expected, f.ApplyR(rv, in))
+	}
+}
+
+func TestCheckpointFuncExactGradient(t *testing.T) {
+	vars, rfuncs := checkpointTestFuncs()
+	funcs := make(ComposedFunc, len(rfuncs))
+	for i, f := range rfuncs {
+		funcs[i] = f
+	}
+	expected := funcs.Apply(vars[0])
+	for _, interval := range []int{0, 1, 2, 4, 5} {
+		f := &CheckpointFunc{Funcs: funcs, Interval: interval}
+		checkpointExactGradCheck(t, vars, expected, f.Apply(vars[0]))
+	}
+}
+
+func TestCheckpointFuncConstant(t *testing.T) {
+	vars, rfuncs := checkpointTestFuncs()
+	funcs := make([]Func, len(rfuncs))
+	for i, f := range rfuncs {
+		funcs[i] = f
+	}
+	in := &Variable{Vector: vars[0].Vector}
+	for _, interval := range []int{1, 2, 6} {
+		out := (&CheckpointFunc{Funcs: funcs, Interval: interval}).Apply(in)
+		for i, v := range vars[1:] {
+			if out.Constant(NewGradient([]*Variable{v})) {
+				t.Errorf("interval %d: should depend on parameter %d", interval, i)
+			}
+		}
+		if !out.Constant(NewGradient([]*Variable{vars[0]})) {
+			t.Errorf("interval %d: should be constant", interval)
+		}
+	}
+
+	step := func(state, in Result) Result {
+		return Mul(state, in)
+	}
+	ins := []Result{&Variable{Vector: []float64{1}}, &Variable{Vector: []float64{2}}}
+	out := CheckpointFold(&Variable{Vector: []float64{3}}, ins, 1, step)
+	if !out.Constant(NewGradient(vars)) {
+		t.Error("fold should be constant")
+	}
+}
+
+func TestCheckpointFuncNested(t *testing.T) {
+	var count int
+	var f Func = &checkpointCountFunc{Count: &count}
+	depth := 4
+	for i := 0; i < depth; i++ {
+		f = &CheckpointFunc{Funcs: []Func{f, f}, Interval: 1}
+	}
+	in := &Variable{Vector: []float64{1, -2}}
+	out := f.Apply(in)
+	forward := count
+	count = 0
+	out.PropagateGradient(linalg.Vector{1, 1}, NewGradient([]*Variable{in}))
+
+	// Each level of nesting recomputes everything below it
+	// once.
+	if count > depth*forward {
+		t.Errorf("backward pass applied %d Funcs (forward applied %d)", count, forward)
+	}
+}
+
+type checkpointCountFunc struct {
+	Count *int
+}
+
+func (c *checkpointCountFunc) Apply(in Result) Result {
+	*c.Count++
+	return Sigmoid{}.Apply(in)
+}
+
+func checkpointTestFuncs() ([]*Variable, []RFunc) {
+	vars := []*Variable{&Variable{Vector: []float64{0.5, -1, 0.25}}}
+	var funcs []RFunc
+	for i := 0; i < 3; i++ {
+		data := &Variable{Vector: make(linalg.Vector, 9)}
+		for j := range data.Vector {
+			data.Vector[j] = rand.NormFloat64()
+		}
+		vars = append(vars, data)
+		funcs = append(funcs, &LinTran{Data: data, Rows: 3, Cols: 3})
+		if i%2 == 0 {
+			funcs = append(funcs, Sigmoid{})
+		} else {
+			funcs = append(funcs, Tanh{})
+		}
+	}
+	return vars, funcs
+}
+
+func checkpointTestRV(vars []*Variable) RVector {
+	rv := RVector{}
+	for _, v := range vars {
+		rv[v] = make(linalg.Vector, len(v.Vector))
+		for i := range rv[v] {
+			rv[v][i] = rand.NormFloat64()
+		}
+	}
+	return rv
+}
+
+func checkpointExactCheck(t *testing.T, vars []*Variable, expected, actual RResult) {
+	if !checkpointVecsEqual(expected.Output(), actual.Output()) ||
+		!checkpointVecsEqual(expected.ROutput(), actual.ROutput()) {
+		t.Errorf("outputs differ: %v %v", expected.Output(), actual.Output())
+		return
+	}
+	var grads [2]Gradient
+	var rgrads [2]RGradient
+	for i, res := range []RResult{expected, actual} {
+		grads[i] = NewGradient(vars)
+		rgrads[i] = NewRGradient(vars)
+		u := make(linalg.Vector, len(res.Output()))
+		uR := make(linalg.Vector, len(res.Output()))
+		for j := range u {
+			u[j] = float64(j) + 0.5
+			uR[j] = 1 - float64(j)
+		}
+		res.PropagateRGradient(u, uR, rgrads[i], grads[i])
+	}
+	for i, v := range vars {
+		if !checkpointVecsEqual(grads[0][v], grads[1][v]) {
+			t.Errorf("variable %d: expected gradient %v got %v", i, grads[0][v], grads[1][v])
+		}
+		if !checkpointVecsEqual(rgrads[0][v], rgrads[1][v]) {
+			t.Errorf("variable %d: expected r-gradient %v got %v", i, rgrads[0][v],
+				rgrads[1][v])
+		}
+	}
+}
+
+func checkpointExactGradCheck(t *testing.T, vars []*Variable, expected, actual Result) {
+	if !checkpointVecsEqual(expected.Output(), actual.Output()) {
+		t.Errorf("outputs differ: %v %v", expected.Output(), actual.Output())
+		return
+	}
+	var grads [2]Gradient
+	for i, res := range []Result{expected, actual} {
+		grads[i] = NewGradient(vars)
+		if res.Constant(grads[i]) {
+			t.Errorf("result %d should not be constant", i)
+			return
+		}
+		u := make(linalg.Vector, len(res.Output()))
+		for j := range u {
+			u[j] = float64(j) + 0.5
+		}
+		res.PropagateGradient(u, grads[i])
+	}
+	for i, v := range vars {
+		if !checkpointVecsEqual(grads[0][v], grads[1][v]) {
+			t.Errorf("variable %d: expected gradient %v got %v", i, grads[0][v], grads[1][v])
+		}
+	}
+}
+
+func checkpointVecsEqual(v1, v2 linalg.Vector) bool {
+	if len(v1) != len(v2) {
+		return false
+	}
+	for i, x := range v1 {
+		if x != v2[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package autofunc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+const (
+	convTestWidth  = 4
+	convTestHeight = 3
+	convTestDepth  = 2
+	convTestBatch  = 2
+
+	convTestInSize = convTestWidth * convTestHeight * convTestDepth
+)
+
+var convTestGen = rand.New(rand.NewSource(1337))
+
+var (
+	convTestInput   = &Variable{Vector: convTestVec(convTestInSize * convTestBatch)}
+	convTestFilters = &Variable{Vector: convTestVec(3 * 2 * 2 * convTestDepth)}
+	convTestBiases  = &Variable{Vector: convTestVec(3)}
+)
+
+var convTestVars = []*Variable{convTestInput, convTestFilters, convTestBiases}
+
+var convTestRV = RVector{
+	convTestInput:   convTestVec(len(convTestInput.Vector)),
+	convTestFilters: convTestVec(len(convTestFilters.Vector)),
+	convTestBiases:  convTestVec(len(convTestBiases.Vector)),
+}
+
+var convTestLayer = &Conv2D{
+	InputWidth:   convTestWidth,
+	InputHeight:  convTestHeight,
+	InputDepth:   convTestDepth,
+	FilterWidth:  2,
+	FilterHeight: 2,
+	FilterCount:  3,
+	StrideX:      2,
+	PaddingX:     1,
+	PaddingY:     1,
+	Filters:      convTestFilters,
+	Biases:       convTestBiases,
+}
+
+var convTestPoolers = []RBatcher{
+	&MaxPool2D{
+		InputWidth:  convTestWidth,
+		InputHeight: convTestHeight,
+		InputDepth:  convTestDepth,
+		SpanX:       2,
+		SpanY:       2,
+	},
+	&MaxPool2D{
+		InputWidth:  convTestWidth,
+		InputHeight: convTestHeight,
+		InputDepth:  convTestDepth,
+		SpanX:       3,
+		SpanY:       2,
+		StrideX:     1,
+		StrideY:     1,
+		PaddingX:    1,
+		PaddingY:    1,
+	},
+	&AvgPool2D{
+		InputWidth:  convTestWidth,
+		InputHeight: convTestHeight,
+		InputDepth:  convTestDepth,
+		SpanX:       2,
+		SpanY:       2,
+	},
+	&AvgPool2D{
+		InputWidth:  convTestWidth,
+		InputHeight: convTestHeight,
+		InputDepth:  convTestDepth,
+		SpanX:       3,
+		SpanY:       2,
+		StrideX:     1,
+		StrideY:     2,
+		PaddingX:    1,
+		PaddingY:    1,
+	},
+}
+
+type convBatchTest struct {
+	B RBatcher
+}
+
+func (c convBatchTest) Apply(in Result) Result {
+	return c.B.Batch(in, convTestBatch)
+}
+
+func (c convBatchTest) ApplyR(v RVector, in RResult) RResult {
+	return c.B.BatchR(v, in, convTestBatch)
+}
+
+func TestConv2DOutput(t *testing.T) {
+	l := convTestLayer
+	if l.OutputWidth() != 3 || l.OutputHeight() != 4 {
+		t.Fatalf("bad output size %dx%d", l.OutputWidth(), l.OutputHeight())
+	}
+	actual := l.Batch(convTestInput, convTestBatch).Output()
+	var idx int
+	for sample := 0; sample < convTestBatch; sample++ {
+		img := convTestInput.Vector[sample*convTestInSize : (sample+1)*convTestInSize]
+		for y := 0; y < l.OutputHeight(); y++ {
+			for x := 0; x < l.OutputWidth(); x++ {
+				for f := 0; f < l.FilterCount; f++ {
+					expected := convTestBiases.Vector[f]
+					for fy := 0; fy < 2; fy++ {
+						for fx := 0; fx < 2; fx++ {
+							inX, inY := x*2-1+fx, y-1+fy
+							if inX < 0 || inY < 0 || inX >= convTestWidth ||
+								inY >= convTestHeight {
+								continue
+							}
+							for z := 0; z < convTestDepth; z++ {
+								w := convTestFilters.Vector[((f*2+fy)*2+fx)*convTestDepth+z]
+								expected += w * img[(inY*convTestWidth+inX)*convTestDepth+z]
+							}
+						}
+					}
+					if math.Abs(actual[idx]-expected) > 1e-8 {
+						t.Errorf("output %d: expected %f got %f", idx, expected, actual[idx])
+					}
+					idx++
+				}
+			}
+		}
+	}
+	if idx != len(actual) {
+		t.Errorf("expected %d outputs but got %d", idx, len(actual))
+	}
+}
+
+func TestConv2DChecks(t *testing.T) {
+	checker := &functest.RFuncChecker{
+		F:     convBatchTest{B: convTestLayer},
+		Vars:  convTestVars,
+		Input: convTestInput,
+		RV:    convTestRV,
+	}
+	checker.FullCheck(t)
+}
+
+func TestPool2DOutput(t *testing.T) {
+	in := &Variable{
+		Vector: []float64{
+			1, -1, 2, -2, 3, -3,
+			4, -4, 5, -5, 6, -6,
+		},
+	}
+	maxPool := &MaxPool2D{InputWidth: 3, InputHeight: 2, InputDepth: 2, SpanX: 2,
+		SpanY: 2, PaddingX: 1}
+	avgPool := &AvgPool2D{InputWidth: 3, InputHeight: 2, InputDepth: 2, SpanX: 2,
+		SpanY: 2, PaddingX: 1}
+	expected := map[string]linalg.Vector{
+		"max": {4, -1, 6, -2},
+		"avg": {5.0 / 4, -5.0 / 4, 16.0 / 4, -16.0 / 4},
+	}
+	actual := map[string]linalg.Vector{
+		"max": maxPool.Apply(in).Output(),
+		"avg": avgPool.Apply(in).Output(),
+	}
+	for name, exp := range expected {
+		act := actual[name]
+		if len(act) != len(exp) {
+			t.Errorf("%s: expected length %d got %d", name, len(exp), len(act))
+			continue
+		}
+		for i, x := range exp {
+			if math.Abs(act[i]-x) > 1e-8 {
+				t.Errorf("%s entry %d: expected %f got %f", name, i, x, act[i])
+			}
+		}
+	}
+}
+
+func TestPool2DChecks(t *testing.T) {
+	for _, p := range convTestPoolers {
+		checker := &functest.RFuncChecker{
+			F:     convBatchTest{B: p},
+			Vars:  convTestVars[:1],
+			Input: convTestInput,
+			RV:    convTestRV,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func convTestVec(n int) linalg.Vector {
+	res := make(linalg.Vector, n)
+	for i := range res {
+		res[i] = convTestGen.NormFloat64()
+	}
+	return res
+}
@@ -0,0 +1,187 @@
+package autofunc
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+func TestCheckFiniteOutput(t *testing.T) {
+	scope := &Scope{Debug: true}
+	v := &Variable{Vector: []float64{1, -1}}
+	err := catchNonFinite(func() {
+		SumAll(Scale(Log{}.Apply(scope.Result(v)), 2))
+	})
+	if err == nil {
+		t.Fatal("expected a NonFiniteError")
+	}
+	if err.Op != "*autofunc.logResult" || err.Vector != "Output" || err.Index != 1 {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if !strings.Contains(string(err.Stack), "debug_test.go") {
+		t.Errorf("stack does not include the creation site: %s", err.Stack)
+	}
+}
+
+func TestCheckFiniteUpstream(t *testing.T) {
+	scope := &Scope{Debug: true}
+	v := &Variable{Vector: []float64{0, 1}}
+	res := SumAll(Pow(scope.Result(v), 0.5))
+	err := catchNonFinite(func() {
+		res.PropagateGradient(linalg.Vector{1}, NewGradient([]*Variable{v}))
+	})
+	if err == nil {
+		t.Fatal("expected a NonFiniteError")
+	}
+	if err.Op != "*autofunc.resultPow" || err.Vector != "upstream" ||
+		err.Input != "*autofunc.Variable" {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	if !strings.Contains(string(err.Stack), "debug_test.go") {
+		t.Errorf("stack does not include the creation site: %s", err.Stack)
+	}
+
+	err = catchNonFinite(func() {
+		CheckFinite(v).PropagateGradient(linalg.Vector{math.Inf(1), 0},
+			NewGradient([]*Variable{v}))
+	})
+	if err == nil || err.Op != "caller" || err.Stack != nil {
+		t.Errorf("expected error from caller but got %v", err)
+	}
+}
+
+func TestCheckFiniteR(t *testing.T) {
+	scope := &Scope{Debug: true}
+	v := &Variable{Vector: []float64{0, 1}}
+	rv := RVector{v: []float64{1, 1}}
+
+	err := catchNonFinite(func() {
+		SumAllR(PowR(scope.RResult(NewRVariable(v, rv)), 0.5))
+	})
+	if err == nil || err.Op != "*autofunc.rresultPow" || err.Vector != "ROutput" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	v.Vector[0] = 1
+	res := CheckFiniteR(MulR(NewRVariable(v, rv), NewRVariable(v, rv)))
+	err = catchNonFinite(func() {
+		res.PropagateRGradient(linalg.Vector{1, 1}, linalg.Vector{0, math.NaN()},
+			NewRGradient([]*Variable{v}), nil)
+	})
+	if err == nil || err.Op != "caller" || err.Vector != "upstreamR" ||
+		err.Input != "*autofunc.rresultProduct" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckFiniteValid(t *testing.T) {
+	v := &Variable{Vector: []float64{1, 2}}
+	rv := RVector{v: []float64{-1, 0.5}}
+	f := func(s *Scope) (Gradient, RGradient) {
+		in := s.RResult(NewRVariable(v, rv))
+		res := SumAllR(MulR(Sigmoid{}.ApplyR(rv, in), in))
+		grad, rgrad := NewGradient([]*Variable{v}), NewRGradient([]*Variable{v})
+		res.PropagateRGradient(linalg.Vector{1}, linalg.Vector{0}, rgrad, grad)
+		return grad, rgrad
+	}
+	expected, expectedR := f(nil)
+	actual, actualR := f(&Scope{Debug: true})
+	for _, pair := range [][2]linalg.Vector{{expected[v], actual[v]},
+		{expectedR[v], actualR[v]}} {
+		if pair[0].Copy().Scale(-1).Add(pair[1]).MaxAbs() > 1e-10 {
+			t.Errorf("expected %v got %v", pair[0], pair[1])
+		}
+	}
+}
+
+func TestDebugToggle(t *testing.T) {
+	v := &Variable{Vector: []float64{1}}
+	if Debug(v) != Result(v) {
+		t.Error("Debug should do nothing outside of debug mode")
+	}
+	defer SetDebug(SetDebug(true))
+	if Debug(v) == Result(v) {
+		t.Error("Debug should check results in debug mode")
+	}
+}
+
+func TestCheckFiniteScopes(t *testing.T) {
+	v := &Variable{Vector: []float64{0, 1}}
+	grad := NewGradient([]*Variable{v})
+	plain := SumAll(Pow((&Scope{}).Result(v), 0.5))
+	checked := SumAll(Pow((&Scope{Debug: true}).Result(v), 0.5))
+	if err := catchNonFinite(func() {
+		plain.PropagateGradient(linalg.Vector{1}, grad)
+	}); err != nil {
+		t.Errorf("result outside of debug scope should not be checked: %s", err.Error())
+	}
+	if err := catchNonFinite(func() {
+		checked.PropagateGradient(linalg.Vector{1}, grad)
+	}); err == nil {
+		t.Error("expected a NonFiniteError")
+	}
+}
+
+func TestCheckFiniteOps(t *testing.T) {
+	scope := &Scope{Debug: true}
+	v := &Variable{Vector: []float64{1, 2, -1, 3}}
+	init := &Variable{Vector: []float64{1, 1}}
+	emb := &Embedding{Matrix: &Variable{Vector: []float64{1, 2, math.NaN(), 4}}, Cols: 2}
+	step := func(s, in Result) Result {
+		return Log{}.Apply(Add(s, in))
+	}
+	results := map[string]func(){
+		"Scan": func() {
+			Scan(scope.Result(init), Split(2, v), step, func(states []Result) Result {
+				return Concat(states...)
+			})
+		},
+		"CheckpointFold": func() {
+			CheckpointFold(scope.Result(init), Split(2, v), 1, step)
+		},
+		"Embedding": func() {
+			Add(init, emb.LookupIn(scope, []int{1}))
+		},
+	}
+	ops := map[string]string{
+		"Scan":           "*autofunc.logResult",
+		"CheckpointFold": "*autofunc.logResult",
+		"Embedding":      "*autofunc.embeddingResult",
+	}
+	for name, f := range results {
+		err := catchNonFinite(f)
+		if err == nil {
+			t.Errorf("%s: expected a NonFiniteError", name)
+		} else if err.Op != ops[name] {
+			t.Errorf("%s: expected op %s but got %s", name, ops[name], err.Op)
+		}
+	}
+
+	// Upstream vectors are checked inside of Scan.
+	state := &Variable{Vector: []float64{0, 1}}
+	res := Scan(scope.Result(state), []Result{init}, func(s, in Result) Result {
+		return Mul(Pow(s, 0.5), in)
+	}, func(states []Result) Result {
+		return SumAll(states[0])
+	})
+	grad := NewGradient([]*Variable{state})
+	err := catchNonFinite(func() {
+		res.PropagateGradient(linalg.Vector{1}, grad)
+	})
+	if err == nil || err.Op != "*autofunc.resultPow" || err.Vector != "upstream" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func catchNonFinite(f func()) (err *NonFiniteError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(*NonFiniteError)
+		}
+	}()
+	f()
+	return
+}
@@ -0,0 +1,112 @@
+package autofunc
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+var (
+	derivTestMat = &LinTran{
+		Data: &Variable{Vector: linalg.Vector{1, -0.5, 0.3, 0.7, 2, -1}},
+		Rows: 2,
+		Cols: 3,
+	}
+	derivTestInput = &Variable{Vector: linalg.Vector{0.5, -0.2, 0.8}}
+	derivTestVars  = []*Variable{derivTestMat.Data, derivTestInput}
+	derivTestRVec  = RVector{
+		derivTestMat.Data: linalg.Vector{0.1, 0.3, -0.2, 0.5, -0.4, 0.2},
+		derivTestInput:    linalg.Vector{0.3, -0.7, 0.1},
+	}
+	derivTestVecFunc    = ComposedRFunc{derivTestMat, Sigmoid{}}
+	derivTestScalarFunc = ComposedRFunc{derivTestMat, Sigmoid{}, SquaredNorm{}}
+)
+
+func TestJacobianVectorProduct(t *testing.T) {
+	jacobian := Jacobian(derivTestVecFunc, derivTestInput, derivTestVars)
+	actual := JacobianVectorProduct(derivTestVecFunc, derivTestInput, derivTestRVec)
+	if len(actual) != len(jacobian) {
+		t.Fatalf("expected length %d but got %d", len(jacobian), len(actual))
+	}
+	for i, row := range jacobian {
+		expected := gradDot(row, derivTestRVec)
+		if math.Abs(actual[i]-expected) > 1e-8 {
+			t.Errorf("output %d: expected %f but got %f", i, expected, actual[i])
+		}
+	}
+}
+
+func TestVectorJacobianProduct(t *testing.T) {
+	upstream := linalg.Vector{-0.5, 2}
+	jacobian := Jacobian(derivTestVecFunc, derivTestInput, derivTestVars)
+	actual := VectorJacobianProduct(derivTestVecFunc, derivTestInput, derivTestVars,
+		upstream)
+	if upstream[0] != -0.5 || upstream[1] != 2 {
+		t.Error("upstream was modified")
+	}
+	for _, v := range derivTestVars {
+		for i, a := range actual[v] {
+			expected := upstream[0]*jacobian[0][v][i] + upstream[1]*jacobian[1][v][i]
+			if math.Abs(a-expected) > 1e-8 {
+				t.Errorf("entry %d: expected %f but got %f", i, expected, a)
+			}
+		}
+	}
+}
+
+func TestHessian(t *testing.T) {
+	hessian := Hessian(derivTestScalarFunc, derivTestInput, derivTestVars)
+	if len(hessian) != 9 {
+		t.Fatalf("expected 9 rows but got %d", len(hessian))
+	}
+
+	// Compare each row to finite differences of the gradient.
+	const delta = 1e-5
+	var rowIdx int
+	for _, variable := range derivTestVars {
+		for i := range variable.Vector {
+			old := variable.Vector[i]
+			variable.Vector[i] = old + delta
+			grad1 := Jacobian(derivTestScalarFunc, derivTestInput, derivTestVars)[0]
+			variable.Vector[i] = old - delta
+			grad2 := Jacobian(derivTestScalarFunc, derivTestInput, derivTestVars)[0]
+			variable.Vector[i] = old
+			for _, v := range derivTestVars {
+				for j, actual := range hessian[rowIdx][v] {
+					expected := (grad1[v][j] - grad2[v][j]) / (2 * delta)
+					if math.Abs(actual-expected) > 1e-5 {
+						t.Errorf("row %d: expected %f but got %f", rowIdx, expected, actual)
+					}
+				}
+			}
+			rowIdx++
+		}
+	}
+}
+
+func TestHessianVectorProduct(t *testing.T) {
+	hessian := Hessian(derivTestScalarFunc, derivTestInput, derivTestVars)
+	actual := HessianVectorProduct(derivTestScalarFunc, derivTestInput, derivTestVars,
+		derivTestRVec)
+	var rowIdx int
+	for _, variable := range derivTestVars {
+		for i := range variable.Vector {
+			expected := gradDot(hessian[rowIdx], derivTestRVec)
+			if math.Abs(actual[variable][i]-expected) > 1e-8 {
+				t.Errorf("row %d: expected %f but got %f", rowIdx, expected,
+					actual[variable][i])
+			}
+			rowIdx++
+		}
+	}
+}
+
+func gradDot(g Gradient, v RVector) float64 {
+	var res float64
+	for variable, vec := range g {
+		res += vec.Dot(v[variable])
+	}
+	return res
+}
@@ -0,0 +1,106 @@
+package autofunc
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type embeddingTestFunc struct {
+	Embedding *Embedding
+	IDs       []int
+}
+
+func (e *embeddingTestFunc) Apply(in Result) Result {
+	return Mul(in, e.Embedding.Lookup(e.IDs))
+}
+
+func (e *embeddingTestFunc) ApplyR(v RVector, in RResult) RResult {
+	return MulR(in, e.Embedding.LookupR(v, e.IDs))
+}
+
+func TestEmbeddingOutput(t *testing.T) {
+	e := &Embedding{Matrix: &Variable{Vector: []float64{1, 2, 3, 4, 5, 6}}, Cols: 2}
+	actual := e.Lookup([]int{2, 0, 2}).Output()
+	expected := linalg.Vector{5, 6, 1, 2, 5, 6}
+	if expected.Copy().Scale(-1).Add(actual).MaxAbs() != 0 {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}
+
+func TestEmbeddingChecks(t *testing.T) {
+	rand.Seed(1337)
+	e := NewEmbedding(4, 2)
+	ids := []int{3, 1, 3}
+	in := &Variable{Vector: []float64{1, -0.5, 0.25, 0.3, 2, -1}}
+	varLists := map[string][]*Variable{
+		"Dense":  {in, e.Matrix},
+		"Sparse": append([]*Variable{in}, e.RowVars(ids)...),
+	}
+	for name, vars := range varLists {
+		t.Run(name, func(t *testing.T) {
+			rv := RVector{}
+			for _, v := range vars {
+				rv[v] = make(linalg.Vector, len(v.Vector))
+				for i := range rv[v] {
+					rv[v][i] = rand.NormFloat64()
+				}
+			}
+			checker := &functest.RFuncChecker{
+				F:     &embeddingTestFunc{Embedding: e, IDs: ids},
+				Vars:  vars,
+				Input: in,
+				RV:    rv,
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func TestEmbeddingSparseGradient(t *testing.T) {
+	e := &Embedding{Matrix: &Variable{Vector: []float64{1, 2, 3, 4, 5, 6}}, Cols: 2}
+	ids := []int{2, 0, 2}
+	grad := NewGradient(e.RowVars(ids))
+	if len(grad) != 2 {
+		t.Fatalf("expected 2 rows but got %d", len(grad))
+	}
+	out := e.Lookup(ids)
+	if out.Constant(grad) {
+		t.Fatal("result should not be constant")
+	}
+	out.PropagateGradient(linalg.Vector{1, 2, 3, 4, 5, 6}, grad)
+	grad.AddToVars(-1)
+	expected := linalg.Vector{-2, -2, 3, 4, -1, -2}
+	if expected.Copy().Scale(-1).Add(e.Matrix.Vector).MaxAbs() != 0 {
+		t.Errorf("expected %v got %v", expected, e.Matrix.Vector)
+	}
+	if !out.Constant(NewGradient([]*Variable{e.Row(1)})) {
+		t.Error("result should be constant for unused rows")
+	}
+}
+
+func TestEmbeddingReplacedMatrix(t *testing.T) {
+	e := &Embedding{Matrix: &Variable{Vector: []float64{1, 2, 3, 4, 5, 6}}, Cols: 2}
+	row := e.Row(1)
+	if e.Row(1) != row {
+		t.Fatal("expected the same row Variable")
+	}
+	e.Matrix.Vector = linalg.Vector{6, 5, 4, 3, 2, 1}
+	newRow := e.Row(1)
+	if newRow == row {
+		t.Fatal("expected a new row Variable")
+	}
+	grad := NewGradient([]*Variable{newRow})
+	e.Lookup([]int{1}).PropagateGradient(linalg.Vector{1, 1}, grad)
+	grad.AddToVars(1)
+	expected := linalg.Vector{6, 5, 5, 4, 2, 1}
+	if expected.Copy().Scale(-1).Add(e.Matrix.Vector).MaxAbs() != 0 {
+		t.Errorf("expected %v got %v", expected, e.Matrix.Vector)
+	}
+	if !e.Lookup([]int{1}).Constant(NewGradient([]*Variable{row})) {
+		t.Error("stale row should not receive gradients")
+	}
+}
@@ -0,0 +1,90 @@
+package autofunc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+func TestHessianEstimatorTrace(t *testing.T) {
+	hessian := Hessian(derivTestScalarFunc, derivTestInput, derivTestVars)
+	var expected float64
+	for _, entry := range hessianDiagonal(hessian) {
+		expected += entry
+	}
+	for _, gaussian := range []bool{false, true} {
+		estimator := &HessianEstimator{
+			F:        derivTestScalarFunc,
+			Input:    derivTestInput,
+			Vars:     derivTestVars,
+			Probes:   20000,
+			Gaussian: gaussian,
+			Rand:     rand.New(rand.NewSource(1337)),
+		}
+		actual := estimator.Trace()
+		if math.Abs(actual-expected) > 0.05*math.Abs(expected)+0.05 {
+			t.Errorf("Gaussian=%v: expected %f but got %f", gaussian, expected, actual)
+		}
+	}
+}
+
+func TestHessianEstimatorDiagonal(t *testing.T) {
+	hessian := Hessian(derivTestScalarFunc, derivTestInput, derivTestVars)
+	expected := hessianDiagonal(hessian)
+	estimator := &HessianEstimator{
+		F:      derivTestScalarFunc,
+		Input:  derivTestInput,
+		Vars:   derivTestVars,
+		Probes: 20000,
+		Rand:   rand.New(rand.NewSource(1337)),
+	}
+	diag := estimator.Diagonal()
+	var idx int
+	for _, v := range derivTestVars {
+		for _, actual := range diag[v] {
+			if math.Abs(actual-expected[idx]) > 0.05 {
+				t.Errorf("entry %d: expected %f but got %f", idx, expected[idx], actual)
+			}
+			idx++
+		}
+	}
+}
+
+func TestHessianEstimatorTopEigen(t *testing.T) {
+	// The Hessian of |Ax|^2 is 2*A'*A, whose eigenvalues
+	// are 2, 18, and 50 for this A.
+	input := &Variable{Vector: linalg.Vector{0.5, -0.2, 0.8}}
+	mat := &LinTran{
+		Data: &Variable{Vector: linalg.Vector{1, 0, 0, 0, -3, 0, 0, 0, 5}},
+		Rows: 3,
+		Cols: 3,
+	}
+	estimator := &HessianEstimator{
+		F:     ComposedRFunc{mat, SquaredNorm{}},
+		Input: input,
+		Vars:  []*Variable{input},
+		Rand:  rand.New(rand.NewSource(1337)),
+	}
+	val, vec := estimator.TopEigen(1000, 1e-12)
+	if math.Abs(val-50) > 1e-6 {
+		t.Errorf("expected eigenvalue 50 but got %f", val)
+	}
+	if math.Abs(math.Abs(vec[input][2])-1) > 1e-4 {
+		t.Errorf("unexpected eigenvector %v", vec[input])
+	}
+}
+
+func hessianDiagonal(hessian []Gradient) []float64 {
+	var res []float64
+	var idx int
+	for _, v := range derivTestVars {
+		for i := range v.Vector {
+			res = append(res, hessian[idx][v][i])
+			idx++
+		}
+	}
+	return res
+}
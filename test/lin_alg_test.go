@@ -145,3 +145,67 @@ func TestScaleRowsChecks(t *testing.T) {
 	}
 	f.FullCheck(t)
 }
+
+type matMulTest struct {
+	Shape MatMulShape
+}
+
+func (m *matMulTest) Apply(in Result) Result {
+	return MatMul(linTranTestMat1.Data, in, m.Shape)
+}
+
+func (m *matMulTest) ApplyR(rv RVector, in RResult) RResult {
+	return MatMulR(NewRVariable(linTranTestMat1.Data, rv), in, m.Shape)
+}
+
+var matMulTestShapes = []MatMulShape{
+	{ARows: 3, ACols: 4, BRows: 4, BCols: 2},
+	{ARows: 3, ACols: 4, BRows: 2, BCols: 4, TransB: true},
+	{ARows: 4, ACols: 3, BRows: 4, BCols: 2, TransA: true},
+	{ARows: 4, ACols: 3, BRows: 2, BCols: 4, TransA: true, TransB: true},
+}
+
+func TestMatMulOutput(t *testing.T) {
+	a := linTranTestMat1.Data.Vector
+	b := linTranTestVec2.Vector
+	for i, shape := range matMulTestShapes {
+		actual := (&matMulTest{Shape: shape}).Apply(linTranTestVec2).Output()
+		if len(actual) != shape.OutRows()*shape.OutCols() {
+			t.Errorf("shape %d: bad output length %d", i, len(actual))
+			continue
+		}
+		for row := 0; row < shape.OutRows(); row++ {
+			for col := 0; col < shape.OutCols(); col++ {
+				var expected float64
+				for k := 0; k < 4; k++ {
+					aIdx := row*shape.ACols + k
+					if shape.TransA {
+						aIdx = k*shape.ACols + row
+					}
+					bIdx := k*shape.BCols + col
+					if shape.TransB {
+						bIdx = col*shape.BCols + k
+					}
+					expected += a[aIdx] * b[bIdx]
+				}
+				a := actual[row*shape.OutCols()+col]
+				if math.Abs(a-expected) > 1e-8 {
+					t.Errorf("shape %d entry (%d,%d): expected %f got %f", i, row, col,
+						expected, a)
+				}
+			}
+		}
+	}
+}
+
+func TestMatMulChecks(t *testing.T) {
+	for _, shape := range matMulTestShapes {
+		f := &functest.RFuncChecker{
+			F:     &matMulTest{Shape: shape},
+			Vars:  linTranTestVariables,
+			Input: linTranTestVec2,
+			RV:    linTranTestRVec,
+		}
+		f.FullCheck(t)
+	}
+}
@@ -0,0 +1,309 @@
+package autofunc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// decompTestMat is a symmetric positive-definite matrix.
+var decompTestMat = &Variable{
+	Vector: []float64{
+		4, 1, 0.5,
+		1, 3, -0.7,
+		0.5, -0.7, 2.5,
+	},
+}
+
+var decompTestRHS = &Variable{
+	Vector: []float64{
+		1, -2,
+		0.5, 3,
+		-1.5, 0.25,
+	},
+}
+
+var decompTestVars = []*Variable{decompTestMat, decompTestRHS}
+
+var decompTestRV = RVector{
+	decompTestMat: []float64{0.3, -0.2, 0.1, 0.15, 0.4, -0.25, 0.2, 0.1, -0.3},
+	decompTestRHS: []float64{0.5, -0.1, 0.2, 0.3, -0.4, 0.6},
+}
+
+type matInverseTest struct {
+	N int
+}
+
+func (m matInverseTest) Apply(in Result) Result {
+	return MatInverse(in, m.N)
+}
+
+func (m matInverseTest) ApplyR(rv RVector, in RResult) RResult {
+	return MatInverseR(in, m.N)
+}
+
+type matSolveTest struct {
+	N   int
+	RHS *Variable
+}
+
+func (m matSolveTest) Apply(in Result) Result {
+	return MatSolve(in, m.N, m.RHS)
+}
+
+func (m matSolveTest) ApplyR(rv RVector, in RResult) RResult {
+	return MatSolveR(in, m.N, NewRVariable(m.RHS, rv))
+}
+
+type logDetTest struct {
+	N int
+}
+
+func (l logDetTest) Apply(in Result) Result {
+	return LogDet(in, l.N)
+}
+
+func (l logDetTest) ApplyR(rv RVector, in RResult) RResult {
+	return LogDetR(in, l.N)
+}
+
+type choleskyTest struct {
+	N int
+}
+
+func (c choleskyTest) Apply(in Result) Result {
+	return Cholesky(in, c.N)
+}
+
+func (c choleskyTest) ApplyR(rv RVector, in RResult) RResult {
+	return CholeskyR(in, c.N)
+}
+
+func TestMatInverseOutput(t *testing.T) {
+	inv := MatInverse(decompTestMat, 3).Output()
+	prod := decompTestProduct(decompTestMat.Vector, inv, 3, 3, 3, false)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			expected := 0.0
+			if i == j {
+				expected = 1
+			}
+			if math.Abs(prod[i*3+j]-expected) > 1e-8 {
+				t.Errorf("entry (%d,%d): expected %f got %f", i, j, expected, prod[i*3+j])
+			}
+		}
+	}
+}
+
+func TestMatSolveOutput(t *testing.T) {
+	x := MatSolve(decompTestMat, 3, decompTestRHS).Output()
+	prod := decompTestProduct(decompTestMat.Vector, x, 3, 3, 2, false)
+	for i, expected := range decompTestRHS.Vector {
+		if math.Abs(prod[i]-expected) > 1e-8 {
+			t.Errorf("entry %d: expected %f got %f", i, expected, prod[i])
+		}
+	}
+}
+
+func TestLogDetOutput(t *testing.T) {
+	m := decompTestMat.Vector
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) +
+		m[2]*(m[3]*m[7]-m[4]*m[6])
+
+	// Swap two rows to make the determinant negative.
+	swapped := append(append(append([]float64{}, m[3:6]...), m[0:3]...), m[6:]...)
+	for i, mat := range []linalg.Vector{m, swapped} {
+		actual := LogDet(&Variable{Vector: mat}, 3).Output()[0]
+		if math.Abs(actual-math.Log(math.Abs(det))) > 1e-8 {
+			t.Errorf("matrix %d: expected %f got %f", i, math.Log(math.Abs(det)), actual)
+		}
+	}
+}
+
+func TestCholeskyOutput(t *testing.T) {
+	l := Cholesky(decompTestMat, 3).Output()
+	for i := 0; i < 3; i++ {
+		for j := i + 1; j < 3; j++ {
+			if l[i*3+j] != 0 {
+				t.Errorf("entry (%d,%d) should be zero", i, j)
+			}
+		}
+	}
+	prod := decompTestProduct(l, l, 3, 3, 3, true)
+	for i, expected := range decompTestMat.Vector {
+		if math.Abs(prod[i]-expected) > 1e-8 {
+			t.Errorf("entry %d: expected %f got %f", i, expected, prod[i])
+		}
+	}
+}
+
+func TestDecompPanics(t *testing.T) {
+	singular := &Variable{Vector: []float64{1, 2, 2, 4}}
+	indefinite := &Variable{Vector: []float64{1, 2, 2, 1}}
+	rhs := &Variable{Vector: []float64{1, 2}}
+	tests := []struct {
+		Name string
+		F    func()
+	}{
+		{"MatInverse", func() { MatInverse(singular, 2) }},
+		{"MatSolve", func() { MatSolve(singular, 2, rhs) }},
+		{"LogDet", func() { LogDet(singular, 2) }},
+		{"Cholesky", func() { Cholesky(indefinite, 2) }},
+		{"CholeskyR", func() { CholeskyR(NewRVariable(singular, RVector{}), 2) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", test.Name)
+				}
+			}()
+			test.F()
+		}()
+	}
+}
+
+func TestDecompChecks(t *testing.T) {
+	for _, f := range []RFunc{matInverseTest{3}, matSolveTest{3, decompTestRHS},
+		logDetTest{3}, choleskyTest{3}} {
+		checker := &functest.RFuncChecker{
+			F:     f,
+			Vars:  decompTestVars,
+			Input: decompTestMat,
+			RV:    decompTestRV,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestDecompRandomOutputs(t *testing.T) {
+	gen := rand.New(rand.NewSource(1337))
+	for n := 1; n <= 6; n++ {
+		for trial := 0; trial < 5; trial++ {
+			mat := decompTestPivotMat(gen, n)
+			rhs := decompTestRandVec(gen, n*2)
+
+			inv := MatInverse(&Variable{Vector: mat}, n).Output()
+			prod := decompTestProduct(mat, inv, n, n, n, false)
+			for i := 0; i < n; i++ {
+				for j := 0; j < n; j++ {
+					expected := 0.0
+					if i == j {
+						expected = 1
+					}
+					if math.Abs(prod[i*n+j]-expected) > 1e-8 {
+						t.Errorf("n=%d inverse entry (%d,%d): expected %f got %f", n, i, j,
+							expected, prod[i*n+j])
+					}
+				}
+			}
+
+			x := MatSolve(&Variable{Vector: mat}, n, &Variable{Vector: rhs}).Output()
+			prod = decompTestProduct(mat, x, n, n, 2, false)
+			for i, expected := range rhs {
+				if math.Abs(prod[i]-expected) > 1e-8 {
+					t.Errorf("n=%d solve entry %d: expected %f got %f", n, i, expected,
+						prod[i])
+				}
+			}
+
+			// log|det(A*B)| = log|det(A)| + log|det(B)|
+			mat1 := decompTestPivotMat(gen, n)
+			matProd := decompTestProduct(mat, mat1, n, n, n, false)
+			expected := LogDet(&Variable{Vector: mat}, n).Output()[0] +
+				LogDet(&Variable{Vector: mat1}, n).Output()[0]
+			actual := LogDet(&Variable{Vector: matProd}, n).Output()[0]
+			if math.Abs(actual-expected) > 1e-8 {
+				t.Errorf("n=%d log-determinant: expected %f got %f", n, expected, actual)
+			}
+		}
+	}
+}
+
+func TestDecompRandomChecks(t *testing.T) {
+	gen := rand.New(rand.NewSource(1337))
+	for _, n := range []int{2, 4, 5} {
+		mat := &Variable{Vector: decompTestPivotMat(gen, n)}
+		rhs := &Variable{Vector: decompTestRandVec(gen, n*3)}
+		rv := RVector{
+			mat: decompTestRandVec(gen, n*n),
+			rhs: decompTestRandVec(gen, n*3),
+		}
+		for _, f := range []RFunc{matInverseTest{n}, matSolveTest{n, rhs}, logDetTest{n}} {
+			checker := &functest.RFuncChecker{
+				F:     f,
+				Vars:  []*Variable{mat, rhs},
+				Input: mat,
+				RV:    rv,
+			}
+			checker.FullCheck(t)
+		}
+
+		// Cholesky needs an SPD matrix, so use A*A' + I.
+		spd := decompTestProduct(mat.Vector, mat.Vector, n, n, n, true)
+		for i := 0; i < n; i++ {
+			spd[i*n+i]++
+		}
+		spdVar := &Variable{Vector: spd}
+		checker := &functest.RFuncChecker{
+			F:     choleskyTest{n},
+			Vars:  []*Variable{spdVar},
+			Input: spdVar,
+			RV:    RVector{spdVar: decompTestRandVec(gen, n*n)},
+		}
+		checker.FullCheck(t)
+	}
+}
+
+// decompTestPivotMat generates a random, well-conditioned
+// non-symmetric matrix whose largest entries are off the
+// diagonal, so that LU decomposition has to pivot.
+func decompTestPivotMat(gen *rand.Rand, n int) linalg.Vector {
+	perm := gen.Perm(n)
+	if n > 1 && perm[0] == 0 {
+		perm[0], perm[1] = perm[1], perm[0]
+	}
+	res := make(linalg.Vector, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			res[perm[i]*n+j] = gen.NormFloat64() * 0.3
+		}
+		sign := 1.0
+		if gen.Intn(2) == 0 {
+			sign = -1
+		}
+		res[perm[i]*n+i] += sign * float64(n+1)
+	}
+	return res
+}
+
+func decompTestRandVec(gen *rand.Rand, size int) linalg.Vector {
+	res := make(linalg.Vector, size)
+	for i := range res {
+		res[i] = gen.NormFloat64()
+	}
+	return res
+}
+
+// decompTestProduct computes a*b (or a*b' if transB is
+// set) for row-major matrices.
+func decompTestProduct(a, b linalg.Vector, rows, inner, cols int,
+	transB bool) linalg.Vector {
+	res := make(linalg.Vector, rows*cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			for k := 0; k < inner; k++ {
+				bVal := b[k*cols+j]
+				if transB {
+					bVal = b[j*inner+k]
+				}
+				res[i*cols+j] += a[i*inner+k] * bVal
+			}
+		}
+	}
+	return res
+}
@@ -89,6 +89,66 @@ func TestSoftmax3(t *testing.T) {
 	f.FullCheck(t)
 }
 
+func TestSoftmaxExtreme(t *testing.T) {
+	inVar := &Variable{Vector: []float64{800, 799, -800}}
+	rv := RVector{inVar: []float64{1, -1, 2}}
+	s := &Softmax{}
+	expected := []float64{1 / (1 + math.Exp(-1)), 1 / (1 + math.Exp(1)), 0}
+	out := s.ApplyR(rv, NewRVariable(inVar, rv))
+	for i, x := range expected {
+		if math.Abs(out.Output()[i]-x) > 1e-8 {
+			t.Errorf("output %d: expected %f got %f", i, x, out.Output()[i])
+		}
+		if math.IsNaN(out.ROutput()[i]) || math.IsInf(out.ROutput()[i], 0) {
+			t.Errorf("r-output %d is not finite: %f", i, out.ROutput()[i])
+		}
+	}
+}
+
+func TestLogSoftmax(t *testing.T) {
+	f := &functest.RFuncChecker{
+		F:     &LogSoftmax{},
+		Vars:  mathFuncTestVars,
+		Input: mathFuncTestVec,
+		RV:    mathFuncTestRVec,
+	}
+	f.FullCheck(t)
+}
+
+func TestLogSoftmax3(t *testing.T) {
+	f := &functest.RFuncChecker{
+		F:     &LogSoftmax{Temperature: 3},
+		Vars:  mathFuncTestVars,
+		Input: mathFuncTestVec,
+		RV:    mathFuncTestRVec,
+	}
+	f.FullCheck(t)
+}
+
+func TestLogSoftmaxExtreme(t *testing.T) {
+	for _, offset := range []float64{0, 1600, -1600} {
+		inVar := &Variable{Vector: []float64{800 + offset, 799 + offset, -800 + offset}}
+		rv := RVector{inVar: []float64{1, -1, 2}}
+		l := &LogSoftmax{}
+		expected := []float64{-math.Log1p(math.Exp(-1)), -1 - math.Log1p(math.Exp(-1)),
+			-1600 - math.Log1p(math.Exp(-1))}
+		expectedR := []float64{1 - (1-math.Exp(-1))/(1+math.Exp(-1)), 0, 0}
+		expectedR[1] = expectedR[0] - 2
+		expectedR[2] = expectedR[0] + 1
+		out := l.ApplyR(rv, NewRVariable(inVar, rv))
+		for i, x := range expected {
+			if math.Abs(out.Output()[i]-x) > 1e-8 {
+				t.Errorf("offset %f: output %d: expected %f got %f", offset, i, x,
+					out.Output()[i])
+			}
+			if math.Abs(out.ROutput()[i]-expectedR[i]) > 1e-8 {
+				t.Errorf("offset %f: r-output %d: expected %f got %f", offset, i,
+					expectedR[i], out.ROutput()[i])
+			}
+		}
+	}
+}
+
 func TestSin(t *testing.T) {
 	f := &functest.RFuncChecker{
 		F:     Sin{},
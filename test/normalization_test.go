@@ -0,0 +1,142 @@
+package autofunc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/serializer"
+)
+
+type normBatchTest struct {
+	B RBatcher
+	N int
+}
+
+func (n normBatchTest) Apply(in Result) Result {
+	return n.B.Batch(in, n.N)
+}
+
+func (n normBatchTest) ApplyR(v RVector, in RResult) RResult {
+	return n.B.BatchR(v, in, n.N)
+}
+
+func TestLayerNormOutput(t *testing.T) {
+	l := NewLayerNorm(4)
+	l.Gains.Vector[1] = 2
+	l.Biases.Vector[2] = -1
+	out := l.Batch(&Variable{Vector: []float64{1, 2, 3, 6, -1, -1, 1, 1}}, 2).Output()
+	std1 := math.Sqrt(3.5 + 1e-5)
+	std2 := math.Sqrt(1 + 1e-5)
+	expected := []float64{-2 / std1, -2 / std1, -1, 3 / std1,
+		-1 / std2, -2 / std2, 1/std2 - 1, 1 / std2}
+	if out.Copy().Scale(-1).Add(expected).MaxAbs() > 1e-8 {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+}
+
+func TestLayerNormChecks(t *testing.T) {
+	in := &Variable{Vector: []float64{1, -0.5, 0.25, 0.3, 2, -1}}
+	for _, n := range []int{1, 2} {
+		l := NewLayerNorm(len(in.Vector) / n)
+		vars := append([]*Variable{in}, l.Parameters()...)
+		rv := RVector{}
+		for _, v := range vars {
+			rv[v] = make(linalg.Vector, len(v.Vector))
+			for i := range rv[v] {
+				rv[v][i] = rand.NormFloat64()
+			}
+		}
+		checker := &functest.RFuncChecker{
+			F:     ComposedRFunc{normBatchTest{B: l, N: n}, Sigmoid{}},
+			Vars:  vars,
+			Input: in,
+			RV:    rv,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestBatchNormOutput(t *testing.T) {
+	b := NewBatchNorm(2)
+	b.Momentum = 0.5
+	in := &Variable{Vector: []float64{1, 2, 3, -2, 5, 3}}
+	out := b.Batch(in, 3).Output()
+	std1 := math.Sqrt(8.0/3 + 1e-5)
+	std2 := math.Sqrt(14.0/3 + 1e-5)
+	expected := []float64{-2 / std1, 1 / std2, 0, -3 / std2, 2 / std1, 2 / std2}
+	if out.Copy().Scale(-1).Add(expected).MaxAbs() > 1e-8 {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+	expectedMean := linalg.Vector{1.5, 0.5}
+	expectedVar := linalg.Vector{0.5 + 4.0/3, 0.5 + 7.0/3}
+	if b.RunningMean.Copy().Scale(-1).Add(expectedMean).MaxAbs() > 1e-8 ||
+		b.RunningVariance.Copy().Scale(-1).Add(expectedVar).MaxAbs() > 1e-8 {
+		t.Errorf("unexpected running statistics %v %v", b.RunningMean, b.RunningVariance)
+	}
+
+	b.Training = false
+	out = b.Apply(&Variable{Vector: []float64{2.5, 0.5}}).Output()
+	expected = []float64{1 / math.Sqrt(expectedVar[0]+1e-5), 0}
+	if out.Copy().Scale(-1).Add(expected).MaxAbs() > 1e-8 {
+		t.Errorf("expected inference output %v but got %v", expected, out)
+	}
+}
+
+func TestBatchNormChecks(t *testing.T) {
+	in := &Variable{Vector: []float64{1, -0.5, 0.25, 0.3, 2, -1}}
+	for _, training := range []bool{false, true} {
+		b := NewBatchNorm(2)
+		b.RunningMean = []float64{0.5, -1}
+		b.RunningVariance = []float64{2, 0.5}
+		b.Training = training
+		vars := append([]*Variable{in}, b.Parameters()...)
+		rv := RVector{}
+		for _, v := range vars {
+			rv[v] = make(linalg.Vector, len(v.Vector))
+			for i := range rv[v] {
+				rv[v][i] = rand.NormFloat64()
+			}
+		}
+		checker := &functest.RFuncChecker{
+			F:     ComposedRFunc{normBatchTest{B: b, N: 3}, Sigmoid{}},
+			Vars:  vars,
+			Input: in,
+			RV:    rv,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestBatchNormSerialize(t *testing.T) {
+	b := NewBatchNorm(3)
+	b.Biases = nil
+	b.Gains.Vector[1] = 2
+	b.RunningMean[2] = -1
+	b.Momentum = 0.75
+	b.Training = false
+	data, err := serializer.SerializeWithType(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := serializer.DeserializeWithType(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, ok := obj.(*BatchNorm)
+	if !ok {
+		t.Fatalf("unexpected type %T", obj)
+	}
+	if b1.Biases != nil || b1.Training || b1.Momentum != 0.75 || b1.Epsilon != 0 {
+		t.Errorf("unexpected settings: %+v", b1)
+	}
+	for i, pair := range [][2]linalg.Vector{{b.Gains.Vector, b1.Gains.Vector},
+		{b.RunningMean, b1.RunningMean}, {b.RunningVariance, b1.RunningVariance}} {
+		if len(pair[0]) != len(pair[1]) || pair[0].Copy().Scale(-1).Add(pair[1]).MaxAbs() != 0 {
+			t.Errorf("vector %d: expected %v got %v", i, pair[0], pair[1])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package autofunc
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type parallelTest struct {
+	LinTran *LinTran
+	Inputs  linalg.Vector
+	RV      RVector
+}
+
+func newParallelTest() *parallelTest {
+	rand.Seed(1337)
+	res := &parallelTest{
+		LinTran: &LinTran{Data: &Variable{Vector: make(linalg.Vector, 6)}, Rows: 2, Cols: 3},
+		Inputs:  make(linalg.Vector, 3*7),
+		RV:      RVector{},
+	}
+	res.RV[res.LinTran.Data] = make(linalg.Vector, 6)
+	for _, vec := range []linalg.Vector{res.LinTran.Data.Vector, res.Inputs,
+		res.RV[res.LinTran.Data]} {
+		for i := range vec {
+			vec[i] = rand.NormFloat64()
+		}
+	}
+	return res
+}
+
+func (p *parallelTest) Cost(start, end int) Result {
+	in := &Variable{Vector: p.Inputs[start*3 : end*3]}
+	return SquaredNorm{}.Apply(Sigmoid{}.Apply(p.LinTran.Batch(in, end-start)))
+}
+
+func (p *parallelTest) CostR(start, end int) RResult {
+	in := NewRVariable(&Variable{Vector: p.Inputs[start*3 : end*3]}, p.RV)
+	return SquaredNorm{}.ApplyR(p.RV, Sigmoid{}.ApplyR(p.RV,
+		p.LinTran.BatchR(p.RV, in, end-start)))
+}
+
+func TestParallelGradient(t *testing.T) {
+	p := newParallelTest()
+	vars := []*Variable{p.LinTran.Data}
+	expected, expectedCost := (&ParallelGradient{Vars: vars, Workers: 1}).Gradient(7, p.Cost)
+	for _, workers := range []int{-1, 0, 2, 3, 10} {
+		pg := &ParallelGradient{Vars: vars, Workers: workers}
+		actual, cost := pg.Gradient(7, p.Cost)
+		if math.Abs(cost-expectedCost) > 1e-10 {
+			t.Errorf("workers %d: expected cost %f got %f", workers, expectedCost, cost)
+		}
+		if diff := gradientDiff(expected, actual); diff > 1e-10 {
+			t.Errorf("workers %d: gradient off by %e", workers, diff)
+		}
+		again, _ := pg.Gradient(7, p.Cost)
+		if diff := gradientDiff(actual, again); diff != 0 {
+			t.Errorf("workers %d: gradient not deterministic", workers)
+		}
+	}
+	if grad, cost := (&ParallelGradient{Vars: vars}).Gradient(0, p.Cost); cost != 0 ||
+		len(grad) != 1 || grad[vars[0]].MaxAbs() != 0 {
+		t.Error("unexpected result for empty batch")
+	}
+}
+
+func TestParallelRGradient(t *testing.T) {
+	p := newParallelTest()
+	vars := []*Variable{p.LinTran.Data}
+	pg := &ParallelGradient{Vars: vars, Workers: 1}
+	expected, expectedR, expectedCost := pg.RGradient(p.RV, 7, p.CostR)
+	plain, _ := pg.Gradient(7, p.Cost)
+	if diff := gradientDiff(expected, plain); diff > 1e-10 {
+		t.Errorf("gradient differs from Gradient by %e", diff)
+	}
+	pg.Workers = 3
+	actual, actualR, cost := pg.RGradient(p.RV, 7, p.CostR)
+	if math.Abs(cost-expectedCost) > 1e-10 {
+		t.Errorf("expected cost %f got %f", expectedCost, cost)
+	}
+	if diff := gradientDiff(expected, actual); diff > 1e-10 {
+		t.Errorf("gradient off by %e", diff)
+	}
+	if diff := gradientDiff(Gradient(expectedR), Gradient(actualR)); diff > 1e-10 {
+		t.Errorf("r-gradient off by %e", diff)
+	}
+}
+
+func gradientDiff(g1, g2 Gradient) float64 {
+	var res float64
+	for k, v := range g1 {
+		res = math.Max(res, v.Copy().Scale(-1).Add(g2[k]).MaxAbs())
+	}
+	return res
+}
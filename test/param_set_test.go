@@ -0,0 +1,162 @@
+package autofunc
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+func TestParamSetPacking(t *testing.T) {
+	v1 := &Variable{Vector: []float64{1, 2}}
+	v2 := &Variable{Vector: []float64{3}}
+	v3 := &Variable{Vector: []float64{4, 5, 6}}
+	p := NewParamSet([]*Variable{v1, v2, v3})
+	if p.Size() != 6 {
+		t.Fatalf("expected size 6 but got %d", p.Size())
+	}
+	if p.Index(v3) != 2 || p.Index(&Variable{}) != -1 {
+		t.Error("unexpected indices")
+	}
+	packed := p.Pack()
+	if (linalg.Vector{1, 2, 3, 4, 5, 6}).Scale(-1).Add(packed).MaxAbs() != 0 {
+		t.Fatalf("unexpected packed vector %v", packed)
+	}
+	packed.Scale(2)
+	p.Unpack(packed)
+	if v1.Vector[1] != 4 || v2.Vector[0] != 6 || v3.Vector[2] != 12 {
+		t.Error("unpack did not update the variables")
+	}
+}
+
+func TestParamVectorViews(t *testing.T) {
+	v1 := &Variable{Vector: []float64{1, 2}}
+	v2 := &Variable{Vector: []float64{3, 4, 5}}
+	vec := NewParamSet([]*Variable{v1, v2}).NewVector()
+	grad := vec.Gradient()
+	grad[v2][1] = 3
+	vec.View(v1)[0] = 2
+	if (linalg.Vector{2, 0, 0, 3, 0}).Scale(-1).Add(vec.Buffer).MaxAbs() != 0 {
+		t.Errorf("unexpected buffer %v", vec.Buffer)
+	}
+	if vec.View(&Variable{}) != nil {
+		t.Error("expected nil view for missing variable")
+	}
+
+	// Appending to a view must not overwrite the next one.
+	_ = append(vec.View(v1), 7)
+	if vec.Buffer[2] != 0 {
+		t.Error("view capacity extends into the next variable")
+	}
+
+	vec.AddToVars(-1)
+	if v1.Vector[0] != -1 || v2.Vector[1] != 1 {
+		t.Errorf("unexpected variables %v %v", v1.Vector, v2.Vector)
+	}
+}
+
+func TestParamVectorBridge(t *testing.T) {
+	rand.Seed(1337)
+	lt := &LinTran{Data: &Variable{Vector: make(linalg.Vector, 6)}, Rows: 2, Cols: 3}
+	in := &Variable{Vector: make(linalg.Vector, 3)}
+	for _, vec := range []linalg.Vector{lt.Data.Vector, in.Vector} {
+		for i := range vec {
+			vec[i] = rand.NormFloat64()
+		}
+	}
+	vars := []*Variable{lt.Data, in}
+	rv := RVector{lt.Data: []float64{1, -1, 0.5, 2, 0.3, -0.2}, in: []float64{1, 2, 3}}
+	upstream := linalg.Vector{1, -2}
+	upstreamR := linalg.Vector{0.5, 0.25}
+
+	expected := NewGradient(vars)
+	expectedR := NewRGradient(vars)
+	out := Sigmoid{}.ApplyR(rv, lt.ApplyR(rv, NewRVariable(in, rv)))
+	out.PropagateRGradient(upstream.Copy(), upstreamR.Copy(), expectedR, expected)
+
+	params := NewParamSet(vars)
+	grad := params.NewVector()
+	rgrad := params.NewVector()
+	denseRV := params.VectorFromMap(rv)
+	out = Sigmoid{}.ApplyR(denseRV.RVector(), lt.ApplyR(denseRV.RVector(),
+		NewRVariable(in, denseRV.RVector())))
+	out.PropagateRGradient(upstream.Copy(), upstreamR.Copy(), rgrad.RGradient(),
+		grad.Gradient())
+
+	for _, pair := range []struct {
+		Dense    *ParamVector
+		Expected map[*Variable]linalg.Vector
+	}{{grad, expected}, {rgrad, expectedR}} {
+		diff := params.VectorFromMap(pair.Expected).Buffer.Scale(-1).Add(pair.Dense.Buffer)
+		if diff.MaxAbs() > 1e-10 {
+			t.Errorf("expected %v got %v", pair.Expected, pair.Dense.Buffer)
+		}
+	}
+
+	grad.Scale(2)
+	if d := grad.Dot(grad) - 4*params.VectorFromMap(expected).Dot(
+		params.VectorFromMap(expected)); d > 1e-10 || d < -1e-10 {
+		t.Errorf("unexpected dot product difference %e", d)
+	}
+	grad.Zero()
+	if grad.Buffer.MaxAbs() != 0 || grad.Gradient()[in].MaxAbs() != 0 {
+		t.Error("Zero did not clear the buffer")
+	}
+}
+
+func TestParamVar(t *testing.T) {
+	rand.Seed(1337)
+	mat := &Variable{Vector: make(linalg.Vector, 6)}
+	in := &Variable{Vector: make(linalg.Vector, 3)}
+	for _, vec := range []linalg.Vector{mat.Vector, in.Vector} {
+		for i := range vec {
+			vec[i] = rand.NormFloat64()
+		}
+	}
+	vars := []*Variable{mat, in}
+	rv := RVector{mat: []float64{1, -1, 0.5, 2, 0.3, -0.2}, in: []float64{1, 2, 3}}
+	upstream := linalg.Vector{1, -2}
+	upstreamR := linalg.Vector{0.5, 0.25}
+	shape := MatMulShape{ARows: 2, ACols: 3, BRows: 3, BCols: 1}
+
+	expected := NewGradient(vars)
+	expectedR := NewRGradient(vars)
+	out := Sigmoid{}.ApplyR(rv, MatMulR(NewRVariable(mat, rv), NewRVariable(in, rv), shape))
+	out.PropagateRGradient(upstream.Copy(), upstreamR.Copy(), expectedR, expected)
+
+	params := NewParamSet(vars)
+	denseRV := params.VectorFromMap(rv)
+	grad := params.NewVector()
+	rgrad := params.NewVector()
+	out = Sigmoid{}.ApplyR(nil, MatMulR(rgrad.RVar(mat, denseRV, grad),
+		rgrad.RVar(in, denseRV, grad), shape))
+	if out.Constant(RGradient{}, Gradient{}) {
+		t.Error("ParamRVars should not be constant")
+	}
+	out.PropagateRGradient(upstream.Copy(), upstreamR.Copy(), RGradient{}, Gradient{})
+	for _, pair := range []struct {
+		Dense    *ParamVector
+		Expected map[*Variable]linalg.Vector
+	}{{grad, expected}, {rgrad, expectedR}} {
+		diff := params.VectorFromMap(pair.Expected).Buffer.Scale(-1).Add(pair.Dense.Buffer)
+		if diff.MaxAbs() > 1e-10 {
+			t.Errorf("expected %v got %v", pair.Expected, pair.Dense.Buffer)
+		}
+	}
+
+	grad.Zero()
+	res := Sigmoid{}.Apply(MatMul(grad.Var(mat), grad.Var(in), shape))
+	res.PropagateGradient(upstream.Copy(), Gradient{})
+	diff := params.VectorFromMap(expected).Buffer.Scale(-1).Add(grad.Buffer)
+	if diff.MaxAbs() > 1e-10 {
+		t.Errorf("expected %v got %v", expected, grad.Buffer)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for missing variable")
+		}
+	}()
+	grad.Var(&Variable{})
+}
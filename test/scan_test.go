@@ -0,0 +1,194 @@
+package autofunc
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+type scanTestFunc struct {
+	initState *Variable
+
+	// Combine indicates that the states should be combined
+	// non-linearly, so that gradients arrive at every
+	// state.
+	Combine bool
+}
+
+func (s *scanTestFunc) Apply(in Result) Result {
+	ins := Split(len(in.Output())/2, in)
+	var step int
+	return Scan(s.initState, ins, func(state Result, in Result) Result {
+		step++
+		if step == 2 {
+			// This step does not depend on the state.
+			return Scale(in, 2)
+		}
+		return Mul(Inverse(state), in)
+	}, func(states []Result) Result {
+		if !s.Combine {
+			return Concat(states...)
+		}
+		return Add(Mul(states[0], states[3]), Mul(states[1], states[2]))
+	})
+}
+
+func (s *scanTestFunc) ApplyR(rv RVector, in RResult) RResult {
+	ins := SplitR(len(in.Output())/2, in)
+	var step int
+	return ScanR(NewRVariable(s.initState, rv), ins, func(state RResult, in RResult) RResult {
+		step++
+		if step == 2 {
+			return ScaleR(in, 2)
+		}
+		return MulR(InverseR(state), in)
+	}, func(states []RResult) RResult {
+		if !s.Combine {
+			return ConcatR(states...)
+		}
+		return AddR(MulR(states[0], states[3]), MulR(states[1], states[2]))
+	})
+}
+
+// scanGrowFunc uses states which grow at every step.
+type scanGrowFunc struct {
+	initState *Variable
+}
+
+func (s *scanGrowFunc) Apply(in Result) Result {
+	return Scan(s.initState, Split(3, in), func(state, in Result) Result {
+		return Concat(Scale(state, 0.5), Mul(in, in))
+	}, func(states []Result) Result {
+		return Concat(states[2], states[0])
+	})
+}
+
+func (s *scanGrowFunc) ApplyR(rv RVector, in RResult) RResult {
+	return ScanR(NewRVariable(s.initState, rv), SplitR(3, in),
+		func(state, in RResult) RResult {
+			return ConcatR(ScaleR(state, 0.5), MulR(in, in))
+		}, func(states []RResult) RResult {
+			return ConcatR(states[2], states[0])
+		})
+}
+
+// scanCountResult counts calls to PropagateGradient.
+type scanCountResult struct {
+	Result
+	Count *int
+}
+
+func (s *scanCountResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	*s.Count++
+	s.Result.PropagateGradient(u, g)
+}
+
+func TestScanOut(t *testing.T) {
+	vars := []*Variable{
+		&Variable{Vector: []float64{1, 2, 2.5, 1.5, 3, 0.5, 0.5, 0.25}},
+		&Variable{Vector: []float64{2, 1}},
+	}
+	f := &scanTestFunc{initState: vars[1]}
+	actual := f.Apply(vars[0]).Output()
+	expected := []float64{0.5, 2, 5, 3, 0.6, 1.0 / 6, 0.5 / 0.6, 1.5}
+	if len(actual) != len(expected) ||
+		actual.Copy().Scale(-1).Add(expected).MaxAbs() > 1e-5 {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+
+	g := &scanGrowFunc{initState: vars[1]}
+	actual = g.Apply(&Variable{Vector: []float64{1, 2, 3, 4, 5, 6}}).Output()
+	expected = []float64{0.25, 0.125, 0.25, 1, 4.5, 8, 25, 36, 1, 0.5, 1, 4}
+	if len(actual) != len(expected) ||
+		actual.Copy().Scale(-1).Add(expected).MaxAbs() > 1e-5 {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestScan(t *testing.T) {
+	vars := []*Variable{
+		&Variable{Vector: []float64{1, 2, 2.5, 1.5, 3, 0.5, 0.5, 0.25}},
+		&Variable{Vector: []float64{2, 1}},
+	}
+	rv := RVector{}
+	for _, v := range vars {
+		rv[v] = make(linalg.Vector, len(v.Vector))
+		for i := range rv[v] {
+			rv[v][i] = rand.NormFloat64()
+		}
+	}
+	for _, combine := range []bool{false, true} {
+		ch := &functest.RFuncChecker{
+			F:     &scanTestFunc{initState: vars[1], Combine: combine},
+			Vars:  vars,
+			Input: vars[0],
+			RV:    rv,
+		}
+		ch.FullCheck(t)
+	}
+
+	growIn := &Variable{Vector: []float64{1, -2, 0.5, 3, 1.5, -1}}
+	vars = []*Variable{growIn, vars[1]}
+	rv[growIn] = []float64{0.5, 1, -1, 0.25, 2, -0.5}
+	ch := &functest.RFuncChecker{
+		F:     &scanGrowFunc{initState: vars[1]},
+		Vars:  vars,
+		Input: growIn,
+		RV:    rv,
+	}
+	ch.FullCheck(t)
+}
+
+func TestScanSinglePass(t *testing.T) {
+	ins := []Result{
+		&Variable{Vector: []float64{1, 2}},
+		&Variable{Vector: []float64{-0.5, 3}},
+		&Variable{Vector: []float64{2, 0.25}},
+	}
+	init := &Variable{Vector: []float64{0.5, -2}}
+	counts := make([]int, len(ins))
+	var step int
+	res := Scan(init, ins, func(state, in Result) Result {
+		res := &scanCountResult{Result: Mul(state, in), Count: &counts[step]}
+		step++
+		return res
+	}, func(states []Result) Result {
+		return SumAll(Add(Add(states[0], states[1]), Mul(states[2], states[0])))
+	})
+	res.PropagateGradient(linalg.Vector{1}, NewGradient([]*Variable{init}))
+	for i, count := range counts {
+		if count != 1 {
+			t.Errorf("step %d: expected 1 propagation but got %d", i, count)
+		}
+	}
+}
+
+func TestScanMatchesFold(t *testing.T) {
+	ins := []Result{
+		&Variable{Vector: []float64{1, 2}},
+		&Variable{Vector: []float64{-0.5, 3}},
+		&Variable{Vector: []float64{2, 0.25}},
+	}
+	init := &Variable{Vector: []float64{0.5, -2}}
+	f := func(state, in Result) Result {
+		return Add(Mul(state, in), in)
+	}
+	scanOut := Scan(init, ins, f, func(states []Result) Result {
+		return states[len(states)-1]
+	}).Output()
+	foldOut := Fold(init, ins, f).Output()
+	if scanOut.Copy().Scale(-1).Add(foldOut).MaxAbs() > 1e-8 {
+		t.Errorf("final state %v should be %v", scanOut, foldOut)
+	}
+	var numStates int
+	Scan(init, nil, f, func(states []Result) Result {
+		numStates = len(states)
+		return init
+	})
+	if numStates != 0 {
+		t.Error("empty scan should have no states")
+	}
+}
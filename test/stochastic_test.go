@@ -0,0 +1,120 @@
+package autofunc
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// seededBatchTest reseeds a stochastic layer before every
+// evaluation, so that the gradient checker sees the same
+// random mask each time.
+type seededBatchTest struct {
+	Layer RBatcher
+	Seed  func(r *rand.Rand)
+	N     int
+}
+
+func (s seededBatchTest) Apply(in Result) Result {
+	s.Seed(rand.New(rand.NewSource(1337)))
+	return s.Layer.Batch(in, s.N)
+}
+
+func (s seededBatchTest) ApplyR(v RVector, in RResult) RResult {
+	s.Seed(rand.New(rand.NewSource(1337)))
+	return s.Layer.BatchR(v, in, s.N)
+}
+
+func TestDropoutOutput(t *testing.T) {
+	d := &Dropout{KeepProb: 0.25, Rand: rand.New(rand.NewSource(1)), Training: true}
+	in := &Variable{Vector: make(linalg.Vector, 10000)}
+	for i := range in.Vector {
+		in.Vector[i] = 1
+	}
+	out := d.Apply(in).Output()
+	var kept int
+	for _, x := range out {
+		if x == 4 {
+			kept++
+		} else if x != 0 {
+			t.Fatalf("unexpected output value %f", x)
+		}
+	}
+	if kept < 2300 || kept > 2700 {
+		t.Errorf("kept %d out of %d", kept, len(out))
+	}
+
+	d.Training = false
+	if d.Apply(in) != Result(in) {
+		t.Error("inference mode should not modify the input")
+	}
+}
+
+func TestStochasticReproducible(t *testing.T) {
+	in := &Variable{Vector: []float64{1, 2, 3, 4, 5, 6}}
+	for i := 0; i < 2; i++ {
+		var outs []linalg.Vector
+		for j := 0; j < 2; j++ {
+			r := rand.New(rand.NewSource(42))
+			var layer Func
+			if i == 0 {
+				layer = &Dropout{KeepProb: 0.5, Rand: r, Training: true}
+			} else {
+				layer = &GaussianNoise{Stddev: 1, Rand: r, Training: true}
+			}
+			outs = append(outs, layer.Apply(in).Output())
+		}
+		if outs[0].Copy().Scale(-1).Add(outs[1]).MaxAbs() != 0 {
+			t.Errorf("layer %d: outputs differ for equal seeds: %v %v", i, outs[0], outs[1])
+		}
+	}
+}
+
+func TestStochasticChecks(t *testing.T) {
+	in := &Variable{Vector: []float64{1, -0.5, 0.25, 0.3, 2, -1}}
+	lt := &LinTran{Data: &Variable{Vector: []float64{1, -1, 0.5, 0.25, 2, -0.3}}, Rows: 2,
+		Cols: 3}
+	dropout := &Dropout{KeepProb: 0.6, Training: true}
+	noise := &GaussianNoise{Stddev: 0.5, Training: true}
+	dropConnect := &DropConnect{LinTran: lt, KeepProb: 0.6, Training: true}
+	tests := map[string]seededBatchTest{
+		"Dropout": {Layer: dropout, N: 2, Seed: func(r *rand.Rand) { dropout.Rand = r }},
+		"Noise":   {Layer: noise, N: 2, Seed: func(r *rand.Rand) { noise.Rand = r }},
+		"DropConnect": {Layer: dropConnect, N: 2,
+			Seed: func(r *rand.Rand) { dropConnect.Rand = r }},
+	}
+	vars := []*Variable{in, lt.Data}
+	rv := RVector{}
+	for _, v := range vars {
+		rv[v] = make(linalg.Vector, len(v.Vector))
+		for i := range rv[v] {
+			rv[v][i] = rand.NormFloat64()
+		}
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checker := &functest.RFuncChecker{
+				F:     ComposedRFunc{test, Sigmoid{}},
+				Vars:  vars,
+				Input: in,
+				RV:    rv,
+			}
+			checker.FullCheck(t)
+		})
+	}
+}
+
+func TestDropConnectInference(t *testing.T) {
+	lt := &LinTran{Data: &Variable{Vector: []float64{1, -1, 0.5, 0.25, 2, -0.3}}, Rows: 2,
+		Cols: 3}
+	d := &DropConnect{LinTran: lt, KeepProb: 0.5}
+	in := &Variable{Vector: []float64{1, 2, 3}}
+	expected := lt.Apply(in).Output()
+	actual := d.Apply(in).Output()
+	if expected.Copy().Scale(-1).Add(actual).MaxAbs() > 1e-8 {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+}
@@ -0,0 +1,163 @@
+package autofunc
+
+import (
+	"testing"
+
+	. "github.com/unixpickle/autofunc"
+	"github.com/unixpickle/autofunc/functest"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+var (
+	tapeTestVec  = &Variable{Vector: linalg.Vector{1, -0.5, 0.3, 0.7}}
+	tapeTestVec2 = &Variable{Vector: linalg.Vector{0.2, 0.4, -0.3, 1.5}}
+	tapeTestVars = []*Variable{tapeTestVec, tapeTestVec2}
+	tapeTestRVec = RVector{
+		tapeTestVec:  linalg.Vector{0.5, -1, 0.3, 2},
+		tapeTestVec2: linalg.Vector{-0.3, 0.2, 1, 0.4},
+	}
+)
+
+// tapeTestFunc computes a function with several shared
+// subexpressions using a Tape.
+type tapeTestFunc struct {
+	// Counts, if non-nil, counts back-propagation calls
+	// through the shared subexpressions.
+	Counts *int
+}
+
+func (f tapeTestFunc) Apply(in Result) Result {
+	t := &Tape{}
+	x := t.Apply(Sigmoid{}, Mul(in, tapeTestVec2))
+	x = t.Record(&countingResult{Result: x, Counts: f.Counts})
+	y := t.Record(Add(Mul(x, x), x))
+	z := t.Record(Mul(y, Sub(y, x)))
+	return t.Output(SumAll(Add(z, Mul(y, x))))
+}
+
+func (f tapeTestFunc) ApplyR(v RVector, in RResult) RResult {
+	t := &Tape{}
+	x := t.ApplyR(Sigmoid{}, v, MulR(in, NewRVariable(tapeTestVec2, v)))
+	x = t.RecordR(&countingRResult{RResult: x, Counts: f.Counts})
+	y := t.RecordR(AddR(MulR(x, x), x))
+	z := t.RecordR(MulR(y, SubR(y, x)))
+	return t.OutputR(SumAllR(AddR(z, MulR(y, x))))
+}
+
+// tapeScopeFunc is like tapeTestFunc, but it records
+// Results automatically using a Scope.
+type tapeScopeFunc struct {
+	Counts *int
+}
+
+func (f tapeScopeFunc) Apply(in Result) Result {
+	t := &Tape{}
+	scope := &Scope{Tape: t}
+	x := Sigmoid{}.Apply(Mul(scope.Result(in), tapeTestVec2))
+	x = scope.Result(&countingResult{Result: x, Counts: f.Counts})
+	y := Add(Mul(x, x), x)
+	z := Pool(Sub(y, x), func(d Result) Result {
+		return Mul(y, Add(d, d))
+	})
+	prod := MatMul(Concat(z, y), Concat(x, y), MatMulShape{ARows: 2, ACols: 4,
+		BRows: 2, BCols: 4, TransB: true})
+	return t.Output(SumAll(Add(SumAll(prod), SumAll(Mul(y, x)))))
+}
+
+func (f tapeScopeFunc) ApplyR(v RVector, in RResult) RResult {
+	t := &Tape{}
+	scope := &Scope{Tape: t}
+	x := Sigmoid{}.ApplyR(v, MulR(scope.RResult(in), NewRVariable(tapeTestVec2, v)))
+	x = scope.RResult(&countingRResult{RResult: x, Counts: f.Counts})
+	y := AddR(MulR(x, x), x)
+	z := PoolR(SubR(y, x), func(d RResult) RResult {
+		return MulR(y, AddR(d, d))
+	})
+	prod := MatMulR(ConcatR(z, y), ConcatR(x, y), MatMulShape{ARows: 2, ACols: 4,
+		BRows: 2, BCols: 4, TransB: true})
+	return t.OutputR(SumAllR(AddR(SumAllR(prod), SumAllR(MulR(y, x)))))
+}
+
+func TestTapeChecks(t *testing.T) {
+	for _, f := range []RFunc{tapeTestFunc{}, tapeScopeFunc{}} {
+		checker := &functest.RFuncChecker{
+			F:     f,
+			Vars:  tapeTestVars,
+			Input: tapeTestVec,
+			RV:    tapeTestRVec,
+		}
+		checker.FullCheck(t)
+	}
+}
+
+func TestTapeSinglePass(t *testing.T) {
+	var counts int
+	testTapeSinglePass(t, tapeTestFunc{Counts: &counts}, &counts)
+	testTapeSinglePass(t, tapeScopeFunc{Counts: &counts}, &counts)
+}
+
+func testTapeSinglePass(t *testing.T, f RFunc, counts *int) {
+	*counts = 0
+	g := NewGradient(tapeTestVars)
+	f.Apply(tapeTestVec).PropagateGradient(linalg.Vector{1}, g)
+	if *counts != 1 {
+		t.Errorf("%T: expected 1 propagation but got %d", f, *counts)
+	}
+
+	*counts = 0
+	rg := NewRGradient(tapeTestVars)
+	out := f.ApplyR(tapeTestRVec, NewRVariable(tapeTestVec, tapeTestRVec))
+	out.PropagateRGradient(linalg.Vector{1}, linalg.Vector{0}, rg, g)
+	if *counts != 1 {
+		t.Errorf("%T: expected 1 r-propagation but got %d", f, *counts)
+	}
+}
+
+func TestTapeConstant(t *testing.T) {
+	out := tapeTestFunc{}.Apply(tapeTestVec)
+	if out.Constant(NewGradient(tapeTestVars)) {
+		t.Error("output should not be constant")
+	}
+	if !out.Constant(Gradient{}) {
+		t.Error("output should be constant")
+	}
+	g := NewGradient([]*Variable{tapeTestVec})
+	constOut := tapeTestFunc{}.Apply(&Variable{Vector: tapeTestVec.Vector})
+	if !constOut.Constant(g) {
+		t.Error("output should be constant")
+	}
+	rg := NewRGradient([]*Variable{tapeTestVec})
+	constOutR := tapeTestFunc{}.ApplyR(RVector{}, NewRVariable(&Variable{
+		Vector: tapeTestVec.Vector,
+	}, RVector{}))
+	if !constOutR.Constant(rg, g) {
+		t.Error("r-output should be constant")
+	}
+	if len(g) != 1 || len(rg) != 1 {
+		t.Error("gradients were modified")
+	}
+}
+
+type countingResult struct {
+	Result
+	Counts *int
+}
+
+func (c *countingResult) PropagateGradient(u linalg.Vector, g Gradient) {
+	if c.Counts != nil {
+		*c.Counts++
+	}
+	c.Result.PropagateGradient(u, g)
+}
+
+type countingRResult struct {
+	RResult
+	Counts *int
+}
+
+func (c *countingRResult) PropagateRGradient(u, uR linalg.Vector, rg RGradient, g Gradient) {
+	if c.Counts != nil {
+		*c.Counts++
+	}
+	c.RResult.PropagateRGradient(u, uR, rg, g)
+}